@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/subtle"
 	"encoding/json"
@@ -10,14 +11,19 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/toricodesthings/file-processing-service/internal/apikey"
 	"github.com/toricodesthings/file-processing-service/internal/config"
+	"github.com/toricodesthings/file-processing-service/internal/embedded"
+	"github.com/toricodesthings/file-processing-service/internal/estimate"
 	"github.com/toricodesthings/file-processing-service/internal/extract"
 	audioextractor "github.com/toricodesthings/file-processing-service/internal/extractors/audio"
 	codeextractor "github.com/toricodesthings/file-processing-service/internal/extractors/code"
@@ -29,8 +35,20 @@ import (
 	plaintextextractor "github.com/toricodesthings/file-processing-service/internal/extractors/plaintext"
 	structuredextractor "github.com/toricodesthings/file-processing-service/internal/extractors/structured"
 	videoextractor "github.com/toricodesthings/file-processing-service/internal/extractors/video"
+	"github.com/toricodesthings/file-processing-service/internal/failover"
+	"github.com/toricodesthings/file-processing-service/internal/gateway"
 	"github.com/toricodesthings/file-processing-service/internal/hybrid"
+	"github.com/toricodesthings/file-processing-service/internal/libreoffice"
+	"github.com/toricodesthings/file-processing-service/internal/ocr"
+	"github.com/toricodesthings/file-processing-service/internal/output"
+	"github.com/toricodesthings/file-processing-service/internal/resilience"
+	"github.com/toricodesthings/file-processing-service/internal/s3"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
+	"github.com/toricodesthings/file-processing-service/internal/signing"
+	"github.com/toricodesthings/file-processing-service/internal/transcribe"
 	"github.com/toricodesthings/file-processing-service/internal/types"
+	"github.com/toricodesthings/file-processing-service/internal/usage"
 	"golang.org/x/sync/semaphore"
 	"golang.org/x/time/rate"
 )
@@ -39,10 +57,20 @@ var (
 	cfg config.Config
 
 	requestSem *semaphore.Weighted
-	ocrSem     *semaphore.Weighted
 	extractRt  *extract.Router
 	extractReg *extract.Registry
 	hybridProc *hybrid.Processor
+	prices     usage.PriceTable
+	estimator  *estimate.Estimator
+	outputW    = &output.Writer{}
+	loPool     *libreoffice.Pool // nil when conversions run cold
+
+	// API keys (nil when API_KEYS_FILE is unset) and their per-key limits.
+	apiKeys    *apikey.FileStore
+	keyTracker = apikey.NewTracker()
+
+	// Verifies HMAC-signed requests and remembers their nonces.
+	verifier *signing.Verifier
 
 	// Per-IP rate limiters
 	limiters = &sync.Map{}
@@ -74,23 +102,87 @@ func (m *serverMetrics) get() (total, active int64) {
 }
 
 func main() {
+	// Re-executed as the rlimit helper for a sandboxed tool: never returns.
+	sandbox.Init()
+
 	cfg = config.Load()
 	if err := cfg.Validate(); err != nil {
 		panic(err)
 	}
 
+	var err error
+	if prices, err = usage.LoadPrices(cfg.PriceTableFile); err != nil {
+		panic(err)
+	}
+	if cfg.APIKeysFile != "" {
+		if apiKeys, err = apikey.LoadFile(cfg.APIKeysFile); err != nil {
+			panic(err)
+		}
+		go reloadAPIKeys()
+	}
+
+	verifier = signing.NewVerifier(cfg.InternalSharedSecret, cfg.RequestSigningMaxSkew)
+
+	extract.ConfigureURLPolicy(extract.URLPolicy{
+		Schemes:      cfg.DownloadAllowedSchemes,
+		Hosts:        cfg.DownloadAllowedHosts,
+		AllowPrivate: cfg.DownloadAllowPrivateHosts,
+		MaxRedirects: cfg.DownloadMaxRedirects,
+	})
+
+	gid := cfg.SandboxGID
+	if gid == 0 {
+		gid = cfg.SandboxUID
+	}
+	if err := sandbox.Configure(sandbox.Config{
+		Limits: sandbox.Limits{
+			MemoryBytes: uint64(cfg.SandboxMaxMemoryMB) << 20,
+			CPUSeconds:  uint64(cfg.SandboxMaxCPUSeconds),
+			FileBytes:   uint64(cfg.SandboxMaxFileMB) << 20,
+			OpenFiles:   uint64(cfg.SandboxMaxOpenFiles),
+		},
+		UID:            uint32(cfg.SandboxUID),
+		GID:            uint32(gid),
+		IsolateNetwork: cfg.SandboxIsolateNetwork,
+		LogUsage:       cfg.SandboxLogUsage,
+	}); err != nil {
+		panic(err)
+	}
+
+	safezip.Configure(safezip.Limits{
+		MaxEntries:    cfg.ArchiveMaxEntries,
+		MaxEntryBytes: cfg.ArchiveMaxEntryBytes,
+		MaxTotalBytes: cfg.ArchiveMaxTotalBytes,
+		MaxRatio:      cfg.ArchiveMaxRatio,
+	})
+
+	configureSources()
+
 	requestSem = semaphore.NewWeighted(cfg.MaxConcurrentRequests)
-	ocrSem = semaphore.NewWeighted(cfg.MaxOCRConcurrent)
+	configureGateways()
+	configureFailover()
 
 	processor := hybrid.New(cfg)
 	hybridProc = processor
 	registry := extract.NewRegistry()
 	extractReg = registry
 
-	audioX := audioextractor.New(cfg.GroqAPIKey, cfg.GroqAPIURL, cfg.GroqModel, cfg.MaxAudioBytes, cfg.GroqTimeout)
+	audioX := audioextractor.New(cfg.GroqAPIKey, cfg.GroqAPIURL, cfg.GroqModel, cfg.MaxAudioBytes, cfg.GroqTimeout).
+		WithLocalWhisper(transcribe.NewLocalWhisper(cfg.WhisperBinary, cfg.WhisperModel, cfg.WhisperTimeout))
 
 	// Register extractors — order matters: more-specific first
-	registry.Register(pdfextractor.New(processor, cfg.MaxPDFBytes))
+	registry.Register(pdfextractor.New(processor, registry, pdfextractor.Limits{
+		MaxBytes:           cfg.MaxPDFBytes,
+		StructureBytes:     cfg.MaxPDFStructureBytes,
+		MaxAttachments:     cfg.MaxPDFAttachments,
+		MaxAttachmentBytes: cfg.MaxPDFAttachmentBytes,
+	}, pdfextractor.FigureConfig{
+		OCRModel:      cfg.DefaultOCRModel,
+		VisionModel:   cfg.DefaultVisionModel,
+		VisionTimeout: cfg.VisionRequestTimeout,
+		MaxFigures:    cfg.MaxPDFFigures,
+		MinPixels:     cfg.MinPDFFigurePixels,
+	}))
 	registry.Register(imageextractor.New(cfg.DefaultOCRModel, cfg.DefaultVisionModel, cfg.VisionRequestTimeout, cfg.MaxImageBytes))
 	registry.Register(plaintextextractor.New(cfg.MaxCodeFileBytes))
 	registry.Register(plaintextextractor.NewHTML(cfg.MaxCodeFileBytes))
@@ -102,16 +194,40 @@ func main() {
 	registry.Register(codeextractor.NewSource(cfg.MaxCodeFileBytes))
 	registry.Register(codeextractor.NewNotebook(cfg.MaxCodeFileBytes))
 	registry.Register(codeextractor.NewLaTeX(cfg.MaxCodeFileBytes))
-	registry.Register(officeextractor.NewDOCX(cfg.MaxFileBytes))
+	embeddedImages := embedded.Config{
+		OCRModel:      cfg.DefaultOCRModel,
+		VisionModel:   cfg.DefaultVisionModel,
+		VisionTimeout: cfg.VisionRequestTimeout,
+		MaxImages:     cfg.MaxEmbeddedImages,
+		MinPixels:     cfg.MinEmbeddedImagePixels,
+		BudgetBytes:   cfg.EmbeddedImageBudgetBytes,
+	}
+	registry.Register(officeextractor.NewDOCX(cfg.MaxFileBytes).WithEmbeddedImages(embeddedImages))
 	registry.Register(officeextractor.NewXLSX(cfg.MaxFileBytes))
-	registry.Register(officeextractor.NewPPTX(cfg.MaxFileBytes))
-	registry.Register(officeextractor.NewLegacy(cfg.LibreOfficeBinary, cfg.LibreOfficeTimeout, cfg.MaxFileBytes))
-	registry.Register(opendocumentextractor.New(cfg.MaxFileBytes))
-	registry.Register(ebookextractor.NewEPUB(cfg.MaxFileBytes))
+	registry.Register(officeextractor.NewPPTX(cfg.MaxFileBytes).WithEmbeddedImages(embeddedImages))
+	registry.Register(officeextractor.NewLegacy(libreOfficeConverter(), cfg.LibreOfficeTimeout, cfg.MaxFileBytes).WithEmbeddedImages(embeddedImages))
+	registry.Register(opendocumentextractor.New(cfg.MaxFileBytes).WithEmbeddedImages(embeddedImages))
+	registry.Register(ebookextractor.NewEPUB(cfg.MaxFileBytes).WithEmbeddedImages(embeddedImages))
 	registry.Register(audioX)
 	registry.Register(videoextractor.New(cfg.FFmpegBinary, cfg.FFmpegTimeout, audioX, cfg.MaxVideoBytes))
 
 	extractRt = extract.NewRouter(registry, cfg.MaxFileBytes, cfg.DownloadTimeout)
+	estimator = estimate.New(registry, processor, prices, estimate.Config{
+		MaxFileBytes:     cfg.MaxFileBytes,
+		MaxDownloadBytes: cfg.EstimateMaxDownloadBytes,
+		DownloadTimeout:  cfg.DownloadTimeout,
+		SamplePages:      cfg.EstimateSamplePages,
+		FFprobeBinary:    cfg.FFprobeBinary,
+		FFprobeTimeout:   cfg.FFprobeTimeout,
+		OCRModel:         cfg.DefaultOCRModel,
+		VisionModel:      cfg.DefaultVisionModel,
+		GroqModel:        cfg.GroqModel,
+		WhisperModel:     cfg.WhisperModel,
+		MaxFigures:       cfg.MaxPDFFigures,
+		MinFigurePixels:  cfg.MinPDFFigurePixels,
+
+		MaxEmbeddedImages: cfg.MaxEmbeddedImages,
+	})
 
 	mux := http.NewServeMux()
 
@@ -136,6 +252,15 @@ func main() {
 						handlePreview(w, r)
 					})))))
 
+	// Cost estimate — inspects the file locally, never calls a paid provider
+	mux.HandleFunc("/estimate",
+		withInternalAuth(
+			withRateLimit(
+				withMethod("POST",
+					withConcurrencyLimit(func(w http.ResponseWriter, r *http.Request) {
+						handleEstimate(w, r)
+					})))))
+
 	maxHeaderBytes := 1 << 20
 	if cfg.MaxHeaderBytes > 0 {
 		maxHeaderBytes = cfg.MaxHeaderBytes
@@ -166,9 +291,48 @@ func main() {
 	fmt.Printf("fileproc listening on %s (max concurrent: %d, OCR: %d)\n",
 		srv.Addr, cfg.MaxConcurrentRequests, cfg.MaxOCRConcurrent)
 
+	stopped := make(chan struct{})
+	go shutdownOnSignal(srv, stopped)
 	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		panic(err)
 	}
+	<-stopped
+}
+
+// shutdownOnSignal drains in-flight requests on SIGINT or SIGTERM, then
+// stops the LibreOffice listeners so none outlive the service.
+func shutdownOnSignal(srv *http.Server, done chan<- struct{}) {
+	defer close(done)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	fmt.Println("fileproc shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		fmt.Fprintf(os.Stderr, "shutdown: %v\n", err)
+	}
+	if loPool != nil {
+		loPool.Close()
+	}
+}
+
+// reloadAPIKeys picks up key file edits so keys can be added or revoked
+// without a restart.
+func reloadAPIKeys() {
+	interval := cfg.APIKeysReloadInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := apiKeys.Reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "[apikey] reload failed, keeping previous keys: %v\n", err)
+		}
+	}
 }
 
 func cleanupRateLimiters() {
@@ -188,11 +352,102 @@ func cleanupRateLimiters() {
 			active, total, runtime.NumGoroutine(), m.Alloc/(1<<20))
 
 		limiters = &sync.Map{}
+		verifier.Sweep()
 	}
 }
 
 // ---------- Handlers ----------
 
+// configureSources registers the optional input sources (data:, file://,
+// s3://) alongside the built-in http(s) one.
+func configureSources() {
+	extract.RegisterSource(extract.DataSource{MaxBytes: cfg.DataURIMaxBytes})
+	outputW.Timeout = cfg.OutputTimeout
+	if cfg.FileSourceRoot != "" {
+		extract.RegisterSource(extract.FileSource{Root: cfg.FileSourceRoot})
+	}
+	if cfg.S3Endpoint != "" {
+		client, err := s3.New(s3.Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			SessionToken:    cfg.S3SessionToken,
+			PathStyle:       cfg.S3ForcePathStyle,
+		})
+		if err != nil {
+			panic(err)
+		}
+		extract.RegisterSource(extract.S3Source{Client: client, Buckets: cfg.S3AllowedBuckets, PresignTTL: cfg.S3PresignTTL})
+		outputW.S3, outputW.Buckets = client, cfg.OutputS3Buckets
+	}
+}
+
+// libreOfficeConverter returns the warm listener pool, or a cold soffice per
+// conversion when the pool is disabled or cannot start.
+func libreOfficeConverter() libreoffice.Converter {
+	cold := libreoffice.Cold{Binary: cfg.LibreOfficeBinary}
+	if cfg.LibreOfficePoolSize <= 0 {
+		return cold
+	}
+	pool, err := libreoffice.NewPool(libreoffice.PoolConfig{
+		Binary:         cfg.LibreOfficeBinary,
+		Python:         cfg.LibreOfficePython,
+		Size:           cfg.LibreOfficePoolSize,
+		MaxConversions: cfg.LibreOfficeMaxConversions,
+		StartTimeout:   cfg.LibreOfficeStartTimeout,
+		HealthInterval: cfg.LibreOfficeHealthInterval,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[libreoffice] pool disabled, converting cold: %v\n", err)
+		return cold
+	}
+	loPool = pool
+	return pool
+}
+
+// configureGateways installs the per-provider limits that every outbound
+// Mistral, OpenRouter and Groq call queues behind.
+func configureGateways() {
+	gateway.Configure(gateway.Mistral, gateway.Limits{
+		Concurrency: cfg.MaxOCRConcurrent,
+		RatePerSec:  cfg.MistralRatePerSec,
+		Burst:       cfg.MistralRateBurst,
+		MaxQueue:    cfg.ProviderMaxQueue,
+	})
+	gateway.Configure(gateway.OpenRouter, gateway.Limits{
+		Concurrency: cfg.OpenRouterMaxConcurrent,
+		RatePerSec:  cfg.OpenRouterRatePerSec,
+		Burst:       cfg.OpenRouterRateBurst,
+		MaxQueue:    cfg.ProviderMaxQueue,
+	})
+	gateway.Configure(gateway.Groq, gateway.Limits{
+		Concurrency: cfg.GroqMaxConcurrent,
+		RatePerSec:  cfg.GroqRatePerSec,
+		Burst:       cfg.GroqRateBurst,
+		MaxQueue:    cfg.ProviderMaxQueue,
+	})
+
+	breaker := resilience.BreakerConfig{
+		FailureThreshold: cfg.ProviderBreakerThreshold,
+		Cooldown:         cfg.ProviderBreakerCooldown,
+	}
+	for _, name := range []string{gateway.Mistral, gateway.OpenRouter, gateway.Groq} {
+		resilience.Configure(name, breaker)
+	}
+}
+
+func configureFailover() {
+	failover.Configure(failover.OCR, cfg.OCRProviders)
+	failover.Configure(failover.Vision, cfg.VisionModels)
+	failover.Configure(failover.Transcription, cfg.TranscriptionProviders)
+	ocr.ConfigureTesseract(ocr.Tesseract{
+		Binary:  cfg.TesseractBinary,
+		Lang:    cfg.TesseractLang,
+		Timeout: cfg.TesseractTimeout,
+	})
+}
+
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	_, active := metrics.get()
 	status := "healthy"
@@ -209,12 +464,24 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, code, map[string]any{
-		"status":  status,
-		"active":  active,
-		"version": "2.0.0",
+		"status":    status,
+		"active":    active,
+		"version":   "2.0.0",
+		"providers": providerHealth(),
 	})
 }
 
+// providerHealth reports each provider's breaker state for /health. An open
+// breaker does not degrade the service as a whole: formats that need no
+// provider keep working.
+func providerHealth() map[string]string {
+	out := map[string]string{}
+	for name, st := range resilience.Snapshot() {
+		out[name] = st.State
+	}
+	return out
+}
+
 func handleMetrics(w http.ResponseWriter, r *http.Request) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
@@ -226,9 +493,23 @@ func handleMetrics(w http.ResponseWriter, r *http.Request) {
 		"goroutines":     runtime.NumGoroutine(),
 		"memAllocMB":     m.Alloc / (1 << 20),
 		"memSysMB":       m.Sys / (1 << 20),
+		"providers":      gateway.Snapshot(),
+		"breakers":       resilience.Snapshot(),
+		"failover":       failover.Snapshot(),
+		"clients":        usage.ClientSnapshot(),
+		"apiKeys":        keyTracker.Snapshot(),
+		"sandbox":        sandbox.Snapshot(),
+		"libreoffice":    libreOfficeStats(),
 	})
 }
 
+func libreOfficeStats() any {
+	if loPool == nil {
+		return nil
+	}
+	return loPool.Snapshot()
+}
+
 func handleUniversalExtract(w http.ResponseWriter, r *http.Request) {
 	req, err := parseJSON[extract.UniversalExtractRequest](r, cfg.MaxJSONBodyBytes)
 	if err != nil {
@@ -236,20 +517,91 @@ func handleUniversalExtract(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if strings.TrimSpace(req.PresignedURL) == "" {
-		writeErr(w, http.StatusBadRequest, "validation_failed", "presignedUrl required")
+	if req.InputURL() == "" {
+		writeErr(w, http.StatusBadRequest, "validation_failed", "presignedUrl or source required")
 		return
 	}
 
+	outSpec, err := output.ParseSpec(req.Options)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "validation_failed", sanitizeError(err))
+		return
+	}
+	if outSpec != nil {
+		if err := outputW.Check(r.Context(), outSpec); err != nil {
+			msg := sanitizeError(err)
+			writeJSON(w, http.StatusBadRequest, extract.Result{Success: false, Error: &msg, Code: extract.ErrorCode(err)})
+			return
+		}
+	}
+
+	key, hasKey := apikey.FromContext(r.Context())
+	if hasKey {
+		if err := keyTracker.CheckQuota(key); err != nil {
+			writeErr(w, http.StatusTooManyRequests, "quota_exceeded", err.Error())
+			return
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), cfg.UniversalExtractTimeout)
 	defer cancel()
+	ctx, rec := usage.WithRecorder(ctx)
 
 	res, err := extractRt.Extract(ctx, req)
+	// Failed extractions can still have consumed paid calls, so usage is
+	// reported either way.
+	res.Usage = rec.Summary(prices)
+	usage.AddClient(clientID(r), res.Usage)
+	if hasKey {
+		keyTracker.Add(key, res.Usage)
+	}
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, res)
 		return
 	}
 
+	if outSpec != nil {
+		objects, err := outputW.Write(ctx, outSpec, res, req.FileName)
+		if err != nil {
+			msg := sanitizeError(err)
+			// The extraction is paid for, so the result stays inline.
+			res.Success, res.Error, res.Code = false, &msg, extract.ErrorCode(err)
+			status := http.StatusBadRequest
+			if res.Code == extract.CodeOutputFailed {
+				status = http.StatusBadGateway
+			}
+			writeJSON(w, status, res)
+			return
+		}
+		res.Output = objects
+		output.Strip(&res)
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func handleEstimate(w http.ResponseWriter, r *http.Request) {
+	req, err := parseJSON[extract.UniversalExtractRequest](r, cfg.MaxJSONBodyBytes)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "bad_request", sanitizeError(err))
+		return
+	}
+
+	if req.InputURL() == "" {
+		writeErr(w, http.StatusBadRequest, "validation_failed", "presignedUrl or source required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.UniversalExtractTimeout)
+	defer cancel()
+
+	res, err := estimator.Estimate(ctx, req)
+	if err != nil {
+		msg := sanitizeError(err)
+		res.Error = &msg
+		writeJSON(w, http.StatusBadRequest, res)
+		return
+	}
 	writeJSON(w, http.StatusOK, res)
 }
 
@@ -260,8 +612,8 @@ func handlePreview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if strings.TrimSpace(req.PresignedURL) == "" {
-		writeErr(w, http.StatusBadRequest, "validation_failed", "presignedUrl required")
+	if req.InputURL() == "" {
+		writeErr(w, http.StatusBadRequest, "validation_failed", "presignedUrl or source required")
 		return
 	}
 
@@ -273,9 +625,10 @@ func handlePreview(w http.ResponseWriter, r *http.Request) {
 		fileName = "input.bin"
 	}
 
-	dl, err := extract.DownloadToTemp(ctx, req.PresignedURL, fileName, cfg.MaxFileBytes, cfg.DownloadTimeout)
+	dl, err := extract.DownloadToTemp(ctx, req.InputURL(), fileName, cfg.MaxFileBytes, cfg.DownloadTimeout)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "error": sanitizeError(err)})
+		msg := sanitizeError(err)
+		writeJSON(w, http.StatusBadRequest, extract.Result{Success: false, Error: &msg, Code: extract.ErrorCode(err)})
 		return
 	}
 	defer dl.Cleanup()
@@ -288,6 +641,12 @@ func handlePreview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := extract.CheckExtractorAllowed(r.Context(), extractor.Name()); err != nil {
+		msg := sanitizeError(err)
+		writeJSON(w, http.StatusBadRequest, extract.Result{Success: false, MIMEType: dl.MIMEType, FileType: extractor.Name(), Error: &msg, Code: extract.ErrorCode(err)})
+		return
+	}
+
 	if !isPreviewAllowed(extractor.Name()) {
 		msg := "preview unsupported for this file type"
 		writeJSON(w, http.StatusBadRequest, extract.Result{Success: false, MIMEType: dl.MIMEType, FileType: extractor.Name(), Error: &msg})
@@ -302,10 +661,11 @@ func handlePreview(w http.ResponseWriter, r *http.Request) {
 			opts.PreviewMaxPages = intOption(req.Options, "previewMaxPages", opts.PreviewMaxPages)
 			opts.PreviewMaxChars = intOption(req.Options, "previewMaxChars", opts.PreviewMaxChars)
 			opts.MinWordsThreshold = intOption(req.Options, "minWordsThreshold", opts.MinWordsThreshold)
+			opts.Password, _ = req.Options["password"].(string)
 		}
 		prev := hybridProc.ProcessPreview(ctx, dl.Path, opts)
 		if prev.Error != nil {
-			writeJSON(w, http.StatusBadRequest, extract.Result{Success: false, Method: "preview-text-layer", FileType: "document/pdf", MIMEType: dl.MIMEType, Error: prev.Error})
+			writeJSON(w, http.StatusBadRequest, extract.Result{Success: false, Method: "preview-text-layer", FileType: "document/pdf", MIMEType: dl.MIMEType, Error: prev.Error, Code: prev.Code})
 			return
 		}
 		text := prev.Text
@@ -332,12 +692,12 @@ func handlePreview(w http.ResponseWriter, r *http.Request) {
 	}
 
 	job := extract.Job{
-		PresignedURL: req.PresignedURL,
+		PresignedURL: dl.RemoteURL,
 		LocalPath:    dl.Path,
 		FileName:     fileName,
 		MIMEType:     dl.MIMEType,
 		FileSize:     dl.Size,
-		Options:      req.Options,
+		Options:      previewOptions(req.Options),
 	}
 
 	res, err := extractor.Extract(ctx, job)
@@ -346,6 +706,9 @@ func handlePreview(w http.ResponseWriter, r *http.Request) {
 			msg := sanitizeError(err)
 			res.Error = &msg
 		}
+		if res.Code == "" {
+			res.Code = extract.ErrorCode(err)
+		}
 		res.Success = false
 		if res.MIMEType == "" {
 			res.MIMEType = dl.MIMEType
@@ -378,11 +741,49 @@ func withMethod(method string, next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// withInternalAuth accepts an API key (X-API-Key or Authorization: Bearer)
+// when API_KEYS_FILE is set, an HMAC-signed request, or the legacy shared
+// secret in X-Internal-Auth (unless REQUEST_SIGNING=required). A presented
+// API key or signature takes precedence, so an invalid one is rejected even
+// alongside a valid secret.
 func withInternalAuth(next http.HandlerFunc) http.HandlerFunc {
 	shared := cfg.InternalSharedSecret
 	return func(w http.ResponseWriter, r *http.Request) {
+		if raw := presentedAPIKey(r); raw != "" && apiKeys != nil {
+			key, err := apikey.Authenticate(apiKeys, raw)
+			if err != nil {
+				writeErr(w, http.StatusUnauthorized, "unauthorized", "Invalid authentication")
+				return
+			}
+			if ww, ok := w.(*wrapWriter); ok {
+				ww.keyID = key.ID
+			}
+			ctx := apikey.WithKey(r.Context(), key)
+			ctx = extract.WithExtractorFilter(ctx, key.Allows)
+			next(w, r.WithContext(ctx))
+			return
+		}
+
+		if shared != "" && cfg.RequestSigning != "off" && signing.Present(r.Header) {
+			body, err := bufferBody(r, cfg.MaxJSONBodyBytes)
+			if err != nil {
+				writeErr(w, http.StatusRequestEntityTooLarge, "request_too_large", "Request body too large")
+				return
+			}
+			if err := verifier.Verify(r.Method, r.URL.RequestURI(), r.Header, body); err != nil {
+				writeErr(w, http.StatusUnauthorized, "unauthorized", "Invalid authentication: "+err.Error())
+				return
+			}
+			next(w, r)
+			return
+		}
+		if cfg.RequestSigning == "required" {
+			writeErr(w, http.StatusUnauthorized, "unauthorized", "Signed request required")
+			return
+		}
+
 		got := r.Header.Get("X-Internal-Auth")
-		if subtle.ConstantTimeCompare([]byte(got), []byte(shared)) != 1 {
+		if shared == "" || subtle.ConstantTimeCompare([]byte(got), []byte(shared)) != 1 {
 			writeErr(w, http.StatusUnauthorized, "unauthorized", "Invalid authentication")
 			return
 		}
@@ -390,6 +791,34 @@ func withInternalAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// bufferBody reads the request body for signature verification and puts it
+// back for the handler.
+func bufferBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("body exceeds %d bytes", limit)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func presentedAPIKey(r *http.Request) string {
+	if k := strings.TrimSpace(r.Header.Get("X-API-Key")); k != "" {
+		return k
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
 func withConcurrencyLimit(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := requestSem.Acquire(r.Context(), 1); err != nil {
@@ -407,8 +836,23 @@ func withConcurrencyLimit(next http.HandlerFunc) http.HandlerFunc {
 
 func withRateLimit(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ip := getClientIP(r)
-		limiter := getRateLimiter(ip)
+		// Keys with their own rate use it; others share the server default,
+		// per key when authenticated by key and per IP otherwise.
+		bucket := getClientIP(r)
+		if key, ok := apikey.FromContext(r.Context()); ok {
+			allowed, own := keyTracker.Allow(key)
+			if own && !allowed {
+				w.Header().Set("Retry-After", "60")
+				writeErr(w, http.StatusTooManyRequests, "rate_limit", "Rate limit exceeded")
+				return
+			}
+			if own {
+				next(w, r)
+				return
+			}
+			bucket = "key:" + key.ID
+		}
+		limiter := getRateLimiter(bucket)
 
 		if !limiter.Allow() {
 			w.Header().Set("Retry-After", "60")
@@ -437,6 +881,11 @@ func withLogging(next http.Handler) http.Handler {
 		ww := &wrapWriter{ResponseWriter: w, status: 200}
 		next.ServeHTTP(ww, r)
 
+		if ww.keyID != "" {
+			fmt.Printf("%s %s -> %d (%s) key=%s\n",
+				r.Method, sanitizeLogString(r.URL.Path), ww.status, time.Since(start), sanitizeLogString(ww.keyID))
+			return
+		}
 		fmt.Printf("%s %s -> %d (%s)\n",
 			r.Method, sanitizeLogString(r.URL.Path), ww.status, time.Since(start))
 	})
@@ -445,6 +894,7 @@ func withLogging(next http.Handler) http.Handler {
 type wrapWriter struct {
 	http.ResponseWriter
 	status int
+	keyID  string // set by withInternalAuth for API-key requests
 }
 
 func (w *wrapWriter) WriteHeader(code int) {
@@ -488,6 +938,18 @@ func getClientIP(r *http.Request) string {
 	return host
 }
 
+// clientID identifies the API client for usage accounting: the API key's ID,
+// else the Worker's X-Client-Id header, else the client IP.
+func clientID(r *http.Request) string {
+	if key, ok := apikey.FromContext(r.Context()); ok {
+		return key.ID
+	}
+	if id := strings.TrimSpace(r.Header.Get("X-Client-Id")); id != "" {
+		return sanitizeLogString(id)
+	}
+	return getClientIP(r)
+}
+
 func sanitizeError(err error) string {
 	if err == nil {
 		return ""
@@ -518,6 +980,22 @@ func isPreviewAllowed(fileType string) bool {
 	}
 }
 
+// paidOptions turn on provider calls inside otherwise native extractors.
+// Preview has no quota check or usage accounting, so they are dropped.
+var paidOptions = []string{"embeddedImages", "maxEmbeddedImages"}
+
+// previewOptions returns options without the paid ones.
+func previewOptions(options map[string]any) map[string]any {
+	out := make(map[string]any, len(options))
+	for k, v := range options {
+		out[k] = v
+	}
+	for _, k := range paidOptions {
+		delete(out, k)
+	}
+	return out
+}
+
 func previewMaxCharsOption(options map[string]any, fallback int) int {
 	v := intOption(options, "previewMaxChars", fallback)
 	if v <= 0 {
@@ -5,6 +5,7 @@ go 1.25.6
 require (
 	github.com/gabriel-vasile/mimetype v1.4.8
 	github.com/xuri/excelize/v2 v2.9.1
+	golang.org/x/crypto v0.43.0
 	golang.org/x/net v0.46.0
 	golang.org/x/sync v0.19.0
 	golang.org/x/time v0.14.0
@@ -17,6 +18,6 @@ require (
 	github.com/tiendc/go-deepcopy v1.6.0 // indirect
 	github.com/xuri/efp v0.0.1 // indirect
 	github.com/xuri/nfp v0.0.1 // indirect
-	golang.org/x/crypto v0.43.0 // indirect
+	golang.org/x/sys v0.37.0 // indirect
 	golang.org/x/text v0.30.0 // indirect
 )
@@ -0,0 +1,189 @@
+// Package apikey authenticates API callers by key and enforces each key's
+// rate limit, daily quotas and allowed extractors.
+//
+// Keys are never stored in clear text: a store holds only the SHA-256 of
+// each key (see Hash), so a leaked key file cannot be replayed. Keys are
+// random, high-entropy tokens, which is why a fast unsalted hash suffices.
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const hashPrefix = "sha256:"
+
+var (
+	ErrUnknownKey  = errors.New("unknown API key")
+	ErrDisabledKey = errors.New("API key disabled")
+)
+
+// Key is one tenant's API key and its limits. Zero limits mean unlimited
+// (or, for RatePerMinute, the server's default per-client rate).
+type Key struct {
+	ID   string `json:"id"`   // stable identity used in logs, metrics and usage
+	Hash string `json:"hash"` // "sha256:<hex>" of the raw key
+
+	RatePerMinute float64 `json:"ratePerMinute,omitempty"`
+	Burst         int     `json:"burst,omitempty"`
+
+	DailyOCRPages             int     `json:"dailyOcrPages,omitempty"`
+	DailyTranscriptionMinutes float64 `json:"dailyTranscriptionMinutes,omitempty"`
+
+	// AllowedExtractors lists extractor names ("document/pdf") or families
+	// ("document/*"). Empty allows every extractor.
+	AllowedExtractors []string `json:"allowedExtractors,omitempty"`
+
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Hash returns the at-rest form of a raw key.
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// Store looks keys up by hash. Implementations must be safe for concurrent
+// use; FileStore is the built-in one.
+type Store interface {
+	Lookup(hash string) (Key, bool)
+}
+
+// FileStore serves keys from a JSON file of the form {"keys": [Key, ...]}.
+// Reload picks up edits (new keys, revocations) without a restart.
+type FileStore struct {
+	path string
+
+	mu      sync.RWMutex
+	byHash  map[string]Key
+	modTime time.Time
+}
+
+// LoadFile reads the key file at path.
+func LoadFile(path string) (*FileStore, error) {
+	s := &FileStore{path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) Lookup(hash string) (Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.byHash[hash]
+	return k, ok
+}
+
+// Reload re-reads the file if it changed since the last load. On error the
+// previous keys stay in effect.
+func (s *FileStore) Reload() error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("stat API key file: %w", err)
+	}
+	s.mu.RLock()
+	unchanged := fi.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	return s.load()
+}
+
+func (s *FileStore) load() error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("stat API key file: %w", err)
+	}
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("read API key file: %w", err)
+	}
+	byHash, err := parse(b)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.byHash, s.modTime = byHash, fi.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+func parse(b []byte) (map[string]Key, error) {
+	var file struct {
+		Keys []Key `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("parse API key file: %w", err)
+	}
+	byHash := make(map[string]Key, len(file.Keys))
+	ids := map[string]bool{}
+	for i, k := range file.Keys {
+		k.ID = strings.TrimSpace(k.ID)
+		k.Hash = strings.ToLower(strings.TrimSpace(k.Hash))
+		if k.ID == "" {
+			return nil, fmt.Errorf("API key %d: id required", i)
+		}
+		if ids[k.ID] {
+			return nil, fmt.Errorf("API key %q: duplicate id", k.ID)
+		}
+		hexPart, ok := strings.CutPrefix(k.Hash, hashPrefix)
+		if b, err := hex.DecodeString(hexPart); !ok || err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("API key %q: hash must be %s<64 hex chars>", k.ID, hashPrefix)
+		}
+		ids[k.ID] = true
+		byHash[k.Hash] = k
+	}
+	return byHash, nil
+}
+
+// Authenticate resolves a raw key presented by a caller.
+func Authenticate(store Store, raw string) (Key, error) {
+	k, ok := store.Lookup(Hash(strings.TrimSpace(raw)))
+	if !ok {
+		return Key{}, ErrUnknownKey
+	}
+	if k.Disabled {
+		return Key{}, ErrDisabledKey
+	}
+	return k, nil
+}
+
+// Allows reports whether the key may use the named extractor.
+func (k Key) Allows(extractor string) bool {
+	if len(k.AllowedExtractors) == 0 {
+		return true
+	}
+	for _, a := range k.AllowedExtractors {
+		if a == "*" || a == extractor {
+			return true
+		}
+		if family, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(extractor, family+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+type ctxKey struct{}
+
+// WithKey attaches the authenticated key to ctx.
+func WithKey(ctx context.Context, k Key) context.Context {
+	return context.WithValue(ctx, ctxKey{}, k)
+}
+
+// FromContext returns the key attached by WithKey, if any. Requests
+// authenticated with the legacy shared secret carry none.
+func FromContext(ctx context.Context) (Key, bool) {
+	k, ok := ctx.Value(ctxKey{}).(Key)
+	return k, ok
+}
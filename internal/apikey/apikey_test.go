@@ -0,0 +1,87 @@
+package apikey
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+func writeKeys(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileStoreAuthenticate(t *testing.T) {
+	path := writeKeys(t, `{"keys": [
+		{"id": "team-a", "hash": "`+Hash("secret-a")+`"},
+		{"id": "team-b", "hash": "`+Hash("secret-b")+`", "disabled": true}
+	]}`)
+	store, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if k, err := Authenticate(store, "secret-a"); err != nil || k.ID != "team-a" {
+		t.Fatalf("team-a: %+v, %v", k, err)
+	}
+	if _, err := Authenticate(store, "secret-b"); !errors.Is(err, ErrDisabledKey) {
+		t.Fatalf("team-b: want ErrDisabledKey, got %v", err)
+	}
+	if _, err := Authenticate(store, "nope"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("unknown: want ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestParseRejectsBadKeys(t *testing.T) {
+	for name, body := range map[string]string{
+		"plaintext": `{"keys": [{"id": "a", "hash": "secret"}]}`,
+		"no id":     `{"keys": [{"hash": "` + Hash("x") + `"}]}`,
+		"dup id":    `{"keys": [{"id": "a", "hash": "` + Hash("x") + `"}, {"id": "a", "hash": "` + Hash("y") + `"}]}`,
+	} {
+		if _, err := parse([]byte(body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	k := Key{AllowedExtractors: []string{"document/*", "image"}}
+	for name, want := range map[string]bool{
+		"document/pdf": true,
+		"image":        true,
+		"media/audio":  false,
+		"documents":    false,
+	} {
+		if got := k.Allows(name); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !(Key{}).Allows("media/video") {
+		t.Error("empty list should allow everything")
+	}
+}
+
+func TestQuotaResetsDaily(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	tr := NewTracker()
+	tr.now = func() time.Time { return now }
+	k := Key{ID: "a", DailyOCRPages: 10}
+
+	tr.Add(k, &extract.Usage{OCRPages: 10})
+	if err := tr.CheckQuota(k); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("want ErrQuotaExceeded, got %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := tr.CheckQuota(k); err != nil {
+		t.Fatalf("quota should reset at midnight UTC: %v", err)
+	}
+}
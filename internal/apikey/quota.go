@@ -0,0 +1,151 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"golang.org/x/time/rate"
+)
+
+// ErrQuotaExceeded is wrapped by CheckQuota errors.
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+// Tracker holds per-key rate limiters and today's usage. Counters live in
+// process memory and reset at 00:00 UTC; with several replicas each enforces
+// its own share.
+type Tracker struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	limiters map[string]*limiterEntry
+	day      string
+	used     map[string]*DailyUsage
+	rejected map[string]int64
+}
+
+type limiterEntry struct {
+	limiter *rate.Limiter
+	perMin  float64
+	burst   int
+}
+
+// DailyUsage is one key's consumption since 00:00 UTC.
+type DailyUsage struct {
+	Requests             int64   `json:"requests"`
+	OCRPages             int     `json:"ocrPages"`
+	TranscriptionMinutes float64 `json:"transcriptionMinutes"`
+}
+
+// KeyStats is the /metrics view of one key.
+type KeyStats struct {
+	Day      string     `json:"day"`
+	Used     DailyUsage `json:"used"`
+	Rejected int64      `json:"rejected"` // rate-limited or over quota
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		now:      time.Now,
+		limiters: map[string]*limiterEntry{},
+		used:     map[string]*DailyUsage{},
+		rejected: map[string]int64{},
+	}
+}
+
+// Allow applies the key's own rate limit. ok is false when the key has no
+// rate of its own and the caller should apply the server default.
+func (t *Tracker) Allow(k Key) (allowed, ok bool) {
+	if k.RatePerMinute <= 0 {
+		return false, false
+	}
+	burst := k.Burst
+	if burst <= 0 {
+		burst = max(1, int(k.RatePerMinute/6)) // ten seconds' worth
+	}
+
+	t.mu.Lock()
+	e := t.limiters[k.ID]
+	if e == nil || e.perMin != k.RatePerMinute || e.burst != burst {
+		// New key or its limits were edited in the key file.
+		e = &limiterEntry{limiter: rate.NewLimiter(rate.Limit(k.RatePerMinute/60), burst), perMin: k.RatePerMinute, burst: burst}
+		t.limiters[k.ID] = e
+	}
+	t.mu.Unlock()
+
+	if !e.limiter.Allow() {
+		t.reject(k.ID)
+		return false, true
+	}
+	return true, true
+}
+
+// CheckQuota fails once today's usage has reached either daily quota. A
+// request that starts under quota runs to completion, so a key can overshoot
+// by at most one request.
+func (t *Tracker) CheckQuota(k Key) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := t.today(k.ID)
+	switch {
+	case k.DailyOCRPages > 0 && u.OCRPages >= k.DailyOCRPages:
+		t.rejected[k.ID]++
+		return fmt.Errorf("%w: %d of %d OCR pages used", ErrQuotaExceeded, u.OCRPages, k.DailyOCRPages)
+	case k.DailyTranscriptionMinutes > 0 && u.TranscriptionMinutes >= k.DailyTranscriptionMinutes:
+		t.rejected[k.ID]++
+		return fmt.Errorf("%w: %.1f of %.1f transcription minutes used", ErrQuotaExceeded, u.TranscriptionMinutes, k.DailyTranscriptionMinutes)
+	}
+	return nil
+}
+
+// Add counts one request and its usage (which may be nil) against the key.
+func (t *Tracker) Add(k Key, usage *extract.Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := t.today(k.ID)
+	u.Requests++
+	if usage != nil {
+		u.OCRPages += usage.OCRPages
+		u.TranscriptionMinutes += usage.TranscriptionSeconds / 60
+	}
+}
+
+// Snapshot returns today's stats for every key seen today.
+func (t *Tracker) Snapshot() map[string]KeyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	out := make(map[string]KeyStats, len(t.used))
+	for id, u := range t.used {
+		out[id] = KeyStats{Day: t.day, Used: *u, Rejected: t.rejected[id]}
+	}
+	return out
+}
+
+func (t *Tracker) reject(id string) {
+	t.mu.Lock()
+	t.rejected[id]++
+	t.mu.Unlock()
+}
+
+// today returns id's counters for the current UTC day. Callers hold t.mu.
+func (t *Tracker) today(id string) *DailyUsage {
+	t.rollover()
+	u := t.used[id]
+	if u == nil {
+		u = &DailyUsage{}
+		t.used[id] = u
+	}
+	return u
+}
+
+func (t *Tracker) rollover() {
+	day := t.now().UTC().Format(time.DateOnly)
+	if day != t.day {
+		t.day = day
+		t.used = map[string]*DailyUsage{}
+		t.rejected = map[string]int64{}
+	}
+}
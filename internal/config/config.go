@@ -18,6 +18,16 @@ type Config struct {
 	OpenRouterAPIKey     string
 	GroqAPIKey           string
 
+	// Multi-tenant API keys: JSON key file (hashed keys with per-key limits),
+	// re-read when it changes.
+	APIKeysFile           string
+	APIKeysReloadInterval time.Duration
+
+	// HMAC request signing with the shared secret: "off" (legacy header
+	// only), "optional" (signed or legacy) or "required".
+	RequestSigning        string
+	RequestSigningMaxSkew time.Duration
+
 	// Limits
 	MaxJSONBodyBytes int64
 	MaxPDFBytes      int64
@@ -27,11 +37,48 @@ type Config struct {
 	MaxCodeFileBytes int64
 	MaxImageBytes    int64
 
+	// PDF structure (outline, page labels) is parsed in memory; larger files
+	// skip it and fall back to text only.
+	MaxPDFStructureBytes int64
+
+	// Embedded files (attachments, portfolio members) extracted per PDF.
+	MaxPDFAttachments     int
+	MaxPDFAttachmentBytes int64
+
+	// Figures analyzed per PDF when the figures option is on, and the
+	// smallest image side (pixels) considered a figure rather than decoration.
+	MaxPDFFigures      int
+	MinPDFFigurePixels int
+
+	// Images analyzed per Office/ODF/EPUB document when the embeddedImages
+	// option is on, the smallest side (pixels) analyzed, and the total image
+	// bytes one document may send to OCR/vision.
+	MaxEmbeddedImages        int
+	MinEmbeddedImagePixels   int
+	EmbeddedImageBudgetBytes int64
+
 	// Concurrency
 	MaxConcurrentRequests int64
 	MaxOCRConcurrent      int64
 	MaxPageWorkers        int // per-document page extraction workers cap
 
+	// Provider gateways: concurrency, token-bucket rate and queue length for
+	// outbound AI provider calls. Mistral concurrency is MaxOCRConcurrent.
+	MistralRatePerSec       float64
+	MistralRateBurst        int
+	OpenRouterMaxConcurrent int64
+	OpenRouterRatePerSec    float64
+	OpenRouterRateBurst     int
+	GroqMaxConcurrent       int64
+	GroqRatePerSec          float64
+	GroqRateBurst           int
+	ProviderMaxQueue        int
+
+	// Provider circuit breakers: consecutive failures before a provider is
+	// marked unavailable, and how long before it is probed again.
+	ProviderBreakerThreshold int
+	ProviderBreakerCooldown  time.Duration
+
 	// Server timeouts
 	ReadHeaderTimeout time.Duration
 	ReadTimeout       time.Duration
@@ -45,10 +92,56 @@ type Config struct {
 	DownloadTimeout time.Duration
 	GroqTimeout     time.Duration
 
+	// Download URL policy (SSRF protection)
+	DownloadAllowedSchemes    []string
+	DownloadAllowedHosts      []string // exact hosts or "*.suffix"; empty allows any public host
+	DownloadAllowPrivateHosts bool
+	DownloadMaxRedirects      int
+
+	// Input sources besides http(s) (see extract.Source)
+	DataURIMaxBytes   int64
+	FileSourceRoot    string // enables file:// below this directory
+	S3Endpoint        string // enables s3://bucket/key
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3SessionToken    string
+	S3ForcePathStyle  bool
+	S3AllowedBuckets  []string
+	S3PresignTTL      time.Duration // presigned GET handed to hosted providers; 0 disables
+
+	// Sandbox for external tools (poppler, LibreOffice, ffmpeg, tesseract,
+	// whisper); zero limits are unset
+	SandboxMaxMemoryMB    int
+	SandboxMaxCPUSeconds  int
+	SandboxMaxFileMB      int
+	SandboxMaxOpenFiles   int
+	SandboxUID            int // non-zero runs tools as this user (service must be root)
+	SandboxGID            int
+	SandboxIsolateNetwork bool
+	SandboxLogUsage       bool
+
+	// Zip container limits for OOXML, ODF and EPUB (see safezip)
+	ArchiveMaxEntries    int
+	ArchiveMaxEntryBytes int64
+	ArchiveMaxTotalBytes int64
+	ArchiveMaxRatio      float64
+
+	// Result output to object storage (the "output" option)
+	OutputS3Buckets []string // buckets s3:// output may write to; empty disables s3 output
+	OutputTimeout   time.Duration
+
 	// Poppler / extraction timeouts
 	PDFInfoTimeout      time.Duration
 	PDFToTextTimeout    time.Duration
 	PDFToTextAllTimeout time.Duration
+	PDFToPPMTimeout     time.Duration
+	PDFDetachTimeout    time.Duration
+	PDFImagesTimeout    time.Duration
+
+	// Resolution used when pages are rasterized locally for OCR
+	// (encrypted PDFs the OCR provider cannot open).
+	OCRRenderDPI int
 
 	// rate limiting (per IP)
 	RateLimitEvery time.Duration
@@ -83,8 +176,37 @@ type Config struct {
 	// Conversion binaries
 	LibreOfficeTimeout time.Duration
 	LibreOfficeBinary  string
-	FFmpegTimeout      time.Duration
-	FFmpegBinary       string
+	// Warm listener pool; size 0 starts a cold soffice per conversion
+	LibreOfficePoolSize       int
+	LibreOfficePython         string
+	LibreOfficeMaxConversions int
+	LibreOfficeStartTimeout   time.Duration
+	LibreOfficeHealthInterval time.Duration
+	FFmpegTimeout             time.Duration
+	FFmpegBinary              string
+
+	// Failover chains: providers tried in order for each capability.
+	OCRProviders           []string // "mistral", "tesseract"
+	VisionModels           []string // OpenRouter models; defaults to DefaultVisionModel
+	TranscriptionProviders []string // "groq", "whisper"
+
+	// Usage accounting: optional JSON price table layered over the built-in
+	// list prices.
+	PriceTableFile string
+
+	// Cost estimation (/estimate)
+	EstimateSamplePages      int   // leading PDF pages checked for a text layer
+	EstimateMaxDownloadBytes int64 // larger files are estimated from size only
+	FFprobeBinary            string
+	FFprobeTimeout           time.Duration
+
+	// Local fallback providers
+	TesseractBinary  string
+	TesseractLang    string
+	TesseractTimeout time.Duration
+	WhisperBinary    string
+	WhisperModel     string
+	WhisperTimeout   time.Duration
 }
 
 func Load() Config {
@@ -96,6 +218,12 @@ func Load() Config {
 		OpenRouterAPIKey:     envStr("OPENROUTER_API_KEY", ""),
 		GroqAPIKey:           envStr("GROQ_API_KEY", ""),
 
+		APIKeysFile:           envStr("API_KEYS_FILE", ""),
+		APIKeysReloadInterval: envDur("API_KEYS_RELOAD_INTERVAL", 30*time.Second),
+
+		RequestSigning:        strings.ToLower(envStr("REQUEST_SIGNING", "optional")),
+		RequestSigningMaxSkew: envDur("REQUEST_SIGNING_MAX_SKEW", 5*time.Minute),
+
 		MaxJSONBodyBytes: int64(envInt("MAX_JSON_BODY_BYTES", 2<<20)),
 		MaxPDFBytes:      int64(envInt("MAX_PDF_BYTES", int(200<<20))),
 		MaxFileBytes:     int64(envInt("MAX_FILE_BYTES", int(500<<20))),
@@ -104,10 +232,35 @@ func Load() Config {
 		MaxCodeFileBytes: int64(envInt("MAX_CODE_FILE_BYTES", int(10<<20))),
 		MaxImageBytes:    int64(envInt("MAX_IMAGE_BYTES", int(40<<20))),
 
+		MaxPDFStructureBytes: int64(envInt("MAX_PDF_STRUCTURE_BYTES", int(100<<20))),
+
+		MaxPDFAttachments:     envInt("MAX_PDF_ATTACHMENTS", 20),
+		MaxPDFAttachmentBytes: int64(envInt("MAX_PDF_ATTACHMENT_BYTES", int(50<<20))),
+
+		MaxPDFFigures:      envInt("MAX_PDF_FIGURES", 10),
+		MinPDFFigurePixels: envInt("MIN_PDF_FIGURE_PIXELS", 150),
+
+		MaxEmbeddedImages:        envInt("MAX_EMBEDDED_IMAGES", 10),
+		MinEmbeddedImagePixels:   envInt("MIN_EMBEDDED_IMAGE_PIXELS", 150),
+		EmbeddedImageBudgetBytes: int64(envInt("EMBEDDED_IMAGE_BUDGET_BYTES", int(25<<20))),
+
 		MaxConcurrentRequests: int64(envInt("MAX_CONCURRENT_REQUESTS", 15)),
 		MaxOCRConcurrent:      int64(envInt("MAX_OCR_CONCURRENT", 3)),
 		MaxPageWorkers:        envInt("MAX_PAGE_WORKERS", 8),
 
+		MistralRatePerSec:       envFloat("MISTRAL_RATE_PER_SEC", 5),
+		MistralRateBurst:        envInt("MISTRAL_RATE_BURST", 5),
+		OpenRouterMaxConcurrent: int64(envInt("OPENROUTER_MAX_CONCURRENT", 8)),
+		OpenRouterRatePerSec:    envFloat("OPENROUTER_RATE_PER_SEC", 10),
+		OpenRouterRateBurst:     envInt("OPENROUTER_RATE_BURST", 10),
+		GroqMaxConcurrent:       int64(envInt("GROQ_MAX_CONCURRENT", 4)),
+		GroqRatePerSec:          envFloat("GROQ_RATE_PER_SEC", 0.33),
+		GroqRateBurst:           envInt("GROQ_RATE_BURST", 3),
+		ProviderMaxQueue:        envInt("PROVIDER_MAX_QUEUE", 200),
+
+		ProviderBreakerThreshold: envInt("PROVIDER_BREAKER_THRESHOLD", 5),
+		ProviderBreakerCooldown:  envDur("PROVIDER_BREAKER_COOLDOWN", 30*time.Second),
+
 		ReadHeaderTimeout: envDur("READ_HEADER_TIMEOUT", 10*time.Second),
 		ReadTimeout:       envDur("READ_TIMEOUT", 30*time.Second),
 		WriteTimeout:      envDur("WRITE_TIMEOUT", 180*time.Second),
@@ -118,9 +271,47 @@ func Load() Config {
 		DownloadTimeout: envDur("DOWNLOAD_TIMEOUT", 25*time.Second),
 		GroqTimeout:     envDur("GROQ_TIMEOUT", 120*time.Second),
 
+		DownloadAllowedSchemes:    envList("DOWNLOAD_ALLOWED_SCHEMES", "https,http"),
+		DownloadAllowedHosts:      envList("DOWNLOAD_ALLOWED_HOSTS", ""),
+		DownloadAllowPrivateHosts: envBool("DOWNLOAD_ALLOW_PRIVATE_HOSTS", false),
+		DownloadMaxRedirects:      envInt("DOWNLOAD_MAX_REDIRECTS", 3),
+
+		DataURIMaxBytes:   int64(envInt("DATA_URI_MAX_BYTES", 1<<20)),
+		FileSourceRoot:    envStr("FILE_SOURCE_ROOT", ""),
+		S3Endpoint:        envStr("S3_ENDPOINT", ""),
+		S3Region:          envStr("S3_REGION", "us-east-1"),
+		S3AccessKeyID:     envStr("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: envStr("S3_SECRET_ACCESS_KEY", ""),
+		S3SessionToken:    envStr("S3_SESSION_TOKEN", ""),
+		S3ForcePathStyle:  envBool("S3_FORCE_PATH_STYLE", false),
+		S3AllowedBuckets:  envList("S3_ALLOWED_BUCKETS", ""),
+		S3PresignTTL:      envDur("S3_PRESIGN_TTL", 15*time.Minute),
+
+		SandboxMaxMemoryMB:    envInt("SANDBOX_MAX_MEMORY_MB", 0),
+		SandboxMaxCPUSeconds:  envInt("SANDBOX_MAX_CPU_SECONDS", 0),
+		SandboxMaxFileMB:      envInt("SANDBOX_MAX_FILE_MB", 2048),
+		SandboxMaxOpenFiles:   envInt("SANDBOX_MAX_OPEN_FILES", 1024),
+		SandboxUID:            envInt("SANDBOX_UID", 0),
+		SandboxGID:            envInt("SANDBOX_GID", 0),
+		SandboxIsolateNetwork: envBool("SANDBOX_ISOLATE_NETWORK", false),
+		SandboxLogUsage:       envBool("SANDBOX_LOG_USAGE", false),
+
+		ArchiveMaxEntries:    envInt("ARCHIVE_MAX_ENTRIES", 10000),
+		ArchiveMaxEntryBytes: int64(envInt("ARCHIVE_MAX_ENTRY_BYTES", 128<<20)),
+		ArchiveMaxTotalBytes: int64(envInt("ARCHIVE_MAX_TOTAL_BYTES", 512<<20)),
+		ArchiveMaxRatio:      envFloat("ARCHIVE_MAX_RATIO", 200),
+
+		OutputS3Buckets: envList("OUTPUT_S3_BUCKETS", ""),
+		OutputTimeout:   envDur("OUTPUT_TIMEOUT", 2*time.Minute),
+
 		PDFInfoTimeout:      envDur("PDFINFO_TIMEOUT", 5*time.Second),
 		PDFToTextTimeout:    envDur("PDFTOTEXT_TIMEOUT", 10*time.Second),
 		PDFToTextAllTimeout: envDur("PDFTOTEXT_ALL_TIMEOUT", 30*time.Second),
+		PDFToPPMTimeout:     envDur("PDFTOPPM_TIMEOUT", 30*time.Second),
+		PDFDetachTimeout:    envDur("PDFDETACH_TIMEOUT", 30*time.Second),
+		PDFImagesTimeout:    envDur("PDFIMAGES_TIMEOUT", 30*time.Second),
+
+		OCRRenderDPI: envInt("OCR_RENDER_DPI", 150),
 
 		RateLimitEvery: envDur("RATE_LIMIT_EVERY", 600*time.Millisecond),
 		RateLimitBurst: envInt("RATE_LIMIT_BURST", 20),
@@ -147,15 +338,60 @@ func Load() Config {
 
 		LibreOfficeTimeout: envDur("LIBREOFFICE_TIMEOUT", 60*time.Second),
 		LibreOfficeBinary:  envStr("LIBREOFFICE_BINARY", "soffice"),
-		FFmpegTimeout:      envDur("FFMPEG_TIMEOUT", 120*time.Second),
-		FFmpegBinary:       envStr("FFMPEG_BINARY", "ffmpeg"),
+
+		LibreOfficePoolSize:       envInt("LIBREOFFICE_POOL_SIZE", 2),
+		LibreOfficePython:         envStr("LIBREOFFICE_PYTHON", "python3"),
+		LibreOfficeMaxConversions: envInt("LIBREOFFICE_MAX_CONVERSIONS", 200),
+		LibreOfficeStartTimeout:   envDur("LIBREOFFICE_START_TIMEOUT", 60*time.Second),
+		LibreOfficeHealthInterval: envDur("LIBREOFFICE_HEALTH_INTERVAL", 30*time.Second),
+		FFmpegTimeout:             envDur("FFMPEG_TIMEOUT", 120*time.Second),
+		FFmpegBinary:              envStr("FFMPEG_BINARY", "ffmpeg"),
+
+		OCRProviders:           envList("OCR_PROVIDERS", "mistral,tesseract"),
+		VisionModels:           envList("VISION_MODELS", ""),
+		TranscriptionProviders: envList("TRANSCRIPTION_PROVIDERS", "groq"),
+
+		PriceTableFile: envStr("PRICE_TABLE_FILE", ""),
+
+		EstimateSamplePages:      envInt("ESTIMATE_SAMPLE_PAGES", 30),
+		EstimateMaxDownloadBytes: int64(envInt("ESTIMATE_MAX_DOWNLOAD_BYTES", int(100<<20))),
+		FFprobeBinary:            envStr("FFPROBE_BINARY", "ffprobe"),
+		FFprobeTimeout:           envDur("FFPROBE_TIMEOUT", 30*time.Second),
+
+		TesseractBinary:  envStr("TESSERACT_BINARY", "tesseract"),
+		TesseractLang:    envStr("TESSERACT_LANG", "eng"),
+		TesseractTimeout: envDur("TESSERACT_TIMEOUT", 60*time.Second),
+		WhisperBinary:    envStr("WHISPER_BINARY", "whisper"),
+		WhisperModel:     envStr("WHISPER_MODEL", "base"),
+		WhisperTimeout:   envDur("WHISPER_TIMEOUT", 10*time.Minute),
 	}
 }
 
 func (c Config) Validate() error {
-	if len(strings.TrimSpace(c.InternalSharedSecret)) < 32 {
+	// The shared secret may be left unset only when API keys are configured.
+	secret := strings.TrimSpace(c.InternalSharedSecret)
+	if (secret != "" || c.APIKeysFile == "") && len(secret) < 32 {
 		return fmt.Errorf("INTERNAL_SHARED_SECRET must be at least 32 characters")
 	}
+	switch c.RequestSigning {
+	case "off", "optional", "required":
+	default:
+		return fmt.Errorf("REQUEST_SIGNING must be off, optional or required")
+	}
+	// excelize rejects an unzip limit below its 16MiB per-XML default.
+	if c.ArchiveMaxTotalBytes < 16<<20 || c.ArchiveMaxEntryBytes > c.ArchiveMaxTotalBytes {
+		return fmt.Errorf("ARCHIVE_MAX_TOTAL_BYTES must be at least 16MiB and no less than ARCHIVE_MAX_ENTRY_BYTES")
+	}
+	for _, p := range c.OCRProviders {
+		if p != "mistral" && p != "tesseract" {
+			return fmt.Errorf("OCR_PROVIDERS: unknown provider %q", p)
+		}
+	}
+	for _, p := range c.TranscriptionProviders {
+		if p != "groq" && p != "whisper" {
+			return fmt.Errorf("TRANSCRIPTION_PROVIDERS: unknown provider %q", p)
+		}
+	}
 	return nil
 }
 
@@ -202,3 +438,31 @@ func envDur(key string, fallback time.Duration) time.Duration {
 	}
 	return d
 }
+
+// envBool reads a boolean in any form strconv.ParseBool accepts.
+func envBool(key string, fallback bool) bool {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fallback
+	}
+	return b
+}
+
+// envList reads a comma-separated list, dropping empty items.
+func envList(key, fallback string) []string {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		v = fallback
+	}
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
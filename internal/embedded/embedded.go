@@ -0,0 +1,325 @@
+// Package embedded analyses images embedded in documents (DOCX, PPTX, ODF,
+// EPUB) through the image OCR/vision routing. Converters mark each image
+// anchor with a placeholder while rendering; Resolve then picks the images
+// worth analysing, sends them to the providers within the document's cap
+// and byte budget, and replaces each placeholder with the result.
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif" // DecodeConfig formats
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	img "github.com/toricodesthings/file-processing-service/internal/image"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
+	"golang.org/x/sync/semaphore"
+)
+
+// Config controls embedded image analysis. A zero MaxImages disables it.
+type Config struct {
+	OCRModel      string
+	VisionModel   string
+	VisionTimeout time.Duration
+	MaxImages     int   // per-document cap on images sent to vision/OCR
+	MinPixels     int   // smallest width/height treated as content
+	BudgetBytes   int64 // per-document total of image bytes sent; 0 = no limit
+}
+
+// sendable are the image types the providers accept inline. Office
+// metafiles (EMF, WMF), TIFF and SVG are skipped.
+var sendable = map[string]bool{"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true}
+
+// Stats reports what Resolve did with a document's images.
+type Stats struct {
+	Found    int // images passing the size and decoration filters
+	Analyzed int
+	Failed   int
+}
+
+// AddTo records the counts in result metadata.
+func (s Stats) AddTo(meta map[string]string) {
+	meta["embeddedImagesFound"] = strconv.Itoa(s.Found)
+	meta["embeddedImagesAnalyzed"] = strconv.Itoa(s.Analyzed)
+	if s.Failed > 0 {
+		meta["embeddedImagesFailed"] = strconv.Itoa(s.Failed)
+	}
+}
+
+// Collector gathers the image anchors of one document. A nil Collector is
+// valid and disabled: Anchor returns "" and Resolve returns text unchanged.
+type Collector struct {
+	cfg   Config
+	limit int
+	names []string       // distinct images in first-anchor order
+	ids   map[string]int // image name -> index in names
+	uses  []int
+	marks []int // anchor number -> image index
+
+	// analyze runs one image through OCR/vision; replaced in tests.
+	analyze func(ctx context.Context, data []byte, mimeType string) (string, error)
+}
+
+// NewCollector returns a collector for a job whose options turn on
+// embeddedImages, or nil. Option maxEmbeddedImages lowers the cap.
+func NewCollector(cfg Config, options map[string]any) *Collector {
+	if cfg.MaxImages <= 0 || !boolOption(options, "embeddedImages", false) {
+		return nil
+	}
+	c := &Collector{
+		cfg:   cfg,
+		limit: min(intOption(options, "maxEmbeddedImages", cfg.MaxImages), cfg.MaxImages),
+		ids:   map[string]int{},
+	}
+	c.analyze = c.describe
+	return c
+}
+
+// Anchor records an occurrence of the image stored at name and returns the
+// placeholder to render where it is anchored.
+func (c *Collector) Anchor(name string) string {
+	if c == nil || name == "" {
+		return ""
+	}
+	id, ok := c.ids[name]
+	if !ok {
+		id = len(c.names)
+		c.ids[name] = id
+		c.names = append(c.names, name)
+		c.uses = append(c.uses, 0)
+	}
+	c.uses[id]++
+	c.marks = append(c.marks, id)
+	return markOpen + strconv.Itoa(len(c.marks)-1) + markClose
+}
+
+// Placeholders are a number between private-use characters, which survive
+// every converter's escaping and whitespace handling.
+const (
+	markOpen  = "\uE000"
+	markClose = "\uE001"
+)
+
+// placeholder matches an anchor with the spaces around it, which an
+// inserted block replaces.
+var placeholder = regexp.MustCompile("([ \t]*)" + markOpen + "([0-9]+)" + markClose + "([ \t]*)")
+
+type selected struct {
+	id       int
+	data     []byte
+	mimeType string
+}
+
+// Resolve analyses the anchored images and replaces their placeholders in
+// text. Each image is read once through read and described at its first
+// anchor as "**Image N:** …"; other anchors and images that were filtered
+// out, over the cap or budget, or failed are removed.
+func (c *Collector) Resolve(ctx context.Context, text string, read func(name string) ([]byte, error)) (string, Stats) {
+	var stats Stats
+	if c == nil || len(c.marks) == 0 {
+		return text, stats
+	}
+
+	var picked []selected
+	var spent int64
+	for id, name := range c.names {
+		if c.uses[id] >= img.DecorativeUses {
+			continue
+		}
+		data, err := read(name)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		mimeType, ok := c.candidate(data)
+		if !ok {
+			continue
+		}
+		stats.Found++
+		if len(picked) >= c.limit || len(data) > img.MaxDocumentImageBytes {
+			continue
+		}
+		if c.cfg.BudgetBytes > 0 && spent+int64(len(data)) > c.cfg.BudgetBytes {
+			continue
+		}
+		spent += int64(len(data))
+		picked = append(picked, selected{id: id, data: data, mimeType: mimeType})
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = map[int]string{}
+		sem     = semaphore.NewWeighted(img.DocumentImageWorkers)
+	)
+	for _, p := range picked {
+		wg.Add(1)
+		go func(p selected) {
+			defer wg.Done()
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return
+			}
+			defer sem.Release(1)
+
+			desc, err := c.analyze(ctx, p.data, p.mimeType)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[embedded] image analysis failed name=%s: %v\n", c.names[p.id], err)
+				stats.Failed++
+				return
+			}
+			if desc != "" {
+				results[p.id] = desc
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	// Number images in document order, at their first anchor.
+	labels := map[int]int{}
+	for _, id := range c.marks {
+		if _, ok := results[id]; ok && labels[id] == 0 {
+			labels[id] = len(labels) + 1
+		}
+	}
+	stats.Analyzed = len(labels)
+
+	done := map[int]bool{}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, markOpen) {
+			continue
+		}
+		table := strings.HasPrefix(strings.TrimSpace(line), "|")
+		lines[i] = placeholder.ReplaceAllStringFunc(line, func(m string) string {
+			sub := placeholder.FindStringSubmatch(m)
+			n, _ := strconv.Atoi(sub[2])
+			// A removed anchor keeps one side's spacing.
+			space := sub[1]
+			if space == "" {
+				space = sub[3]
+			}
+			if n >= len(c.marks) {
+				return space
+			}
+			id := c.marks[n]
+			desc, ok := results[id]
+			if !ok || done[id] {
+				return space
+			}
+			done[id] = true
+			block := fmt.Sprintf("**Image %d:** %s", labels[id], desc)
+			if table {
+				// A table cell holds one line.
+				return " " + strings.ReplaceAll(strings.Join(strings.Fields(block), " "), "|", `\|`) + " "
+			}
+			return "\n\n" + block + "\n\n"
+		})
+	}
+	return tidy(strings.Join(lines, "\n")), stats
+}
+
+// blankRuns matches the gaps left where blocks were inserted or removed.
+var blankRuns = regexp.MustCompile(`[ \t]*\n(?:[ \t]*\n)+`)
+
+func tidy(s string) string {
+	return strings.TrimSpace(blankRuns.ReplaceAllString(s, "\n\n"))
+}
+
+// candidate reports whether data is an image worth analysing: a type the
+// providers accept, not too small and not strip-shaped. Images whose size
+// cannot be read (WebP) are kept.
+func (c *Collector) candidate(data []byte) (string, bool) {
+	mimeType := http.DetectContentType(data)
+	if !sendable[mimeType] {
+		return "", false
+	}
+	cfg, _, err := image.DecodeConfig(strings.NewReader(string(data)))
+	if err != nil {
+		return mimeType, mimeType == "image/webp"
+	}
+	if !img.ContentSized(cfg.Width, cfg.Height, c.cfg.MinPixels) {
+		return "", false
+	}
+	return mimeType, true
+}
+
+// describe sends one image through the image pipeline.
+func (c *Collector) describe(ctx context.Context, data []byte, mimeType string) (string, error) {
+	return img.DescribeDocumentImage(ctx, data, mimeType, c.cfg.OCRModel, c.cfg.VisionModel, c.cfg.VisionTimeout)
+}
+
+func intOption(options map[string]any, key string, fallback int) int {
+	if options == nil {
+		return fallback
+	}
+	switch n := options[key].(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(n)); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
+
+func boolOption(options map[string]any, key string, fallback bool) bool {
+	if options == nil {
+		return fallback
+	}
+	switch b := options[key].(type) {
+	case bool:
+		return b
+	case string:
+		if parsed, err := strconv.ParseBool(strings.TrimSpace(b)); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// mediaExts are the extensions of images Resolve can send.
+var mediaExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true}
+
+// Count reports how many images in the document package at file could be
+// analysed, judged by location and extension only: media of DOCX and PPTX,
+// ODF Pictures/ and EPUB images. Thumbnails and object previews are not
+// counted.
+func Count(file string) (int, error) {
+	zr, err := safezip.OpenReader(file)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+	n := 0
+	for _, f := range zr.File {
+		name := f.Name
+		if !mediaExts[strings.ToLower(path.Ext(name))] {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, "docProps/"), strings.HasPrefix(name, "Thumbnails/"),
+			strings.HasPrefix(name, "ObjectReplacements/"):
+			continue
+		case strings.HasPrefix(name, "word/"), strings.HasPrefix(name, "ppt/"):
+			if !strings.HasPrefix(name, "word/media/") && !strings.HasPrefix(name, "ppt/media/") {
+				continue
+			}
+		}
+		n++
+	}
+	return n, nil
+}
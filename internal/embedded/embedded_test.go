@@ -0,0 +1,99 @@
+package embedded
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"testing"
+)
+
+func pngOf(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testCollector(options map[string]any, budget int64) *Collector {
+	c := NewCollector(Config{MaxImages: 3, MinPixels: 100, BudgetBytes: budget}, options)
+	if c != nil {
+		c.analyze = func(_ context.Context, data []byte, mimeType string) (string, error) {
+			return fmt.Sprintf("%s of %d bytes", mimeType, len(data)), nil
+		}
+	}
+	return c
+}
+
+func TestResolveFiltersAndInlines(t *testing.T) {
+	files := map[string][]byte{
+		"media/chart.png": pngOf(t, 400, 300),
+		"media/icon.png":  pngOf(t, 32, 32),
+		"media/rule.png":  pngOf(t, 900, 100),
+		"media/logo.png":  pngOf(t, 200, 200),
+		"media/table.png": pngOf(t, 300, 300),
+		"media/chart.emf": []byte("\x01\x00\x00\x00 EMF"),
+	}
+	read := func(name string) ([]byte, error) {
+		if b, ok := files[name]; ok {
+			return b, nil
+		}
+		return nil, fs.ErrNotExist
+	}
+
+	c := testCollector(map[string]any{"embeddedImages": true}, 0)
+	logo := c.Anchor("media/logo.png") + c.Anchor("media/logo.png") + c.Anchor("media/logo.png")
+	text := logo + "\n\nSales grew. " + c.Anchor("media/chart.png") + " See above.\n\n" +
+		c.Anchor("media/icon.png") + c.Anchor("media/rule.png") + c.Anchor("media/chart.emf") + c.Anchor("media/missing.png") + "\n\n" +
+		"| Q1 | " + c.Anchor("media/table.png") + " |\n\n" + c.Anchor("media/chart.png")
+
+	got, stats := c.Resolve(context.Background(), text, read)
+	chart := fmt.Sprintf("image/png of %d bytes", len(files["media/chart.png"]))
+	table := fmt.Sprintf("image/png of %d bytes", len(files["media/table.png"]))
+	want := "Sales grew.\n\n**Image 1:** " + chart + "\n\nSee above.\n\n| Q1 | **Image 2:** " + table + " |"
+	if got != want {
+		t.Fatalf("got\n%q\nwant\n%q", got, want)
+	}
+	if stats != (Stats{Found: 2, Analyzed: 2}) {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestResolveCapAndBudget(t *testing.T) {
+	img := pngOf(t, 200, 200)
+	read := func(string) ([]byte, error) { return img, nil }
+	anchors := func(c *Collector) string {
+		var s string
+		for i := range 5 {
+			s += c.Anchor(fmt.Sprintf("img%d.png", i)) + "\n\n"
+		}
+		return s
+	}
+
+	c := testCollector(map[string]any{"embeddedImages": "true", "maxEmbeddedImages": 2}, 0)
+	if _, stats := c.Resolve(context.Background(), anchors(c), read); stats != (Stats{Found: 5, Analyzed: 2}) {
+		t.Errorf("cap: stats = %+v", stats)
+	}
+
+	c = testCollector(map[string]any{"embeddedImages": true}, int64(len(img))*2+1)
+	if _, stats := c.Resolve(context.Background(), anchors(c), read); stats != (Stats{Found: 5, Analyzed: 2}) {
+		t.Errorf("budget: stats = %+v", stats)
+	}
+}
+
+func TestDisabledCollector(t *testing.T) {
+	c := testCollector(nil, 0)
+	if c != nil {
+		t.Fatal("collector without the embeddedImages option")
+	}
+	if mark := c.Anchor("a.png"); mark != "" {
+		t.Errorf("nil Anchor = %q", mark)
+	}
+	if got, _ := c.Resolve(context.Background(), "text", nil); got != "text" {
+		t.Errorf("nil Resolve = %q", got)
+	}
+}
@@ -0,0 +1,414 @@
+// Package estimate predicts the provider usage, cost and latency of an
+// extraction without calling any paid provider. It inspects the file locally
+// (PDF text-layer sampling, ffprobe, pdfimages) or, for files too large to
+// fetch, only its size and type.
+package estimate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/embedded"
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/extractor"
+	pdfextractor "github.com/toricodesthings/file-processing-service/internal/extractors/pdf"
+	"github.com/toricodesthings/file-processing-service/internal/failover"
+	"github.com/toricodesthings/file-processing-service/internal/hybrid"
+	"github.com/toricodesthings/file-processing-service/internal/ocr"
+	"github.com/toricodesthings/file-processing-service/internal/transcribe"
+	"github.com/toricodesthings/file-processing-service/internal/types"
+	"github.com/toricodesthings/file-processing-service/internal/usage"
+)
+
+// Probe modes (option "probe").
+const (
+	ProbeAuto     = "auto"     // probe size first, download when small enough
+	ProbeHead     = "head"     // size and type only
+	ProbeDownload = "download" // always download and inspect
+)
+
+// Rough per-unit figures used when the real numbers are unknowable without
+// calling a provider. They are deliberately conservative.
+const (
+	visionInputTokensPerCall  = 1500
+	visionOutputTokensPerCall = 250
+
+	// Latency model (seconds).
+	textLayerSecondsPerPage  = 0.05
+	mistralOCROverhead       = 4.0
+	mistralOCRSecondsPerPage = 0.6
+	localOCRSecondsPerPage   = 3.0
+	visionSecondsPerCall     = 4.0
+	groqOverhead             = 3.0
+	groqRealtimeFactor       = 150.0 // audio seconds transcribed per wall second
+	whisperRealtimeFactor    = 1.0
+
+	// Size-only heuristics for the head probe.
+	headBytesPerScannedPage = 100 << 10
+	headAudioBitsPerSecond  = 128_000
+	headVideoBitsPerSecond  = 1_500_000
+)
+
+// Config holds server settings the estimator needs.
+type Config struct {
+	MaxFileBytes     int64
+	MaxDownloadBytes int64 // auto probe downloads files up to this size
+	DownloadTimeout  time.Duration
+	SamplePages      int // leading PDF pages inspected for text-layer coverage
+	FFprobeBinary    string
+	FFprobeTimeout   time.Duration
+
+	OCRModel        string
+	VisionModel     string
+	GroqModel       string
+	WhisperModel    string
+	MaxFigures      int
+	MinFigurePixels int
+	// Images analysed per Office/ODF/EPUB document with embeddedImages on.
+	MaxEmbeddedImages int
+}
+
+// Result is the /estimate response.
+type Result struct {
+	Success                 bool           `json:"success"`
+	FileType                string         `json:"fileType"`
+	MIMEType                string         `json:"mimeType"`
+	FileSize                int64          `json:"fileSize"`
+	Basis                   string         `json:"basis"` // "download" | "head"
+	TotalPages              int            `json:"totalPages,omitempty"`
+	SampledPages            int            `json:"sampledPages,omitempty"`
+	TextLayerPages          int            `json:"textLayerPages,omitempty"` // within the sample
+	OCRPages                int            `json:"ocrPages"`
+	VisionCalls             int            `json:"visionCalls"`
+	DurationSeconds         float64        `json:"durationSeconds,omitempty"`
+	TranscriptionMinutes    float64        `json:"transcriptionMinutes"`
+	EstimatedCostUSD        float64        `json:"estimatedCostUsd"`
+	EstimatedLatencySeconds float64        `json:"estimatedLatencySeconds"`
+	Usage                   *extract.Usage `json:"usage,omitempty"` // predicted, same shape as extraction usage
+	Notes                   []string       `json:"notes,omitempty"`
+	Error                   *string        `json:"error,omitempty"`
+	Code                    string         `json:"code,omitempty"`
+}
+
+// Estimator predicts extraction cost for a file.
+type Estimator struct {
+	registry  *extract.Registry
+	processor *hybrid.Processor
+	prices    usage.PriceTable
+	cfg       Config
+}
+
+func New(registry *extract.Registry, processor *hybrid.Processor, prices usage.PriceTable, cfg Config) *Estimator {
+	if cfg.SamplePages <= 0 {
+		cfg.SamplePages = 30
+	}
+	if cfg.MaxDownloadBytes <= 0 || cfg.MaxDownloadBytes > cfg.MaxFileBytes {
+		cfg.MaxDownloadBytes = cfg.MaxFileBytes
+	}
+	if strings.TrimSpace(cfg.FFprobeBinary) == "" {
+		cfg.FFprobeBinary = "ffprobe"
+	}
+	if cfg.FFprobeTimeout <= 0 {
+		cfg.FFprobeTimeout = 30 * time.Second
+	}
+	return &Estimator{registry: registry, processor: processor, prices: prices, cfg: cfg}
+}
+
+// plan is the predicted work before pricing.
+type plan struct {
+	ocrPages       int
+	localOCR       bool // pages rendered locally and sent one by one
+	visionCalls    int
+	mediaSeconds   float64
+	textLayerPages int
+}
+
+// Estimate inspects req's file and predicts usage, cost and latency.
+func (e *Estimator) Estimate(ctx context.Context, req extract.UniversalExtractRequest) (Result, error) {
+	fileName := strings.TrimSpace(req.FileName)
+	if fileName == "" {
+		fileName = "input.bin"
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	mode := stringOption(req.Options, "probe", ProbeAuto)
+
+	var res Result
+	var (
+		path  string
+		size  int64
+		mime  string
+		start = time.Now()
+	)
+
+	if mode != ProbeDownload {
+		var err error
+		size, mime, err = extract.Stat(ctx, req.InputURL(), e.cfg.DownloadTimeout)
+		if extract.ErrorCode(err) != "" {
+			return fail(res, err)
+		}
+	}
+	download := mode == ProbeDownload || (mode == ProbeAuto && (size <= 0 || size <= e.cfg.MaxDownloadBytes))
+	var fetchSeconds float64
+	if download {
+		dl, err := extract.DownloadToTemp(ctx, req.InputURL(), fileName, e.cfg.MaxFileBytes, e.cfg.DownloadTimeout)
+		if err != nil {
+			return fail(res, err)
+		}
+		defer dl.Cleanup()
+		path, size, mime = dl.Path, dl.Size, dl.MIMEType
+		fetchSeconds = time.Since(start).Seconds()
+		res.Basis = ProbeDownload
+	} else {
+		if size <= 0 {
+			return fail(res, errors.New("could not determine file size without downloading"))
+		}
+		res.Basis = ProbeHead
+		res.Notes = append(res.Notes, "estimated from file size and type only")
+	}
+	res.FileSize, res.MIMEType = size, mime
+
+	x, err := e.registry.Resolve(mime, ext)
+	if err != nil {
+		res.FileType = "unknown"
+		return fail(res, err)
+	}
+	res.FileType = x.Name()
+	if err := extract.CheckExtractorAllowed(ctx, x.Name()); err != nil {
+		return fail(res, err)
+	}
+	if max := x.MaxFileSize(); max > 0 && size > max {
+		return fail(res, fmt.Errorf("file exceeds %dMB limit for %s", max/(1<<20), x.Name()))
+	}
+
+	var p plan
+	switch x.Name() {
+	case "document/pdf":
+		if path == "" {
+			p.ocrPages = int(math.Ceil(float64(size) / headBytesPerScannedPage))
+			res.TotalPages = p.ocrPages
+			res.Notes = append(res.Notes, "page count assumed from size as a fully scanned document")
+			break
+		}
+		if err := e.planPDF(ctx, path, req.Options, &res, &p); err != nil {
+			return fail(res, err)
+		}
+	case "image":
+		// One classification call, plus OCR when the image turns out to be
+		// text or mixed — assume the worst.
+		p.visionCalls = 1
+		p.ocrPages = 1
+		p.localOCR = true
+	case "media/audio", "media/video":
+		if path != "" {
+			d, err := probeDuration(ctx, e.cfg.FFprobeBinary, path, e.cfg.FFprobeTimeout)
+			if err != nil {
+				return fail(res, err)
+			}
+			p.mediaSeconds = d
+		} else {
+			bps := float64(headAudioBitsPerSecond)
+			if x.Name() == "media/video" {
+				bps = headVideoBitsPerSecond
+			}
+			p.mediaSeconds = float64(size) * 8 / bps
+			res.Notes = append(res.Notes, "duration assumed from size and a typical bitrate")
+		}
+		res.DurationSeconds = round2(p.mediaSeconds)
+	case "document/docx", "document/pptx", "document/opendocument", "document/epub", "document/legacy-office":
+		if !boolOption(req.Options, "embeddedImages", false) || e.cfg.MaxEmbeddedImages <= 0 {
+			res.Notes = append(res.Notes, "native extraction: no paid provider calls")
+			break
+		}
+		limit := min(intOption(req.Options, "maxEmbeddedImages", e.cfg.MaxEmbeddedImages), e.cfg.MaxEmbeddedImages)
+		n := limit
+		switch {
+		case x.Name() == "document/legacy-office":
+			// Images inside a binary .doc/.ppt are only visible after
+			// conversion.
+			res.Notes = append(res.Notes, "embedded images assumed at the cap")
+		case path != "":
+			if count, err := embedded.Count(path); err == nil {
+				n = min(count, limit)
+			} else {
+				res.Notes = append(res.Notes, "embedded image count unavailable; assuming the cap")
+			}
+		default:
+			res.Notes = append(res.Notes, "embedded images assumed at the cap")
+		}
+		p.visionCalls = max(n, 0)
+	default:
+		res.Notes = append(res.Notes, "native extraction: no paid provider calls")
+	}
+
+	res.OCRPages = p.ocrPages
+	res.VisionCalls = p.visionCalls
+	res.TranscriptionMinutes = round2(p.mediaSeconds / 60)
+	res.Usage, res.EstimatedLatencySeconds = e.price(p, req.Options)
+	if res.Usage != nil {
+		res.EstimatedCostUSD = res.Usage.EstimatedCostUSD
+	}
+	res.EstimatedLatencySeconds = round2(res.EstimatedLatencySeconds + fetchSeconds)
+	res.Success = true
+	return res, nil
+}
+
+// planPDF samples the leading pages with ProcessPreview and extrapolates the
+// share that will need OCR, mirroring ProcessHybrid's full-OCR trigger.
+func (e *Estimator) planPDF(ctx context.Context, path string, options map[string]any, res *Result, p *plan) error {
+	opts := e.processor.ApplyDefaults(types.HybridProcessorOptions{})
+	opts.PreviewMaxPages = e.cfg.SamplePages
+	opts.Password = stringOption(options, "password", "")
+
+	prev := e.processor.ProcessPreview(ctx, path, opts)
+	if prev.Error != nil {
+		return extract.WithCode(prev.Code, errors.New(*prev.Error))
+	}
+	res.TotalPages = prev.TotalPages
+	res.SampledPages = prev.SampledPages
+	res.TextLayerPages = prev.TextLayerPages
+	if prev.SampledPages == 0 {
+		return nil
+	}
+
+	ratio := float64(prev.SampledPages-prev.TextLayerPages) / float64(prev.SampledPages)
+	switch {
+	case ratio >= opts.OCRTriggerRatio:
+		p.ocrPages = prev.TotalPages
+	case ratio > 0:
+		p.ocrPages = int(math.Ceil(ratio * float64(prev.TotalPages)))
+	}
+	p.textLayerPages = prev.TotalPages - p.ocrPages
+	p.localOCR = opts.Password != ""
+	if prev.SampledPages < prev.TotalPages {
+		res.Notes = append(res.Notes, fmt.Sprintf("OCR share extrapolated from the first %d of %d pages", prev.SampledPages, prev.TotalPages))
+	}
+
+	if boolOption(options, "figures", false) && p.textLayerPages > 0 {
+		limit := min(intOption(options, "maxFigures", e.cfg.MaxFigures), e.cfg.MaxFigures)
+		cfg := e.processor.ExtractorConfig()
+		cfg.Password = opts.Password
+		images, err := extractor.ListImages(ctx, path, 1, prev.TotalPages, cfg)
+		if err != nil {
+			res.Notes = append(res.Notes, "figure count unavailable")
+			return nil
+		}
+		pages := make(map[int]bool, prev.TotalPages)
+		for i := 1; i <= prev.TotalPages; i++ {
+			pages[i] = true
+		}
+		p.visionCalls = min(pdfextractor.FigureCandidates(images, pages, e.cfg.MinFigurePixels), limit)
+	}
+	return nil
+}
+
+// price turns a plan into predicted usage items, priced with the primary
+// provider of each failover chain, and a latency estimate.
+func (e *Estimator) price(p plan, options map[string]any) (*extract.Usage, float64) {
+	var items []extract.UsageItem
+	latency := float64(p.textLayerPages) * textLayerSecondsPerPage
+
+	if p.ocrPages > 0 {
+		provider := failover.Chain(failover.OCR, ocr.ProviderMistral)[0]
+		it := extract.UsageItem{Capability: usage.OCR, Provider: provider, Pages: p.ocrPages, Calls: 1}
+		switch {
+		case provider == ocr.ProviderTesseract:
+			it.Calls = p.ocrPages
+			latency += float64(p.ocrPages) * localOCRSecondsPerPage / 2
+		case p.localOCR:
+			it.Model = e.cfg.OCRModel
+			it.Calls = p.ocrPages
+			latency += float64(p.ocrPages) * (mistralOCRSecondsPerPage + 1)
+		default:
+			it.Model = e.cfg.OCRModel
+			latency += mistralOCROverhead + float64(p.ocrPages)*mistralOCRSecondsPerPage
+		}
+		items = append(items, it)
+	}
+	if p.visionCalls > 0 {
+		model := failover.Chain(failover.Vision, e.cfg.VisionModel)[0]
+		items = append(items, extract.UsageItem{
+			Capability:   usage.Vision,
+			Provider:     "openrouter",
+			Model:        model,
+			Calls:        p.visionCalls,
+			InputTokens:  p.visionCalls * visionInputTokensPerCall,
+			OutputTokens: p.visionCalls * visionOutputTokensPerCall,
+		})
+		latency += math.Ceil(float64(p.visionCalls)/2) * visionSecondsPerCall
+	}
+	if p.mediaSeconds > 0 {
+		provider := failover.Chain(failover.Transcription, transcribe.ProviderGroq)[0]
+		it := extract.UsageItem{Capability: usage.Transcription, Provider: provider, Calls: 1, Seconds: round2(p.mediaSeconds)}
+		if provider == transcribe.ProviderWhisper {
+			it.Model = e.cfg.WhisperModel
+			latency += p.mediaSeconds / whisperRealtimeFactor
+		} else {
+			it.Model = stringOption(options, "model", e.cfg.GroqModel)
+			latency += groqOverhead + p.mediaSeconds/groqRealtimeFactor
+		}
+		items = append(items, it)
+	}
+
+	if len(items) == 0 {
+		return nil, latency
+	}
+	u := &extract.Usage{Items: items}
+	for i := range items {
+		items[i].CostUSD = e.prices.Cost(items[i])
+		u.OCRPages += items[i].Pages
+		u.VisionInputTokens += items[i].InputTokens
+		u.VisionOutputTokens += items[i].OutputTokens
+		u.TranscriptionSeconds += items[i].Seconds
+		u.EstimatedCostUSD += items[i].CostUSD
+	}
+	u.EstimatedCostUSD = math.Round(u.EstimatedCostUSD*1e6) / 1e6
+	return u, latency
+}
+
+func fail(res Result, err error) (Result, error) {
+	msg := err.Error()
+	res.Success = false
+	res.Error = &msg
+	res.Code = extract.ErrorCode(err)
+	return res, err
+}
+
+func round2(v float64) float64 { return math.Round(v*100) / 100 }
+
+func stringOption(options map[string]any, key, fallback string) string {
+	if options == nil {
+		return fallback
+	}
+	if v, ok := options[key].(string); ok && strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	return fallback
+}
+
+func boolOption(options map[string]any, key string, fallback bool) bool {
+	if options == nil {
+		return fallback
+	}
+	if v, ok := options[key].(bool); ok {
+		return v
+	}
+	return fallback
+}
+
+func intOption(options map[string]any, key string, fallback int) int {
+	if options == nil {
+		return fallback
+	}
+	switch v := options[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return fallback
+}
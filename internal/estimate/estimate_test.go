@@ -0,0 +1,37 @@
+package estimate
+
+import (
+	"testing"
+
+	"github.com/toricodesthings/file-processing-service/internal/usage"
+)
+
+func TestParseDuration(t *testing.T) {
+	d, err := parseDuration("62.500000\n")
+	if err != nil || d != 62.5 {
+		t.Fatalf("got %v, %v", d, err)
+	}
+	if _, err := parseDuration("N/A\n"); err == nil {
+		t.Fatal("expected error for N/A")
+	}
+}
+
+func TestPriceUsesPriceTable(t *testing.T) {
+	e := &Estimator{prices: usage.DefaultPrices, cfg: Config{OCRModel: "mistral-ocr-latest", VisionModel: "m", GroqModel: "g"}}
+	u, latency := e.price(plan{ocrPages: 10, visionCalls: 2, mediaSeconds: 1800}, nil)
+	if u == nil || len(u.Items) != 3 {
+		t.Fatalf("usage = %+v", u)
+	}
+	// 10 pages × $0.001 + 2 × (1500 in, 250 out) tokens + half an audio hour.
+	want := 0.01 + (3000*0.10+500*0.30)/1e6 + 0.02
+	if diff := u.EstimatedCostUSD - want; diff > 1e-6 || diff < -1e-6 {
+		t.Fatalf("cost = %v, want %v", u.EstimatedCostUSD, want)
+	}
+	if latency <= 0 {
+		t.Fatalf("latency = %v", latency)
+	}
+
+	if u, _ := e.price(plan{textLayerPages: 5}, nil); u != nil {
+		t.Fatalf("free plan should have no usage, got %+v", u)
+	}
+}
@@ -0,0 +1,50 @@
+package estimate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
+)
+
+// probeDuration returns the container duration of a media file in seconds.
+func probeDuration(ctx context.Context, binary, path string, timeout time.Duration) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := sandbox.Command(ctx, binary,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=nw=1:nk=1",
+		path,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return 0, fmt.Errorf("ffprobe timed out after %s", timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return 0, fmt.Errorf("ffprobe failed: %v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return parseDuration(string(out))
+}
+
+func parseDuration(out string) (float64, error) {
+	s := strings.TrimSpace(out)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	d, err := strconv.ParseFloat(s, 64)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("ffprobe returned no duration (%q)", s)
+	}
+	return d, nil
+}
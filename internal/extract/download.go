@@ -3,7 +3,6 @@ package extract
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -18,6 +17,9 @@ type DownloadedFile struct {
 	Path     string
 	MIMEType string
 	Size     int64
+	// RemoteURL is an HTTP(S) URL providers can fetch the file from, or ""
+	// (see Fetched.RemoteURL).
+	RemoteURL string
 }
 
 func (d DownloadedFile) Cleanup() {
@@ -26,7 +28,14 @@ func (d DownloadedFile) Cleanup() {
 	}
 }
 
+// DownloadToTemp fetches url from its registered Source into a fresh temp
+// dir. HTTP(S) URLs are subject to the URL policy (see ConfigureURLPolicy).
 func DownloadToTemp(ctx context.Context, url string, fileName string, maxBytes int64, timeout time.Duration) (DownloadedFile, error) {
+	src, u, err := sourceFor(url)
+	if err != nil {
+		return DownloadedFile{}, err
+	}
+
 	tmpDir, err := os.MkdirTemp("", "fileproc-*")
 	if err != nil {
 		return DownloadedFile{}, fmt.Errorf("temp dir: %w", err)
@@ -38,22 +47,6 @@ func DownloadToTemp(ctx context.Context, url string, fileName string, maxBytes i
 	}
 	outPath := filepath.Join(tmpDir, filepath.Base(safeName))
 
-	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
-	req.Header.Set("User-Agent", "fileproc/2.0")
-
-	client := &http.Client{Timeout: timeout}
-	resp, err := client.Do(req)
-	if err != nil {
-		_ = os.RemoveAll(tmpDir)
-		return DownloadedFile{}, fmt.Errorf("download: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		_ = os.RemoveAll(tmpDir)
-		return DownloadedFile{}, fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
-	}
-
 	f, err := os.Create(outPath)
 	if err != nil {
 		_ = os.RemoveAll(tmpDir)
@@ -61,15 +54,11 @@ func DownloadToTemp(ctx context.Context, url string, fileName string, maxBytes i
 	}
 	defer f.Close()
 
-	lr := &io.LimitedReader{R: resp.Body, N: maxBytes + 1}
-	n, err := io.Copy(f, lr)
+	lw := &limitWriter{w: f, n: maxBytes}
+	fetched, err := src.Fetch(ctx, u, lw, timeout)
 	if err != nil {
 		_ = os.RemoveAll(tmpDir)
-		return DownloadedFile{}, fmt.Errorf("write: %w", err)
-	}
-	if n > maxBytes {
-		_ = os.RemoveAll(tmpDir)
-		return DownloadedFile{}, fmt.Errorf("file exceeds %dMB limit", maxBytes/(1<<20))
+		return DownloadedFile{}, sourceError(err, maxBytes)
 	}
 
 	if err := f.Sync(); err != nil {
@@ -77,23 +66,25 @@ func DownloadToTemp(ctx context.Context, url string, fileName string, maxBytes i
 		return DownloadedFile{}, fmt.Errorf("sync: %w", err)
 	}
 
-	mt := sniffMIMEType(outPath)
+	mt := SniffMIMEType(outPath)
 	if mt == "" {
-		mt = strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Type")))
+		mt = strings.ToLower(strings.TrimSpace(fetched.ContentType))
 		if i := strings.Index(mt, ";"); i > 0 {
 			mt = strings.TrimSpace(mt[:i])
 		}
 	}
 
 	return DownloadedFile{
-		TempDir:  tmpDir,
-		Path:     outPath,
-		MIMEType: mt,
-		Size:     n,
+		TempDir:   tmpDir,
+		Path:      outPath,
+		MIMEType:  mt,
+		Size:      maxBytes - lw.n,
+		RemoteURL: fetched.RemoteURL,
 	}, nil
 }
 
-func sniffMIMEType(path string) string {
+// SniffMIMEType detects a file's MIME type from its content.
+func SniffMIMEType(path string) string {
 	m, err := mimetype.DetectFile(path)
 	if err == nil && m != nil {
 		return strings.ToLower(strings.TrimSpace(m.String()))
@@ -0,0 +1,59 @@
+package extract
+
+import "errors"
+
+// Machine-readable error codes returned in Result.Code alongside the
+// human-readable Error message.
+const (
+	CodePasswordRequired  = "password_required"
+	CodePasswordIncorrect = "password_incorrect"
+
+	// CodeProviderUnavailable means an upstream OCR/vision/transcription
+	// provider's circuit breaker is open and the call was not attempted.
+	CodeProviderUnavailable = "provider_unavailable"
+
+	// CodeExtractorNotAllowed means the caller's API key may not use the
+	// extractor the file resolved to.
+	CodeExtractorNotAllowed = "extractor_not_allowed"
+
+	// CodeURLNotAllowed means a URL the service was asked to fetch failed
+	// the download policy (scheme, host allowlist, non-public address or
+	// too many redirects).
+	CodeURLNotAllowed = "url_not_allowed"
+
+	// CodeArchiveLimitsExceeded means a zip-based document (OOXML, ODF,
+	// EPUB) has too many entries, inflates past the size limits or is
+	// compressed suspiciously well.
+	CodeArchiveLimitsExceeded = "archive_limits_exceeded"
+
+	// CodeOutputFailed means extraction succeeded but the result could not
+	// be written to the requested output destination.
+	CodeOutputFailed = "output_failed"
+)
+
+// CodedError attaches an API error code to an underlying error.
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+func (e *CodedError) Error() string { return e.Err.Error() }
+func (e *CodedError) Unwrap() error { return e.Err }
+
+// WithCode wraps err so ErrorCode(err) reports code. A nil err stays nil.
+func WithCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
+
+// ErrorCode returns the code of the outermost CodedError in err's chain, or
+// "" if none is present.
+func ErrorCode(err error) string {
+	var ce *CodedError
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return ""
+}
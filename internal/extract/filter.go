@@ -0,0 +1,24 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+)
+
+type filterKey struct{}
+
+// WithExtractorFilter restricts the extractors usable by requests made with
+// ctx (e.g. to an API key's allowed list). allow receives Extractor.Name().
+func WithExtractorFilter(ctx context.Context, allow func(name string) bool) context.Context {
+	return context.WithValue(ctx, filterKey{}, allow)
+}
+
+// CheckExtractorAllowed returns a CodeExtractorNotAllowed error when ctx
+// carries a filter that rejects name.
+func CheckExtractorAllowed(ctx context.Context, name string) error {
+	allow, _ := ctx.Value(filterKey{}).(func(string) bool)
+	if allow == nil || allow(name) {
+		return nil
+	}
+	return WithCode(CodeExtractorNotAllowed, fmt.Errorf("extractor %s not allowed for this API key", name))
+}
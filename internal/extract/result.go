@@ -1,34 +1,99 @@
 package extract
 
 type Job struct {
+	// PresignedURL is an HTTP(S) URL hosted providers can fetch the file
+	// from; "" when the input has none (data:, file://, attachments) and
+	// extractors must work from LocalPath.
 	PresignedURL string
 	LocalPath    string
 	FileName     string
 	MIMEType     string
 	FileSize     int64
 	Options      map[string]any
+
+	// Depth is 0 for the requested file and increases for files extracted
+	// from inside it (e.g. PDF attachments), to bound recursion.
+	Depth int
 }
 
 type Result struct {
-	Success   bool              `json:"success"`
-	Text      string            `json:"text"`
-	Method    string            `json:"method"`
-	FileType  string            `json:"fileType"`
-	MIMEType  string            `json:"mimeType"`
-	Pages     []PageResult      `json:"pages,omitempty"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
-	WordCount int               `json:"wordCount"`
-	CharCount int               `json:"charCount"`
-	Error     *string           `json:"error,omitempty"`
+	Success     bool              `json:"success"`
+	Text        string            `json:"text"`
+	Method      string            `json:"method"`
+	FileType    string            `json:"fileType"`
+	MIMEType    string            `json:"mimeType"`
+	Pages       []PageResult      `json:"pages,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Outline     []OutlineEntry    `json:"outline,omitempty"`
+	FormFields  []FormFieldEntry  `json:"formFields,omitempty"`
+	Annotations []AnnotationEntry `json:"annotations,omitempty"`
+	Attachments []Attachment      `json:"attachments,omitempty"`
+	Usage       *Usage            `json:"usage,omitempty"`
+	Output      []OutputObject    `json:"output,omitempty"` // set when the result was written to storage instead
+	WordCount   int               `json:"wordCount"`
+	CharCount   int               `json:"charCount"`
+	Error       *string           `json:"error,omitempty"`
+	Code        string            `json:"code,omitempty"` // machine-readable error code (see errors.go)
 }
 
 type PageResult struct {
 	PageNumber int    `json:"pageNumber"`
+	PageLabel  string `json:"pageLabel,omitempty"`
 	Text       string `json:"text"`
 	Method     string `json:"method"`
 	WordCount  int    `json:"wordCount"`
 }
 
+// OutlineEntry is one document bookmark / table-of-contents entry.
+// OutputObject is one object written for the "output" option.
+type OutputObject struct {
+	Format      string `json:"format"`   // md, json or jsonl
+	Location    string `json:"location"` // s3:// URL, or the PUT URL without its query
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	ETag        string `json:"etag,omitempty"`
+	ContentType string `json:"contentType"`
+}
+
+type OutlineEntry struct {
+	Title string `json:"title"`
+	Level int    `json:"level"`
+	Page  int    `json:"page,omitempty"`
+}
+
+// FormFieldEntry is one filled-in form field. Checked is set for checkboxes,
+// radio buttons and signatures.
+type FormFieldEntry struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Value   string `json:"value,omitempty"`
+	Checked *bool  `json:"checked,omitempty"`
+	Page    int    `json:"page,omitempty"`
+}
+
+// AnnotationEntry is one reviewer annotation. MarkedText is the page text
+// under a highlight/underline/strike-out; Comment is the attached note.
+type AnnotationEntry struct {
+	Page       int    `json:"page"`
+	Type       string `json:"type"`
+	Author     string `json:"author,omitempty"`
+	MarkedText string `json:"markedText,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	Modified   string `json:"modified,omitempty"`
+}
+
+// Attachment is a file embedded in the extracted document, with its own
+// extraction result. Skipped is set (and Result omitted) when a limit or an
+// unsupported type prevented extraction.
+type Attachment struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Size        int64   `json:"size"`
+	Page        int     `json:"page,omitempty"`
+	Skipped     string  `json:"skipped,omitempty"`
+	Result      *Result `json:"result,omitempty"`
+}
+
 func BuildCounts(text string) (wordCount int, charCount int) {
 	charCount = len([]rune(text))
 	wordCount = 0
@@ -48,3 +113,27 @@ func BuildCounts(text string) (wordCount int, charCount int) {
 	}
 	return
 }
+
+// Usage summarizes the paid provider work done for one request, including
+// any attachments. EstimatedCostUSD comes from the configured price table.
+type Usage struct {
+	OCRPages             int         `json:"ocrPages"`
+	VisionInputTokens    int         `json:"visionInputTokens"`
+	VisionOutputTokens   int         `json:"visionOutputTokens"`
+	TranscriptionSeconds float64     `json:"transcriptionSeconds"`
+	EstimatedCostUSD     float64     `json:"estimatedCostUsd"`
+	Items                []UsageItem `json:"items,omitempty"`
+}
+
+// UsageItem is the usage of one capability/provider/model combination.
+type UsageItem struct {
+	Capability   string  `json:"capability"` // "ocr" | "vision" | "transcription"
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model,omitempty"`
+	Calls        int     `json:"calls"`
+	Pages        int     `json:"pages,omitempty"`
+	InputTokens  int     `json:"inputTokens,omitempty"`
+	OutputTokens int     `json:"outputTokens,omitempty"`
+	Seconds      float64 `json:"seconds,omitempty"`
+	CostUSD      float64 `json:"costUsd"`
+}
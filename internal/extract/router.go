@@ -20,8 +20,8 @@ func NewRouter(registry *Registry, maxFileBytes int64, downloadTimeout time.Dura
 }
 
 func (r *Router) Extract(ctx context.Context, req UniversalExtractRequest) (Result, error) {
-	if strings.TrimSpace(req.PresignedURL) == "" {
-		return errResult("presignedUrl required"), fmt.Errorf("presignedUrl required")
+	if req.InputURL() == "" {
+		return errResult("presignedUrl or source required"), fmt.Errorf("presignedUrl or source required")
 	}
 
 	fileName := strings.TrimSpace(req.FileName)
@@ -29,9 +29,11 @@ func (r *Router) Extract(ctx context.Context, req UniversalExtractRequest) (Resu
 		fileName = "input.bin"
 	}
 
-	dl, err := DownloadToTemp(ctx, req.PresignedURL, fileName, r.maxFileBytes, r.downloadTimeout)
+	dl, err := DownloadToTemp(ctx, req.InputURL(), fileName, r.maxFileBytes, r.downloadTimeout)
 	if err != nil {
-		return errResult(err.Error()), err
+		res := errResult(err.Error())
+		res.Code = ErrorCode(err)
+		return res, err
 	}
 	defer dl.Cleanup()
 
@@ -42,13 +44,18 @@ func (r *Router) Extract(ctx context.Context, req UniversalExtractRequest) (Resu
 		return Result{Success: false, MIMEType: dl.MIMEType, FileType: "unknown", Error: &msg}, err
 	}
 
+	if err := CheckExtractorAllowed(ctx, extractor.Name()); err != nil {
+		msg := err.Error()
+		return Result{Success: false, MIMEType: dl.MIMEType, FileType: extractor.Name(), Error: &msg, Code: CodeExtractorNotAllowed}, err
+	}
+
 	if max := extractor.MaxFileSize(); max > 0 && dl.Size > max {
 		msg := fmt.Sprintf("file exceeds extractor limit (%dMB)", max/(1<<20))
 		return Result{Success: false, MIMEType: dl.MIMEType, FileType: extractor.Name(), Error: &msg}, errors.New(msg)
 	}
 
 	job := Job{
-		PresignedURL: req.PresignedURL,
+		PresignedURL: dl.RemoteURL,
 		LocalPath:    dl.Path,
 		FileName:     fileName,
 		MIMEType:     dl.MIMEType,
@@ -62,6 +69,9 @@ func (r *Router) Extract(ctx context.Context, req UniversalExtractRequest) (Resu
 			msg := err.Error()
 			res.Error = &msg
 		}
+		if res.Code == "" {
+			res.Code = ErrorCode(err)
+		}
 		res.Success = false
 		if res.MIMEType == "" {
 			res.MIMEType = dl.MIMEType
@@ -80,9 +90,20 @@ func (r *Router) Extract(ctx context.Context, req UniversalExtractRequest) (Resu
 }
 
 type UniversalExtractRequest struct {
-	PresignedURL string         `json:"presignedUrl"`
-	FileName     string         `json:"fileName"`
-	Options      map[string]any `json:"options"`
+	PresignedURL string `json:"presignedUrl"`
+	// Source is any registered source URL (https://, s3://, file://, data:).
+	// It takes precedence over PresignedURL.
+	Source   string         `json:"source,omitempty"`
+	FileName string         `json:"fileName"`
+	Options  map[string]any `json:"options"`
+}
+
+// InputURL returns the URL the input file is fetched from.
+func (r UniversalExtractRequest) InputURL() string {
+	if s := strings.TrimSpace(r.Source); s != "" {
+		return s
+	}
+	return strings.TrimSpace(r.PresignedURL)
 }
 
 func errResult(message string) Result {
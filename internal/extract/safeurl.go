@@ -0,0 +1,203 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrURLNotAllowed is wrapped by every URL policy rejection.
+var ErrURLNotAllowed = errors.New("url not allowed")
+
+// URLPolicy restricts which URLs the service fetches on a caller's behalf,
+// so a presigned URL cannot be pointed at cloud metadata endpoints or
+// internal services.
+type URLPolicy struct {
+	Schemes []string // allowed schemes; default https and http
+	// Hosts are exact hostnames or "*.suffix" patterns. Empty allows any
+	// host that resolves to a public address.
+	Hosts        []string
+	AllowPrivate bool // permit loopback/private/link-local targets (local development)
+	MaxRedirects int
+}
+
+var (
+	policyMu sync.RWMutex
+	policy   = URLPolicy{Schemes: []string{"https", "http"}, MaxRedirects: 3}
+)
+
+// ConfigureURLPolicy replaces the process-wide policy used by SafeClient and
+// CheckURL.
+func ConfigureURLPolicy(p URLPolicy) {
+	if len(p.Schemes) == 0 {
+		p.Schemes = []string{"https", "http"}
+	}
+	if p.MaxRedirects < 0 {
+		p.MaxRedirects = 0
+	}
+	policyMu.Lock()
+	policy = p
+	policyMu.Unlock()
+}
+
+func currentPolicy() URLPolicy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return policy
+}
+
+func notAllowed(format string, args ...any) error {
+	return WithCode(CodeURLNotAllowed, fmt.Errorf("%w: "+format, append([]any{ErrURLNotAllowed}, args...)...))
+}
+
+// CheckURL validates scheme and host against the policy and resolves the
+// host, rejecting it if any address is non-public. SafeClient repeats the
+// address check at dial time, which is what actually defeats DNS rebinding;
+// this early check gives callers a clear error before any connection.
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return notAllowed("unparseable URL")
+	}
+	return checkURL(ctx, currentPolicy(), u)
+}
+
+func checkURL(ctx context.Context, p URLPolicy, u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !containsFold(p.Schemes, scheme) {
+		return notAllowed("scheme %q", u.Scheme)
+	}
+	if u.User != nil {
+		return notAllowed("credentials in URL")
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return notAllowed("missing host")
+	}
+	if len(p.Hosts) > 0 && !hostMatches(p.Hosts, host) {
+		return notAllowed("host %q", host)
+	}
+	if p.AllowPrivate {
+		return nil
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if blockedAddr(addr) {
+			return notAllowed("address %s", addr)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, a := range addrs {
+		if blockedAddr(a) {
+			return notAllowed("host %q resolves to %s", host, a.Unmap())
+		}
+	}
+	return nil
+}
+
+func hostMatches(patterns []string, host string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if suffix, ok := strings.CutPrefix(p, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if p == host {
+			return true
+		}
+	}
+	return false
+}
+
+// Ranges IsPrivate/IsLoopback/etc. do not cover.
+var extraBlocked = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, incl. broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, can embed private IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("2002::/16"),      // 6to4, can embed private IPv4
+	netip.MustParsePrefix("fec0::/10"),      // deprecated site-local
+}
+
+func blockedAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	if !a.IsValid() || a.IsLoopback() || a.IsPrivate() || a.IsLinkLocalUnicast() ||
+		a.IsLinkLocalMulticast() || a.IsInterfaceLocalMulticast() || a.IsMulticast() || a.IsUnspecified() {
+		return true
+	}
+	for _, p := range extraBlocked {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// SafeClient returns an HTTP client that enforces the URL policy on every
+// redirect hop and refuses to connect to non-public addresses, whatever DNS
+// returned. Environment proxies are ignored since they would hide the
+// destination address.
+func SafeClient(timeout time.Duration) *http.Client {
+	p := currentPolicy()
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if p.AllowPrivate {
+				return nil
+			}
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return notAllowed("dial %s", address)
+			}
+			if blockedAddr(ap.Addr()) {
+				return notAllowed("address %s", ap.Addr().Unmap())
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: timeout,
+		DisableKeepAlives:     true, // one fetch per client
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > p.MaxRedirects {
+				return notAllowed("more than %d redirects", p.MaxRedirects)
+			}
+			return checkURL(req.Context(), p, req.URL)
+		},
+	}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestBlockedAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"169.254.169.254":  true, // cloud metadata
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"100.64.0.1":       true,
+		"::1":              true,
+		"::ffff:127.0.0.1": true,
+		"fd00::1":          true,
+		"8.8.8.8":          false,
+		"2606:4700::1111":  false,
+	} {
+		if got := blockedAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("blockedAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckURLPolicy(t *testing.T) {
+	p := URLPolicy{Schemes: []string{"https"}, Hosts: []string{"*.r2.cloudflarestorage.com", "files.example.com"}, AllowPrivate: true}
+	ConfigureURLPolicy(p)
+	defer ConfigureURLPolicy(URLPolicy{MaxRedirects: 3})
+
+	ctx := context.Background()
+	for raw, ok := range map[string]bool{
+		"https://acct.r2.cloudflarestorage.com/b/k?X-Amz-Signature=x": true,
+		"https://files.example.com/a.pdf":                             true,
+		"http://files.example.com/a.pdf":                              false,
+		"https://r2.cloudflarestorage.com.evil.com/a.pdf":             false,
+		"https://user:pw@files.example.com/a.pdf":                     false,
+		"file:///etc/passwd":                                          false,
+	} {
+		err := CheckURL(ctx, raw)
+		if ok && err != nil {
+			t.Errorf("%s: unexpected error %v", raw, err)
+		}
+		if !ok && ErrorCode(err) != CodeURLNotAllowed {
+			t.Errorf("%s: want url_not_allowed, got %v", raw, err)
+		}
+	}
+}
+
+func TestDownloadBlocksPrivateTargets(t *testing.T) {
+	ConfigureURLPolicy(URLPolicy{MaxRedirects: 3})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+
+	_, err := DownloadToTemp(context.Background(), srv.URL, "a.txt", 1<<20, 5*time.Second)
+	if !errors.Is(err, ErrURLNotAllowed) || ErrorCode(err) != CodeURLNotAllowed {
+		t.Fatalf("loopback download: want url_not_allowed, got %v", err)
+	}
+
+	// The dial-time check holds even when the pre-flight check is skipped,
+	// as it would be if DNS changed between the two.
+	resp, err := SafeClient(5 * time.Second).Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if ErrorCode(err) != CodeURLNotAllowed {
+		t.Fatalf("dial: want url_not_allowed, got %v", err)
+	}
+}
+
+func TestRedirectCap(t *testing.T) {
+	ConfigureURLPolicy(URLPolicy{AllowPrivate: true, MaxRedirects: 2})
+	defer ConfigureURLPolicy(URLPolicy{MaxRedirects: 3})
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, srv.URL+"/next", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	_, err := DownloadToTemp(context.Background(), srv.URL, "a.txt", 1<<20, 5*time.Second)
+	if ErrorCode(err) != CodeURLNotAllowed {
+		t.Fatalf("want url_not_allowed after redirect cap, got %v", err)
+	}
+}
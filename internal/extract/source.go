@@ -0,0 +1,118 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source fetches input files for one or more URL schemes. HTTP(S) and data:
+// sources are always registered; s3:// and file:// are added at startup
+// when configured.
+type Source interface {
+	Schemes() []string
+	// Fetch copies the object at u into dst. dst fails once the request's
+	// size limit is passed, so sources need not enforce it themselves.
+	Fetch(ctx context.Context, u *url.URL, dst io.Writer, timeout time.Duration) (Fetched, error)
+}
+
+// Stater is implemented by sources that can report an object's size and
+// type without fetching it.
+type Stater interface {
+	Stat(ctx context.Context, u *url.URL, timeout time.Duration) (size int64, contentType string, err error)
+}
+
+// Fetched describes a fetched object.
+type Fetched struct {
+	ContentType string // as reported by the source; the content is sniffed too
+	// RemoteURL is an HTTP(S) URL hosted providers (Mistral document OCR,
+	// vision) can fetch the same object from, or "" when there is none and
+	// the local copy must be used.
+	RemoteURL string
+}
+
+// ErrStatUnsupported is returned by Stat for sources that cannot report an
+// object's size without fetching it.
+var ErrStatUnsupported = errors.New("source cannot report size without fetching")
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = map[string]Source{}
+)
+
+func init() {
+	RegisterSource(HTTPSource{})
+	RegisterSource(DataSource{MaxBytes: 1 << 20})
+}
+
+// RegisterSource installs s for its schemes, replacing any earlier source.
+func RegisterSource(s Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	for _, scheme := range s.Schemes() {
+		sources[strings.ToLower(scheme)] = s
+	}
+}
+
+func sourceFor(raw string) (Source, *url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		// data: URIs routinely contain characters url.Parse rejects.
+		if scheme, _, ok := strings.Cut(raw, ":"); ok && strings.EqualFold(scheme, "data") {
+			u = &url.URL{Scheme: "data", Opaque: raw[len(scheme)+1:]}
+		} else {
+			return nil, nil, notAllowed("unparseable URL")
+		}
+	}
+	sourcesMu.RLock()
+	s := sources[strings.ToLower(u.Scheme)]
+	sourcesMu.RUnlock()
+	if s == nil {
+		return nil, nil, notAllowed("unsupported source scheme %q", u.Scheme)
+	}
+	return s, u, nil
+}
+
+// Stat reports an object's size and content type without fetching it, or
+// ErrStatUnsupported.
+func Stat(ctx context.Context, raw string, timeout time.Duration) (int64, string, error) {
+	s, u, err := sourceFor(raw)
+	if err != nil {
+		return 0, "", err
+	}
+	st, ok := s.(Stater)
+	if !ok {
+		return 0, "", ErrStatUnsupported
+	}
+	return st.Stat(ctx, u, timeout)
+}
+
+var errTooLarge = errors.New("size limit exceeded")
+
+// limitWriter fails once more than n bytes have been written.
+type limitWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.n {
+		return 0, errTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+func sourceError(err error, maxBytes int64) error {
+	if errors.Is(err, errTooLarge) {
+		return fmt.Errorf("file exceeds %dMB limit", maxBytes/(1<<20))
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package extract
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DataSource decodes RFC 2397 data: URIs, for inputs small enough to send
+// inline in the request body.
+type DataSource struct {
+	MaxBytes int64 // decoded size cap, independent of the file size limit
+}
+
+func (DataSource) Schemes() []string { return []string{"data"} }
+
+func (d DataSource) Fetch(ctx context.Context, u *url.URL, dst io.Writer, timeout time.Duration) (Fetched, error) {
+	mediaType, data, err := decodeDataURI(u.Opaque)
+	if err != nil {
+		return Fetched{}, err
+	}
+	if d.MaxBytes > 0 && int64(len(data)) > d.MaxBytes {
+		return Fetched{}, fmt.Errorf("data URI exceeds %d bytes", d.MaxBytes)
+	}
+	if _, err := dst.Write(data); err != nil {
+		return Fetched{}, err
+	}
+	return Fetched{ContentType: mediaType}, nil
+}
+
+func (d DataSource) Stat(ctx context.Context, u *url.URL, timeout time.Duration) (int64, string, error) {
+	mediaType, data, err := decodeDataURI(u.Opaque)
+	if err != nil {
+		return 0, "", err
+	}
+	return int64(len(data)), mediaType, nil
+}
+
+// decodeDataURI parses the part after "data:": [<mediatype>][;base64],<data>.
+func decodeDataURI(opaque string) (string, []byte, error) {
+	meta, payload, ok := strings.Cut(opaque, ",")
+	if !ok {
+		return "", nil, fmt.Errorf("malformed data URI")
+	}
+	isBase64 := false
+	if m, ok := strings.CutSuffix(meta, ";base64"); ok {
+		meta, isBase64 = m, true
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(meta))
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = mediaType[:i]
+	}
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+
+	if isBase64 {
+		// Tolerate URL-safe and unpadded encodings some clients produce.
+		payload = strings.NewReplacer("-", "+", "_", "/", "\n", "", "\r", "", " ", "").Replace(payload)
+		data, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+		if err != nil {
+			return "", nil, fmt.Errorf("data URI: %w", err)
+		}
+		return mediaType, data, nil
+	}
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("data URI: %w", err)
+	}
+	return mediaType, []byte(data), nil
+}
@@ -0,0 +1,99 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSource reads file:// URLs from the local filesystem, confined to Root
+// (after resolving symlinks). It is meant for development and for sidecar
+// deployments that share a volume with the service.
+type FileSource struct {
+	Root string
+}
+
+func (FileSource) Schemes() []string { return []string{"file"} }
+
+func (s FileSource) Fetch(ctx context.Context, u *url.URL, dst io.Writer, timeout time.Duration) (Fetched, error) {
+	path, err := s.resolve(u)
+	if err != nil {
+		return Fetched{}, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Fetched{}, fmt.Errorf("open: %w", errors.Unwrap(err))
+	}
+	defer f.Close()
+	if _, err := io.Copy(dst, f); err != nil {
+		return Fetched{}, fmt.Errorf("read: %w", err)
+	}
+	return Fetched{}, nil
+}
+
+func (s FileSource) Stat(ctx context.Context, u *url.URL, timeout time.Duration) (int64, string, error) {
+	path, err := s.resolve(u)
+	if err != nil {
+		return 0, "", err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("stat: %w", errors.Unwrap(err))
+	}
+	return fi.Size(), "", nil
+}
+
+// resolve maps u to a regular file inside Root. The path is checked against
+// Root before the filesystem is touched, and errors never echo the resolved
+// path, so callers cannot probe the filesystem outside Root.
+func (s FileSource) resolve(u *url.URL) (string, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return "", notAllowed("file URL host %q", u.Host)
+	}
+	outside := notAllowed("file path outside the allowed directory")
+	root, err := filepath.Abs(s.Root)
+	if err != nil {
+		return "", notAllowed("file source root unavailable")
+	}
+	// Root itself may be a symlink, so a path is accepted under either form.
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", notAllowed("file source root unavailable")
+	}
+
+	path := filepath.Clean(u.Path)
+	if !filepath.IsAbs(path) || (!within(root, path) && !within(realRoot, path)) {
+		return "", outside
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("file not found")
+		}
+		return "", notAllowed("file path")
+	}
+	// Symlinks inside Root must not lead out of it.
+	if !within(realRoot, path) {
+		return "", outside
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("file not found")
+	}
+	if !fi.Mode().IsRegular() {
+		return "", notAllowed("not a regular file")
+	}
+	return path, nil
+}
+
+// within reports whether path is root or below it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
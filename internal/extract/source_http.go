@@ -0,0 +1,88 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPSource fetches http:// and https:// URLs (typically presigned) with
+// SafeClient, so the URL policy applies.
+type HTTPSource struct{}
+
+func (HTTPSource) Schemes() []string { return []string{"http", "https"} }
+
+func (HTTPSource) Fetch(ctx context.Context, u *url.URL, dst io.Writer, timeout time.Duration) (Fetched, error) {
+	raw := u.String()
+	if err := CheckURL(ctx, raw); err != nil {
+		return Fetched{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", raw, nil)
+	if err != nil {
+		return Fetched{}, fmt.Errorf("download: %w", err)
+	}
+	req.Header.Set("User-Agent", "fileproc/2.0")
+
+	resp, err := SafeClient(timeout).Do(req)
+	if err != nil {
+		return Fetched{}, fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Fetched{}, fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+	}
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		return Fetched{}, fmt.Errorf("write: %w", err)
+	}
+	return Fetched{ContentType: resp.Header.Get("Content-Type"), RemoteURL: raw}, nil
+}
+
+// Stat uses a one-byte ranged GET rather than HEAD because presigned URLs
+// are signed for a single method.
+func (HTTPSource) Stat(ctx context.Context, u *url.URL, timeout time.Duration) (int64, string, error) {
+	raw := u.String()
+	if err := CheckURL(ctx, raw); err != nil {
+		return 0, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	req.Header.Set("User-Agent", "fileproc/2.0")
+
+	resp, err := SafeClient(timeout).Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	mime := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Type")))
+	if i := strings.Index(mime, ";"); i > 0 {
+		mime = strings.TrimSpace(mime[:i])
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345
+		cr := resp.Header.Get("Content-Range")
+		if i := strings.LastIndex(cr, "/"); i >= 0 {
+			if n, err := strconv.ParseInt(strings.TrimSpace(cr[i+1:]), 10, 64); err == nil {
+				return n, mime, nil
+			}
+		}
+	case http.StatusOK:
+		if resp.ContentLength >= 0 {
+			return resp.ContentLength, mime, nil
+		}
+	default:
+		return 0, mime, fmt.Errorf("probe failed: HTTP %d", resp.StatusCode)
+	}
+	return 0, mime, fmt.Errorf("probe: size unknown")
+}
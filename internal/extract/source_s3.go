@@ -0,0 +1,88 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/s3"
+)
+
+// S3Source reads s3://bucket/key from an S3-compatible store (AWS, R2,
+// MinIO) with the service's own credentials.
+type S3Source struct {
+	Client *s3.Client
+	// Buckets lists the buckets callers may name. Reads are refused when it
+	// is empty, so the service's credentials never expose every bucket they
+	// can read.
+	Buckets []string
+	// PresignTTL, when positive, gives hosted providers a presigned GET URL
+	// for the object instead of falling back to local processing. Leave it
+	// zero when the store is not reachable from the internet.
+	PresignTTL time.Duration
+}
+
+func (S3Source) Schemes() []string { return []string{"s3"} }
+
+func (s S3Source) Fetch(ctx context.Context, u *url.URL, dst io.Writer, timeout time.Duration) (Fetched, error) {
+	bucket, key, err := s.object(u)
+	if err != nil {
+		return Fetched{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, info, err := s.Client.Get(ctx, bucket, key)
+	if err != nil {
+		return Fetched{}, s3Error(err)
+	}
+	defer body.Close()
+	if _, err := io.Copy(dst, body); err != nil {
+		return Fetched{}, fmt.Errorf("write: %w", err)
+	}
+
+	f := Fetched{ContentType: info.ContentType}
+	if s.PresignTTL > 0 {
+		f.RemoteURL = s.Client.PresignGet(bucket, key, s.PresignTTL)
+	}
+	return f, nil
+}
+
+func (s S3Source) Stat(ctx context.Context, u *url.URL, timeout time.Duration) (int64, string, error) {
+	bucket, key, err := s.object(u)
+	if err != nil {
+		return 0, "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	info, err := s.Client.Head(ctx, bucket, key)
+	if err != nil {
+		return 0, "", s3Error(err)
+	}
+	return info.Size, info.ContentType, nil
+}
+
+func (s S3Source) object(u *url.URL) (bucket, key string, err error) {
+	bucket, key = u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("s3 URL must be s3://bucket/key")
+	}
+	if !slices.Contains(s.Buckets, bucket) {
+		return "", "", notAllowed("bucket %q", bucket)
+	}
+	return bucket, key, nil
+}
+
+func s3Error(err error) error {
+	var se *s3.Error
+	if errors.As(err, &se) && se.Status == http.StatusNotFound {
+		return fmt.Errorf("download failed: object not found")
+	}
+	return fmt.Errorf("download: %w", err)
+}
@@ -0,0 +1,113 @@
+package extract
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHTTPSourceStatRangedGet(t *testing.T) {
+	ConfigureURLPolicy(URLPolicy{AllowPrivate: true, MaxRedirects: 3})
+	defer ConfigureURLPolicy(URLPolicy{MaxRedirects: 3})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Range") != "bytes=0-0" {
+			t.Errorf("unexpected probe %s range=%q", r.Method, r.Header.Get("Range"))
+		}
+		w.Header().Set("Content-Type", "application/pdf; qs=1")
+		w.Header().Set("Content-Range", "bytes 0-0/123456")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("%"))
+	}))
+	defer srv.Close()
+
+	size, mime, err := Stat(context.Background(), srv.URL, 5*time.Second)
+	if err != nil || size != 123456 || mime != "application/pdf" {
+		t.Fatalf("got size=%d mime=%q err=%v", size, mime, err)
+	}
+}
+
+func TestDataSource(t *testing.T) {
+	ctx := context.Background()
+	for raw, want := range map[string]string{
+		"data:text/plain;base64,aGVsbG8gd29ybGQ=": "hello world",
+		"data:,hello%20world":                     "hello world",
+		"data:text/csv;charset=utf-8,a,b%0A1,2":   "a,b\n1,2",
+	} {
+		dl, err := DownloadToTemp(ctx, raw, "in.txt", 1<<20, time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", raw, err)
+		}
+		b, _ := os.ReadFile(dl.Path)
+		dl.Cleanup()
+		if string(b) != want {
+			t.Errorf("%s: got %q, want %q", raw, b, want)
+		}
+		if dl.RemoteURL != "" {
+			t.Errorf("%s: data URIs have no remote URL", raw)
+		}
+	}
+
+	if _, err := DownloadToTemp(ctx, "data:text/plain,0123456789", "in.txt", 5, time.Second); err == nil {
+		t.Fatal("expected size limit error")
+	}
+}
+
+func TestFileSourceConfinedToRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(root, "ok.txt"), []byte("inside"), 0o600)
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o600)
+	os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt"))
+
+	RegisterSource(FileSource{Root: root})
+	defer func() {
+		sourcesMu.Lock()
+		delete(sources, "file")
+		sourcesMu.Unlock()
+	}()
+
+	ctx := context.Background()
+	dl, err := DownloadToTemp(ctx, "file://"+filepath.Join(root, "ok.txt"), "ok.txt", 1<<20, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl.Cleanup()
+
+	for _, raw := range []string{
+		"file://" + filepath.Join(outside, "secret.txt"),
+		"file://" + filepath.Join(outside, "missing.txt"),
+		"file://" + filepath.Join(root, "..", filepath.Base(outside), "secret.txt"),
+		"file://" + filepath.Join(root, "link.txt"),
+		"file://evil.example.com" + filepath.Join(root, "ok.txt"),
+	} {
+		if _, err := DownloadToTemp(ctx, raw, "x.txt", 1<<20, time.Second); ErrorCode(err) != CodeURLNotAllowed {
+			t.Errorf("%s: want url_not_allowed, got %v", raw, err)
+		}
+	}
+}
+
+func TestUnknownSchemeNotAllowed(t *testing.T) {
+	_, err := DownloadToTemp(context.Background(), "gopher://example.com/x", "x", 1<<20, time.Second)
+	if ErrorCode(err) != CodeURLNotAllowed {
+		t.Fatalf("want url_not_allowed, got %v", err)
+	}
+}
+
+func TestS3SourceBucketsDefaultDeny(t *testing.T) {
+	u, _ := url.Parse("s3://tenant-a/report.pdf")
+	if _, _, err := (S3Source{}).object(u); ErrorCode(err) != CodeURLNotAllowed {
+		t.Fatalf("empty list: want url_not_allowed, got %v", err)
+	}
+	if _, _, err := (S3Source{Buckets: []string{"tenant-b"}}).object(u); ErrorCode(err) != CodeURLNotAllowed {
+		t.Fatalf("unlisted bucket: want url_not_allowed, got %v", err)
+	}
+	if b, k, err := (S3Source{Buckets: []string{"tenant-a"}}).object(u); err != nil || b != "tenant-a" || k != "report.pdf" {
+		t.Fatalf("listed bucket: got %q %q %v", b, k, err)
+	}
+}
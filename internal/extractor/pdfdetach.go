@@ -0,0 +1,79 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
+)
+
+// EmbeddedFile is one entry of `pdfdetach -list`. Index is the 1-based number
+// accepted by `pdfdetach -save`.
+type EmbeddedFile struct {
+	Index int
+	Name  string
+}
+
+var detachListRegex = regexp.MustCompile(`^\s*(\d+):\s+(.+)$`)
+
+// ListEmbeddedFiles enumerates the embedded files of a PDF (attachments and
+// portfolio members) with pdfdetach.
+func ListEmbeddedFiles(ctx context.Context, pdfPath string, cfg ExtractorConfig) ([]EmbeddedFile, error) {
+	cfg = cfg.withDefaults()
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.PDFDetachTimeout)
+	defer cancel()
+
+	args := []string{"-list", "-enc", "UTF-8"}
+	args = append(args, cfg.passwordArgs()...)
+	cmd := sandbox.Command(ctx, "pdfdetach", append(args, pdfPath)...)
+
+	out, stderrStr, err := runCommandCaptureLimited(ctx, cmd, 1<<20)
+	if err != nil {
+		return nil, classifyPopplerErr("pdfdetach", err, ctx, stderrStr)
+	}
+
+	var files []EmbeddedFile
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		m := detachListRegex.FindStringSubmatch(sc.Text())
+		if m == nil {
+			continue
+		}
+		idx, _ := strconv.Atoi(m[1])
+		files = append(files, EmbeddedFile{Index: idx, Name: strings.TrimSpace(m[2])})
+	}
+	return files, nil
+}
+
+// SaveEmbeddedFile writes embedded file number index to outPath.
+func SaveEmbeddedFile(ctx context.Context, pdfPath string, index int, outPath string, cfg ExtractorConfig) error {
+	cfg = cfg.withDefaults()
+
+	if index < 1 {
+		return fmt.Errorf("invalid embedded file index: %d (must be >= 1)", index)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.PDFDetachTimeout)
+	defer cancel()
+
+	args := []string{"-save", strconv.Itoa(index), "-o", outPath}
+	args = append(args, cfg.passwordArgs()...)
+	cmd := sandbox.Command(ctx, "pdfdetach", append(args, pdfPath)...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return classifyPopplerErr("pdfdetach", err, ctx, stderr.String())
+	}
+	if _, err := os.Stat(outPath); err != nil {
+		return fmt.Errorf("pdfdetach produced no output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
+)
+
+// PDFImage is one row of `pdfimages -list`. Num is the document-wide image
+// number used in extracted file names; Object is the image XObject number,
+// shared by every placement of the same image.
+type PDFImage struct {
+	Page   int
+	Num    int
+	Type   string // image, mask, smask, stencil
+	Width  int
+	Height int
+	Enc    string // jpeg, image, jbig2, jpx, ccitt
+	Object int
+}
+
+// ListImages lists the images placed on pages first..last.
+func ListImages(ctx context.Context, pdfPath string, first, last int, cfg ExtractorConfig) ([]PDFImage, error) {
+	cfg = cfg.withDefaults()
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.PDFImagesTimeout)
+	defer cancel()
+
+	args := []string{"-list", "-f", strconv.Itoa(first), "-l", strconv.Itoa(last)}
+	args = append(args, cfg.passwordArgs()...)
+	cmd := sandbox.Command(ctx, "pdfimages", append(args, pdfPath)...)
+
+	out, stderrStr, err := runCommandCaptureLimited(ctx, cmd, 8<<20)
+	if err != nil {
+		return nil, classifyPopplerErr("pdfimages", err, ctx, stderrStr)
+	}
+
+	var images []PDFImage
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 12 {
+			continue
+		}
+		page, err1 := strconv.Atoi(f[0])
+		num, err2 := strconv.Atoi(f[1])
+		if err1 != nil || err2 != nil {
+			continue // header / separator
+		}
+		w, _ := strconv.Atoi(f[3])
+		h, _ := strconv.Atoi(f[4])
+		obj, _ := strconv.Atoi(f[10])
+		images = append(images, PDFImage{Page: page, Num: num, Type: f[2], Width: w, Height: h, Enc: f[8], Object: obj})
+	}
+	return images, nil
+}
+
+// ExtractPageImages writes the images of one page as outPrefix-PPP-NNN.png
+// (or .jpg for JPEG-encoded images) and returns a map from image number to
+// file path.
+func ExtractPageImages(ctx context.Context, pdfPath string, page int, outPrefix string, cfg ExtractorConfig) (map[int]string, error) {
+	cfg = cfg.withDefaults()
+
+	if page < 1 {
+		return nil, fmt.Errorf("invalid page number: %d (must be >= 1)", page)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.PDFImagesTimeout)
+	defer cancel()
+
+	args := []string{"-png", "-j", "-p", "-f", strconv.Itoa(page), "-l", strconv.Itoa(page)}
+	args = append(args, cfg.passwordArgs()...)
+	cmd := sandbox.Command(ctx, "pdfimages", append(args, pdfPath, outPrefix)...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, classifyPopplerErr("pdfimages", err, ctx, stderr.String())
+	}
+
+	matches, err := filepath.Glob(outPrefix + "-*-*.*")
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[int]string, len(matches))
+	for _, m := range matches {
+		base := strings.TrimSuffix(filepath.Base(m), filepath.Ext(m))
+		i := strings.LastIndexByte(base, '-')
+		if i < 0 {
+			continue
+		}
+		if num, err := strconv.Atoi(base[i+1:]); err == nil {
+			files[num] = m
+		}
+	}
+	return files, nil
+}
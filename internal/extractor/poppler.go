@@ -8,19 +8,31 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
 )
 
 type ExtractorConfig struct {
 	PDFInfoTimeout      time.Duration
 	PDFToTextTimeout    time.Duration
 	PDFToTextAllTimeout time.Duration
+	PDFToPPMTimeout     time.Duration
+	PDFDetachTimeout    time.Duration
+	PDFImagesTimeout    time.Duration
+
+	// Password opens encrypted PDFs (tried as both owner and user password).
+	// It is passed only on the poppler command line and never logged.
+	Password string
 }
 
+// ErrPasswordProtected is returned when poppler rejects the (missing or
+// wrong) password of an encrypted PDF.
+var ErrPasswordProtected = errors.New("PDF is password protected")
+
 // Sensible defaults if you pass zeros.
 func (c ExtractorConfig) withDefaults() ExtractorConfig {
 	out := c
@@ -33,28 +45,61 @@ func (c ExtractorConfig) withDefaults() ExtractorConfig {
 	if out.PDFToTextAllTimeout <= 0 {
 		out.PDFToTextAllTimeout = 30 * time.Second
 	}
+	if out.PDFToPPMTimeout <= 0 {
+		out.PDFToPPMTimeout = 30 * time.Second
+	}
+	if out.PDFDetachTimeout <= 0 {
+		out.PDFDetachTimeout = 30 * time.Second
+	}
+	if out.PDFImagesTimeout <= 0 {
+		out.PDFImagesTimeout = 30 * time.Second
+	}
 	return out
 }
 
+// passwordArgs returns the poppler flags for the configured password.
+func (c ExtractorConfig) passwordArgs() []string {
+	if c.Password == "" {
+		return nil
+	}
+	return []string{"-opw", c.Password, "-upw", c.Password}
+}
+
 type PDFInfo struct {
 	Pages     int
 	Encrypted bool
 	Raw       string // full pdfinfo stdout (for debugging if needed)
+
+	// Document information dictionary and file properties.
+	Title        string
+	Author       string
+	Subject      string
+	Keywords     string
+	Creator      string
+	Producer     string
+	CreationDate string // ISO 8601 (pdfinfo -isodates)
+	ModDate      string
+	PDFVersion   string
+	PageSize     string // e.g. "612 x 792 pts (letter)"
+	Tagged       bool
+	Linearized   bool
 }
 
 var (
 	pageCountRegex = regexp.MustCompile(`(?m)^Pages:\s+(\d+)\s*$`)
-	encryptedRegex = regexp.MustCompile(`(?mi)^Encrypted:\s+yes\s*$`)
+	encryptedRegex = regexp.MustCompile(`(?mi)^Encrypted:\s+yes\b`)
 )
 
-// GetPDFInfo runs pdfinfo once and extracts page count + encryption flag.
+// GetPDFInfo runs pdfinfo once and extracts page count, encryption flag and
+// document properties.
 func GetPDFInfo(ctx context.Context, pdfPath string, cfg ExtractorConfig) (PDFInfo, error) {
 	cfg = cfg.withDefaults()
 
 	ctx, cancel := context.WithTimeout(ctx, cfg.PDFInfoTimeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "pdfinfo", pdfPath)
+	args := append([]string{"-isodates"}, cfg.passwordArgs()...)
+	cmd := sandbox.Command(ctx, "pdfinfo", append(args, pdfPath)...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -76,9 +121,35 @@ func GetPDFInfo(ctx context.Context, pdfPath string, cfg ExtractorConfig) (PDFIn
 		Encrypted: encryptedRegex.MatchString(out),
 		Raw:       out,
 	}
+	parseInfoFields(out, &info)
 	return info, nil
 }
 
+// Metadata returns the non-empty document properties keyed the same way as
+// the other document extractors (title, author, created, modified, ...).
+func (i PDFInfo) Metadata() map[string]string {
+	meta := map[string]string{}
+	set := func(k, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			meta[k] = v
+		}
+	}
+	set("title", i.Title)
+	set("author", i.Author)
+	set("subject", i.Subject)
+	set("keywords", i.Keywords)
+	set("creator", i.Creator)
+	set("producer", i.Producer)
+	set("created", i.CreationDate)
+	set("modified", i.ModDate)
+	set("pdfVersion", i.PDFVersion)
+	set("pageSize", i.PageSize)
+	meta["tagged"] = strconv.FormatBool(i.Tagged)
+	meta["linearized"] = strconv.FormatBool(i.Linearized)
+	meta["encrypted"] = strconv.FormatBool(i.Encrypted)
+	return meta
+}
+
 // PageCount extracts total pages using pdfinfo (compat wrapper).
 func PageCount(ctx context.Context, pdfPath string, cfg ExtractorConfig) (int, error) {
 	info, err := GetPDFInfo(ctx, pdfPath, cfg)
@@ -109,16 +180,15 @@ func TextForPage(ctx context.Context, pdfPath string, page int, cfg ExtractorCon
 	ctx, cancel := context.WithTimeout(ctx, cfg.PDFToTextTimeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx,
-		"pdftotext",
+	args := []string{
 		"-f", strconv.Itoa(page),
 		"-l", strconv.Itoa(page),
 		"-layout",
 		"-nopgbrk",
 		"-enc", "UTF-8",
-		pdfPath,
-		"-",
-	)
+	}
+	args = append(args, cfg.passwordArgs()...)
+	cmd := sandbox.Command(ctx, "pdftotext", append(args, pdfPath, "-")...)
 
 	text, stderrStr, err := runCommandCaptureLimited(ctx, cmd, maxPerPageBytes)
 	if err != nil {
@@ -132,6 +202,47 @@ func TextForPage(ctx context.Context, pdfPath string, page int, cfg ExtractorCon
 	return text, nil
 }
 
+// TextInRegion extracts the text inside a rectangle of one page. x, y, w, h
+// are in PDF points measured from the top-left corner of the page's crop box
+// as displayed, after /Rotate (pdftotext renders at 72 dpi here, so one
+// pixel is one point).
+func TextInRegion(ctx context.Context, pdfPath string, page, x, y, w, h int, cfg ExtractorConfig) (string, error) {
+	cfg = cfg.withDefaults()
+
+	if page < 1 {
+		return "", fmt.Errorf("invalid page number: %d (must be >= 1)", page)
+	}
+	if w <= 0 || h <= 0 {
+		return "", nil
+	}
+
+	const maxRegionBytes = 1<<20 + 1
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.PDFToTextTimeout)
+	defer cancel()
+
+	args := []string{
+		"-f", strconv.Itoa(page),
+		"-l", strconv.Itoa(page),
+		"-r", "72",
+		"-x", strconv.Itoa(max(x, 0)),
+		"-y", strconv.Itoa(max(y, 0)),
+		"-W", strconv.Itoa(w),
+		"-H", strconv.Itoa(h),
+		"-cropbox",
+		"-nopgbrk",
+		"-enc", "UTF-8",
+	}
+	args = append(args, cfg.passwordArgs()...)
+	cmd := sandbox.Command(ctx, "pdftotext", append(args, pdfPath, "-")...)
+
+	text, stderrStr, err := runCommandCaptureLimited(ctx, cmd, maxRegionBytes)
+	if err != nil {
+		return "", classifyPdftotextErr(err, ctx, stderrStr, page)
+	}
+	return text, nil
+}
+
 // ExtractAllPages extracts text for whole PDF using pdftotext.
 // Output is capped to maxAllBytes to avoid OOM.
 func ExtractAllPages(ctx context.Context, pdfPath string, cfg ExtractorConfig) (string, error) {
@@ -143,14 +254,8 @@ func ExtractAllPages(ctx context.Context, pdfPath string, cfg ExtractorConfig) (
 	ctx, cancel := context.WithTimeout(ctx, cfg.PDFToTextAllTimeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx,
-		"pdftotext",
-		"-layout",
-		"-nopgbrk",
-		"-enc", "UTF-8",
-		pdfPath,
-		"-",
-	)
+	args := append([]string{"-layout", "-nopgbrk", "-enc", "UTF-8"}, cfg.passwordArgs()...)
+	cmd := sandbox.Command(ctx, "pdftotext", append(args, pdfPath, "-")...)
 
 	text, stderrStr, err := runCommandCaptureLimited(ctx, cmd, maxAllBytes)
 	if err != nil {
@@ -163,6 +268,46 @@ func ExtractAllPages(ctx context.Context, pdfPath string, cfg ExtractorConfig) (
 	return text, nil
 }
 
+// RenderPage rasterizes one page to PNG at the given resolution using
+// pdftoppm and returns the output path (outPrefix + ".png"). Encrypted PDFs
+// are decrypted locally with cfg.Password, which lets OCR run on documents a
+// remote service could not open.
+func RenderPage(ctx context.Context, pdfPath string, page, dpi int, outPrefix string, cfg ExtractorConfig) (string, error) {
+	cfg = cfg.withDefaults()
+
+	if page < 1 {
+		return "", fmt.Errorf("invalid page number: %d (must be >= 1)", page)
+	}
+	if dpi <= 0 {
+		dpi = 150
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.PDFToPPMTimeout)
+	defer cancel()
+
+	args := []string{
+		"-f", strconv.Itoa(page),
+		"-l", strconv.Itoa(page),
+		"-r", strconv.Itoa(dpi),
+		"-png",
+		"-singlefile",
+	}
+	args = append(args, cfg.passwordArgs()...)
+	cmd := sandbox.Command(ctx, "pdftoppm", append(args, pdfPath, outPrefix)...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", classifyPopplerErr("pdftoppm", err, ctx, stderr.String())
+	}
+
+	out := outPrefix + ".png"
+	if _, err := os.Stat(out); err != nil {
+		return "", fmt.Errorf("pdftoppm output missing for page %d", page)
+	}
+	return out, nil
+}
+
 // --- internals ---
 
 func parsePages(pdfinfoOut string) (int, error) {
@@ -198,6 +343,54 @@ func parsePages(pdfinfoOut string) (int, error) {
 	return 0, fmt.Errorf("pdfinfo: pages field not found in output")
 }
 
+// parseInfoFields fills the "Key: value" properties printed by pdfinfo.
+// Only the first occurrence of a key is used; per-page "Page N size" lines
+// are not requested so "Page size" refers to the first page.
+func parseInfoFields(pdfinfoOut string, info *PDFInfo) {
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(strings.NewReader(pdfinfoOut))
+	for sc.Scan() {
+		key, val, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		switch key {
+		case "Title":
+			info.Title = val
+		case "Author":
+			info.Author = val
+		case "Subject":
+			info.Subject = val
+		case "Keywords":
+			info.Keywords = val
+		case "Creator":
+			info.Creator = val
+		case "Producer":
+			info.Producer = val
+		case "CreationDate":
+			info.CreationDate = val
+		case "ModDate":
+			info.ModDate = val
+		case "PDF version":
+			info.PDFVersion = val
+		case "Page size":
+			info.PageSize = val
+		case "Tagged":
+			info.Tagged = strings.HasPrefix(strings.ToLower(val), "yes")
+		case "Optimized", "Linearized":
+			// Older poppler prints "Optimized", newer prints "Linearized".
+			info.Linearized = info.Linearized || strings.HasPrefix(strings.ToLower(val), "yes")
+		}
+	}
+}
+
 func validatePages(count int) (int, error) {
 	if count <= 0 || count > 50000 {
 		return 0, fmt.Errorf("pdfinfo: unreasonable page count: %d", count)
@@ -207,7 +400,7 @@ func validatePages(count int) (int, error) {
 
 // runCommandCaptureLimited runs cmd and captures stdout up to maxBytes (inclusive of sentinel).
 // It captures stderr fully (usually small) for error reporting.
-func runCommandCaptureLimited(ctx context.Context, cmd *exec.Cmd, maxBytes int64) (stdoutText string, stderrText string, err error) {
+func runCommandCaptureLimited(ctx context.Context, cmd *sandbox.Cmd, maxBytes int64) (stdoutText string, stderrText string, err error) {
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
 		return "", "", fmt.Errorf("stdout pipe: %w", err)
@@ -271,7 +464,7 @@ func classifyPopplerErr(tool string, err error, ctx context.Context, stderr stri
 			"Command Line Error: Incorrect password",
 		) {
 			logPopplerErr(tool, stderr, 0)
-			return fmt.Errorf("PDF is password protected")
+			return ErrPasswordProtected
 		}
 		if containsAny(stderr,
 			"PDF file is damaged",
@@ -313,7 +506,7 @@ func classifyPdftotextErr(err error, ctx context.Context, stderr string, page in
 
 		if containsAny(stderr, "Incorrect password", "Command Line Error: Incorrect password") {
 			logPopplerErr("pdftotext", stderr, page)
-			return fmt.Errorf("PDF is password protected")
+			return ErrPasswordProtected
 		}
 		if containsAny(stderr, "PDF file is damaged", "Syntax Error", "Couldn't find trailer dictionary", "May not be a PDF file") {
 			logPopplerErr("pdftotext", stderr, page)
@@ -12,11 +12,13 @@ import (
 	"time"
 
 	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/failover"
 	"github.com/toricodesthings/file-processing-service/internal/transcribe"
 )
 
 type Extractor struct {
 	client   *transcribe.Client
+	local    *transcribe.LocalWhisper
 	model    string
 	maxBytes int64
 }
@@ -28,6 +30,13 @@ func New(apiKey, apiURL, model string, maxBytes int64, timeout time.Duration) *E
 	return &Extractor{client: transcribe.NewClient(apiKey, apiURL, timeout), model: model, maxBytes: maxBytes}
 }
 
+// WithLocalWhisper enables the "whisper" provider in the transcription
+// failover chain.
+func (e *Extractor) WithLocalWhisper(w *transcribe.LocalWhisper) *Extractor {
+	e.local = w
+	return e
+}
+
 func (e *Extractor) Name() string       { return "media/audio" }
 func (e *Extractor) MaxFileSize() int64 { return e.maxBytes }
 func (e *Extractor) SupportedTypes() []string {
@@ -48,12 +57,10 @@ func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Resul
 		return extract.Result{Success: false, Method: "groq", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, errors.New(msg)
 	}
 
-	b, err := os.ReadFile(job.LocalPath)
-	if err != nil {
+	if st, err := os.Stat(job.LocalPath); err != nil {
 		msg := err.Error()
 		return extract.Result{Success: false, Method: "groq", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
-	}
-	if len(b) == 0 {
+	} else if st.Size() == 0 {
 		msg := "audio file is empty"
 		return extract.Result{Success: false, Method: "groq", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, errors.New(msg)
 	}
@@ -65,16 +72,38 @@ func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Resul
 	if temp, ok := floatOption(job.Options, "temperature"); ok {
 		temperature = &temp
 	}
-	payload, err := e.client.Transcribe(ctx, filepath.Base(job.LocalPath), b, transcribe.Options{
+	opts := transcribe.Options{
 		Model:          model,
 		Language:       stringOption(job.Options, "language", ""),
 		Prompt:         stringOption(job.Options, "prompt", ""),
 		Temperature:    temperature,
 		ResponseFormat: responseFormat,
+	}
+
+	chain := failover.Chain(failover.Transcription, transcribe.ProviderGroq)
+	payload, provider, err := failover.Run(ctx, failover.Transcription, chain, func(ctx context.Context, provider string) (transcribe.Response, error) {
+		switch provider {
+		case transcribe.ProviderGroq:
+			b, err := os.ReadFile(job.LocalPath)
+			if err != nil {
+				return transcribe.Response{}, err
+			}
+			return e.client.Transcribe(ctx, filepath.Base(job.LocalPath), b, opts)
+		case transcribe.ProviderWhisper:
+			if e.local == nil {
+				return transcribe.Response{}, errors.New("local whisper not configured")
+			}
+			return e.local.Transcribe(ctx, job.LocalPath, opts)
+		default:
+			return transcribe.Response{}, fmt.Errorf("unknown transcription provider %q", provider)
+		}
 	})
 	if err != nil {
 		msg := err.Error()
-		return extract.Result{Success: false, Method: "groq", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, Method: chain[0], FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+	}
+	if provider == transcribe.ProviderWhisper {
+		model = e.local.Model()
 	}
 
 	text := strings.TrimSpace(payload.Text)
@@ -82,8 +111,8 @@ func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Resul
 		text = formatTimestampedTranscript(payload.Segments)
 	}
 	if text == "" {
-		msg := "groq transcription returned empty transcript"
-		return extract.Result{Success: false, Method: "groq", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, errors.New(msg)
+		msg := provider + " transcription returned empty transcript"
+		return extract.Result{Success: false, Method: provider, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, errors.New(msg)
 	}
 
 	words, chars := extract.BuildCounts(text)
@@ -95,8 +124,9 @@ func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Resul
 		meta["durationSeconds"] = strconv.FormatFloat(payload.Duration, 'f', 3, 64)
 	}
 	meta["model"] = model
+	meta["transcriptionProvider"] = provider
 
-	return extract.Result{Success: true, Text: text, Method: "groq", FileType: e.Name(), MIMEType: job.MIMEType, Metadata: meta, WordCount: words, CharCount: chars}, nil
+	return extract.Result{Success: true, Text: text, Method: provider, FileType: e.Name(), MIMEType: job.MIMEType, Metadata: meta, WordCount: words, CharCount: chars}, nil
 }
 
 func formatTimestampedTranscript(segments []transcribe.Segment) string {
@@ -1,23 +1,34 @@
 package ebook
 
 import (
-	"archive/zip"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"io"
+	"net/url"
 	"path"
+	"regexp"
 	"strings"
 
+	"github.com/toricodesthings/file-processing-service/internal/embedded"
 	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
 )
 
 type EPUBExtractor struct {
 	maxBytes int64
+	images   embedded.Config
 }
 
 func NewEPUB(maxBytes int64) *EPUBExtractor { return &EPUBExtractor{maxBytes: maxBytes} }
 
+// WithEmbeddedImages enables the embeddedImages option, which runs the
+// images chapters show through OCR/vision.
+func (e *EPUBExtractor) WithEmbeddedImages(cfg embedded.Config) *EPUBExtractor {
+	e.images = cfg
+	return e
+}
+
 func (e *EPUBExtractor) Name() string                  { return "document/epub" }
 func (e *EPUBExtractor) MaxFileSize() int64            { return e.maxBytes }
 func (e *EPUBExtractor) SupportedTypes() []string      { return []string{"application/epub+zip"} }
@@ -30,10 +41,10 @@ func (e *EPUBExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 	default:
 	}
 
-	zr, err := zip.OpenReader(job.LocalPath)
+	zr, err := safezip.OpenReader(job.LocalPath)
 	if err != nil {
 		msg := err.Error()
-		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
 	}
 	defer zr.Close()
 
@@ -53,7 +64,7 @@ func (e *EPUBExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 	var spineItems []string
 
 	if opfPath != "" {
-		opfData, err := readZipEntry(zr, opfPath)
+		opfData, err := zr.ReadFile(opfPath)
 		if err == nil {
 			spineItems, meta = parseOPF(opfData, path.Dir(opfPath))
 		}
@@ -69,13 +80,18 @@ func (e *EPUBExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 		}
 	}
 
+	media := embedded.NewCollector(e.images, job.Options)
 	var chapters []string
 	for i, item := range spineItems {
-		b, err := readZipEntry(zr, item)
+		b, err := zr.ReadFile(item)
+		if errors.Is(err, safezip.ErrLimitsExceeded) {
+			msg := err.Error()
+			return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
+		}
 		if err != nil {
 			continue
 		}
-		chapterText := epubStripHTML(string(b))
+		chapterText := epubStripHTML(epubAnchorImages(string(b), path.Dir(item), media))
 		if strings.TrimSpace(chapterText) == "" {
 			continue
 		}
@@ -83,6 +99,11 @@ func (e *EPUBExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 	}
 
 	text := strings.Join(chapters, "\n\n---\n\n")
+	if media != nil {
+		var images embedded.Stats
+		text, images = media.Resolve(ctx, text, zr.ReadFile)
+		images.AddTo(meta)
+	}
 
 	if len(meta) > 0 {
 		text = epubFrontmatter(meta) + text
@@ -94,8 +115,8 @@ func (e *EPUBExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 }
 
 // findOPFPath reads META-INF/container.xml and returns the rootfile full-path.
-func findOPFPath(zr *zip.ReadCloser) string {
-	b, err := readZipEntry(zr, "META-INF/container.xml")
+func findOPFPath(zr *safezip.ReadCloser) string {
+	b, err := zr.ReadFile("META-INF/container.xml")
 	if err != nil {
 		return ""
 	}
@@ -207,6 +228,27 @@ func parseOPF(data []byte, opfDir string) ([]string, map[string]string) {
 	return paths, meta
 }
 
+// epubImage matches an <img> or SVG <image> tag and its source.
+var epubImage = regexp.MustCompile(`(?is)<(?:img|image)\b[^>]*?\s(?:src|xlink:href|href)\s*=\s*["']([^"']+)["'][^>]*>`)
+
+// epubAnchorImages replaces the images of a chapter in dir with media
+// anchors. Remote images and data URLs are left to be stripped.
+func epubAnchorImages(s, dir string, media *embedded.Collector) string {
+	if media == nil {
+		return s
+	}
+	return epubImage.ReplaceAllStringFunc(s, func(tag string) string {
+		src := epubImage.FindStringSubmatch(tag)[1]
+		if strings.Contains(src, ":") {
+			return ""
+		}
+		if u, err := url.PathUnescape(strings.SplitN(src, "#", 2)[0]); err == nil {
+			src = u
+		}
+		return " " + media.Anchor(path.Join(dir, src)) + " "
+	})
+}
+
 // epubStripHTML converts basic HTML to markdown-like text.
 func epubStripHTML(s string) string {
 	// Convert block elements
@@ -260,20 +302,6 @@ func epubStripHTML(s string) string {
 	return strings.Join(out, "\n\n")
 }
 
-func readZipEntry(zr *zip.ReadCloser, name string) ([]byte, error) {
-	for _, f := range zr.File {
-		if f.Name == name {
-			rc, err := f.Open()
-			if err != nil {
-				return nil, err
-			}
-			defer rc.Close()
-			return io.ReadAll(rc)
-		}
-	}
-	return nil, fmt.Errorf("not found: %s", name)
-}
-
 func epubFrontmatter(meta map[string]string) string {
 	if len(meta) == 0 {
 		return ""
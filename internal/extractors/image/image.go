@@ -2,10 +2,12 @@ package image
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"github.com/toricodesthings/file-processing-service/internal/extract"
 	img "github.com/toricodesthings/file-processing-service/internal/image"
+	"github.com/toricodesthings/file-processing-service/internal/types"
 )
 
 type Extractor struct {
@@ -34,7 +36,20 @@ func (e *Extractor) SupportedExtensions() []string {
 }
 
 func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Result, error) {
-	res, err := img.ProcessImage(ctx, job.PresignedURL, e.ocrModel, e.visionModel, e.visionTimeout)
+	var (
+		res types.ImageExtractionResult
+		err error
+	)
+	if job.PresignedURL != "" {
+		res, err = img.ProcessImage(ctx, job.PresignedURL, job.LocalPath, e.ocrModel, e.visionModel, e.visionTimeout)
+	} else {
+		// No URL providers can reach (data:, file://, attachments): send the
+		// bytes inline.
+		var data []byte
+		if data, err = os.ReadFile(job.LocalPath); err == nil {
+			res, err = img.ProcessImageData(ctx, data, job.MIMEType, e.ocrModel, e.visionModel, e.visionTimeout)
+		}
+	}
 	if err != nil {
 		msg := err.Error()
 		return extract.Result{Success: false, Method: "image", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
@@ -48,6 +63,12 @@ func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Resul
 	if res.Description != "" {
 		metadata["description"] = res.Description
 	}
+	if res.OCRProvider != "" {
+		metadata["ocrProvider"] = res.OCRProvider
+	}
+	if res.VisionModel != "" {
+		metadata["visionModel"] = res.VisionModel
+	}
 
 	return extract.Result{
 		Success:   true,
@@ -1,30 +1,46 @@
 package office
 
 import (
-	"archive/zip"
 	"context"
 	"encoding/xml"
-	"fmt"
-	"io"
+	"strconv"
 	"strings"
 
+	"github.com/toricodesthings/file-processing-service/internal/embedded"
 	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/format"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
 )
 
 type DOCXExtractor struct {
 	maxBytes int64
+	images   embedded.Config
 }
 
 func NewDOCX(maxBytes int64) *DOCXExtractor {
 	return &DOCXExtractor{maxBytes: maxBytes}
 }
 
+// WithEmbeddedImages enables the embeddedImages option, which runs images
+// in word/media through OCR/vision.
+func (e *DOCXExtractor) WithEmbeddedImages(cfg embedded.Config) *DOCXExtractor {
+	e.images = cfg
+	return e
+}
+
 func (e *DOCXExtractor) Name() string       { return "document/docx" }
 func (e *DOCXExtractor) MaxFileSize() int64 { return e.maxBytes }
 func (e *DOCXExtractor) SupportedTypes() []string {
-	return []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"}
+	return []string{
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.template",
+		"application/vnd.ms-word.document.macroenabled.12",
+		"application/vnd.ms-word.template.macroenabled.12",
+	}
+}
+func (e *DOCXExtractor) SupportedExtensions() []string {
+	return []string{".docx", ".docm", ".dotx", ".dotm"}
 }
-func (e *DOCXExtractor) SupportedExtensions() []string { return []string{".docx"} }
 
 func (e *DOCXExtractor) Extract(ctx context.Context, job extract.Job) (extract.Result, error) {
 	select {
@@ -33,71 +49,213 @@ func (e *DOCXExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 	default:
 	}
 
-	zr, err := zip.OpenReader(job.LocalPath)
+	zr, closer, err := openOOXML(job.LocalPath, stringOption(job.Options, "password"))
 	if err != nil {
 		msg := err.Error()
-		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
 	}
-	defer zr.Close()
+	defer closer.Close()
 
-	body, err := readZipFile(&zr.Reader, "word/document.xml")
+	body, err := zr.ReadFile("word/document.xml")
 	if err != nil {
 		msg := err.Error()
-		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
 	}
 
-	text := docxToMarkdown(body)
-	meta := parseCoreMetadata(&zr.Reader)
+	conv := newDOCXConverter(zr, docxOptionsFrom(job.Options))
+	conv.media = embedded.NewCollector(e.images, job.Options)
+	text, err := conv.convert(body)
+	if err != nil {
+		msg := err.Error()
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
+	}
+	text, images := conv.media.Resolve(ctx, text, zr.ReadFile)
+	meta := parseCoreMetadata(zr)
 
 	// Prepend metadata frontmatter if available
 	if len(meta) > 0 {
 		text = metadataFrontmatter(meta) + text
 	}
 
+	sec, err := scanOOXMLSecurity(zr)
+	if err != nil {
+		msg := err.Error()
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
+	}
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	sec.addTo(meta)
+	conv.addCounts(meta)
+	if conv.media != nil {
+		images.AddTo(meta)
+	}
+
 	text = strings.TrimSpace(text)
 	words, chars := extract.BuildCounts(text)
 	return extract.Result{Success: true, Text: text, Method: "native", FileType: e.Name(), MIMEType: job.MIMEType, Metadata: meta, WordCount: words, CharCount: chars}, nil
 }
 
-// docxToMarkdown walks <w:body> in word/document.xml producing markdown.
-// Handles paragraphs with heading styles, numbered/bulleted lists, and tables.
-func docxToMarkdown(b []byte) string {
-	dec := xml.NewDecoder(strings.NewReader(string(b)))
+// docxOptions selects which auxiliary parts are rendered alongside the body.
+type docxOptions struct {
+	footnotes      bool // footnotes and endnotes
+	comments       bool
+	headersFooters bool
+	revisions      string // accept, reject or markup
+}
+
+func docxOptionsFrom(options map[string]any) docxOptions {
+	return docxOptions{
+		footnotes:      boolOption(options, "footnotes", true),
+		comments:       boolOption(options, "comments", true),
+		headersFooters: boolOption(options, "headersFooters", true),
+		revisions:      format.Revisions(options),
+	}
+}
+
+// docxConverter renders word/document.xml as markdown. It carries the state
+// that spans paragraphs: note numbering, open comment ranges and section
+// breaks.
+type docxConverter struct {
+	opts docxOptions
+	zr   *safezip.Reader
+	err  error // first zip limit error from an auxiliary part
+
+	rels      map[string]string // relationship id -> part name
+	links     map[string]string // relationship id -> external URL
+	bookmarks map[string]bool   // bookmarks some link points at
+	styles    docxStyles
+	numbering *docxNumbering
+	footnotes *docxNotes
+	endnotes  *docxNotes
+
+	comments map[string]docxComment
+	anchors  map[string]*strings.Builder // commented text by comment id
+	active   map[string]bool             // comment ranges currently open
+	pending  []string                    // comments referenced in the current block
+	emitted  map[string]bool
+
+	insertions int
+	deletions  int
+	dropping   int // > 0 inside a revision the revisions mode discards
+	equations  int
+
+	sections     []docxSection
+	sectionBreak *docxSection // set by a paragraph that ends a section
+	partCache    map[string]string
+
+	media *embedded.Collector // image anchors; nil unless embeddedImages is on
+}
 
+func newDOCXConverter(zr *safezip.Reader, opts docxOptions) *docxConverter {
+	c := &docxConverter{
+		opts:      opts,
+		zr:        zr,
+		anchors:   map[string]*strings.Builder{},
+		active:    map[string]bool{},
+		emitted:   map[string]bool{},
+		partCache: map[string]string{},
+	}
+	c.rels, c.links = partRelationships(zr, "word/document.xml")
+	c.styles = parseDOCXStyles(c.read("word/styles.xml"))
+	c.numbering = parseDOCXNumbering(c.read("word/numbering.xml"))
+	if opts.footnotes {
+		c.footnotes = c.loadNotes("word/footnotes.xml", "footnote", "")
+		c.endnotes = c.loadNotes("word/endnotes.xml", "endnote", "e")
+	}
+	if opts.comments {
+		c.comments = c.loadComments("word/comments.xml")
+	}
+	return c
+}
+
+// convert renders the main document part.
+func (c *docxConverter) convert(body []byte) (string, error) {
+	c.bookmarks = linkedBookmarks(body)
+	blocks := c.blocks(xml.NewDecoder(strings.NewReader(string(body))))
+	if c.opts.headersFooters {
+		blocks = c.withHeadersFooters(blocks)
+	}
+	if c.footnotes != nil {
+		blocks = append(blocks, c.footnotes.definitions()...)
+	}
+	if c.endnotes != nil {
+		blocks = append(blocks, c.endnotes.definitions()...)
+	}
+	return joinBlocks(blocks, "\n\n"), c.err
+}
+
+// addCounts records how many notes, comments, revisions and equations were
+// rendered.
+func (c *docxConverter) addCounts(meta map[string]string) {
+	if c.footnotes != nil && len(c.footnotes.order) > 0 {
+		meta["footnotes"] = strconv.Itoa(len(c.footnotes.order))
+	}
+	if c.endnotes != nil && len(c.endnotes.order) > 0 {
+		meta["endnotes"] = strconv.Itoa(len(c.endnotes.order))
+	}
+	if len(c.emitted) > 0 {
+		meta["comments"] = strconv.Itoa(len(c.emitted))
+	}
+	if c.insertions > 0 || c.deletions > 0 {
+		meta["revisionInsertions"] = strconv.Itoa(c.insertions)
+		meta["revisionDeletions"] = strconv.Itoa(c.deletions)
+	}
+	if c.equations > 0 {
+		meta["equations"] = strconv.Itoa(c.equations)
+	}
+}
+
+// blocks walks block-level content (paragraphs and tables) until the end of
+// the enclosing element, returning one markdown block per element. Comments
+// referenced in a block follow it as quotes.
+func (c *docxConverter) blocks(dec *xml.Decoder) []string {
 	var blocks []string
+	depth := 0
 	for {
 		tok, err := dec.Token()
 		if err != nil {
 			break
 		}
-		se, ok := tok.(xml.StartElement)
-		if !ok {
-			continue
-		}
-		switch se.Name.Local {
-		case "p":
-			blocks = append(blocks, docxParagraph(dec, se))
-		case "tbl":
-			blocks = append(blocks, docxTable(dec))
-		}
-	}
-
-	var out []string
-	for _, b := range blocks {
-		b = strings.TrimSpace(b)
-		if b != "" {
-			out = append(out, b)
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				blocks = append(blocks, c.paragraph(dec))
+			case "tbl":
+				blocks = append(blocks, c.table(dec))
+			case "sectPr":
+				// The body's final section properties.
+				sec := c.sectPr(dec)
+				sec.end = len(blocks)
+				c.sections = append(c.sections, sec)
+				continue
+			default:
+				depth++
+				continue
+			}
+			blocks = append(blocks, c.flushComments()...)
+			if c.sectionBreak != nil {
+				c.sectionBreak.end = len(blocks)
+				c.sections = append(c.sections, *c.sectionBreak)
+				c.sectionBreak = nil
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				return blocks
+			}
+			depth--
 		}
 	}
-	return strings.Join(out, "\n\n")
+	return blocks
 }
 
-// docxParagraph reads one <w:p> element and returns markdown text.
-func docxParagraph(dec *xml.Decoder, start xml.StartElement) string {
+// paragraph reads one <w:p> element and returns markdown text.
+func (c *docxConverter) paragraph(dec *xml.Decoder) string {
 	var style string
 	var numID string
 	var numLvl string
-	var runs []string
+	var spans []docxSpan
 	depth := 1
 
 	for depth > 0 {
@@ -107,64 +265,178 @@ func docxParagraph(dec *xml.Decoder, start xml.StartElement) string {
 		}
 		switch t := tok.(type) {
 		case xml.StartElement:
+			if t.Name.Local == "sectPr" {
+				// Section properties in a paragraph end the section after it.
+				sec := c.sectPr(dec)
+				c.sectionBreak = &sec
+				continue
+			}
 			depth++
 			switch t.Name.Local {
 			case "pStyle":
-				for _, a := range t.Attr {
-					if a.Name.Local == "val" {
-						style = a.Value
-					}
-				}
+				style = attrValue(t, "val")
 			case "numId":
-				for _, a := range t.Attr {
-					if a.Name.Local == "val" {
-						numID = a.Value
-					}
-				}
+				numID = attrValue(t, "val")
 			case "ilvl":
-				for _, a := range t.Attr {
-					if a.Name.Local == "val" {
-						numLvl = a.Value
-					}
-				}
-			case "t":
-				text := readCharData(dec, &depth)
-				runs = append(runs, text)
-			case "tab":
-				runs = append(runs, "\t")
-			case "br":
-				runs = append(runs, "\n")
+				numLvl = attrValue(t, "val")
+			default:
+				spans = append(spans, c.inline(dec, t, &depth)...)
 			}
 		case xml.EndElement:
 			depth--
 		}
 	}
 
-	text := strings.Join(runs, "")
-	if strings.TrimSpace(text) == "" {
+	spans = resolveFields(spans)
+	h := headingLevel(style)
+	if h > 0 {
+		spans = plainSpans(spans)
+	}
+	text := strings.TrimSpace(renderSpans(spans))
+	if text == "" {
 		return ""
 	}
 
 	// Check for heading styles (Heading1, Heading2, etc. or HeadingN patterns)
-	if h := headingLevel(style); h > 0 {
+	if h > 0 {
 		prefix := strings.Repeat("#", h)
-		return prefix + " " + strings.TrimSpace(text)
+		return prefix + " " + text
+	}
+
+	// List items, numbered directly or through the paragraph style
+	ref := c.styles.lists[style]
+	if numID != "" {
+		ref.numID = numID
+	}
+	if numLvl != "" {
+		ref.ilvl, _ = strconv.Atoi(numLvl)
+	}
+	if ref.numID != "" && ref.numID != "0" {
+		if prefix, ok := c.numbering.item(ref.numID, ref.ilvl); ok {
+			return prefix + text
+		}
 	}
 
-	// List items
-	if numID != "" && numID != "0" {
-		indent := ""
-		if numLvl != "" && numLvl != "0" {
-			lvl := 0
-			for _, c := range numLvl {
-				lvl = lvl*10 + int(c-'0')
+	if strings.EqualFold(style, "caption") && !strings.HasPrefix(text, "*") {
+		return "*" + text + "*"
+	}
+	return text
+}
+
+// inline renders run-level content: runs with their formatting, text, tabs,
+// breaks, hyperlinks, bookmarks, images, equations, fields, tracked changes,
+// and note and comment references. Elements it does not know render nothing; their
+// children are visited by the caller. Content inside a discarded revision
+// is read without numbering notes or extending comment anchors.
+func (c *docxConverter) inline(dec *xml.Decoder, t xml.StartElement, depth *int) []docxSpan {
+	switch t.Name.Local {
+	case "r":
+		return c.run(dec, depth)
+	case "t", "delText":
+		text := readCharData(dec, depth)
+		if c.dropping == 0 {
+			for id := range c.active {
+				c.anchors[id].WriteString(text)
 			}
-			indent = strings.Repeat("  ", lvl)
 		}
-		return indent + "- " + strings.TrimSpace(text)
+		return []docxSpan{{text: text}}
+	case "tab":
+		return []docxSpan{{text: "\t"}}
+	case "br", "cr":
+		return []docxSpan{{text: "\n"}}
+	case "instrText", "delInstrText":
+		return []docxSpan{{text: readCharData(dec, depth), field: fieldInstr}}
+	case "fldChar":
+		switch attrValue(t, "fldCharType") {
+		case "begin":
+			return []docxSpan{{field: fieldBegin}}
+		case "separate":
+			return []docxSpan{{field: fieldSeparate}}
+		case "end":
+			return []docxSpan{{field: fieldEnd}}
+		}
+	case "fldSimple":
+		return linkSpans(c.children(dec, depth), fieldLinkTarget(attrValue(t, "instr")))
+	case "hyperlink":
+		return c.hyperlink(dec, t, depth)
+	case "bookmarkStart":
+		if name := attrValue(t, "name"); c.bookmarks[name] {
+			return []docxSpan{{text: `<a id="` + name + `"></a>`, raw: true}}
+		}
+	case "drawing", "pict":
+		return c.drawing(dec, depth)
+	case "oMath", "oMathPara":
+		return c.equation(dec, t, depth)
+	case "Fallback":
+		// mc:AlternateContent repeats its choice (a text box, a shape) as a
+		// fallback for older readers; rendering both would duplicate it.
+		dec.Skip()
+		*depth--
+	case "ins", "moveTo":
+		return c.revision(dec, t, depth, true)
+	case "del", "moveFrom":
+		return c.revision(dec, t, depth, false)
+	case "footnoteReference":
+		if c.footnotes != nil && c.dropping == 0 {
+			return []docxSpan{{text: c.footnotes.ref(attrValue(t, "id")), raw: true}}
+		}
+	case "endnoteReference":
+		if c.endnotes != nil && c.dropping == 0 {
+			return []docxSpan{{text: c.endnotes.ref(attrValue(t, "id")), raw: true}}
+		}
+	case "commentRangeStart":
+		if id := attrValue(t, "id"); c.comments != nil && c.dropping == 0 && !c.emitted[id] {
+			c.anchors[id] = &strings.Builder{}
+			c.active[id] = true
+		}
+	case "commentRangeEnd":
+		delete(c.active, attrValue(t, "id"))
+	case "commentReference":
+		if c.comments != nil && c.dropping == 0 {
+			c.pending = append(c.pending, attrValue(t, "id"))
+		}
+	}
+	return nil
+}
+
+// revision reads a tracked insertion (<w:ins>, <w:moveTo>) or deletion
+// (<w:del>, <w:moveFrom>) and renders it for the revisions mode: accept
+// keeps insertions, reject keeps deletions, and markup keeps both as
+// CriticMarkup annotated with the author and date. Revisions carrying no
+// text, such as paragraph-mark changes, are not counted.
+func (c *docxConverter) revision(dec *xml.Decoder, start xml.StartElement, depth *int, inserted bool) []docxSpan {
+	keep := c.opts.revisions == "markup" || (c.opts.revisions == "accept") == inserted
+	if !keep {
+		c.dropping++
+	}
+	spans := c.children(dec, depth)
+	if !keep {
+		c.dropping--
 	}
 
-	return strings.TrimSpace(text)
+	if strings.TrimSpace(renderSpans(resolveFields(spans))) == "" {
+		if keep {
+			return spans
+		}
+		return nil
+	}
+	if inserted {
+		c.insertions++
+	} else {
+		c.deletions++
+	}
+	switch {
+	case !keep:
+		return nil
+	case c.opts.revisions != "markup":
+		return spans
+	}
+	mark := "++"
+	if !inserted {
+		mark = "--"
+	}
+	out := append([]docxSpan{{text: "{" + mark, raw: true}}, spans...)
+	return append(out, docxSpan{text: mark + "}" + format.CriticNote(attrValue(start, "author"), attrValue(start, "date")), raw: true})
 }
 
 // headingLevel returns the markdown heading level for OOXML paragraph styles.
@@ -185,8 +457,8 @@ func headingLevel(style string) int {
 	return 0
 }
 
-// docxTable reads one <w:tbl> element and returns a markdown table.
-func docxTable(dec *xml.Decoder) string {
+// table reads one <w:tbl> element and returns a markdown table.
+func (c *docxConverter) table(dec *xml.Decoder) string {
 	var rows [][]string
 	depth := 1
 
@@ -199,7 +471,8 @@ func docxTable(dec *xml.Decoder) string {
 		case xml.StartElement:
 			depth++
 			if t.Name.Local == "tr" {
-				rows = append(rows, docxTableRow(dec, &depth))
+				rows = append(rows, c.tableRow(dec))
+				depth--
 			}
 		case xml.EndElement:
 			depth--
@@ -236,8 +509,8 @@ func docxTable(dec *xml.Decoder) string {
 	return sb.String()
 }
 
-// docxTableRow reads one <w:tr> element and returns cell texts.
-func docxTableRow(dec *xml.Decoder, outerDepth *int) []string {
+// tableRow reads one <w:tr> element and returns cell texts.
+func (c *docxConverter) tableRow(dec *xml.Decoder) []string {
 	var cells []string
 	depth := 0 // already counted by caller
 
@@ -250,7 +523,8 @@ func docxTableRow(dec *xml.Decoder, outerDepth *int) []string {
 		case xml.StartElement:
 			depth++
 			if t.Name.Local == "tc" {
-				cells = append(cells, docxTableCell(dec, &depth))
+				cells = append(cells, c.tableCell(dec))
+				depth--
 			}
 		case xml.EndElement:
 			if depth == 0 {
@@ -263,9 +537,17 @@ func docxTableRow(dec *xml.Decoder, outerDepth *int) []string {
 	return cells
 }
 
-// docxTableCell reads one <w:tc> element and returns its text content.
-func docxTableCell(dec *xml.Decoder, outerDepth *int) string {
-	var texts []string
+// tableCell reads one <w:tc> element and returns its text on one line, with
+// the cell's paragraphs separated by spaces.
+func (c *docxConverter) tableCell(dec *xml.Decoder) string {
+	var paras []string
+	var spans []docxSpan
+	flush := func() {
+		if s := strings.Join(strings.Fields(renderSpans(resolveFields(spans))), " "); s != "" {
+			paras = append(paras, s)
+		}
+		spans = nil
+	}
 	depth := 0
 
 	for {
@@ -276,17 +558,33 @@ func docxTableCell(dec *xml.Decoder, outerDepth *int) string {
 		switch t := tok.(type) {
 		case xml.StartElement:
 			depth++
-			if t.Name.Local == "t" {
-				texts = append(texts, readCharData(dec, &depth))
-			}
+			spans = append(spans, c.inline(dec, t, &depth)...)
 		case xml.EndElement:
 			if depth == 0 {
-				return strings.TrimSpace(strings.Join(texts, " "))
+				flush()
+				return strings.Join(paras, " ")
 			}
 			depth--
+			if t.Name.Local == "p" {
+				flush()
+			}
 		}
 	}
-	return strings.TrimSpace(strings.Join(texts, " "))
+	flush()
+	return strings.Join(paras, " ")
+}
+
+// joinBlocks trims blocks and joins the non-empty ones with sep. Leading
+// spaces are kept: they indent nested list items.
+func joinBlocks(blocks []string, sep string) string {
+	var out []string
+	for _, b := range blocks {
+		b = strings.TrimLeft(strings.TrimRight(b, " \t\r\n"), "\r\n")
+		if strings.TrimSpace(b) != "" {
+			out = append(out, b)
+		}
+	}
+	return strings.Join(out, sep)
 }
 
 // readCharData reads character data inside a text element, tracking depth.
@@ -312,24 +610,9 @@ func readCharData(dec *xml.Decoder, depth *int) string {
 
 // --- Shared helpers ---
 
-func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
-	for _, f := range zr.File {
-		if f.Name != name {
-			continue
-		}
-		rc, err := f.Open()
-		if err != nil {
-			return nil, err
-		}
-		defer rc.Close()
-		return io.ReadAll(rc)
-	}
-	return nil, fmt.Errorf("missing %s", name)
-}
-
 // parseCoreMetadata extracts title, author, dates from docProps/core.xml.
-func parseCoreMetadata(zr *zip.Reader) map[string]string {
-	b, err := readZipFile(zr, "docProps/core.xml")
+func parseCoreMetadata(zr *safezip.Reader) map[string]string {
+	b, err := zr.ReadFile("docProps/core.xml")
 	if err != nil {
 		return nil
 	}
@@ -0,0 +1,447 @@
+package office
+
+import (
+	"encoding/xml"
+	"path"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/mathtex"
+)
+
+// docxSpan is a piece of paragraph content. Text spans carry the formatting
+// of their run; raw spans are already markdown (links, images, references)
+// and are never wrapped. Field spans mark the structure of complex fields
+// and are resolved by resolveFields.
+type docxSpan struct {
+	text  string
+	fmt   docxFormat
+	raw   bool
+	field docxField
+}
+
+type docxField int
+
+const (
+	fieldNone docxField = iota
+	fieldBegin
+	fieldInstr
+	fieldSeparate
+	fieldEnd
+)
+
+// docxFormat is the run formatting that has a markdown equivalent.
+type docxFormat struct {
+	bold   bool
+	italic bool
+	strike bool
+	code   bool
+}
+
+// wrap applies f to text. Surrounding whitespace stays outside the markers,
+// where markdown requires it.
+func (f docxFormat) wrap(text string) string {
+	core := strings.TrimSpace(text)
+	if core == "" || f == (docxFormat{}) {
+		return text
+	}
+	lead := text[:strings.Index(text, core)]
+	trail := text[len(lead)+len(core):]
+	if f.code {
+		fence := "`"
+		if strings.Contains(core, "`") {
+			fence = "``"
+			core = " " + core + " "
+		}
+		core = fence + core + fence
+	}
+	if f.strike {
+		core = "~~" + core + "~~"
+	}
+	if f.italic {
+		core = "*" + core + "*"
+	}
+	if f.bold {
+		core = "**" + core + "**"
+	}
+	return lead + core + trail
+}
+
+// monospaceFonts mark runs rendered as inline code.
+var monospaceFonts = map[string]bool{
+	"courier": true, "courier new": true, "consolas": true, "menlo": true, "monaco": true,
+	"lucida console": true, "source code pro": true, "cascadia code": true, "cascadia mono": true,
+}
+
+// codeStyle reports whether a style id or font name denotes source code.
+func codeStyle(s string) bool {
+	s = strings.ToLower(s)
+	return monospaceFonts[s] || strings.Contains(s, "code")
+}
+
+// onOff reads an OOXML toggle property such as <w:b/> or <w:b w:val="0"/>.
+func onOff(se xml.StartElement) bool {
+	switch attrValue(se, "val") {
+	case "0", "false", "off", "none":
+		return false
+	}
+	return true
+}
+
+// run reads one <w:r> and returns its content with the run's formatting.
+func (c *docxConverter) run(dec *xml.Decoder, depth *int) []docxSpan {
+	var f docxFormat
+	var spans []docxSpan
+	d := 0
+loop:
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			d++
+			if t.Name.Local == "rPr" {
+				f = c.runFormat(dec)
+				d--
+				continue
+			}
+			spans = append(spans, c.inline(dec, t, &d)...)
+		case xml.EndElement:
+			if d == 0 {
+				break loop
+			}
+			d--
+		}
+	}
+	*depth--
+	for i := range spans {
+		if !spans[i].raw && spans[i].field == fieldNone {
+			spans[i].fmt = f
+		}
+	}
+	return spans
+}
+
+// runFormat reads a <w:rPr>, starting from its character style.
+func (c *docxConverter) runFormat(dec *xml.Decoder) docxFormat {
+	var f docxFormat
+	depth := 1
+	for depth > 0 {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch t.Name.Local {
+			case "rPrChange":
+				// The formatting before a tracked change; not what is shown.
+				dec.Skip()
+				depth--
+			case "rStyle":
+				id := attrValue(t, "val")
+				sf := c.styles.chars[id]
+				f.bold = f.bold || sf.bold
+				f.italic = f.italic || sf.italic
+				f.strike = f.strike || sf.strike
+				f.code = f.code || sf.code || codeStyle(id)
+			case "b":
+				f.bold = onOff(t)
+			case "i":
+				f.italic = onOff(t)
+			case "strike", "dstrike":
+				f.strike = onOff(t)
+			case "rFonts":
+				if codeStyle(attrValue(t, "ascii")) {
+					f.code = true
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return f
+}
+
+// children reads inline content up to and including the end of the current
+// element.
+func (c *docxConverter) children(dec *xml.Decoder, depth *int) []docxSpan {
+	var spans []docxSpan
+	d := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			d++
+			spans = append(spans, c.inline(dec, t, &d)...)
+		case xml.EndElement:
+			if d == 0 {
+				*depth--
+				return spans
+			}
+			d--
+		}
+	}
+	*depth--
+	return spans
+}
+
+// hyperlink reads a <w:hyperlink>, resolving its relationship target or
+// internal bookmark anchor.
+func (c *docxConverter) hyperlink(dec *xml.Decoder, start xml.StartElement, depth *int) []docxSpan {
+	spans := c.children(dec, depth)
+	target := c.links[attrValue(start, "id")]
+	if a := attrValue(start, "anchor"); target == "" && a != "" {
+		target = "#" + a
+	}
+	return linkSpans(spans, target)
+}
+
+// linkSpans renders spans as a markdown link to target.
+func linkSpans(spans []docxSpan, target string) []docxSpan {
+	text := strings.TrimSpace(renderSpans(resolveFields(spans)))
+	if target == "" || text == "" || imageOnly(spans) {
+		return spans
+	}
+	return []docxSpan{{text: "[" + text + "](" + strings.ReplaceAll(target, " ", "%20") + ")", raw: true}}
+}
+
+// imageOnly reports whether spans hold just an image, which is kept rather
+// than wrapped in a link.
+func imageOnly(spans []docxSpan) bool {
+	return len(spans) == 1 && spans[0].raw && strings.HasPrefix(spans[0].text, "![")
+}
+
+// drawing reads a <w:drawing> or VML <w:pict> and returns the image with its
+// alt text. Text boxes inside the drawing are returned after it.
+func (c *docxConverter) drawing(dec *xml.Decoder, depth *int) []docxSpan {
+	var alt, title, target string
+	var boxes []string
+	d := 0
+loop:
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			d++
+			switch t.Name.Local {
+			case "docPr":
+				alt, title = attrValue(t, "descr"), attrValue(t, "title")
+			case "blip":
+				target = c.rels[attrValue(t, "embed")]
+			case "imagedata":
+				target = c.rels[attrValue(t, "id")]
+				if title == "" {
+					title = attrValue(t, "title")
+				}
+			case "txbxContent":
+				if s := joinBlocks(c.blocks(dec), " "); s != "" {
+					boxes = append(boxes, s)
+				}
+				d--
+			}
+		case xml.EndElement:
+			if d == 0 {
+				break loop
+			}
+			d--
+		}
+	}
+	*depth--
+
+	var spans []docxSpan
+	if target != "" {
+		if alt == "" {
+			alt = title
+		}
+		alt = strings.NewReplacer("[", `\[`, "]", `\]`, "\n", " ", "\r", "").Replace(strings.TrimSpace(alt))
+		spans = append(spans, docxSpan{text: "![" + alt + "](" + path.Base(target) + ")", raw: true})
+		if c.dropping == 0 {
+			if mark := c.media.Anchor(target); mark != "" {
+				spans = append(spans, docxSpan{text: mark, raw: true})
+			}
+		}
+	}
+	for _, b := range boxes {
+		spans = append(spans, docxSpan{text: " " + b + " "})
+	}
+	return spans
+}
+
+// equation reads an <m:oMath> as inline $...$ math, or an <m:oMathPara> as
+// display $$...$$ math, one line per equation.
+func (c *docxConverter) equation(dec *xml.Decoder, start xml.StartElement, depth *int) []docxSpan {
+	eqs := mathtex.OMML(dec, start)
+	*depth--
+	if c.dropping == 0 {
+		c.equations += len(eqs)
+	}
+	if start.Name.Local == "oMath" {
+		if len(eqs) == 0 {
+			return nil
+		}
+		return []docxSpan{{text: "$" + eqs[0] + "$", raw: true}}
+	}
+	spans := make([]docxSpan, 0, len(eqs))
+	for i, eq := range eqs {
+		text := "$$" + eq + "$$"
+		if i > 0 {
+			text = "\n" + text
+		}
+		spans = append(spans, docxSpan{text: text, raw: true})
+	}
+	return spans
+}
+
+// resolveFields replaces complex fields (fldChar begin … separate … end)
+// with their displayed result. HYPERLINK fields become links; the
+// instructions of every field are dropped.
+func resolveFields(spans []docxSpan) []docxSpan {
+	type frame struct {
+		instr    strings.Builder
+		result   []docxSpan
+		inResult bool
+	}
+	var stack []*frame
+	var out []docxSpan
+	emit := func(s ...docxSpan) {
+		if n := len(stack); n > 0 {
+			if stack[n-1].inResult {
+				stack[n-1].result = append(stack[n-1].result, s...)
+			}
+			return
+		}
+		out = append(out, s...)
+	}
+	for _, s := range spans {
+		n := len(stack)
+		switch s.field {
+		case fieldBegin:
+			stack = append(stack, &frame{})
+		case fieldInstr:
+			if n > 0 && !stack[n-1].inResult {
+				stack[n-1].instr.WriteString(s.text)
+			}
+		case fieldSeparate:
+			if n > 0 {
+				stack[n-1].inResult = true
+			}
+		case fieldEnd:
+			if n == 0 {
+				continue
+			}
+			f := stack[n-1]
+			stack = stack[:n-1]
+			emit(linkSpans(f.result, fieldLinkTarget(f.instr.String()))...)
+		default:
+			emit(s)
+		}
+	}
+	// Unterminated fields keep whatever result they have.
+	for n := len(stack); n > 0; n = len(stack) {
+		f := stack[n-1]
+		stack = stack[:n-1]
+		emit(f.result...)
+	}
+	return out
+}
+
+// fieldLinkTarget returns the target of a HYPERLINK field instruction:
+// HYPERLINK "url" or HYPERLINK \l "bookmark". It is "" for other fields.
+func fieldLinkTarget(instr string) string {
+	args := fieldArgs(instr)
+	if len(args) < 2 || !strings.EqualFold(args[0], "HYPERLINK") {
+		return ""
+	}
+	var target, anchor string
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case `\l`:
+			if i+1 < len(args) {
+				anchor = args[i+1]
+				i++
+			}
+		case `\o`, `\t`:
+			i++ // tooltip and target frame take an argument
+		case `\m`, `\n`, `\h`:
+		default:
+			if target == "" {
+				target = args[i]
+			}
+		}
+	}
+	if anchor != "" {
+		return target + "#" + anchor
+	}
+	return target
+}
+
+// fieldArgs splits a field instruction into words, keeping quoted strings
+// together.
+func fieldArgs(instr string) []string {
+	var args []string
+	var cur strings.Builder
+	quoted, have := false, false
+	for _, r := range instr {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			have = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if have {
+				args = append(args, cur.String())
+				cur.Reset()
+				have = false
+			}
+		default:
+			cur.WriteRune(r)
+			have = true
+		}
+	}
+	if have {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// renderSpans renders resolved spans as markdown. Adjacent text spans with
+// the same formatting share one set of markers, so formatting split across
+// runs does not produce "**Quar****terly**".
+func renderSpans(spans []docxSpan) string {
+	var sb strings.Builder
+	for i := 0; i < len(spans); {
+		s := spans[i]
+		if s.raw || s.fmt == (docxFormat{}) {
+			sb.WriteString(s.text)
+			i++
+			continue
+		}
+		var text strings.Builder
+		j := i
+		for ; j < len(spans) && !spans[j].raw && spans[j].fmt == s.fmt; j++ {
+			text.WriteString(spans[j].text)
+		}
+		sb.WriteString(s.fmt.wrap(text.String()))
+		i = j
+	}
+	return sb.String()
+}
+
+// plainSpans drops run formatting, for headings whose style already
+// conveys emphasis.
+func plainSpans(spans []docxSpan) []docxSpan {
+	out := make([]docxSpan, len(spans))
+	for i, s := range spans {
+		s.fmt = docxFormat{}
+		out[i] = s
+	}
+	return out
+}
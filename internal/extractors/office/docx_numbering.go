@@ -0,0 +1,211 @@
+package office
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// docxStyles holds what the converter needs from word/styles.xml:
+// character style formatting and paragraph styles that imply a list.
+type docxStyles struct {
+	chars map[string]docxFormat
+	lists map[string]docxNumRef // paragraph style id -> numbering
+}
+
+type docxNumRef struct {
+	numID string
+	ilvl  int
+}
+
+// xmlVal is an element whose value is in its w:val attribute.
+type xmlVal struct {
+	Val *string `xml:"val,attr"`
+}
+
+func (v *xmlVal) on() bool {
+	if v == nil {
+		return false
+	}
+	if v.Val == nil {
+		return true
+	}
+	switch *v.Val {
+	case "0", "false", "off", "none":
+		return false
+	}
+	return true
+}
+
+func (v *xmlVal) str() string {
+	if v == nil || v.Val == nil {
+		return ""
+	}
+	return *v.Val
+}
+
+func parseDOCXStyles(b []byte) docxStyles {
+	s := docxStyles{chars: map[string]docxFormat{}, lists: map[string]docxNumRef{}}
+	if b == nil {
+		return s
+	}
+	var doc struct {
+		Styles []struct {
+			Type  string  `xml:"type,attr"`
+			ID    string  `xml:"styleId,attr"`
+			Name  xmlVal  `xml:"name"`
+			NumID *xmlVal `xml:"pPr>numPr>numId"`
+			Ilvl  *xmlVal `xml:"pPr>numPr>ilvl"`
+			RPr   struct {
+				B      *xmlVal `xml:"b"`
+				I      *xmlVal `xml:"i"`
+				Strike *xmlVal `xml:"strike"`
+				Fonts  struct {
+					ASCII string `xml:"ascii,attr"`
+				} `xml:"rFonts"`
+			} `xml:"rPr"`
+		} `xml:"style"`
+	}
+	if xml.Unmarshal(b, &doc) != nil {
+		return s
+	}
+	for _, st := range doc.Styles {
+		switch st.Type {
+		case "character":
+			s.chars[st.ID] = docxFormat{
+				bold:   st.RPr.B.on(),
+				italic: st.RPr.I.on(),
+				strike: st.RPr.Strike.on(),
+				code:   codeStyle(st.Name.str()) || codeStyle(st.RPr.Fonts.ASCII),
+			}
+		case "paragraph":
+			if id := st.NumID.str(); id != "" && id != "0" {
+				lvl, _ := strconv.Atoi(st.Ilvl.str())
+				s.lists[st.ID] = docxNumRef{numID: id, ilvl: lvl}
+			}
+		}
+	}
+	return s
+}
+
+// docxNumbering holds the list definitions from word/numbering.xml and the
+// running counters of ordered lists.
+type docxNumbering struct {
+	levels map[string]map[int]docxLevel // numId -> level -> definition
+	counts map[string][]int             // numId -> items seen per level
+	widths []int                        // marker width per level of the current list
+}
+
+type docxLevel struct {
+	format string // numFmt: bullet, decimal, lowerLetter, none, ...
+	start  int
+}
+
+type docxLvlXML struct {
+	Ilvl   string  `xml:"ilvl,attr"`
+	Start  *xmlVal `xml:"start"`
+	NumFmt *xmlVal `xml:"numFmt"`
+}
+
+func (l docxLvlXML) level() docxLevel {
+	lv := docxLevel{format: l.NumFmt.str(), start: 1}
+	if n, err := strconv.Atoi(l.Start.str()); err == nil {
+		lv.start = n
+	}
+	return lv
+}
+
+func parseDOCXNumbering(b []byte) *docxNumbering {
+	n := &docxNumbering{levels: map[string]map[int]docxLevel{}, counts: map[string][]int{}}
+	if b == nil {
+		return n
+	}
+	var doc struct {
+		Abstract []struct {
+			ID     string       `xml:"abstractNumId,attr"`
+			Levels []docxLvlXML `xml:"lvl"`
+		} `xml:"abstractNum"`
+		Nums []struct {
+			ID        string `xml:"numId,attr"`
+			Abstract  xmlVal `xml:"abstractNumId"`
+			Overrides []struct {
+				Ilvl  string      `xml:"ilvl,attr"`
+				Start *xmlVal     `xml:"startOverride"`
+				Lvl   *docxLvlXML `xml:"lvl"`
+			} `xml:"lvlOverride"`
+		} `xml:"num"`
+	}
+	if xml.Unmarshal(b, &doc) != nil {
+		return n
+	}
+	abstract := map[string]map[int]docxLevel{}
+	for _, a := range doc.Abstract {
+		levels := map[int]docxLevel{}
+		for _, l := range a.Levels {
+			i, _ := strconv.Atoi(l.Ilvl)
+			levels[i] = l.level()
+		}
+		abstract[a.ID] = levels
+	}
+	for _, num := range doc.Nums {
+		levels := map[int]docxLevel{}
+		for i, l := range abstract[num.Abstract.str()] {
+			levels[i] = l
+		}
+		for _, o := range num.Overrides {
+			i, _ := strconv.Atoi(o.Ilvl)
+			if o.Lvl != nil {
+				levels[i] = o.Lvl.level()
+			}
+			if s, err := strconv.Atoi(o.Start.str()); err == nil {
+				l := levels[i]
+				l.start = s
+				levels[i] = l
+			}
+		}
+		n.levels[num.ID] = levels
+	}
+	return n
+}
+
+// item returns the markdown prefix for a list item at level ilvl of list
+// numID: an indent matching the parent items' markers, then "- " for
+// bullets or "N. " for ordered levels. ok is false when the level shows no
+// marker at all.
+func (n *docxNumbering) item(numID string, ilvl int) (prefix string, ok bool) {
+	if ilvl < 0 || ilvl > 8 {
+		ilvl = 0
+	}
+	counts := n.counts[numID]
+	for len(counts) <= ilvl {
+		counts = append(counts, 0)
+	}
+	counts[ilvl]++
+	for i := ilvl + 1; i < len(counts); i++ {
+		counts[i] = 0
+	}
+	n.counts[numID] = counts
+
+	lv, defined := n.levels[numID][ilvl]
+	marker := "-"
+	switch {
+	case lv.format == "none":
+		return "", false
+	case defined && lv.format != "bullet" && lv.format != "":
+		marker = strconv.Itoa(lv.start+counts[ilvl]-1) + "."
+	}
+
+	indent := 0
+	for i := 0; i < ilvl; i++ {
+		if i < len(n.widths) && n.widths[i] > 0 {
+			indent += n.widths[i]
+		} else {
+			indent += 2
+		}
+	}
+	for len(n.widths) <= ilvl {
+		n.widths = append(n.widths, 0)
+	}
+	n.widths[ilvl] = len(marker) + 1
+	return strings.Repeat(" ", indent) + marker + " ", true
+}
@@ -0,0 +1,340 @@
+package office
+
+import (
+	"encoding/xml"
+	"errors"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
+)
+
+// maxCommentAnchor caps the commented text quoted with a comment.
+const maxCommentAnchor = 200
+
+// read returns an auxiliary part, or nil if it is missing or unreadable. Zip
+// limit errors are kept so the extraction still fails on them.
+func (c *docxConverter) read(name string) []byte {
+	b, err := c.zr.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, safezip.ErrLimitsExceeded) && c.err == nil {
+			c.err = err
+		}
+		return nil
+	}
+	return b
+}
+
+// partRelationships maps the relationship ids of an OOXML part to part names
+// and, for external targets such as hyperlinks, to URLs.
+func partRelationships(zr *safezip.Reader, part string) (parts, links map[string]string) {
+	parts, links = map[string]string{}, map[string]string{}
+	b, err := zr.ReadFile(path.Join(path.Dir(part), "_rels", path.Base(part)+".rels"))
+	if err != nil {
+		return parts, links
+	}
+	var doc struct {
+		Relationships []struct {
+			ID         string `xml:"Id,attr"`
+			Target     string `xml:"Target,attr"`
+			TargetMode string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	if xml.Unmarshal(b, &doc) != nil {
+		return parts, links
+	}
+	for _, r := range doc.Relationships {
+		switch {
+		case strings.EqualFold(r.TargetMode, "External"):
+			links[r.ID] = r.Target
+		case strings.HasPrefix(r.Target, "/"):
+			parts[r.ID] = strings.TrimPrefix(r.Target, "/")
+		default:
+			parts[r.ID] = path.Join(path.Dir(part), r.Target)
+		}
+	}
+	return parts, links
+}
+
+// withPartRels runs fn with relationship ids resolved against part, for
+// rendering headers, notes and comments, whose ids are their own.
+func (c *docxConverter) withPartRels(part string, fn func()) {
+	rels, links := c.rels, c.links
+	c.rels, c.links = partRelationships(c.zr, part)
+	fn()
+	c.rels, c.links = rels, links
+}
+
+// linkRefs finds bookmark names targeted by hyperlinks, either
+// w:anchor="name" or a HYPERLINK \l "name" field.
+var linkRefs = regexp.MustCompile(`(?:w:anchor="|\\l\s+"|\\l\s+&quot;)([^"&]+)`)
+
+// linkedBookmarks returns the bookmarks that need an HTML anchor in the
+// output. Other bookmarks (_GoBack, unreferenced _Toc entries) are dropped.
+func linkedBookmarks(body []byte) map[string]bool {
+	names := map[string]bool{}
+	for _, m := range linkRefs.FindAllSubmatch(body, -1) {
+		names[string(m[1])] = true
+	}
+	return names
+}
+
+// --- Footnotes and endnotes ---
+
+// docxNotes holds the bodies of one notes part and the order in which the
+// document first references them. Notes are numbered in that order.
+type docxNotes struct {
+	prefix string // label prefix: "" for footnotes, "e" for endnotes
+	bodies map[string]string
+	labels map[string]string
+	order  []string
+}
+
+func (c *docxConverter) loadNotes(part, elem, prefix string) *docxNotes {
+	b := c.read(part)
+	if b == nil {
+		return nil
+	}
+	n := &docxNotes{prefix: prefix, bodies: map[string]string{}, labels: map[string]string{}}
+	c.withPartRels(part, func() { c.readNotes(n, b, elem) })
+	return n
+}
+
+func (c *docxConverter) readNotes(n *docxNotes, b []byte, elem string) {
+	dec := xml.NewDecoder(strings.NewReader(string(b)))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != elem {
+			continue
+		}
+		// Separator notes hold the rule drawn above the notes, not text.
+		typ := attrValue(se, "type")
+		body := joinBlocks(c.blocks(dec), "\n\n")
+		if (typ == "" || typ == "normal") && body != "" {
+			n.bodies[attrValue(se, "id")] = body
+		}
+	}
+}
+
+// ref returns the markdown reference for note id, numbering it on first use.
+func (n *docxNotes) ref(id string) string {
+	if _, ok := n.bodies[id]; !ok {
+		return ""
+	}
+	label, ok := n.labels[id]
+	if !ok {
+		label = n.prefix + strconv.Itoa(len(n.order)+1)
+		n.labels[id] = label
+		n.order = append(n.order, id)
+	}
+	return "[^" + label + "]"
+}
+
+// definitions returns the referenced notes as markdown footnote definitions.
+// Continuation paragraphs are indented so they stay part of the note.
+func (n *docxNotes) definitions() []string {
+	defs := make([]string, 0, len(n.order))
+	for _, id := range n.order {
+		lines := strings.Split(n.bodies[id], "\n")
+		for i := 1; i < len(lines); i++ {
+			if lines[i] != "" {
+				lines[i] = "    " + lines[i]
+			}
+		}
+		defs = append(defs, "[^"+n.labels[id]+"]: "+strings.Join(lines, "\n"))
+	}
+	return defs
+}
+
+// --- Comments ---
+
+type docxComment struct {
+	author string
+	date   string
+	text   string
+}
+
+func (c *docxConverter) loadComments(part string) map[string]docxComment {
+	b := c.read(part)
+	if b == nil {
+		return nil
+	}
+	comments := map[string]docxComment{}
+	c.withPartRels(part, func() { c.readComments(comments, b) })
+	return comments
+}
+
+func (c *docxConverter) readComments(comments map[string]docxComment, b []byte) {
+	dec := xml.NewDecoder(strings.NewReader(string(b)))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "comment" {
+			continue
+		}
+		comments[attrValue(se, "id")] = docxComment{
+			author: attrValue(se, "author"),
+			date:   attrValue(se, "date"),
+			text:   joinBlocks(c.blocks(dec), "\n"),
+		}
+	}
+}
+
+// flushComments renders the comments referenced since the last block.
+func (c *docxConverter) flushComments() []string {
+	var out []string
+	for _, id := range c.pending {
+		cm, ok := c.comments[id]
+		if !ok || c.emitted[id] {
+			continue
+		}
+		c.emitted[id] = true
+		delete(c.active, id)
+		var anchor string
+		if a := c.anchors[id]; a != nil {
+			anchor = a.String()
+		}
+		out = append(out, cm.markdown(anchor))
+	}
+	c.pending = c.pending[:0]
+	return out
+}
+
+// markdown renders the comment as a quote naming its author, date and the
+// text it was attached to.
+func (cm docxComment) markdown(anchor string) string {
+	head := "**Comment"
+	if cm.author != "" {
+		head += " by " + cm.author
+	}
+	if cm.date != "" {
+		// w:date is an xsd:dateTime; the day is enough to read a thread.
+		head += " (" + strings.SplitN(cm.date, "T", 2)[0] + ")"
+	}
+	head += "**"
+	if anchor = strings.Join(strings.Fields(anchor), " "); anchor != "" {
+		if r := []rune(anchor); len(r) > maxCommentAnchor {
+			anchor = string(r[:maxCommentAnchor]) + "…"
+		}
+		head += ` on "` + anchor + `"`
+	}
+	return quoteBlock(head+":", cm.text)
+}
+
+// quoteBlock renders head followed by body as a markdown blockquote.
+func quoteBlock(head, body string) string {
+	if body == "" {
+		return "> " + head
+	}
+	return "> " + head + "\n> " + strings.ReplaceAll(body, "\n", "\n> ")
+}
+
+// --- Headers and footers ---
+
+// docxSection is one document section. end indexes the rendered blocks just
+// past the section's last block. Header and footer are relationship ids; an
+// empty id means the section inherits the previous section's.
+type docxSection struct {
+	end    int
+	header string
+	footer string
+}
+
+// sectPr reads a <w:sectPr>, keeping the default header and footer
+// references (or the first of another type when there is no default).
+func (c *docxConverter) sectPr(dec *xml.Decoder) docxSection {
+	var sec docxSection
+	depth := 1
+	for depth > 0 {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			typ := attrValue(t, "type")
+			switch t.Name.Local {
+			case "headerReference":
+				if sec.header == "" || typ == "default" {
+					sec.header = attrValue(t, "id")
+				}
+			case "footerReference":
+				if sec.footer == "" || typ == "default" {
+					sec.footer = attrValue(t, "id")
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return sec
+}
+
+// withHeadersFooters places each section's header before it and its footer
+// after it. A header or footer shared by consecutive sections is rendered
+// once for the run of sections rather than repeated.
+func (c *docxConverter) withHeadersFooters(blocks []string) []string {
+	if len(c.sections) == 0 {
+		return blocks
+	}
+	headers := make([]string, len(c.sections))
+	footers := make([]string, len(c.sections))
+	var header, footer string
+	for i, sec := range c.sections {
+		if sec.header != "" {
+			header = sec.header
+		}
+		if sec.footer != "" {
+			footer = sec.footer
+		}
+		headers[i], footers[i] = c.partText(header), c.partText(footer)
+	}
+
+	out := make([]string, 0, len(blocks)+2*len(c.sections))
+	start := 0
+	for i, sec := range c.sections {
+		if headers[i] != "" && (i == 0 || headers[i] != headers[i-1]) {
+			out = append(out, quoteBlock("**Header:**", headers[i]))
+		}
+		end := min(max(sec.end, start), len(blocks))
+		out = append(out, blocks[start:end]...)
+		start = end
+		if footers[i] != "" && (i == len(c.sections)-1 || footers[i] != footers[i+1]) {
+			out = append(out, quoteBlock("**Footer:**", footers[i]))
+		}
+	}
+	return append(out, blocks[start:]...)
+}
+
+// partText renders the header or footer part behind relationship id.
+func (c *docxConverter) partText(id string) string {
+	name, ok := c.rels[id]
+	if id == "" || !ok {
+		return ""
+	}
+	if text, ok := c.partCache[name]; ok {
+		return text
+	}
+	var text string
+	if b := c.read(name); b != nil {
+		// Header and footer images are logos and rules, not content.
+		media := c.media
+		c.media = nil
+		c.withPartRels(name, func() {
+			text = joinBlocks(c.blocks(xml.NewDecoder(strings.NewReader(string(b)))), "\n")
+		})
+		c.media = media
+	}
+	c.partCache[name] = text
+	return text
+}
@@ -0,0 +1,197 @@
+package office
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/toricodesthings/file-processing-service/internal/embedded"
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+const wNS = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+
+func annotatedDOCX(t *testing.T) string {
+	return writeZip(t, "contract.docx", map[string]string{
+		"word/document.xml": `<w:document ` + wNS + `><w:body>
+			<w:p><w:r><w:t>Payment is due</w:t></w:r><w:r><w:footnoteReference w:id="2"/></w:r><w:r><w:t xml:space="preserve"> within </w:t></w:r>
+				<w:commentRangeStart w:id="0"/><w:r><w:t>30 days</w:t></w:r><w:commentRangeEnd w:id="0"/><w:r><w:commentReference w:id="0"/></w:r><w:r><w:t>.</w:t></w:r></w:p>
+			<w:p><w:pPr><w:sectPr><w:headerReference w:type="default" r:id="rH1"/><w:footerReference w:type="default" r:id="rF1"/></w:sectPr></w:pPr><w:r><w:t>End of part one.</w:t></w:r></w:p>
+			<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Fee</w:t></w:r><w:r><w:endnoteReference w:id="1"/></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>100</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+			<w:p><w:r><w:t>Part two.</w:t></w:r></w:p>
+			<w:sectPr><w:headerReference w:type="default" r:id="rH2"/></w:sectPr>
+		</w:body></w:document>`,
+		"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rH1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/header" Target="header1.xml"/>
+			<Relationship Id="rH2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/header" Target="header2.xml"/>
+			<Relationship Id="rF1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/footer" Target="footer1.xml"/>
+		</Relationships>`,
+		"word/header1.xml": `<w:hdr ` + wNS + `><w:p><w:r><w:t>ACME Confidential</w:t></w:r></w:p></w:hdr>`,
+		"word/header2.xml": `<w:hdr ` + wNS + `><w:p><w:r><w:t>Schedule A</w:t></w:r></w:p></w:hdr>`,
+		"word/footer1.xml": `<w:ftr ` + wNS + `><w:p><w:r><w:t>Page footer</w:t></w:r></w:p></w:ftr>`,
+		"word/footnotes.xml": `<w:footnotes ` + wNS + `>
+			<w:footnote w:type="separator" w:id="-1"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>
+			<w:footnote w:id="2"><w:p><w:r><w:footnoteRef/></w:r><w:r><w:t xml:space="preserve"> Net of taxes.</w:t></w:r></w:p></w:footnote>
+		</w:footnotes>`,
+		"word/endnotes.xml": `<w:endnotes ` + wNS + `><w:endnote w:id="1"><w:p><w:r><w:t>In euros.</w:t></w:r></w:p></w:endnote></w:endnotes>`,
+		"word/comments.xml": `<w:comments ` + wNS + `><w:comment w:id="0" w:author="Dana Reviewer" w:date="2024-03-05T10:00:00Z"><w:p><w:r><w:t>Should be 45?</w:t></w:r></w:p></w:comment></w:comments>`,
+	})
+}
+
+func TestDOCXNotesCommentsHeaders(t *testing.T) {
+	res, err := NewDOCX(1<<20).Extract(context.Background(), extract.Job{LocalPath: annotatedDOCX(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"> **Header:**\n> ACME Confidential",
+		"Payment is due[^1] within 30 days.",
+		"> **Comment by Dana Reviewer (2024-03-05)** on \"30 days\":\n> Should be 45?",
+		"End of part one.",
+		"> **Header:**\n> Schedule A",
+		"| Fee[^e1] | 100 |\n| --- | --- |",
+		"Part two.",
+		"> **Footer:**\n> Page footer",
+		"[^1]: Net of taxes.",
+		"[^e1]: In euros.",
+	}, "\n\n")
+	if res.Text != want {
+		t.Fatalf("text =\n%s\n\nwant\n%s", res.Text, want)
+	}
+	if res.Metadata["footnotes"] != "1" || res.Metadata["endnotes"] != "1" || res.Metadata["comments"] != "1" {
+		t.Fatalf("metadata = %v", res.Metadata)
+	}
+}
+
+func TestDOCXPartsCanBeDisabled(t *testing.T) {
+	opts := map[string]any{"footnotes": false, "comments": "false", "headersFooters": false}
+	res, err := NewDOCX(1<<20).Extract(context.Background(), extract.Job{LocalPath: annotatedDOCX(t), Options: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"[^", "Comment", "Header", "Footer"} {
+		if strings.Contains(res.Text, s) {
+			t.Fatalf("text contains %q:\n%s", s, res.Text)
+		}
+	}
+	if !strings.HasPrefix(res.Text, "Payment is due within 30 days.") {
+		t.Fatalf("text = %q", res.Text)
+	}
+}
+
+func TestDOCXRevisionModes(t *testing.T) {
+	path := writeZip(t, "draft.docx", map[string]string{
+		"word/document.xml": `<w:document ` + wNS + `><w:body><w:p><w:r><w:t xml:space="preserve">Pay within </w:t></w:r>` +
+			`<w:del w:id="1" w:author="Ben" w:date="2024-05-02T09:00:00Z"><w:r><w:delText>thirty</w:delText></w:r></w:del>` +
+			`<w:ins w:id="2" w:author="Ana" w:date="2024-05-01T09:00:00Z"><w:r><w:t>sixty</w:t></w:r></w:ins>` +
+			`<w:r><w:t xml:space="preserve"> days.</w:t></w:r></w:p></w:body></w:document>`,
+	})
+	for mode, want := range map[string]string{
+		"":       "Pay within sixty days.",
+		"reject": "Pay within thirty days.",
+		"markup": "Pay within {--thirty--}{>>Ben, 2024-05-02<<}{++sixty++}{>>Ana, 2024-05-01<<} days.",
+	} {
+		res, err := NewDOCX(1<<20).Extract(context.Background(), extract.Job{LocalPath: path, Options: map[string]any{"revisions": mode}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Text != want {
+			t.Errorf("%q: text = %q, want %q", mode, res.Text, want)
+		}
+		if res.Metadata["revisionInsertions"] != "1" || res.Metadata["revisionDeletions"] != "1" {
+			t.Errorf("%q: metadata = %v", mode, res.Metadata)
+		}
+	}
+}
+
+func TestDOCXInlineFormattingLinksAndLists(t *testing.T) {
+	const numbering = `<w:numbering ` + wNS + `>
+		<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="decimal"/></w:lvl><w:lvl w:ilvl="1"><w:numFmt w:val="bullet"/></w:lvl></w:abstractNum>
+		<w:abstractNum w:abstractNumId="1"><w:lvl w:ilvl="0"><w:numFmt w:val="bullet"/></w:lvl></w:abstractNum>
+		<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+		<w:num w:numId="2"><w:abstractNumId w:val="1"/></w:num>
+	</w:numbering>`
+	item := func(num, lvl, text string) string {
+		return `<w:p><w:pPr><w:numPr><w:ilvl w:val="` + lvl + `"/><w:numId w:val="` + num + `"/></w:numPr></w:pPr><w:r><w:t>` + text + `</w:t></w:r></w:p>`
+	}
+	path := writeZip(t, "rich.docx", map[string]string{
+		"word/document.xml": `<w:document ` + wNS + ` xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><w:body>
+			<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:bookmarkStart w:id="0" w:name="_Toc1"/><w:r><w:rPr><w:b/></w:rPr><w:t>Scope</w:t></w:r><w:bookmarkEnd w:id="0"/><w:bookmarkStart w:id="1" w:name="_GoBack"/></w:p>
+			<w:p><w:r><w:t xml:space="preserve">This is </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>very</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve"> important </w:t></w:r><w:r><w:rPr><w:i/><w:strike/></w:rPr><w:t>old</w:t></w:r><w:r><w:t xml:space="preserve"> and </w:t></w:r><w:r><w:rPr><w:rFonts w:ascii="Consolas"/></w:rPr><w:t>go test</w:t></w:r><w:r><w:t>.</w:t></w:r></w:p>
+			<w:p><w:hyperlink r:id="rLink"><w:r><w:t>our site</w:t></w:r></w:hyperlink><w:r><w:t xml:space="preserve">, </w:t></w:r><w:hyperlink w:anchor="_Toc1"><w:r><w:t>back to scope</w:t></w:r></w:hyperlink><w:r><w:t xml:space="preserve">, </w:t></w:r>
+				<w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText xml:space="preserve"> HYPERLINK "https://example.org/terms" \o "Terms" </w:instrText></w:r><w:r><w:fldChar w:fldCharType="separate"/></w:r><w:r><w:t>terms</w:t></w:r><w:r><w:fldChar w:fldCharType="end"/></w:r></w:p>
+			<w:p><w:r><w:drawing><wp:inline><wp:docPr id="1" name="Picture 1" descr="Revenue by quarter"/><a:graphic><a:graphicData><a:blip r:embed="rImg"/></a:graphicData></a:graphic></wp:inline></w:drawing></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="Caption"/></w:pPr><w:r><w:t xml:space="preserve">Figure </w:t></w:r><w:fldSimple w:instr=" SEQ Figure \* ARABIC "><w:r><w:t>1</w:t></w:r></w:fldSimple><w:r><w:t>: Revenue</w:t></w:r></w:p>
+			` + item("1", "0", "First") + item("1", "1", "Detail") + item("1", "0", "Second") + item("2", "0", "Loose") + `
+		</w:body></w:document>`,
+		"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rLink" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/" TargetMode="External"/>
+			<Relationship Id="rImg" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/image1.png"/>
+		</Relationships>`,
+		"word/numbering.xml": numbering,
+	})
+	res, err := NewDOCX(1<<20).Extract(context.Background(), extract.Job{LocalPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		`# <a id="_Toc1"></a>Scope`,
+		"This is **very important** *~~old~~* and `go test`.",
+		"[our site](https://example.com/), [back to scope](#_Toc1), [terms](https://example.org/terms)",
+		"![Revenue by quarter](image1.png)",
+		"*Figure 1: Revenue*",
+		"1. First",
+		"   - Detail",
+		"2. Second",
+		"- Loose",
+	}, "\n\n")
+	if res.Text != want {
+		t.Fatalf("text =\n%s\n\nwant\n%s", res.Text, want)
+	}
+}
+
+func TestDOCXEquations(t *testing.T) {
+	const m = `xmlns:m="http://schemas.openxmlformats.org/officeDocument/2006/math" xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006"`
+	path := writeZip(t, "physics.docx", map[string]string{
+		"word/document.xml": `<w:document ` + wNS + ` ` + m + `><w:body>
+			<w:p><w:r><w:t xml:space="preserve">Energy is </w:t></w:r><m:oMath><m:r><m:t>E=m</m:t></m:r><m:sSup><m:e><m:r><m:t>c</m:t></m:r></m:e><m:sup><m:r><m:t>2</m:t></m:r></m:sup></m:sSup></m:oMath><w:r><w:t>.</w:t></w:r></w:p>
+			<w:p><m:oMathPara><m:oMath><m:f><m:num><m:r><m:t>1</m:t></m:r></m:num><m:den><m:r><m:t>2</m:t></m:r></m:den></m:f></m:oMath></m:oMathPara></w:p>
+			<w:p><w:r><mc:AlternateContent><mc:Choice Requires="wps"><w:t>Box</w:t></mc:Choice><mc:Fallback><w:t>Box</w:t></mc:Fallback></mc:AlternateContent></w:r></w:p>
+		</w:body></w:document>`,
+	})
+	res, err := NewDOCX(1<<20).Extract(context.Background(), extract.Job{LocalPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Energy is $E=mc^{2}$.\n\n$$\\frac{1}{2}$$\n\nBox"
+	if res.Text != want {
+		t.Fatalf("text =\n%s\n\nwant\n%s", res.Text, want)
+	}
+	if res.Metadata["equations"] != "2" {
+		t.Errorf("equations = %q", res.Metadata["equations"])
+	}
+}
+
+func TestDOCXEmbeddedImagesSkipsIcons(t *testing.T) {
+	path := writeZip(t, "icons.docx", map[string]string{
+		"word/document.xml": `<w:document ` + wNS + ` xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><w:body>
+			<w:p><w:r><w:drawing><wp:inline><wp:docPr id="1" name="Picture 1" descr="Tick"/><a:graphic><a:graphicData><a:blip r:embed="rImg"/></a:graphicData></a:graphic></wp:inline></w:drawing></w:r><w:r><w:t xml:space="preserve"> Done</w:t></w:r></w:p>
+		</w:body></w:document>`,
+		"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rImg" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/image1.gif"/>
+		</Relationships>`,
+		// A 1x1 GIF: below the size filter, so no provider is called.
+		"word/media/image1.gif": "GIF89a\x01\x00\x01\x00\x00\x00\x00;",
+	})
+	x := NewDOCX(1 << 20).WithEmbeddedImages(embedded.Config{MaxImages: 5, MinPixels: 100})
+	res, err := x.Extract(context.Background(), extract.Job{LocalPath: path, Options: map[string]any{"embeddedImages": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "![Tick](image1.gif) Done" {
+		t.Errorf("text = %q", res.Text)
+	}
+	if res.Metadata["embeddedImagesFound"] != "0" || res.Metadata["embeddedImagesAnalyzed"] != "0" {
+		t.Errorf("metadata = %v", res.Metadata)
+	}
+}
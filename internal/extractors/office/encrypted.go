@@ -0,0 +1,111 @@
+package office
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
+	"github.com/xuri/excelize/v2"
+)
+
+// cfbMagic is the OLE compound file signature. Password-protected OOXML
+// files are stored as a CFB container holding EncryptionInfo and
+// EncryptedPackage streams instead of a plain zip.
+var cfbMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+var (
+	errPasswordRequired  = extract.WithCode(extract.CodePasswordRequired, errors.New("document is password protected; supply the password option"))
+	errPasswordIncorrect = extract.WithCode(extract.CodePasswordIncorrect, errors.New("incorrect document password"))
+)
+
+// openOOXML opens a DOCX/PPTX package, transparently decrypting ECMA-376
+// encrypted files with password. The returned closer must be closed by the
+// caller.
+func openOOXML(path, password string) (*safezip.Reader, io.Closer, error) {
+	encrypted, err := isCFB(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !encrypted {
+		zr, err := safezip.OpenReader(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr.Reader, zr, nil
+	}
+
+	if password == "" {
+		return nil, nil, errPasswordRequired
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := excelize.Decrypt(raw, &excelize.Options{Password: password})
+	if err != nil {
+		if errors.Is(err, excelize.ErrUnsupportedEncryptMechanism) || errors.Is(err, excelize.ErrUnknownEncryptMechanism) {
+			return nil, nil, fmt.Errorf("decrypt document: %w", err)
+		}
+		return nil, nil, errPasswordIncorrect
+	}
+	// A wrong password decrypts to garbage rather than failing outright, so
+	// the zip check doubles as password verification.
+	zr, err := safezip.NewReader(bytes.NewReader(plain), int64(len(plain)))
+	if errors.Is(err, safezip.ErrLimitsExceeded) {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, errPasswordIncorrect
+	}
+	return zr, io.NopCloser(nil), nil
+}
+
+func isCFB(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, len(cfbMagic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(head, cfbMagic), nil
+}
+
+func stringOption(options map[string]any, key string) string {
+	if options == nil {
+		return ""
+	}
+	s, _ := options[key].(string)
+	return s
+}
+
+func boolOption(options map[string]any, key string, fallback bool) bool {
+	if options == nil {
+		return fallback
+	}
+	v, ok := options[key]
+	if !ok {
+		return fallback
+	}
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(b))
+		if err != nil {
+			return fallback
+		}
+		return parsed
+	default:
+		return fallback
+	}
+}
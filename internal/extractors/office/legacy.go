@@ -2,58 +2,212 @@ package office
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/toricodesthings/file-processing-service/internal/embedded"
 	"github.com/toricodesthings/file-processing-service/internal/extract"
+	opendocumentextractor "github.com/toricodesthings/file-processing-service/internal/extractors/opendocument"
+	"github.com/toricodesthings/file-processing-service/internal/libreoffice"
 )
 
+// LegacyExtractor converts formats only LibreOffice reads into OOXML (or
+// ODF drawings) and hands the result to the structured extractor for that
+// format. Headings, lists, tables, sheets and slides survive the round
+// trip. If conversion or extraction fails, it falls back to LibreOffice's
+// plain-text export where the format has one.
 type LegacyExtractor struct {
-	binary  string
+	conv    libreoffice.Converter
 	timeout time.Duration
 	maxSize int64
+	targets map[string]legacyTarget
 }
 
-func NewLegacy(binary string, timeout time.Duration, maxSize int64) *LegacyExtractor {
-	if strings.TrimSpace(binary) == "" {
-		binary = "soffice"
+// legacyTarget is what one legacy family converts to.
+type legacyTarget struct {
+	ext      string // extension of the converted file
+	format   string // soffice --convert-to format
+	mimeType string
+	delegate extract.Extractor
+	// textFormat is the plain-text fallback; "" when LibreOffice has none
+	// for the family (presentations, drawings).
+	textFormat string
+}
+
+// NewLegacy converts with conv, typically a libreoffice.Pool, or a cold
+// soffice per file when conv is nil.
+func NewLegacy(conv libreoffice.Converter, timeout time.Duration, maxSize int64) *LegacyExtractor {
+	if conv == nil {
+		conv = libreoffice.Cold{Binary: "soffice"}
 	}
 	if timeout <= 0 {
 		timeout = 60 * time.Second
 	}
-	return &LegacyExtractor{binary: binary, timeout: timeout, maxSize: maxSize}
+
+	text := legacyTarget{
+		ext: "docx", format: "docx:MS Word 2007 XML",
+		mimeType:   "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		delegate:   NewDOCX(maxSize),
+		textFormat: "txt:Text",
+	}
+	sheet := legacyTarget{
+		ext: "xlsx", format: "xlsx:Calc MS Excel 2007 XML",
+		mimeType:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		delegate:   NewXLSX(maxSize),
+		textFormat: "csv:Text - txt - csv (StarCalc)",
+	}
+	slides := legacyTarget{
+		ext: "pptx", format: "pptx:Impress MS PowerPoint 2007 XML",
+		mimeType: "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		delegate: NewPPTX(maxSize),
+	}
+	// LibreOffice opens Publisher and Visio files in Draw, which has no
+	// OOXML export.
+	drawing := legacyTarget{
+		ext: "odg", format: "odg:draw8",
+		mimeType: "application/vnd.oasis.opendocument.graphics",
+		delegate: opendocumentextractor.New(maxSize),
+	}
+
+	return &LegacyExtractor{conv: conv, timeout: timeout, maxSize: maxSize, targets: map[string]legacyTarget{
+		".doc": text, ".wpd": text, ".hwp": text,
+		".xls": sheet, ".xlsb": sheet,
+		".ppt": slides,
+		".pub": drawing, ".vsd": drawing,
+
+		"application/msword":                                    text,
+		"application/vnd.wordperfect":                           text,
+		"application/x-hwp":                                     text,
+		"application/vnd.ms-excel":                              sheet,
+		"application/vnd.ms-excel.sheet.binary.macroenabled.12": sheet,
+		"application/vnd.ms-powerpoint":                         slides,
+		"application/x-mspublisher":                             drawing,
+		"application/vnd.visio":                                 drawing,
+	}}
+}
+
+// WithEmbeddedImages passes cfg to the extractors that read converted
+// documents and drawings.
+func (e *LegacyExtractor) WithEmbeddedImages(cfg embedded.Config) *LegacyExtractor {
+	for _, t := range e.targets {
+		switch d := t.delegate.(type) {
+		case *DOCXExtractor:
+			d.WithEmbeddedImages(cfg)
+		case *PPTXExtractor:
+			d.WithEmbeddedImages(cfg)
+		case *opendocumentextractor.Extractor:
+			d.WithEmbeddedImages(cfg)
+		}
+	}
+	return e
 }
 
 func (e *LegacyExtractor) Name() string       { return "document/legacy-office" }
 func (e *LegacyExtractor) MaxFileSize() int64 { return e.maxSize }
 func (e *LegacyExtractor) SupportedTypes() []string {
-	return []string{"application/msword", "application/vnd.ms-excel", "application/vnd.ms-powerpoint"}
+	return []string{
+		"application/msword", "application/vnd.ms-excel", "application/vnd.ms-powerpoint",
+		"application/vnd.wordperfect", "application/x-hwp",
+		"application/vnd.ms-excel.sheet.binary.macroenabled.12",
+		"application/x-mspublisher", "application/vnd.visio",
+	}
+}
+func (e *LegacyExtractor) SupportedExtensions() []string {
+	return []string{".doc", ".xls", ".ppt", ".wpd", ".hwp", ".xlsb", ".pub", ".vsd"}
 }
-func (e *LegacyExtractor) SupportedExtensions() []string { return []string{".doc", ".xls", ".ppt"} }
 
 func (e *LegacyExtractor) Extract(ctx context.Context, job extract.Job) (extract.Result, error) {
-	localCtx, cancel := context.WithTimeout(ctx, e.timeout)
-	defer cancel()
-
-	outDir := filepath.Dir(job.LocalPath)
-	cmd := exec.CommandContext(localCtx, e.binary, "--headless", "--convert-to", "txt:Text", "--outdir", outDir, job.LocalPath)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		msg := fmt.Sprintf("libreoffice conversion failed: %v: %s", err, strings.TrimSpace(string(out)))
+	target, ok := e.target(job)
+	if !ok {
+		err := fmt.Errorf("unsupported legacy format %q", job.MIMEType)
+		msg := err.Error()
 		return extract.Result{Success: false, Method: "libreoffice", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
 	}
 
-	txtPath := strings.TrimSuffix(job.LocalPath, filepath.Ext(job.LocalPath)) + ".txt"
-	b, err := os.ReadFile(txtPath)
-	if err != nil {
+	res, err := e.viaOOXML(ctx, job, target)
+	if err == nil {
+		return res, nil
+	}
+	if errors.Is(err, context.Canceled) || target.textFormat == "" {
 		msg := err.Error()
-		return extract.Result{Success: false, Method: "libreoffice", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, Method: "libreoffice", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
 	}
 
-	text := strings.TrimSpace(string(b))
+	text, terr := e.plainText(ctx, job, target.textFormat)
+	if terr != nil {
+		msg := err.Error()
+		return extract.Result{Success: false, Method: "libreoffice", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
+	}
 	words, chars := extract.BuildCounts(text)
-	return extract.Result{Success: true, Text: text, Method: "libreoffice", FileType: e.Name(), MIMEType: job.MIMEType, WordCount: words, CharCount: chars}, nil
+	meta := map[string]string{"structuredConversionError": err.Error()}
+	return extract.Result{Success: true, Text: text, Method: "libreoffice-text", FileType: e.Name(), MIMEType: job.MIMEType, Metadata: meta, WordCount: words, CharCount: chars}, nil
+}
+
+func (e *LegacyExtractor) target(job extract.Job) (legacyTarget, bool) {
+	for _, name := range []string{job.FileName, job.LocalPath} {
+		if t, ok := e.targets[strings.ToLower(filepath.Ext(name))]; ok {
+			return t, true
+		}
+	}
+	mt, _, _ := strings.Cut(strings.ToLower(job.MIMEType), ";")
+	t, ok := e.targets[strings.TrimSpace(mt)]
+	return t, ok
+}
+
+// viaOOXML converts the file to target's format and extracts it with the
+// target's extractor, reporting the result as this extractor's.
+func (e *LegacyExtractor) viaOOXML(ctx context.Context, job extract.Job, target legacyTarget) (extract.Result, error) {
+	out, err := e.convert(ctx, job.LocalPath, target.ext, target.format)
+	if err != nil {
+		return extract.Result{}, err
+	}
+	defer os.Remove(out)
+
+	st, err := os.Stat(out)
+	if err != nil {
+		return extract.Result{}, err
+	}
+	sub := job
+	sub.LocalPath, sub.MIMEType, sub.FileSize = out, target.mimeType, st.Size()
+	res, err := target.delegate.Extract(ctx, sub)
+	if err != nil {
+		return extract.Result{}, fmt.Errorf("extract converted %s: %w", target.ext, err)
+	}
+
+	res.Method = "libreoffice+" + res.Method
+	res.FileType = e.Name()
+	res.MIMEType = job.MIMEType
+	if res.Metadata == nil {
+		res.Metadata = map[string]string{}
+	}
+	res.Metadata["convertedTo"] = target.ext
+	return res, nil
+}
+
+func (e *LegacyExtractor) plainText(ctx context.Context, job extract.Job, format string) (string, error) {
+	ext, _, _ := strings.Cut(format, ":")
+	out, err := e.convert(ctx, job.LocalPath, ext, format)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(out)
+	b, err := os.ReadFile(out)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (e *LegacyExtractor) convert(ctx context.Context, in, ext, format string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	out := strings.TrimSuffix(in, filepath.Ext(in)) + ".converted." + ext
+	if err := e.conv.Convert(ctx, in, out, format); err != nil {
+		return "", err
+	}
+	return out, nil
 }
@@ -0,0 +1,77 @@
+package office
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+// fakeConverter "converts" by writing canned output per target extension.
+type fakeConverter map[string][]byte
+
+func (f fakeConverter) Convert(_ context.Context, _, out, format string) error {
+	ext, _, _ := strings.Cut(format, ":")
+	b, ok := f[ext]
+	if !ok {
+		return errors.New("no export filter")
+	}
+	return os.WriteFile(out, b, 0o600)
+}
+
+func minimalDOCX(t *testing.T, body string) []byte {
+	t.Helper()
+	path := writeZip(t, "x.docx", map[string]string{
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` + body + `</w:body></w:document>`,
+	})
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func legacyJob(t *testing.T, name string) extract.Job {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("legacy bytes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return extract.Job{LocalPath: path, FileName: name, MIMEType: "application/octet-stream"}
+}
+
+func TestLegacyDelegatesToDOCX(t *testing.T) {
+	docx := minimalDOCX(t, `<w:p><w:r><w:t>Quarterly report</w:t></w:r></w:p>`)
+	e := NewLegacy(fakeConverter{"docx": docx}, 0, 1<<20)
+
+	res, err := e.Extract(context.Background(), legacyJob(t, "old.wpd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Method != "libreoffice+native" || res.FileType != "document/legacy-office" || res.Metadata["convertedTo"] != "docx" {
+		t.Fatalf("result = %+v", res)
+	}
+	if !strings.Contains(res.Text, "Quarterly report") {
+		t.Fatalf("text = %q", res.Text)
+	}
+}
+
+func TestLegacyFallsBackToPlainText(t *testing.T) {
+	e := NewLegacy(fakeConverter{"txt": []byte("  just text \n")}, 0, 1<<20)
+	res, err := e.Extract(context.Background(), legacyJob(t, "old.doc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Method != "libreoffice-text" || res.Text != "just text" || res.Metadata["structuredConversionError"] == "" {
+		t.Fatalf("result = %+v", res)
+	}
+
+	// Presentations have no text export to fall back to.
+	if _, err := e.Extract(context.Background(), legacyJob(t, "old.ppt")); err == nil {
+		t.Fatal("expected failure for .ppt without pptx conversion")
+	}
+}
@@ -1,31 +1,49 @@
 package office
 
 import (
-	"archive/zip"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"sort"
 	"strings"
 
+	"github.com/toricodesthings/file-processing-service/internal/embedded"
 	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/mathtex"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
 )
 
 type PPTXExtractor struct {
 	maxBytes int64
+	images   embedded.Config
 }
 
 func NewPPTX(maxBytes int64) *PPTXExtractor {
 	return &PPTXExtractor{maxBytes: maxBytes}
 }
 
+// WithEmbeddedImages enables the embeddedImages option, which runs pictures
+// placed on slides through OCR/vision.
+func (e *PPTXExtractor) WithEmbeddedImages(cfg embedded.Config) *PPTXExtractor {
+	e.images = cfg
+	return e
+}
+
 func (e *PPTXExtractor) Name() string       { return "document/pptx" }
 func (e *PPTXExtractor) MaxFileSize() int64 { return e.maxBytes }
 func (e *PPTXExtractor) SupportedTypes() []string {
-	return []string{"application/vnd.openxmlformats-officedocument.presentationml.presentation"}
+	return []string{
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"application/vnd.openxmlformats-officedocument.presentationml.template",
+		"application/vnd.ms-powerpoint.presentation.macroenabled.12",
+		"application/vnd.ms-powerpoint.template.macroenabled.12",
+	}
+}
+func (e *PPTXExtractor) SupportedExtensions() []string {
+	return []string{".pptx", ".pptm", ".potx", ".potm"}
 }
-func (e *PPTXExtractor) SupportedExtensions() []string { return []string{".pptx"} }
 
 func (e *PPTXExtractor) Extract(ctx context.Context, job extract.Job) (extract.Result, error) {
 	select {
@@ -34,12 +52,12 @@ func (e *PPTXExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 	default:
 	}
 
-	zr, err := zip.OpenReader(job.LocalPath)
+	zr, closer, err := openOOXML(job.LocalPath, stringOption(job.Options, "password"))
 	if err != nil {
 		msg := err.Error()
-		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
 	}
-	defer zr.Close()
+	defer closer.Close()
 
 	// Collect slide files in order
 	slideNames := make([]string, 0)
@@ -50,12 +68,13 @@ func (e *PPTXExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 	}
 	sort.Strings(slideNames)
 
-	meta := parseCoreMetadata(&zr.Reader)
+	meta := parseCoreMetadata(zr)
 	if meta == nil {
 		meta = map[string]string{}
 	}
 	meta["slides"] = fmt.Sprintf("%d", len(slideNames))
 
+	media := embedded.NewCollector(e.images, job.Options)
 	parts := make([]string, 0, len(slideNames))
 	for i, name := range slideNames {
 		slideNum := i + 1
@@ -63,19 +82,28 @@ func (e *PPTXExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 		sb.WriteString(fmt.Sprintf("## Slide %d", slideNum))
 
 		// Extract slide body text
-		b, err := readZipFile(&zr.Reader, name)
+		b, err := zr.ReadFile(name)
+		if errors.Is(err, safezip.ErrLimitsExceeded) {
+			msg := err.Error()
+			return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
+		}
 		if err != nil {
 			continue
 		}
-		slideText := pptxExtractTextBlocks(b)
+		var anchor func(rid string) string
+		if media != nil {
+			rels, _ := partRelationships(zr, name)
+			anchor = func(rid string) string { return media.Anchor(rels[rid]) }
+		}
+		slideText := pptxExtractTextBlocks(b, anchor)
 		if slideText != "" {
 			sb.WriteString("\n\n" + slideText)
 		}
 
 		// Extract speaker notes from ppt/notesSlides/notesSlideN.xml
 		notesPath := fmt.Sprintf("ppt/notesSlides/notesSlide%d.xml", slideNum)
-		if nb, err := readZipFile(&zr.Reader, notesPath); err == nil {
-			notesText := pptxExtractTextBlocks(nb)
+		if nb, err := zr.ReadFile(notesPath); err == nil {
+			notesText := pptxExtractTextBlocks(nb, nil)
 			// Filter out the slide number placeholder text that's often in notes
 			notesText = strings.TrimSpace(notesText)
 			if notesText != "" {
@@ -87,19 +115,33 @@ func (e *PPTXExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 	}
 
 	text := strings.Join(parts, "\n\n---\n\n")
+	if media != nil {
+		var images embedded.Stats
+		text, images = media.Resolve(ctx, text, zr.ReadFile)
+		images.AddTo(meta)
+	}
 
 	if len(meta) > 0 {
 		text = metadataFrontmatter(meta) + text
 	}
 
+	sec, err := scanOOXMLSecurity(zr)
+	if err != nil {
+		msg := err.Error()
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
+	}
+	sec.addTo(meta)
+
 	text = strings.TrimSpace(text)
 	words, chars := extract.BuildCounts(text)
 	return extract.Result{Success: true, Text: text, Method: "native", FileType: e.Name(), MIMEType: job.MIMEType, Metadata: meta, WordCount: words, CharCount: chars}, nil
 }
 
 // pptxExtractTextBlocks walks OOXML slide/notes XML and returns text organized by paragraphs.
+// When anchor is set, each picture becomes a paragraph holding the anchor
+// for its relationship id.
 // Groups <a:p> elements, joining <a:r>/<a:t> text runs within each paragraph.
-func pptxExtractTextBlocks(b []byte) string {
+func pptxExtractTextBlocks(b []byte, anchor func(rid string) string) string {
 	dec := xml.NewDecoder(strings.NewReader(string(b)))
 	var paragraphs []string
 	var currentPara []string
@@ -118,6 +160,31 @@ func pptxExtractTextBlocks(b []byte) string {
 					inParagraph = true
 					currentPara = nil
 				}
+			case "oMath", "oMathPara":
+				// Equations sit in a paragraph as inline math, or on their
+				// own as display math.
+				delim := "$"
+				if t.Name.Local == "oMathPara" {
+					delim = "$$"
+				}
+				for _, eq := range mathtex.OMML(dec, t) {
+					currentPara = append(currentPara, delim+eq+delim)
+				}
+			case "blip":
+				if anchor == nil {
+					break
+				}
+				for _, a := range t.Attr {
+					if a.Name.Local == "embed" {
+						if mark := anchor(a.Value); mark != "" {
+							paragraphs = append(paragraphs, mark)
+						}
+					}
+				}
+			case "Fallback":
+				// mc:AlternateContent falls back to a picture or flattened
+				// text of the equation or shape its choice already holds.
+				dec.Skip()
 			}
 		case xml.CharData:
 			if inParagraph {
@@ -0,0 +1,223 @@
+package office
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
+)
+
+// ooxmlSecurity summarises the parts of an OOXML package that can run code
+// or fetch content when the file is opened in Office.
+type ooxmlSecurity struct {
+	macros           bool
+	externalTemplate string // remote attachedTemplate target, "" if none
+	oleLinks         int    // OLE objects linked to an external file
+	ddeFields        int    // DDE/DDEAUTO fields, DDE external links and DDE formulas
+	remoteTargets    int    // other external relationships, hyperlinks excluded
+	hosts            []string
+}
+
+// maxReportedHosts caps remoteTargetHosts so a document with thousands of
+// links cannot bloat the metadata.
+const maxReportedHosts = 10
+
+// scanOOXMLSecurity inspects zr for VBA projects, external relationships and
+// DDE. Parts that fail to read are skipped; only zip limit errors are
+// returned.
+func scanOOXMLSecurity(zr *safezip.Reader) (ooxmlSecurity, error) {
+	var s ooxmlSecurity
+	hosts := map[string]bool{}
+
+	for _, f := range zr.File {
+		name := f.Name
+		switch {
+		case strings.EqualFold(path.Base(name), "vbaProject.bin"):
+			s.macros = true
+		case strings.HasSuffix(name, ".rels"):
+			b, err := zr.ReadFile(name)
+			if errors.Is(err, safezip.ErrLimitsExceeded) {
+				return s, err
+			}
+			if err == nil {
+				s.addRelationships(b, hosts)
+			}
+		case strings.HasSuffix(name, ".xml") && isFieldPart(name):
+			b, err := zr.ReadFile(name)
+			if errors.Is(err, safezip.ErrLimitsExceeded) {
+				return s, err
+			}
+			if err == nil {
+				s.ddeFields += countDDE(name, b)
+			}
+		}
+	}
+
+	for h := range hosts {
+		s.hosts = append(s.hosts, h)
+	}
+	sort.Strings(s.hosts)
+	if len(s.hosts) > maxReportedHosts {
+		s.hosts = s.hosts[:maxReportedHosts]
+	}
+	return s, nil
+}
+
+// isFieldPart reports whether name is a part that can carry DDE: Word body
+// parts, workbook external links and worksheets.
+func isFieldPart(name string) bool {
+	return strings.HasPrefix(name, "word/") ||
+		strings.HasPrefix(name, "xl/externalLinks/") ||
+		strings.HasPrefix(name, "xl/worksheets/")
+}
+
+func (s *ooxmlSecurity) addRelationships(b []byte, hosts map[string]bool) {
+	var rels struct {
+		Relationships []struct {
+			Type       string `xml:"Type,attr"`
+			Target     string `xml:"Target,attr"`
+			TargetMode string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	if xml.Unmarshal(b, &rels) != nil {
+		return
+	}
+	for _, r := range rels.Relationships {
+		if !strings.EqualFold(r.TargetMode, "External") {
+			continue
+		}
+		kind := path.Base(r.Type)
+		if kind == "hyperlink" {
+			continue
+		}
+		switch kind {
+		case "attachedTemplate":
+			s.externalTemplate = r.Target
+		case "oleObject":
+			s.oleLinks++
+		default:
+			s.remoteTargets++
+		}
+		if u, err := url.Parse(r.Target); err == nil && u.Host != "" {
+			hosts[strings.ToLower(u.Host)] = true
+		}
+	}
+}
+
+// ddeFormula matches worksheet formulas calling a DDE server, e.g.
+// =cmd|'/c calc'!A0.
+var ddeFormula = regexp.MustCompile(`<f[^>]*>[^<]*[A-Za-z0-9_.]+\|[^<]*![^<]*</f>`)
+
+func countDDE(name string, b []byte) int {
+	switch {
+	case strings.HasPrefix(name, "xl/externalLinks/"):
+		return strings.Count(string(b), "<ddeLink")
+	case strings.HasPrefix(name, "xl/worksheets/"):
+		return len(ddeFormula.FindAll(b, -1))
+	}
+	return countDDEFields(b)
+}
+
+// countDDEFields counts DDE and DDEAUTO field codes in a WordprocessingML
+// part. Complex fields split their instruction across runs, so instrText
+// is collected from the field's begin to its separator.
+func countDDEFields(b []byte) int {
+	dec := xml.NewDecoder(strings.NewReader(string(b)))
+	n := 0
+	var instr *strings.Builder
+	inInstr := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "fldSimple":
+				if isDDEInstruction(attrValue(t, "instr")) {
+					n++
+				}
+			case "fldChar":
+				switch attrValue(t, "fldCharType") {
+				case "begin":
+					instr = &strings.Builder{}
+				case "separate", "end":
+					if instr != nil && isDDEInstruction(instr.String()) {
+						n++
+					}
+					instr = nil
+				}
+			case "instrText":
+				inInstr = true
+			}
+		case xml.EndElement:
+			if t.Name.Local == "instrText" {
+				inInstr = false
+			}
+		case xml.CharData:
+			if inInstr && instr != nil {
+				instr.Write(t)
+			}
+		}
+	}
+	return n
+}
+
+func isDDEInstruction(s string) bool {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return false
+	}
+	code := strings.ToUpper(f[0])
+	return code == "DDE" || code == "DDEAUTO"
+}
+
+func attrValue(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// addTo records the report in meta. securityFlags lists what was found,
+// or "none", so callers can filter on a single key.
+func (s ooxmlSecurity) addTo(meta map[string]string) {
+	var flags []string
+	meta["hasMacros"] = fmt.Sprintf("%t", s.macros)
+	if s.macros {
+		flags = append(flags, "macros")
+	}
+	if s.externalTemplate != "" {
+		meta["externalTemplate"] = s.externalTemplate
+		flags = append(flags, "external-template")
+	}
+	if s.oleLinks > 0 {
+		meta["externalOleLinks"] = fmt.Sprintf("%d", s.oleLinks)
+		flags = append(flags, "ole-link")
+	}
+	if s.ddeFields > 0 {
+		meta["ddeFields"] = fmt.Sprintf("%d", s.ddeFields)
+		flags = append(flags, "dde")
+	}
+	if s.remoteTargets > 0 {
+		meta["remoteTargets"] = fmt.Sprintf("%d", s.remoteTargets)
+		flags = append(flags, "remote-target")
+	}
+	if len(s.hosts) > 0 {
+		meta["remoteTargetHosts"] = strings.Join(s.hosts, ",")
+	}
+	if len(flags) == 0 {
+		meta["securityFlags"] = "none"
+	} else {
+		meta["securityFlags"] = strings.Join(flags, ",")
+	}
+}
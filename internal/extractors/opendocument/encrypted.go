@@ -0,0 +1,268 @@
+package opendocument
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/blowfish"
+)
+
+var (
+	errPasswordRequired  = extract.WithCode(extract.CodePasswordRequired, errors.New("document is password protected; supply the password option"))
+	errPasswordIncorrect = extract.WithCode(extract.CodePasswordIncorrect, errors.New("incorrect document password"))
+)
+
+// odfPackage reads entries from an ODF zip, decrypting those listed with
+// <manifest:encryption-data> in META-INF/manifest.xml.
+type odfPackage struct {
+	zr        *safezip.Reader
+	password  string
+	encrypted map[string]odfEncryption
+}
+
+type odfEncryption struct {
+	size         int64
+	checksumType string
+	checksum     []byte
+	algorithm    string
+	iv           []byte
+	startKey     string
+	derivation   string
+	keySize      int
+	iterations   int
+	salt         []byte
+	argonMemory  uint32
+	argonLanes   uint8
+}
+
+func openODFPackage(zr *safezip.Reader, password string) (*odfPackage, error) {
+	pkg := &odfPackage{zr: zr, password: password}
+	if b, err := zr.ReadFile("META-INF/manifest.xml"); err == nil {
+		pkg.encrypted = parseManifestEncryption(b)
+	}
+	if len(pkg.encrypted) > 0 && password == "" {
+		return nil, errPasswordRequired
+	}
+	return pkg, nil
+}
+
+// read returns the plain contents of name, decrypting if necessary.
+func (p *odfPackage) read(name string) ([]byte, error) {
+	raw, err := p.zr.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	enc, ok := p.encrypted[name]
+	if !ok {
+		return raw, nil
+	}
+	return enc.decrypt(raw, p.password)
+}
+
+func parseManifestEncryption(b []byte) map[string]odfEncryption {
+	out := map[string]odfEncryption{}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+
+	var path string
+	var cur *odfEncryption
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := map[string]string{}
+			for _, a := range t.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+			switch t.Name.Local {
+			case "file-entry":
+				path = attrs["full-path"]
+				cur = &odfEncryption{}
+				cur.size, _ = strconv.ParseInt(attrs["size"], 10, 64)
+			case "encryption-data":
+				if cur == nil {
+					continue
+				}
+				cur.checksumType = attrs["checksum-type"]
+				cur.checksum, _ = base64.StdEncoding.DecodeString(attrs["checksum"])
+				out[path] = *cur
+			case "algorithm":
+				if enc, ok := out[path]; ok {
+					enc.algorithm = attrs["algorithm-name"]
+					enc.iv, _ = base64.StdEncoding.DecodeString(attrs["initialisation-vector"])
+					out[path] = enc
+				}
+			case "start-key-generation":
+				if enc, ok := out[path]; ok {
+					enc.startKey = attrs["start-key-generation-name"]
+					out[path] = enc
+				}
+			case "key-derivation":
+				if enc, ok := out[path]; ok {
+					enc.derivation = attrs["key-derivation-name"]
+					enc.keySize, _ = strconv.Atoi(attrs["key-size"])
+					enc.iterations, _ = strconv.Atoi(attrs["iteration-count"])
+					if enc.iterations == 0 {
+						enc.iterations, _ = strconv.Atoi(attrs["argon2-iterations"])
+					}
+					mem, _ := strconv.ParseUint(attrs["argon2-memory"], 10, 32)
+					lanes, _ := strconv.ParseUint(attrs["argon2-lanes"], 10, 8)
+					enc.argonMemory, enc.argonLanes = uint32(mem), uint8(lanes)
+					enc.salt, _ = base64.StdEncoding.DecodeString(attrs["salt"])
+					out[path] = enc
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "file-entry" {
+				path, cur = "", nil
+			}
+		}
+	}
+	return out
+}
+
+// decrypt derives the entry key from password, decrypts and verifies the
+// entry, then inflates it. Checksum or authentication failures are reported
+// as an incorrect password.
+func (e odfEncryption) decrypt(data []byte, password string) ([]byte, error) {
+	key, err := e.deriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var plain []byte
+	switch {
+	case strings.HasSuffix(e.algorithm, "#aes256-cbc"):
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 || len(data)%aes.BlockSize != 0 || len(e.iv) != aes.BlockSize {
+			return nil, errPasswordIncorrect
+		}
+		plain = make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, e.iv).CryptBlocks(plain, data)
+		// W3C padding: the last byte holds the pad length, other pad bytes are arbitrary.
+		pad := int(plain[len(plain)-1])
+		if pad == 0 || pad > aes.BlockSize {
+			return nil, errPasswordIncorrect
+		}
+		plain = plain[:len(plain)-pad]
+	case strings.HasSuffix(e.algorithm, "#aes256-gcm"):
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := cipher.NewGCMWithNonceSize(block, len(e.iv))
+		if err != nil {
+			return nil, err
+		}
+		if plain, err = gcm.Open(nil, e.iv, data, nil); err != nil {
+			return nil, errPasswordIncorrect
+		}
+	case strings.EqualFold(e.algorithm, "Blowfish CFB"):
+		block, err := blowfish.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(e.iv) != blowfish.BlockSize {
+			return nil, errPasswordIncorrect
+		}
+		plain = make([]byte, len(data))
+		cipher.NewCFBDecrypter(block, e.iv).XORKeyStream(plain, data)
+	default:
+		return nil, fmt.Errorf("unsupported ODF encryption algorithm %q", e.algorithm)
+	}
+
+	if !e.verifyChecksum(plain) {
+		return nil, errPasswordIncorrect
+	}
+
+	// The zip entry was stored, so safezip only saw its ciphertext size; the
+	// inflate after decryption is capped here instead.
+	limit := safezip.Current().MaxEntryBytes
+	out, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(plain)), limit+1))
+	if int64(len(out)) > limit {
+		return nil, extract.WithCode(extract.CodeArchiveLimitsExceeded, fmt.Errorf("%w: encrypted entry inflates past %d bytes", safezip.ErrLimitsExceeded, limit))
+	}
+	if err != nil {
+		// Entries may be stored without compression before encryption.
+		if int64(len(plain)) == e.size {
+			return plain, nil
+		}
+		return nil, errPasswordIncorrect
+	}
+	return out, nil
+}
+
+func (e odfEncryption) deriveKey(password string) ([]byte, error) {
+	var start []byte
+	switch {
+	case e.startKey == "" || strings.EqualFold(e.startKey, "SHA1") || strings.HasSuffix(e.startKey, "#sha1"):
+		sum := sha1.Sum([]byte(password))
+		start = sum[:]
+	case strings.HasSuffix(e.startKey, "#sha256"):
+		sum := sha256.Sum256([]byte(password))
+		start = sum[:]
+	default:
+		return nil, fmt.Errorf("unsupported ODF start key generation %q", e.startKey)
+	}
+
+	size := e.keySize
+	if size == 0 {
+		size = 16
+	}
+	switch {
+	case strings.EqualFold(e.derivation, "PBKDF2"):
+		return pbkdf2.Key(sha1.New, string(start), e.salt, e.iterations, size)
+	case strings.HasSuffix(e.derivation, "argon2id"):
+		if e.iterations <= 0 || e.argonMemory == 0 || e.argonLanes == 0 {
+			return nil, errors.New("invalid argon2id parameters in ODF manifest")
+		}
+		return argon2.IDKey(start, e.salt, uint32(e.iterations), e.argonMemory, e.argonLanes, uint32(size)), nil
+	}
+	return nil, fmt.Errorf("unsupported ODF key derivation %q", e.derivation)
+}
+
+// verifyChecksum checks the manifest digest over the decrypted, still
+// compressed bytes. AEAD entries carry no checksum.
+func (e odfEncryption) verifyChecksum(plain []byte) bool {
+	if e.checksumType == "" {
+		return true
+	}
+	var h hash.Hash
+	data := plain
+	switch {
+	case strings.HasSuffix(e.checksumType, "sha256-1k"):
+		h, data = sha256.New(), plain[:min(len(plain), 1024)]
+	case strings.EqualFold(e.checksumType, "SHA1/1K"):
+		h, data = sha1.New(), plain[:min(len(plain), 1024)]
+	case strings.HasSuffix(e.checksumType, "#sha256"):
+		h = sha256.New()
+	case strings.EqualFold(e.checksumType, "SHA1"):
+		h = sha1.New()
+	default:
+		// Unknown digest; fall back to the inflate step to catch bad keys.
+		return true
+	}
+	h.Write(data)
+	return bytes.Equal(h.Sum(nil), e.checksum)
+}
@@ -0,0 +1,98 @@
+package opendocument
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
+)
+
+// encryptedODT builds an ODT whose content.xml is encrypted the way
+// LibreOffice does it by default: SHA-256 start key, PBKDF2, AES-256-CBC.
+func encryptedODT(t *testing.T, password, content string) *safezip.Reader {
+	t.Helper()
+
+	var deflated bytes.Buffer
+	fw, _ := flate.NewWriter(&deflated, flate.DefaultCompression)
+	fw.Write([]byte(content))
+	fw.Close()
+	plain := deflated.Bytes()
+
+	sum := sha256.Sum256(plain[:min(len(plain), 1024)])
+	salt := bytes.Repeat([]byte{7}, 16)
+	iv := bytes.Repeat([]byte{9}, 16)
+	start := sha256.Sum256([]byte(password))
+	key, err := pbkdf2.Key(sha1.New, string(start[:]), salt, 1000, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, _ := aes.NewCipher(key)
+	data := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, padded)
+
+	b64 := base64.StdEncoding.EncodeToString
+	manifest := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0">
+ <manifest:file-entry manifest:full-path="/" manifest:media-type="application/vnd.oasis.opendocument.text"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml" manifest:size="%d">
+  <manifest:encryption-data manifest:checksum-type="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0#sha256-1k" manifest:checksum="%s">
+   <manifest:algorithm manifest:algorithm-name="http://www.w3.org/2001/04/xmlenc#aes256-cbc" manifest:initialisation-vector="%s"/>
+   <manifest:start-key-generation manifest:start-key-generation-name="http://www.w3.org/2000/09/xmldsig#sha256" manifest:key-size="32"/>
+   <manifest:key-derivation manifest:key-derivation-name="PBKDF2" manifest:key-size="32" manifest:iteration-count="1000" manifest:salt="%s"/>
+  </manifest:encryption-data>
+ </manifest:file-entry>
+</manifest:manifest>`, len(content), b64(sum[:]), b64(iv), b64(salt))
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string][]byte{"META-INF/manifest.xml": []byte(manifest), "content.xml": data} {
+		w, _ := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		w.Write(body)
+	}
+	zw.Close()
+
+	zr, err := safezip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestEncryptedPackage(t *testing.T) {
+	content := `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"><office:body>secret text</office:body></office:document-content>`
+	zr := encryptedODT(t, "hunter2", content)
+
+	if _, err := openODFPackage(zr, ""); extract.ErrorCode(err) != extract.CodePasswordRequired {
+		t.Fatalf("expected password_required, got %v", err)
+	}
+
+	pkg, err := openODFPackage(zr, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pkg.read("content.xml"); extract.ErrorCode(err) != extract.CodePasswordIncorrect {
+		t.Fatalf("expected password_incorrect, got %v", err)
+	}
+
+	pkg, _ = openODFPackage(zr, "hunter2")
+	got, err := pkg.read("content.xml")
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if string(got) != content {
+		t.Fatalf("unexpected plaintext %q", got)
+	}
+}
@@ -1,28 +1,39 @@
 package opendocument
 
 import (
-	"archive/zip"
 	"context"
 	"encoding/xml"
 	"fmt"
-	"io"
+	"strconv"
 	"strings"
 
+	"github.com/toricodesthings/file-processing-service/internal/embedded"
 	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/format"
+	"github.com/toricodesthings/file-processing-service/internal/mathtex"
+	"github.com/toricodesthings/file-processing-service/internal/safezip"
 )
 
 type Extractor struct {
 	maxBytes int64
+	images   embedded.Config
 }
 
 func New(maxBytes int64) *Extractor { return &Extractor{maxBytes: maxBytes} }
 
+// WithEmbeddedImages enables the embeddedImages option, which runs images
+// in Pictures/ through OCR/vision.
+func (e *Extractor) WithEmbeddedImages(cfg embedded.Config) *Extractor {
+	e.images = cfg
+	return e
+}
+
 func (e *Extractor) Name() string       { return "document/opendocument" }
 func (e *Extractor) MaxFileSize() int64 { return e.maxBytes }
 func (e *Extractor) SupportedTypes() []string {
-	return []string{"application/vnd.oasis.opendocument.text", "application/vnd.oasis.opendocument.spreadsheet", "application/vnd.oasis.opendocument.presentation"}
+	return []string{"application/vnd.oasis.opendocument.text", "application/vnd.oasis.opendocument.spreadsheet", "application/vnd.oasis.opendocument.presentation", "application/vnd.oasis.opendocument.graphics"}
 }
-func (e *Extractor) SupportedExtensions() []string { return []string{".odt", ".ods", ".odp"} }
+func (e *Extractor) SupportedExtensions() []string { return []string{".odt", ".ods", ".odp", ".odg"} }
 
 func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Result, error) {
 	select {
@@ -31,45 +42,90 @@ func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Resul
 	default:
 	}
 
-	zr, err := zip.OpenReader(job.LocalPath)
+	zr, err := safezip.OpenReader(job.LocalPath)
 	if err != nil {
 		msg := err.Error()
-		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
 	}
 	defer zr.Close()
 
-	var content []byte
-	for _, f := range zr.File {
-		if f.Name == "content.xml" {
-			rc, err := f.Open()
-			if err != nil {
-				continue
-			}
-			content, _ = io.ReadAll(rc)
-			rc.Close()
-			break
-		}
+	pkg, err := openODFPackage(zr.Reader, stringOption(job.Options, "password"))
+	if err != nil {
+		msg := err.Error()
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
 	}
-	if len(content) == 0 {
+
+	content, err := pkg.read("content.xml")
+	if err == nil && len(content) == 0 {
 		err = fmt.Errorf("content.xml not found")
+	}
+	if err != nil {
 		msg := err.Error()
-		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: extract.ErrorCode(err)}, err
 	}
 
-	text := odfToMarkdown(content)
-	meta := odfParseMetadata(zr)
+	conv := newODFConverter(format.Revisions(job.Options))
+	conv.read = pkg.read
+	conv.media = embedded.NewCollector(e.images, job.Options)
+	text := conv.toMarkdown(content)
+	text, images := conv.media.Resolve(ctx, text, pkg.read)
+	var meta map[string]string
+	if b, err := pkg.read("meta.xml"); err == nil {
+		meta = odfParseMetadata(b)
+	}
 
 	if len(meta) > 0 {
 		text = odfFrontmatter(meta) + text
 	}
+	if ins, del := conv.counts(); ins > 0 || del > 0 {
+		if meta == nil {
+			meta = map[string]string{}
+		}
+		meta["revisionInsertions"] = strconv.Itoa(ins)
+		meta["revisionDeletions"] = strconv.Itoa(del)
+	}
+	if conv.equations > 0 {
+		if meta == nil {
+			meta = map[string]string{}
+		}
+		meta["equations"] = strconv.Itoa(conv.equations)
+	}
+	if conv.media != nil {
+		if meta == nil {
+			meta = map[string]string{}
+		}
+		images.AddTo(meta)
+	}
 
 	text = strings.TrimSpace(text)
 	words, chars := extract.BuildCounts(text)
 	return extract.Result{Success: true, Text: text, Method: "native", FileType: e.Name(), MIMEType: job.MIMEType, Metadata: meta, WordCount: words, CharCount: chars}, nil
 }
 
-// odfToMarkdown walks ODF content.xml and produces markdown.
-func odfToMarkdown(b []byte) string {
+const (
+	nsText  = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	nsTable = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	nsDraw  = "urn:oasis:names:tc:opendocument:xmlns:drawing:1.0"
+)
+
+// odfConverter renders ODF content.xml as markdown. It carries the tracked
+// changes, which are declared once and referenced from the body, and reads
+// embedded formula objects through read when it is set.
+type odfConverter struct {
+	revisions string // accept, reject or markup
+	changes   map[string]odfChange
+	open      map[string]bool // insertions whose start marker has been read
+	read      func(name string) ([]byte, error)
+	equations int
+	media     *embedded.Collector // image anchors; nil unless embeddedImages is on
+}
+
+func newODFConverter(revisions string) *odfConverter {
+	return &odfConverter{revisions: revisions, changes: map[string]odfChange{}, open: map[string]bool{}}
+}
+
+// toMarkdown walks ODF content.xml and produces markdown.
+func (c *odfConverter) toMarkdown(b []byte) string {
 	dec := xml.NewDecoder(strings.NewReader(string(b)))
 	var blocks []string
 
@@ -84,7 +140,17 @@ func odfToMarkdown(b []byte) string {
 		}
 
 		switch {
-		case se.Name.Local == "h" && se.Name.Space == "urn:oasis:names:tc:opendocument:xmlns:text:1.0":
+		case se.Name.Local == "tracked-changes":
+			// Deleted text lives here; it is rendered at its change marker,
+			// not where it is declared. Spreadsheet change tracking
+			// (table:tracked-changes) is skipped entirely.
+			if se.Name.Space == nsText {
+				c.readTrackedChanges(dec, se)
+			} else {
+				dec.Skip()
+			}
+
+		case se.Name.Local == "h" && se.Name.Space == nsText:
 			// Heading element - extract outline level
 			level := 1
 			for _, a := range se.Attr {
@@ -94,25 +160,31 @@ func odfToMarkdown(b []byte) string {
 					}
 				}
 			}
-			text := odfCollectText(dec, "h")
+			text := c.collectText(dec)
 			if text != "" {
 				blocks = append(blocks, strings.Repeat("#", level)+" "+text)
 			}
 
-		case se.Name.Local == "p" && se.Name.Space == "urn:oasis:names:tc:opendocument:xmlns:text:1.0":
-			text := odfCollectText(dec, "p")
+		case se.Name.Local == "p" && se.Name.Space == nsText:
+			text := c.collectText(dec)
 			if text != "" {
 				blocks = append(blocks, text)
 			}
 
-		case se.Name.Local == "list" && se.Name.Space == "urn:oasis:names:tc:opendocument:xmlns:text:1.0":
-			items := odfCollectList(dec, 0)
+		case se.Name.Local == "list" && se.Name.Space == nsText:
+			items := c.collectList(dec, 0)
 			if len(items) > 0 {
 				blocks = append(blocks, strings.Join(items, "\n"))
 			}
 
-		case se.Name.Local == "table" && se.Name.Space == "urn:oasis:names:tc:opendocument:xmlns:table:1.0":
-			table := odfCollectTable(dec)
+		case se.Name.Local == "image" && se.Name.Space == nsDraw:
+			// Pictures placed on a slide or drawing page rather than in text.
+			if mark := c.imageAnchor(se); mark != "" {
+				blocks = append(blocks, mark)
+			}
+
+		case se.Name.Local == "table" && se.Name.Space == nsTable:
+			table := c.collectTable(dec)
 			if table != "" {
 				blocks = append(blocks, table)
 			}
@@ -122,9 +194,12 @@ func odfToMarkdown(b []byte) string {
 	return strings.Join(blocks, "\n\n")
 }
 
-// odfCollectText reads all text inside an element until its closing tag.
-func odfCollectText(dec *xml.Decoder, endTag string) string {
+// collectText reads all text inside an element until its closing tag,
+// applying tracked changes.
+func (c *odfConverter) collectText(dec *xml.Decoder) string {
 	var texts []string
+	// An insertion spanning paragraphs is marked up in each of them.
+	reopened := c.revisions == "markup" && len(c.open) > 0
 	depth := 1
 	for depth > 0 {
 		tok, err := dec.Token()
@@ -134,25 +209,114 @@ func odfCollectText(dec *xml.Decoder, endTag string) string {
 		switch t := tok.(type) {
 		case xml.StartElement:
 			depth++
-			if t.Name.Local == "tab" {
+			switch t.Name.Local {
+			case "tab":
 				texts = append(texts, "\t")
-			} else if t.Name.Local == "line-break" {
+			case "line-break":
 				texts = append(texts, "\n")
+			case "change-start", "change-end", "change":
+				texts = append(texts, c.changeMarker(t))
+			case "image":
+				if mark := c.imageAnchor(t); mark != "" {
+					texts = append(texts, mark)
+				}
+			case "object":
+				if eq := c.formulaObject(t); eq != "" {
+					texts = append(texts, eq)
+				}
+			case "math":
+				// MathML embedded in the body rather than in an object.
+				if eq := c.formula(mathtex.MathML(dec, t)); eq != "" {
+					texts = append(texts, eq)
+				}
+				depth--
 			}
 		case xml.EndElement:
 			depth--
 		case xml.CharData:
 			s := string(t)
-			if strings.TrimSpace(s) != "" {
+			if strings.TrimSpace(s) != "" && (c.revisions != "reject" || len(c.open) == 0) {
 				texts = append(texts, s)
 			}
 		}
 	}
-	return strings.TrimSpace(strings.Join(texts, ""))
+	text := strings.TrimSpace(strings.Join(texts, ""))
+	if text == "" {
+		return ""
+	}
+	if isFormula(text) {
+		// A formula alone in its paragraph is display math.
+		text = "$" + text + "$"
+	}
+	if reopened {
+		text = "{++" + text
+	}
+	if c.revisions == "markup" && len(c.open) > 0 {
+		text += "++}"
+	}
+	return text
+}
+
+// formulaObject converts the formula object a draw:object refers to, such as
+// "./Object 1", whose MathML is in "Object 1/content.xml". Other objects
+// (charts, OLE) and unreadable ones render nothing.
+func (c *odfConverter) formulaObject(se xml.StartElement) string {
+	if c.read == nil {
+		return ""
+	}
+	var href string
+	for _, a := range se.Attr {
+		if a.Name.Local == "href" {
+			href = a.Value
+		}
+	}
+	href = strings.TrimSuffix(strings.TrimPrefix(href, "./"), "/")
+	if href == "" || strings.Contains(href, ":") {
+		return ""
+	}
+	b, err := c.read(href + "/content.xml")
+	if err != nil {
+		return ""
+	}
+	return c.formula(mathtex.MathMLDocument(b))
+}
+
+// imageAnchor marks a draw:image stored in the package. Linked images and
+// the preview pictures of embedded objects (ObjectReplacements/) are not
+// marked.
+func (c *odfConverter) imageAnchor(se xml.StartElement) string {
+	if c.media == nil {
+		return ""
+	}
+	var href string
+	for _, a := range se.Attr {
+		if a.Name.Local == "href" {
+			href = strings.TrimPrefix(a.Value, "./")
+		}
+	}
+	if href == "" || strings.Contains(href, ":") || strings.HasPrefix(href, "ObjectReplacements/") {
+		return ""
+	}
+	return c.media.Anchor(href)
+}
+
+// formula wraps converted LaTeX as inline math.
+func (c *odfConverter) formula(tex string) string {
+	if tex == "" {
+		return ""
+	}
+	c.equations++
+	return "$" + tex + "$"
 }
 
-// odfCollectList reads a text:list element and returns markdown list items.
-func odfCollectList(dec *xml.Decoder, indentLevel int) []string {
+// isFormula reports whether text is exactly one inline formula.
+func isFormula(text string) bool {
+	return len(text) > 2 && strings.HasPrefix(text, "$") && strings.HasSuffix(text, "$") &&
+		strings.Count(text, "$") == 2
+}
+
+// collectList reads a text:list element and returns markdown list items.
+func (c *odfConverter) collectList(dec *xml.Decoder, indentLevel int) []string {
 	var items []string
 	depth := 1
 	indent := strings.Repeat("  ", indentLevel)
@@ -166,13 +330,13 @@ func odfCollectList(dec *xml.Decoder, indentLevel int) []string {
 		case xml.StartElement:
 			depth++
 			if t.Name.Local == "p" {
-				text := odfCollectText(dec, "p")
-				depth-- // odfCollectText consumed the end tag
+				text := c.collectText(dec)
+				depth-- // collectText consumed the end tag
 				if text != "" {
 					items = append(items, indent+"- "+text)
 				}
 			} else if t.Name.Local == "list" {
-				sub := odfCollectList(dec, indentLevel+1)
+				sub := c.collectList(dec, indentLevel+1)
 				depth-- // recursive call consumed the end tag
 				items = append(items, sub...)
 			}
@@ -183,8 +347,8 @@ func odfCollectList(dec *xml.Decoder, indentLevel int) []string {
 	return items
 }
 
-// odfCollectTable reads a table:table element and returns a markdown table.
-func odfCollectTable(dec *xml.Decoder) string {
+// collectTable reads a table:table element and returns a markdown table.
+func (c *odfConverter) collectTable(dec *xml.Decoder) string {
 	var rows [][]string
 	depth := 1
 
@@ -197,7 +361,7 @@ func odfCollectTable(dec *xml.Decoder) string {
 		case xml.StartElement:
 			depth++
 			if t.Name.Local == "table-row" {
-				row := odfCollectTableRow(dec)
+				row := c.collectTableRow(dec)
 				depth-- // consumed by recursive function
 				if len(row) > 0 {
 					rows = append(rows, row)
@@ -238,7 +402,7 @@ func odfCollectTable(dec *xml.Decoder) string {
 	return sb.String()
 }
 
-func odfCollectTableRow(dec *xml.Decoder) []string {
+func (c *odfConverter) collectTableRow(dec *xml.Decoder) []string {
 	var cells []string
 	depth := 1
 
@@ -251,7 +415,7 @@ func odfCollectTableRow(dec *xml.Decoder) []string {
 		case xml.StartElement:
 			depth++
 			if t.Name.Local == "table-cell" {
-				cell := odfCollectCellText(dec)
+				cell := c.collectCellText(dec)
 				depth--
 				cells = append(cells, cell)
 			}
@@ -262,7 +426,8 @@ func odfCollectTableRow(dec *xml.Decoder) []string {
 	return cells
 }
 
-func odfCollectCellText(dec *xml.Decoder) string {
+// collectCellText returns a cell's paragraphs on one line.
+func (c *odfConverter) collectCellText(dec *xml.Decoder) string {
 	var texts []string
 	depth := 1
 	for depth > 0 {
@@ -273,71 +438,59 @@ func odfCollectCellText(dec *xml.Decoder) string {
 		switch t := tok.(type) {
 		case xml.StartElement:
 			depth++
+			if t.Name.Local == "p" || t.Name.Local == "h" {
+				if s := strings.Join(strings.Fields(c.collectText(dec)), " "); s != "" {
+					texts = append(texts, s)
+				}
+				depth--
+			}
 		case xml.EndElement:
 			depth--
-		case xml.CharData:
-			s := strings.TrimSpace(string(t))
-			if s != "" {
-				texts = append(texts, s)
-			}
 		}
 	}
 	return strings.Join(texts, " ")
 }
 
-// odfParseMetadata reads meta.xml from the zip and extracts title, author, etc.
-func odfParseMetadata(zr *zip.ReadCloser) map[string]string {
-	for _, f := range zr.File {
-		if f.Name != "meta.xml" {
-			continue
-		}
-		rc, err := f.Open()
+// odfParseMetadata parses meta.xml and extracts title, author, etc.
+func odfParseMetadata(b []byte) map[string]string {
+	meta := map[string]string{}
+	dec := xml.NewDecoder(strings.NewReader(string(b)))
+	var tag string
+	for {
+		tok, err := dec.Token()
 		if err != nil {
-			return nil
+			break
 		}
-		b, _ := io.ReadAll(rc)
-		rc.Close()
-
-		meta := map[string]string{}
-		dec := xml.NewDecoder(strings.NewReader(string(b)))
-		var tag string
-		for {
-			tok, err := dec.Token()
-			if err != nil {
-				break
+		switch t := tok.(type) {
+		case xml.StartElement:
+			tag = t.Name.Local
+		case xml.CharData:
+			val := strings.TrimSpace(string(t))
+			if val == "" {
+				continue
 			}
-			switch t := tok.(type) {
-			case xml.StartElement:
-				tag = t.Name.Local
-			case xml.CharData:
-				val := strings.TrimSpace(string(t))
-				if val == "" {
-					continue
-				}
-				switch tag {
-				case "title":
-					meta["title"] = val
-				case "initial-creator", "creator":
-					meta["author"] = val
-				case "creation-date":
-					meta["created"] = val
-				case "date":
-					meta["modified"] = val
-				case "description":
-					meta["description"] = val
-				case "subject":
-					meta["subject"] = val
-				}
-			case xml.EndElement:
-				tag = ""
+			switch tag {
+			case "title":
+				meta["title"] = val
+			case "initial-creator", "creator":
+				meta["author"] = val
+			case "creation-date":
+				meta["created"] = val
+			case "date":
+				meta["modified"] = val
+			case "description":
+				meta["description"] = val
+			case "subject":
+				meta["subject"] = val
 			}
+		case xml.EndElement:
+			tag = ""
 		}
-		if len(meta) == 0 {
-			return nil
-		}
-		return meta
 	}
-	return nil
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
 }
 
 func odfFrontmatter(meta map[string]string) string {
@@ -354,3 +507,11 @@ func odfFrontmatter(meta map[string]string) string {
 	sb.WriteString("---\n\n")
 	return sb.String()
 }
+
+func stringOption(options map[string]any, key string) string {
+	if options == nil {
+		return ""
+	}
+	s, _ := options[key].(string)
+	return s
+}
@@ -0,0 +1,32 @@
+package opendocument
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestFormulaObjects(t *testing.T) {
+	const content = `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0" xmlns:xlink="http://www.w3.org/1999/xlink"><office:body><office:text>
+<text:p>Area is <draw:frame><draw:object xlink:href="./Object 1"/></draw:frame> square units.</text:p>
+<text:p><draw:frame><draw:object xlink:href="./Object 2"/><draw:image xlink:href="./ObjectReplacements/Object 2"/></draw:frame></text:p>
+</office:text></office:body></office:document-content>`
+	objects := map[string]string{
+		"Object 1/content.xml": `<math xmlns="http://www.w3.org/1998/Math/MathML"><mrow><mi>π</mi><msup><mi>r</mi><mn>2</mn></msup></mrow></math>`,
+		"Object 2/content.xml": `<math xmlns="http://www.w3.org/1998/Math/MathML"><mroot><mi>x</mi><mn>3</mn></mroot></math>`,
+	}
+	c := newODFConverter("accept")
+	c.read = func(name string) ([]byte, error) {
+		b, ok := objects[name]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return []byte(b), nil
+	}
+	want := "Area is $\\pi r^{2}$ square units.\n\n$$\\sqrt[3]{x}$$"
+	if got := c.toMarkdown([]byte(content)); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if c.equations != 2 {
+		t.Errorf("equations = %d", c.equations)
+	}
+}
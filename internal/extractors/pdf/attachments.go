@@ -0,0 +1,163 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/extractor"
+	"github.com/toricodesthings/file-processing-service/internal/pdfobj"
+)
+
+// maxAttachmentDepth stops a PDF attached to a PDF attached to a PDF...
+const maxAttachmentDepth = 2
+
+// extractAttachments saves each embedded file into the job's temp dir and
+// runs it back through the registry. doc may be nil (encrypted or oversized
+// PDFs); it only supplies declared sizes and descriptions.
+func (e *Extractor) extractAttachments(ctx context.Context, job extract.Job, doc *pdfobj.Document) []extract.Attachment {
+	if e.registry == nil || e.limits.MaxAttachments <= 0 || job.Depth >= maxAttachmentDepth {
+		return nil
+	}
+
+	cfg := e.processor.ExtractorConfig()
+	cfg.Password = stringOption(job.Options, "password")
+
+	files, err := extractor.ListEmbeddedFiles(ctx, job.LocalPath, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[pdf] attachment listing failed: %v\n", err)
+		return nil
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	specs := map[string]pdfobj.FileSpec{}
+	if doc != nil {
+		for _, s := range doc.EmbeddedFiles() {
+			if _, dup := specs[s.Name]; !dup {
+				specs[s.Name] = s
+			}
+		}
+	}
+
+	dir := filepath.Join(filepath.Dir(job.LocalPath), fmt.Sprintf("attachments-%d", job.Depth))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		fmt.Fprintf(os.Stderr, "[pdf] attachment dir: %v\n", err)
+		return nil
+	}
+
+	out := make([]extract.Attachment, 0, len(files))
+	for i, f := range files {
+		spec := specs[f.Name]
+		att := extract.Attachment{Name: f.Name, Description: spec.Description, Size: max(spec.Size, 0), Page: spec.Page}
+
+		switch {
+		case ctx.Err() != nil:
+			att.Skipped = "timeout"
+		case i >= e.limits.MaxAttachments:
+			att.Skipped = fmt.Sprintf("attachment limit reached (%d)", e.limits.MaxAttachments)
+		case e.limits.MaxAttachmentBytes > 0 && att.Size > e.limits.MaxAttachmentBytes:
+			att.Skipped = fmt.Sprintf("exceeds attachment size limit (%dMB)", e.limits.MaxAttachmentBytes/(1<<20))
+		default:
+			e.extractAttachment(ctx, job, cfg, dir, f, &att)
+		}
+		out = append(out, att)
+	}
+	return out
+}
+
+func (e *Extractor) extractAttachment(ctx context.Context, job extract.Job, cfg extractor.ExtractorConfig, dir string, f extractor.EmbeddedFile, att *extract.Attachment) {
+	path := filepath.Join(dir, fmt.Sprintf("%03d-%s", f.Index, safeFileName(f.Name)))
+	if err := extractor.SaveEmbeddedFile(ctx, job.LocalPath, f.Index, path, cfg); err != nil {
+		att.Skipped = "save failed: " + err.Error()
+		return
+	}
+	defer os.Remove(path)
+
+	st, err := os.Stat(path)
+	if err != nil {
+		att.Skipped = "save failed: " + err.Error()
+		return
+	}
+	att.Size = st.Size()
+	if e.limits.MaxAttachmentBytes > 0 && att.Size > e.limits.MaxAttachmentBytes {
+		att.Skipped = fmt.Sprintf("exceeds attachment size limit (%dMB)", e.limits.MaxAttachmentBytes/(1<<20))
+		return
+	}
+
+	mimeType := extract.SniffMIMEType(path)
+	ex, err := e.registry.Resolve(mimeType, strings.ToLower(filepath.Ext(f.Name)))
+	if err != nil {
+		att.Skipped = "unsupported file type"
+		return
+	}
+	// An attachment must not reach an extractor the caller's key cannot
+	// use directly.
+	if err := extract.CheckExtractorAllowed(ctx, ex.Name()); err != nil {
+		att.Skipped = extract.ErrorCode(err)
+		return
+	}
+	if max := ex.MaxFileSize(); max > 0 && att.Size > max {
+		att.Skipped = fmt.Sprintf("file exceeds extractor limit (%dMB)", max/(1<<20))
+		return
+	}
+
+	// The attachment's own password (if any) is unknown; never forward the
+	// container's.
+	opts := make(map[string]any, len(job.Options))
+	for k, v := range job.Options {
+		if k != "password" {
+			opts[k] = v
+		}
+	}
+
+	res, err := ex.Extract(ctx, extract.Job{
+		LocalPath: path,
+		FileName:  f.Name,
+		MIMEType:  mimeType,
+		FileSize:  att.Size,
+		Options:   opts,
+		Depth:     job.Depth + 1,
+	})
+	if err != nil {
+		res.Success = false
+		if res.Error == nil {
+			msg := err.Error()
+			res.Error = &msg
+		}
+		if res.Code == "" {
+			res.Code = extract.ErrorCode(err)
+		}
+	}
+	if res.MIMEType == "" {
+		res.MIMEType = mimeType
+	}
+	att.Result = &res
+}
+
+// safeFileName reduces an embedded file name (which may contain directory
+// components from the producing system) to a single safe path element.
+func safeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == '/' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "attachment.bin"
+	}
+	if len(name) > 128 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = name[:128-len(ext)] + ext
+	}
+	return name
+}
@@ -0,0 +1,202 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/extractor"
+	img "github.com/toricodesthings/file-processing-service/internal/image"
+	"github.com/toricodesthings/file-processing-service/internal/types"
+	"golang.org/x/sync/semaphore"
+)
+
+// FigureConfig controls figure analysis on text-layer pages.
+type FigureConfig struct {
+	OCRModel      string
+	VisionModel   string
+	VisionTimeout time.Duration
+	MaxFigures    int // per-document cap on images sent to vision/OCR
+	MinPixels     int // smallest width/height treated as a figure
+}
+
+type figureStats struct {
+	candidates int
+	analyzed   int
+	failed     int
+}
+
+// selectFigures filters the pdfimages listing down to figures worth
+// analysing: real images (not masks), large enough, not strip-shaped, not
+// repeated across pages, on a text-layer page. Each image object is selected
+// once, at its first placement, in document order up to limit.
+func selectFigures(images []extractor.PDFImage, textPages map[int]bool, minPixels, limit int) (selected []extractor.PDFImage, candidates int) {
+	pagesPerObject := map[int]map[int]bool{}
+	for _, im := range images {
+		if pagesPerObject[im.Object] == nil {
+			pagesPerObject[im.Object] = map[int]bool{}
+		}
+		pagesPerObject[im.Object][im.Page] = true
+	}
+
+	seen := map[int]bool{}
+	for _, im := range images {
+		if im.Type != "image" || !textPages[im.Page] || seen[im.Object] {
+			continue
+		}
+		if !img.ContentSized(im.Width, im.Height, minPixels) {
+			continue
+		}
+		if len(pagesPerObject[im.Object]) >= img.DecorativeUses {
+			continue
+		}
+		seen[im.Object] = true
+		candidates++
+		if len(selected) < limit {
+			selected = append(selected, im)
+		}
+	}
+	return selected, candidates
+}
+
+// FigureCandidates counts the images on pages that figure analysis would
+// consider, without extracting or sending any of them.
+func FigureCandidates(images []extractor.PDFImage, pages map[int]bool, minPixels int) int {
+	_, n := selectFigures(images, pages, minPixels, 0)
+	return n
+}
+
+// describeFigures extracts figures from text-layer pages, runs them through
+// the image classifier/OCR routing and appends the results to their page.
+func (e *Extractor) describeFigures(ctx context.Context, pdfPath string, pages []types.PageExtractionResult, limit int, cfg extractor.ExtractorConfig) figureStats {
+	var stats figureStats
+
+	textPages := map[int]bool{}
+	first, last := 0, 0
+	for _, p := range pages {
+		if p.Method != "text-layer" {
+			continue
+		}
+		textPages[p.PageNumber] = true
+		if first == 0 || p.PageNumber < first {
+			first = p.PageNumber
+		}
+		last = max(last, p.PageNumber)
+	}
+	if len(textPages) == 0 || limit <= 0 {
+		return stats
+	}
+
+	images, err := extractor.ListImages(ctx, pdfPath, first, last, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[pdf] figure listing failed: %v\n", err)
+		return stats
+	}
+	selected, candidates := selectFigures(images, textPages, e.figures.MinPixels, limit)
+	stats.candidates = candidates
+	if len(selected) == 0 {
+		return stats
+	}
+
+	dir := filepath.Join(filepath.Dir(pdfPath), "figures")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		fmt.Fprintf(os.Stderr, "[pdf] figure dir: %v\n", err)
+		return stats
+	}
+	defer os.RemoveAll(dir)
+
+	// Extract each page once.
+	files := map[int]string{}
+	extracted := map[int]bool{}
+	for _, im := range selected {
+		if extracted[im.Page] {
+			continue
+		}
+		extracted[im.Page] = true
+		prefix := filepath.Join(dir, fmt.Sprintf("p%d", im.Page))
+		got, err := extractor.ExtractPageImages(ctx, pdfPath, im.Page, prefix, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[pdf] figure extraction failed page=%d: %v\n", im.Page, err)
+			continue
+		}
+		for num, path := range got {
+			files[num] = path
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make([]string, len(selected))
+		sem     = semaphore.NewWeighted(img.DocumentImageWorkers)
+	)
+	for i, im := range selected {
+		path, ok := files[im.Num]
+		if !ok {
+			mu.Lock()
+			stats.failed++
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return
+			}
+			defer sem.Release(1)
+
+			text, err := e.analyzeFigure(ctx, path)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[pdf] figure analysis failed: %v\n", err)
+				stats.failed++
+				return
+			}
+			results[i] = text
+		}(i, path)
+	}
+	wg.Wait()
+
+	// Append in document order, numbering only figures that produced text.
+	byPage := map[int][]string{}
+	n := 0
+	for i, im := range selected {
+		if results[i] == "" {
+			continue
+		}
+		n++
+		byPage[im.Page] = append(byPage[im.Page], fmt.Sprintf("**Figure %d:** %s", n, results[i]))
+	}
+	stats.analyzed = n
+	for i := range pages {
+		figs := byPage[pages[i].PageNumber]
+		if len(figs) == 0 {
+			continue
+		}
+		text := strings.TrimRight(pages[i].Text, "\n")
+		if strings.TrimSpace(text) != "" {
+			text += "\n\n"
+		}
+		pages[i].Text = text + strings.Join(figs, "\n\n")
+	}
+	return stats
+}
+
+// analyzeFigure sends one extracted image through the image pipeline.
+func (e *Extractor) analyzeFigure(ctx context.Context, path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	mimeType := "image/png"
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".jpg" || ext == ".jpeg" {
+		mimeType = "image/jpeg"
+	}
+	return img.DescribeDocumentImage(ctx, b, mimeType, e.figures.OCRModel, e.figures.VisionModel, e.figures.VisionTimeout)
+}
@@ -0,0 +1,217 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/extractor"
+	"github.com/toricodesthings/file-processing-service/internal/pdfobj"
+	"github.com/toricodesthings/file-processing-service/internal/types"
+)
+
+// maxMarkedTextLookups bounds pdftotext invocations spent recovering the
+// text under highlight-style annotations in one document.
+const maxMarkedTextLookups = 200
+
+// collectFormFields converts AcroForm fields to result entries.
+func collectFormFields(doc *pdfobj.Document) []extract.FormFieldEntry {
+	var out []extract.FormFieldEntry
+	for _, f := range doc.FormFields() {
+		out = append(out, extract.FormFieldEntry{
+			Name:    f.Name,
+			Type:    f.Type,
+			Value:   f.Value,
+			Checked: f.Checked,
+			Page:    f.Page,
+		})
+	}
+	return out
+}
+
+// collectAnnotations converts page annotations to result entries, recovering
+// the marked text of highlights, underlines and strike-outs with pdftotext.
+func collectAnnotations(ctx context.Context, doc *pdfobj.Document, pdfPath string, cfg extractor.ExtractorConfig) []extract.AnnotationEntry {
+	annots := doc.Annotations()
+	if len(annots) == 0 {
+		return nil
+	}
+
+	boxes := map[int]pdfobj.Rect{}
+	rotations := map[int]int{}
+	for _, p := range doc.Pages() {
+		if box, ok := doc.PageBox(p); ok {
+			boxes[p.Number] = box
+			rotations[p.Number] = doc.PageRotation(p)
+		}
+	}
+
+	lookups := 0
+	out := make([]extract.AnnotationEntry, 0, len(annots))
+	for _, a := range annots {
+		entry := extract.AnnotationEntry{
+			Page:     a.Page,
+			Type:     annotationType(a.Subtype),
+			Author:   a.Author,
+			Comment:  a.Contents,
+			Modified: a.Modified,
+		}
+		box, hasBox := boxes[a.Page]
+		if a.TextMarkup() && hasBox && lookups < maxMarkedTextLookups {
+			var parts []string
+			for _, r := range a.MarkedRegions() {
+				if lookups >= maxMarkedTextLookups {
+					break
+				}
+				lookups++
+				text, err := regionText(ctx, pdfPath, a.Page, box, rotations[a.Page], r, cfg)
+				if err != nil {
+					break
+				}
+				if text != "" {
+					parts = append(parts, text)
+				}
+			}
+			entry.MarkedText = strings.Join(parts, " ")
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// regionText extracts the text inside a user-space rectangle of a page.
+func regionText(ctx context.Context, pdfPath string, page int, box pdfobj.Rect, rotate int, r pdfobj.Rect, cfg extractor.ExtractorConfig) (string, error) {
+	x, y, w, h := deviceRect(box, rotate, r)
+	text, err := extractor.TextInRegion(ctx, pdfPath, page, x, y, w, h, cfg)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(text), " "), nil
+}
+
+// deviceRect maps a user-space rectangle into pdftotext's coordinates: top
+// left of the crop box as displayed, with the page turned clockwise by
+// rotate degrees, at 72 dpi.
+func deviceRect(box pdfobj.Rect, rotate int, r pdfobj.Rect) (x, y, w, h int) {
+	// Offsets from the box's bottom-left corner.
+	x0, x1 := r.X0-box.X0, r.X1-box.X0
+	y0, y1 := r.Y0-box.Y0, r.Y1-box.Y0
+	width, height := box.X1-box.X0, box.Y1-box.Y0
+
+	var left, top, spanX, spanY float64
+	switch rotate {
+	case 90:
+		left, top, spanX, spanY = y0, x0, y1-y0, x1-x0
+	case 180:
+		left, top, spanX, spanY = width-x1, y0, x1-x0, y1-y0
+	case 270:
+		left, top, spanX, spanY = height-y1, width-x1, y1-y0, x1-x0
+	default:
+		left, top, spanX, spanY = x0, height-y1, x1-x0, y1-y0
+	}
+	return int(math.Floor(left)), int(math.Floor(top)), int(math.Ceil(spanX)) + 1, int(math.Ceil(spanY)) + 1
+}
+
+func annotationType(subtype string) string {
+	switch subtype {
+	case "Text":
+		return "note"
+	case "FreeText":
+		return "freeText"
+	case "StrikeOut":
+		return "strikeOut"
+	}
+	if subtype == "" {
+		return ""
+	}
+	return strings.ToLower(subtype[:1]) + subtype[1:]
+}
+
+// appendFormSections adds "Form fields" and "Annotations" sections to the end
+// of the pages they belong to. Fields without a placed widget go on the last
+// page.
+func appendFormSections(pages []types.PageExtractionResult, fields []extract.FormFieldEntry, annots []extract.AnnotationEntry) {
+	if len(pages) == 0 {
+		return
+	}
+	pos := make(map[int]int, len(pages))
+	for i, p := range pages {
+		pos[p.PageNumber] = i
+	}
+
+	fieldLines := map[int][]string{}
+	for _, f := range fields {
+		i, ok := pos[f.Page]
+		if !ok {
+			i = len(pages) - 1
+		}
+		fieldLines[i] = append(fieldLines[i], formatField(f))
+	}
+	annotLines := map[int][]string{}
+	for _, a := range annots {
+		if i, ok := pos[a.Page]; ok {
+			annotLines[i] = append(annotLines[i], formatAnnotation(a))
+		}
+	}
+
+	for i := range pages {
+		var sections []string
+		if lines := fieldLines[i]; len(lines) > 0 {
+			sections = append(sections, "### Form fields\n\n"+strings.Join(lines, "\n"))
+		}
+		if lines := annotLines[i]; len(lines) > 0 {
+			sections = append(sections, "### Annotations\n\n"+strings.Join(lines, "\n"))
+		}
+		if len(sections) == 0 {
+			continue
+		}
+		text := strings.TrimRight(pages[i].Text, "\n")
+		if strings.TrimSpace(text) != "" {
+			text += "\n\n"
+		}
+		pages[i].Text = text + strings.Join(sections, "\n\n")
+	}
+}
+
+func formatField(f extract.FormFieldEntry) string {
+	value := f.Value
+	if f.Checked != nil {
+		state := "unchecked"
+		if *f.Checked {
+			state = "checked"
+			if f.Type == "signature" {
+				state = "signed"
+			}
+		}
+		if value != "" {
+			value = state + ", " + value
+		} else {
+			value = state
+		}
+	}
+	if value == "" {
+		value = "(empty)"
+	}
+	return fmt.Sprintf("- %s (%s): %s", f.Name, f.Type, value)
+}
+
+func formatAnnotation(a extract.AnnotationEntry) string {
+	var sb strings.Builder
+	sb.WriteString("- " + a.Type)
+	if a.Author != "" {
+		sb.WriteString(" by " + a.Author)
+	}
+	sb.WriteString(":")
+	if a.MarkedText != "" {
+		sb.WriteString(` "` + a.MarkedText + `"`)
+	}
+	if a.Comment != "" {
+		if a.MarkedText != "" {
+			sb.WriteString(" —")
+		}
+		sb.WriteString(" " + strings.Join(strings.Fields(a.Comment), " "))
+	}
+	return sb.String()
+}
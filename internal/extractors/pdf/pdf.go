@@ -2,24 +2,43 @@ package pdf
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/format"
 	"github.com/toricodesthings/file-processing-service/internal/hybrid"
+	"github.com/toricodesthings/file-processing-service/internal/pdfobj"
 	"github.com/toricodesthings/file-processing-service/internal/types"
 )
 
 type Extractor struct {
 	processor *hybrid.Processor
-	maxBytes  int64
+	registry  *extract.Registry
+	limits    Limits
+	figures   FigureConfig
 }
 
-func New(processor *hybrid.Processor, maxBytes int64) *Extractor {
-	return &Extractor{processor: processor, maxBytes: maxBytes}
+// Limits bounds the work done per PDF.
+type Limits struct {
+	MaxBytes           int64 // largest PDF accepted
+	StructureBytes     int64 // largest PDF whose object graph is parsed
+	MaxAttachments     int   // embedded files extracted per PDF
+	MaxAttachmentBytes int64 // largest embedded file extracted
+}
+
+// New returns the PDF extractor. registry is used to extract embedded files
+// and may be nil to disable attachment extraction.
+func New(processor *hybrid.Processor, registry *extract.Registry, limits Limits, figures FigureConfig) *Extractor {
+	return &Extractor{processor: processor, registry: registry, limits: limits, figures: figures}
 }
 
 func (e *Extractor) Name() string { return "document/pdf" }
 
-func (e *Extractor) MaxFileSize() int64 { return e.maxBytes }
+func (e *Extractor) MaxFileSize() int64 { return e.limits.MaxBytes }
 
 func (e *Extractor) SupportedTypes() []string {
 	return []string{"application/pdf"}
@@ -31,31 +50,348 @@ func (e *Extractor) SupportedExtensions() []string {
 
 func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Result, error) {
 	opts := e.processor.ApplyDefaults(types.HybridProcessorOptions{})
+	opts.Password = stringOption(job.Options, "password")
 	out, err := e.processor.ProcessHybrid(ctx, job.PresignedURL, job.LocalPath, opts)
 	if err != nil {
 		msg := err.Error()
-		return extract.Result{Success: false, Method: "hybrid", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg}, err
+		return extract.Result{Success: false, Method: "hybrid", FileType: e.Name(), MIMEType: job.MIMEType, Error: &msg, Code: out.Code}, err
+	}
+
+	meta := out.Metadata
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	meta["totalPages"] = strconv.Itoa(out.TotalPages)
+	// OCR failures are not fatal: text-layer pages are still returned, and
+	// the reason (e.g. provider_unavailable) is surfaced alongside them.
+	if out.Error != nil {
+		meta["ocrError"] = *out.Error
+		if out.Code != "" {
+			meta["ocrErrorCode"] = out.Code
+		}
+	}
+	if providers := ocrProviders(out.Pages); providers != "" {
+		meta["ocrProvider"] = providers
+	}
+
+	var outline []pdfobj.OutlineItem
+	var fields []extract.FormFieldEntry
+	var annots []extract.AnnotationEntry
+	doc := e.openStructure(job.LocalPath)
+	if doc != nil {
+		outline = doc.Outline()
+		if boolOption(job.Options, "formFields", true) {
+			fields = collectFormFields(doc)
+		}
+		if boolOption(job.Options, "annotations", true) {
+			annots = collectAnnotations(ctx, doc, job.LocalPath, e.processor.ExtractorConfig())
+		}
+		if labels := doc.PageLabels(out.TotalPages); labels != nil {
+			for i := range out.Pages {
+				if n := out.Pages[i].PageNumber; n >= 1 && n <= len(labels) {
+					out.Pages[i].PageLabel = labels[n-1]
+				}
+			}
+			meta["pageLabels"] = summarizeLabels(labels)
+		}
+	}
+
+	text := out.Text
+	recombine := meta["pageLabels"] != ""
+	if len(outline) > 0 {
+		meta["outlineItems"] = strconv.Itoa(len(outline))
+		if boolOption(job.Options, "outlineHeadings", true) {
+			insertOutlineHeadings(out.Pages, outline)
+			recombine = true
+		}
+	}
+	if boolOption(job.Options, "figures", false) {
+		limit := min(intOption(job.Options, "maxFigures", e.figures.MaxFigures), e.figures.MaxFigures)
+		cfg := e.processor.ExtractorConfig()
+		cfg.Password = opts.Password
+		stats := e.describeFigures(ctx, job.LocalPath, out.Pages, limit, cfg)
+		meta["figuresFound"] = strconv.Itoa(stats.candidates)
+		meta["figuresAnalyzed"] = strconv.Itoa(stats.analyzed)
+		if stats.failed > 0 {
+			meta["figuresFailed"] = strconv.Itoa(stats.failed)
+		}
+		recombine = recombine || stats.analyzed > 0
+	}
+	if len(fields) > 0 || len(annots) > 0 {
+		meta["formFields"] = strconv.Itoa(len(fields))
+		meta["annotations"] = strconv.Itoa(len(annots))
+		appendFormSections(out.Pages, fields, annots)
+		recombine = true
+	}
+	// Recombine so page labels, outline headings, figures and form sections
+	// reach the final text.
+	if recombine {
+		text = format.Combine(out.Pages, opts.PageSeparator, opts.IncludePageNumbers)
 	}
 
 	pages := make([]extract.PageResult, 0, len(out.Pages))
 	for _, p := range out.Pages {
 		pages = append(pages, extract.PageResult{
 			PageNumber: p.PageNumber,
+			PageLabel:  p.PageLabel,
 			Text:       p.Text,
 			Method:     p.Method,
 			WordCount:  p.WordCount,
 		})
 	}
 
-	words, chars := extract.BuildCounts(out.Text)
+	var attachments []extract.Attachment
+	if boolOption(job.Options, "attachments", true) {
+		attachments = e.extractAttachments(ctx, job, doc)
+		if len(attachments) > 0 {
+			meta["attachments"] = strconv.Itoa(len(attachments))
+		}
+	}
+	if doc != nil && doc.Portfolio() {
+		meta["portfolio"] = "true"
+	}
+
+	var entries []extract.OutlineEntry
+	for _, item := range outline {
+		entries = append(entries, extract.OutlineEntry{Title: item.Title, Level: item.Level, Page: item.Page})
+	}
+
+	words, chars := extract.BuildCounts(text)
 	return extract.Result{
-		Success:   true,
-		Text:      out.Text,
-		Method:    "hybrid",
-		FileType:  e.Name(),
-		MIMEType:  job.MIMEType,
-		Pages:     pages,
-		WordCount: words,
-		CharCount: chars,
+		Success:     true,
+		Text:        text,
+		Method:      "hybrid",
+		FileType:    e.Name(),
+		MIMEType:    job.MIMEType,
+		Pages:       pages,
+		Metadata:    meta,
+		Outline:     entries,
+		FormFields:  fields,
+		Annotations: annots,
+		Attachments: attachments,
+		WordCount:   words,
+		CharCount:   chars,
 	}, nil
 }
+
+// openStructure parses the PDF object graph for outline and page labels.
+// Failures are logged and treated as "no structure" — text extraction has
+// already succeeded and must not be lost over optional metadata.
+func (e *Extractor) openStructure(path string) *pdfobj.Document {
+	doc, err := pdfobj.Open(path, e.limits.StructureBytes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[pdf] structure unavailable: %v\n", err)
+		return nil
+	}
+	if doc.Encrypted() {
+		return nil
+	}
+	return doc
+}
+
+// insertOutlineHeadings turns bookmark entries into markdown headings on the
+// page they point to. If the title already appears as a line on that page the
+// line is promoted in place; otherwise the heading is placed at the top of the
+// page, in outline order.
+func insertOutlineHeadings(pages []types.PageExtractionResult, outline []pdfobj.OutlineItem) {
+	byPage := map[int][]pdfobj.OutlineItem{}
+	for _, item := range outline {
+		if item.Page > 0 {
+			byPage[item.Page] = append(byPage[item.Page], item)
+		}
+	}
+
+	for i := range pages {
+		items := byPage[pages[i].PageNumber]
+		if len(items) == 0 || strings.TrimSpace(pages[i].Text) == "" {
+			continue
+		}
+
+		lines := strings.Split(pages[i].Text, "\n")
+		var prepend []string
+		for _, item := range items {
+			heading := strings.Repeat("#", min(item.Level, 6)) + " " + item.Title
+			if idx := findTitleLine(lines, item.Title); idx >= 0 {
+				lines[idx] = heading
+				continue
+			}
+			prepend = append(prepend, heading)
+		}
+
+		text := strings.Join(lines, "\n")
+		if len(prepend) > 0 {
+			text = strings.Join(prepend, "\n\n") + "\n\n" + text
+		}
+		pages[i].Text = text
+	}
+}
+
+// findTitleLine returns the index of the first line whose text matches the
+// outline title (case- and whitespace-insensitive), or -1.
+func findTitleLine(lines []string, title string) int {
+	want := normalizeTitle(title)
+	if want == "" {
+		return -1
+	}
+	for i, line := range lines {
+		got := normalizeTitle(strings.TrimLeft(line, "# "))
+		if got == want {
+			return i
+		}
+	}
+	return -1
+}
+
+func normalizeTitle(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// summarizeLabels collapses per-page labels into ranges of consecutive
+// numbering, e.g. "i-iv, 1-120".
+func summarizeLabels(labels []string) string {
+	var parts []string
+	start := 0
+	for i := 1; i <= len(labels); i++ {
+		if i < len(labels) && labelFollows(labels[i-1], labels[i]) {
+			continue
+		}
+		if start == i-1 {
+			parts = append(parts, labels[start])
+		} else {
+			parts = append(parts, labels[start]+"-"+labels[i-1])
+		}
+		start = i
+	}
+	return strings.Join(parts, ", ")
+}
+
+// labelFollows reports whether next continues the numbering of prev: same
+// prefix, the same numbering family (arabic, roman, alphabetic) and the
+// next number, so restarts and gaps start a new range.
+func labelFollows(prev, next string) bool {
+	pp, pn := splitLabel(prev)
+	np, nn := splitLabel(next)
+	if pp != np || pn == "" || nn == "" {
+		return false
+	}
+	family := labelFamily(pn)
+	if family != labelFamily(nn) {
+		return false
+	}
+	pv := labelValue(pn, family)
+	return pv > 0 && labelValue(nn, family) == pv+1
+}
+
+func splitLabel(l string) (prefix, num string) {
+	i := len(l)
+	for i > 0 && isLabelChar(l[i-1]) {
+		i--
+	}
+	return l[:i], l[i:]
+}
+
+func isLabelChar(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func labelFamily(num string) string {
+	switch {
+	case strings.Trim(num, "0123456789") == "":
+		return "arabic"
+	case strings.Trim(num, "ivxlcdm") == "":
+		return "roman"
+	case strings.Trim(num, "IVXLCDM") == "":
+		return "ROMAN"
+	case strings.ToLower(num) == num:
+		return "alpha"
+	}
+	return "ALPHA"
+}
+
+// labelValue is the number a label's numeric part stands for in its
+// family, or 0 when it is not a well-formed number.
+func labelValue(num, family string) int {
+	num = strings.ToLower(num)
+	switch family {
+	case "arabic":
+		n, _ := strconv.Atoi(num)
+		return n
+	case "roman", "ROMAN":
+		vals := map[byte]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100, 'd': 500, 'm': 1000}
+		n := 0
+		for i := 0; i < len(num); i++ {
+			v := vals[num[i]]
+			if i+1 < len(num) && v < vals[num[i+1]] {
+				n -= v
+			} else {
+				n += v
+			}
+		}
+		return n
+	}
+	// Alphabetic labels repeat one letter: a..z, aa..zz, and so on.
+	if strings.Trim(num, num[:1]) != "" {
+		return 0
+	}
+	return (len(num)-1)*26 + int(num[0]-'a') + 1
+}
+
+func stringOption(options map[string]any, key string) string {
+	if options == nil {
+		return ""
+	}
+	s, _ := options[key].(string)
+	return s
+}
+
+func intOption(options map[string]any, key string, fallback int) int {
+	if options == nil {
+		return fallback
+	}
+	switch n := options[key].(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(n)); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
+
+func boolOption(options map[string]any, key string, fallback bool) bool {
+	if options == nil {
+		return fallback
+	}
+	v, ok := options[key]
+	if !ok {
+		return fallback
+	}
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(b))
+		if err != nil {
+			return fallback
+		}
+		return parsed
+	default:
+		return fallback
+	}
+}
+
+// ocrProviders lists the distinct providers recorded in page methods
+// ("ocr:<provider>"), comma-separated in first-seen order.
+func ocrProviders(pages []types.PageExtractionResult) string {
+	var out []string
+	for _, p := range pages {
+		provider, ok := strings.CutPrefix(p.Method, "ocr:")
+		if ok && !slices.Contains(out, provider) {
+			out = append(out, provider)
+		}
+	}
+	return strings.Join(out, ",")
+}
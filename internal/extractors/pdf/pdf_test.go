@@ -0,0 +1,94 @@
+package pdf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toricodesthings/file-processing-service/internal/extractor"
+	"github.com/toricodesthings/file-processing-service/internal/pdfobj"
+	"github.com/toricodesthings/file-processing-service/internal/types"
+)
+
+func TestInsertOutlineHeadings(t *testing.T) {
+	pages := []types.PageExtractionResult{
+		{PageNumber: 1, Text: "Preface text"},
+		{PageNumber: 2, Text: "  1   Introduction\nBody of the chapter"},
+	}
+	insertOutlineHeadings(pages, []pdfobj.OutlineItem{
+		{Title: "Preface", Level: 1, Page: 1},
+		{Title: "1 Introduction", Level: 2, Page: 2},
+	})
+
+	if !strings.HasPrefix(pages[0].Text, "# Preface\n\nPreface text") {
+		t.Fatalf("expected prepended heading, got %q", pages[0].Text)
+	}
+	if pages[1].Text != "## 1 Introduction\nBody of the chapter" {
+		t.Fatalf("expected promoted heading, got %q", pages[1].Text)
+	}
+}
+
+func TestSummarizeLabels(t *testing.T) {
+	got := summarizeLabels([]string{"i", "ii", "iii", "1", "2", "3", "A-1", "A-2"})
+	if got != "i-iii, 1-3, A-1-A-2" {
+		t.Fatalf("unexpected summary %q", got)
+	}
+	// Restarts and gaps within one family start new ranges.
+	got = summarizeLabels([]string{"1", "2", "3", "9", "10", "1", "2", "ix", "x", "xii", "y", "z", "aa"})
+	if got != "1-3, 9-10, 1-2, ix-x, xii, y-aa" {
+		t.Fatalf("unexpected summary %q", got)
+	}
+}
+
+func TestDeviceRect(t *testing.T) {
+	box := pdfobj.Rect{X0: 10, Y0: 20, X1: 210, Y1: 320} // cropped, 200x300
+	r := pdfobj.Rect{X0: 30, Y0: 40, X1: 80, Y1: 60}
+	for rotate, want := range map[int][4]int{
+		0:   {20, 260, 51, 21},
+		90:  {20, 20, 21, 51},
+		180: {130, 20, 51, 21},
+		270: {260, 130, 21, 51},
+	} {
+		x, y, w, h := deviceRect(box, rotate, r)
+		if got := [4]int{x, y, w, h}; got != want {
+			t.Errorf("rotate %d: got %v, want %v", rotate, got, want)
+		}
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	cases := map[string]string{
+		`C:\Users\vendor\quote.xlsx`: "quote.xlsx",
+		"../../etc/passwd":           "passwd",
+		"..":                         "attachment.bin",
+		"a\x00b.txt":                 "a_b.txt",
+	}
+	for in, want := range cases {
+		if got := safeFileName(in); got != want {
+			t.Fatalf("safeFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSelectFigures(t *testing.T) {
+	images := []extractor.PDFImage{
+		{Page: 1, Num: 0, Type: "image", Width: 120, Height: 40, Object: 5},   // logo, every page
+		{Page: 1, Num: 1, Type: "image", Width: 800, Height: 600, Object: 9},  // chart
+		{Page: 1, Num: 2, Type: "smask", Width: 800, Height: 600, Object: 10}, // its mask
+		{Page: 2, Num: 3, Type: "image", Width: 120, Height: 40, Object: 5},
+		{Page: 2, Num: 4, Type: "image", Width: 2000, Height: 20, Object: 11}, // rule
+		{Page: 3, Num: 5, Type: "image", Width: 120, Height: 40, Object: 5},
+		{Page: 3, Num: 6, Type: "image", Width: 50, Height: 50, Object: 12},   // icon
+		{Page: 3, Num: 7, Type: "image", Width: 640, Height: 480, Object: 13}, // photo
+		{Page: 4, Num: 8, Type: "image", Width: 640, Height: 480, Object: 14}, // OCR page
+	}
+	textPages := map[int]bool{1: true, 2: true, 3: true}
+
+	got, candidates := selectFigures(images, textPages, 100, 1)
+	if candidates != 2 || len(got) != 1 || got[0].Num != 1 {
+		t.Fatalf("got %+v (candidates %d)", got, candidates)
+	}
+	got, _ = selectFigures(images, textPages, 100, 10)
+	if len(got) != 2 || got[1].Num != 7 {
+		t.Fatalf("got %+v", got)
+	}
+}
@@ -5,13 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/toricodesthings/file-processing-service/internal/extract"
 	audioextractor "github.com/toricodesthings/file-processing-service/internal/extractors/audio"
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
 )
 
 type Extractor struct {
@@ -50,7 +50,7 @@ func (e *Extractor) Extract(ctx context.Context, job extract.Job) (extract.Resul
 	localCtx, cancel := context.WithTimeout(ctx, e.ffmpegTO)
 	defer cancel()
 
-	cmd := exec.CommandContext(localCtx, e.ffmpegBinary, "-y", "-i", job.LocalPath, "-vn", "-acodec", "mp3", "-ab", "128k", outAudio)
+	cmd := sandbox.Command(localCtx, e.ffmpegBinary, "-y", "-i", job.LocalPath, "-vn", "-acodec", "mp3", "-ab", "128k", outAudio)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		msg := fmt.Sprintf("ffmpeg failed: %v: %s", err, strings.TrimSpace(string(out)))
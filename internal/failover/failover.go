@@ -0,0 +1,133 @@
+// Package failover runs a capability (OCR, vision, transcription) against an
+// ordered chain of providers, moving to the next one when a provider fails,
+// and records which provider ended up serving each call.
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Capabilities.
+const (
+	OCR           = "ocr"
+	Vision        = "vision"
+	Transcription = "transcription"
+)
+
+// ProviderStats counts outcomes for one provider within a capability.
+type ProviderStats struct {
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+}
+
+type counters struct {
+	succeeded atomic.Int64
+	failed    atomic.Int64
+}
+
+var (
+	mu     sync.RWMutex
+	chains = map[string][]string{}
+	stats  = map[string]map[string]*counters{}
+)
+
+// Configure sets the provider chain for a capability. Call it at startup,
+// before any requests are made. Empty entries are dropped.
+func Configure(capability string, providers []string) {
+	out := make([]string, 0, len(providers))
+	for _, p := range providers {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	chains[capability] = out
+}
+
+// Chain returns the configured chain for a capability, or defaults when
+// none was configured.
+func Chain(capability string, defaults ...string) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if c := chains[capability]; len(c) > 0 {
+		return append([]string(nil), c...)
+	}
+	return defaults
+}
+
+// Run calls fn with each provider in order until one succeeds, returning its
+// result and name. Cancellation stops the chain immediately. A single-provider
+// chain returns that provider's error unchanged; otherwise all errors are
+// joined, so coded errors (e.g. provider_unavailable) stay visible to
+// extract.ErrorCode.
+func Run[T any](ctx context.Context, capability string, providers []string, fn func(ctx context.Context, provider string) (T, error)) (T, string, error) {
+	var zero T
+	if len(providers) == 0 {
+		return zero, "", fmt.Errorf("%s: no providers configured", capability)
+	}
+
+	var errs []error
+	for i, provider := range providers {
+		out, err := fn(ctx, provider)
+		if err == nil {
+			record(capability, provider, true)
+			return out, provider, nil
+		}
+		record(capability, provider, false)
+		if ctx.Err() != nil {
+			return zero, "", err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider, err))
+		if i+1 < len(providers) {
+			fmt.Fprintf(os.Stderr, "[failover] %s: %s failed, trying %s: %v\n", capability, provider, providers[i+1], err)
+		}
+	}
+	if len(errs) == 1 {
+		return zero, "", errors.Unwrap(errs[0])
+	}
+	return zero, "", fmt.Errorf("all %s providers failed: %w", capability, errors.Join(errs...))
+}
+
+func record(capability, provider string, ok bool) {
+	mu.RLock()
+	c := stats[capability][provider]
+	mu.RUnlock()
+	if c == nil {
+		mu.Lock()
+		if stats[capability] == nil {
+			stats[capability] = map[string]*counters{}
+		}
+		if c = stats[capability][provider]; c == nil {
+			c = &counters{}
+			stats[capability][provider] = c
+		}
+		mu.Unlock()
+	}
+	if ok {
+		c.succeeded.Add(1)
+	} else {
+		c.failed.Add(1)
+	}
+}
+
+// Snapshot returns per-capability, per-provider outcome counts for /metrics.
+func Snapshot() map[string]map[string]ProviderStats {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make(map[string]map[string]ProviderStats, len(stats))
+	for capability, providers := range stats {
+		m := make(map[string]ProviderStats, len(providers))
+		for name, c := range providers {
+			m[name] = ProviderStats{Succeeded: c.succeeded.Load(), Failed: c.failed.Load()}
+		}
+		out[capability] = m
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+func TestRunFallsThrough(t *testing.T) {
+	var tried []string
+	out, provider, err := Run(context.Background(), "t-fall", []string{"a", "b", "c"}, func(_ context.Context, p string) (string, error) {
+		tried = append(tried, p)
+		if p == "a" {
+			return "", errors.New("down")
+		}
+		return "text from " + p, nil
+	})
+	if err != nil || provider != "b" || out != "text from b" {
+		t.Fatalf("out=%q provider=%q err=%v", out, provider, err)
+	}
+	if len(tried) != 2 {
+		t.Fatalf("tried %v, want a then b", tried)
+	}
+	st := Snapshot()["t-fall"]
+	if st["a"].Failed != 1 || st["b"].Succeeded != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	sentinel := errors.New("only provider failed")
+	_, _, err := Run(context.Background(), "t-single", []string{"a"}, func(context.Context, string) (int, error) {
+		return 0, sentinel
+	})
+	if err != sentinel {
+		t.Fatalf("single-provider error changed: %v", err)
+	}
+
+	_, _, err = Run(context.Background(), "t-all", []string{"a", "b"}, func(_ context.Context, p string) (int, error) {
+		if p == "a" {
+			return 0, extract.WithCode(extract.CodeProviderUnavailable, errors.New("circuit open"))
+		}
+		return 0, errors.New("binary missing")
+	})
+	if err == nil || extract.ErrorCode(err) != extract.CodeProviderUnavailable {
+		t.Fatalf("err=%v code=%q, want joined error keeping code", err, extract.ErrorCode(err))
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	_, _, err := Run(ctx, "t-cancel", []string{"a", "b"}, func(context.Context, string) (int, error) {
+		calls++
+		cancel()
+		return 0, context.Canceled
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("err=%v calls=%d", err, calls)
+	}
+}
+
+func TestChainDefaults(t *testing.T) {
+	if got := Chain("t-unset", "x"); len(got) != 1 || got[0] != "x" {
+		t.Fatalf("defaults: %v", got)
+	}
+	Configure("t-set", []string{" a ", "", "b"})
+	if got := Chain("t-set", "x"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("configured: %v", got)
+	}
+}
@@ -33,7 +33,11 @@ func Combine(pages []types.PageExtractionResult, sep string, includePageNums boo
 
 		// Add page marker if requested (as plain text, not HTML)
 		if includePageNums {
-			parts = append(parts, fmt.Sprintf("[Page %d]\n\n%s", p.PageNumber, txt))
+			marker := fmt.Sprintf("[Page %d]", p.PageNumber)
+			if p.PageLabel != "" && p.PageLabel != fmt.Sprint(p.PageNumber) {
+				marker = fmt.Sprintf("[Page %d (%s)]", p.PageNumber, p.PageLabel)
+			}
+			parts = append(parts, marker+"\n\n"+txt)
 		} else {
 			parts = append(parts, txt)
 		}
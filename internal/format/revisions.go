@@ -0,0 +1,29 @@
+package format
+
+import "strings"
+
+// Revisions reads the revisions option shared by the DOCX and ODT
+// extractors. Tracked changes are accepted unless the caller asks for the
+// original text ("reject") or a markup view ("markup").
+func Revisions(options map[string]any) string {
+	mode, _ := options["revisions"].(string)
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "reject", "markup":
+		return mode
+	default:
+		return "accept"
+	}
+}
+
+// CriticNote renders a tracked change's author and day as a CriticMarkup
+// comment.
+func CriticNote(author, date string) string {
+	date = strings.SplitN(date, "T", 2)[0]
+	switch {
+	case author != "" && date != "":
+		return "{>>" + author + ", " + date + "<<}"
+	case author != "" || date != "":
+		return "{>>" + author + date + "<<}"
+	}
+	return ""
+}
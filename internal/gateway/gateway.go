@@ -0,0 +1,208 @@
+// Package gateway throttles outbound calls to external AI providers. Every
+// Mistral, OpenRouter and Groq request acquires a slot from its provider's
+// Gateway first, which enforces a concurrency limit and a token-bucket
+// request rate and queues callers (honouring context cancellation) when
+// either is exhausted.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
+)
+
+// Provider names.
+const (
+	Mistral    = "mistral"
+	OpenRouter = "openrouter"
+	Groq       = "groq"
+)
+
+// ErrQueueFull is returned when a provider already has MaxQueue callers
+// waiting for a slot.
+var ErrQueueFull = errors.New("provider queue full")
+
+// Limits configures one provider. Zero values mean "unlimited".
+type Limits struct {
+	Concurrency int64   // simultaneous in-flight requests
+	RatePerSec  float64 // sustained requests per second
+	Burst       int     // token-bucket burst (defaults to 1 when RatePerSec > 0)
+	MaxQueue    int     // callers allowed to wait; further callers fail fast
+}
+
+// Stats is a point-in-time view of one provider for /metrics.
+type Stats struct {
+	InFlight   int64   `json:"inFlight"`
+	Queued     int64   `json:"queued"`
+	Total      int64   `json:"total"`
+	Rejected   int64   `json:"rejected"`
+	Cancelled  int64   `json:"cancelled"`
+	AvgWaitMs  float64 `json:"avgWaitMs"`
+	MaxWaitMs  float64 `json:"maxWaitMs"`
+	Limit      int64   `json:"concurrencyLimit,omitempty"`
+	RatePerSec float64 `json:"ratePerSec,omitempty"`
+}
+
+// Gateway guards one provider.
+type Gateway struct {
+	name    string
+	limits  Limits
+	sem     *semaphore.Weighted
+	limiter *rate.Limiter
+
+	inFlight  atomic.Int64
+	queued    atomic.Int64
+	total     atomic.Int64
+	rejected  atomic.Int64
+	cancelled atomic.Int64
+	waitNanos atomic.Int64
+	maxWait   atomic.Int64
+}
+
+// New returns a gateway for the named provider.
+func New(name string, l Limits) *Gateway {
+	g := &Gateway{name: name, limits: l}
+	if l.Concurrency > 0 {
+		g.sem = semaphore.NewWeighted(l.Concurrency)
+	}
+	if l.RatePerSec > 0 {
+		burst := l.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		g.limiter = rate.NewLimiter(rate.Limit(l.RatePerSec), burst)
+	}
+	return g
+}
+
+// Acquire waits for a rate token and a concurrency slot. The returned
+// release func must be called once the request (including reading the
+// response body) is finished.
+func (g *Gateway) Acquire(ctx context.Context) (release func(), err error) {
+	if n := g.queued.Add(1); g.limits.MaxQueue > 0 && n > int64(g.limits.MaxQueue) {
+		g.queued.Add(-1)
+		g.rejected.Add(1)
+		return nil, fmt.Errorf("%s: %w", g.name, ErrQueueFull)
+	}
+	start := time.Now()
+	defer func() {
+		g.queued.Add(-1)
+		if err != nil {
+			g.cancelled.Add(1)
+			return
+		}
+		wait := time.Since(start).Nanoseconds()
+		g.waitNanos.Add(wait)
+		for {
+			cur := g.maxWait.Load()
+			if wait <= cur || g.maxWait.CompareAndSwap(cur, wait) {
+				break
+			}
+		}
+	}()
+
+	// Take the slot first so the rate applies at dispatch time, not while
+	// the caller is still queued behind in-flight requests.
+	if g.sem != nil {
+		if err := g.sem.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+	}
+	if g.limiter != nil {
+		if err := g.limiter.Wait(ctx); err != nil {
+			if g.sem != nil {
+				g.sem.Release(1)
+			}
+			return nil, err
+		}
+	}
+
+	g.inFlight.Add(1)
+	g.total.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.inFlight.Add(-1)
+			if g.sem != nil {
+				g.sem.Release(1)
+			}
+		})
+	}, nil
+}
+
+// Stats returns current counters.
+func (g *Gateway) Stats() Stats {
+	total := g.total.Load()
+	var avg float64
+	if total > 0 {
+		avg = float64(g.waitNanos.Load()) / float64(total) / 1e6
+	}
+	return Stats{
+		InFlight:   g.inFlight.Load(),
+		Queued:     g.queued.Load(),
+		Total:      total,
+		Rejected:   g.rejected.Load(),
+		Cancelled:  g.cancelled.Load(),
+		AvgWaitMs:  avg,
+		MaxWaitMs:  float64(g.maxWait.Load()) / 1e6,
+		Limit:      g.limits.Concurrency,
+		RatePerSec: g.limits.RatePerSec,
+	}
+}
+
+// ── Process-wide registry ────────────────────────────────────────────────────
+
+var (
+	mu       sync.RWMutex
+	gateways = map[string]*Gateway{}
+)
+
+// Configure installs (or replaces) the gateway for a provider. Call it at
+// startup, before any requests are made.
+func Configure(name string, l Limits) {
+	mu.Lock()
+	defer mu.Unlock()
+	gateways[name] = New(name, l)
+}
+
+// For returns the provider's gateway, creating an unlimited one if the
+// provider was never configured (e.g. in tests or CLI tools).
+func For(name string) *Gateway {
+	mu.RLock()
+	g, ok := gateways[name]
+	mu.RUnlock()
+	if ok {
+		return g
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if g, ok := gateways[name]; ok {
+		return g
+	}
+	g = New(name, Limits{})
+	gateways[name] = g
+	return g
+}
+
+// Acquire is shorthand for For(name).Acquire(ctx).
+func Acquire(ctx context.Context, name string) (func(), error) {
+	return For(name).Acquire(ctx)
+}
+
+// Snapshot returns the stats of every known provider, keyed by name.
+func Snapshot() map[string]Stats {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make(map[string]Stats, len(gateways))
+	for n, g := range gateways {
+		out[n] = g.Stats()
+	}
+	return out
+}
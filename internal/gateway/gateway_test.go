@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	g := New("test", Limits{Concurrency: 2})
+
+	var cur, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := g.Acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+			n := cur.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			cur.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() > 2 {
+		t.Fatalf("peak concurrency %d exceeds limit", peak.Load())
+	}
+	if s := g.Stats(); s.Total != 8 || s.InFlight != 0 || s.Queued != 0 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestQueueCancellationAndOverflow(t *testing.T) {
+	g := New("test", Limits{Concurrency: 1, MaxQueue: 1})
+	release, err := g.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := g.Acquire(ctx)
+		done <- err
+	}()
+	for g.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := g.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	release()
+
+	if s := g.Stats(); s.Rejected != 1 || s.Cancelled != 1 || s.Queued != 0 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
@@ -2,15 +2,19 @@ package hybrid
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
 
 	"github.com/toricodesthings/file-processing-service/internal/config"
+	"github.com/toricodesthings/file-processing-service/internal/extract"
 	"github.com/toricodesthings/file-processing-service/internal/extractor"
+	"github.com/toricodesthings/file-processing-service/internal/failover"
 	"github.com/toricodesthings/file-processing-service/internal/format"
 	"github.com/toricodesthings/file-processing-service/internal/ocr"
 	"github.com/toricodesthings/file-processing-service/internal/quality"
@@ -32,6 +36,9 @@ func New(cfg config.Config) *Processor {
 			PDFInfoTimeout:      cfg.PDFInfoTimeout,
 			PDFToTextTimeout:    cfg.PDFToTextTimeout,
 			PDFToTextAllTimeout: cfg.PDFToTextAllTimeout,
+			PDFToPPMTimeout:     cfg.PDFToPPMTimeout,
+			PDFDetachTimeout:    cfg.PDFDetachTimeout,
+			PDFImagesTimeout:    cfg.PDFImagesTimeout,
 		},
 	}
 }
@@ -60,6 +67,12 @@ func (p *Processor) ApplyDefaults(opts types.HybridProcessorOptions) types.Hybri
 	return opts
 }
 
+// ExtractorConfig returns the poppler settings used by this processor, for
+// callers that run additional poppler tools against the same document.
+func (p *Processor) ExtractorConfig() extractor.ExtractorConfig {
+	return p.extractCfg
+}
+
 func (p *Processor) ProcessHybrid(
 	ctx context.Context,
 	presignedURL, pdfPath string,
@@ -70,14 +83,25 @@ func (p *Processor) ProcessHybrid(
 		Pages:   []types.PageExtractionResult{},
 	}
 
-	// Your compiler says PageCount wants ExtractorConfig
-	totalPages, err := extractor.PageCount(ctx, pdfPath, p.extractCfg)
+	extractCfg := p.extractCfg
+	extractCfg.Password = opts.Password
+
+	info, err := extractor.GetPDFInfo(ctx, pdfPath, extractCfg)
 	if err != nil {
+		if isPasswordProtectedErr(err) {
+			err = passwordError(opts.Password)
+			msg := err.Error()
+			result.Error = &msg
+			result.Code = extract.ErrorCode(err)
+			return result, err
+		}
 		msg := fmt.Sprintf("page count failed: %v", err)
 		result.Error = &msg
 		return result, err
 	}
+	totalPages := info.Pages
 	result.TotalPages = totalPages
+	result.Metadata = info.Metadata()
 
 	if totalPages == 0 {
 		msg := "PDF has no pages"
@@ -95,7 +119,7 @@ func (p *Processor) ProcessHybrid(
 	}
 
 	// Phase 1: Extract text from all pages in parallel
-	pageResults := p.extractPagesParallel(ctx, pdfPath, pages, opts.MinWordsThreshold)
+	pageResults := p.extractPagesParallel(ctx, pdfPath, pages, opts.MinWordsThreshold, extractCfg)
 
 	// Phase 2: Analyze quality
 	needsOCRPages := make([]int, 0)
@@ -122,12 +146,29 @@ func (p *Processor) ProcessHybrid(
 			ocrPages = needsOCRPages
 		}
 
-		ocrResults, err := runOCRBatch(ctx, presignedURL, ocrPages, opts)
+		chain := failover.Chain(failover.OCR, ocr.ProviderMistral)
+		ocrResults, provider, err := failover.Run(ctx, failover.OCR, chain, func(ctx context.Context, provider string) (map[int]string, error) {
+			switch provider {
+			case ocr.ProviderMistral:
+				// Mistral fetches the presigned URL itself and cannot decrypt
+				// it, so password-protected PDFs are rasterized locally
+				// instead, as are inputs with no URL Mistral can reach.
+				if (info.Encrypted && opts.Password != "") || presignedURL == "" {
+					return p.runLocalOCRBatch(ctx, pdfPath, ocrPages, provider, opts, extractCfg)
+				}
+				return runOCRBatch(ctx, presignedURL, ocrPages, opts)
+			case ocr.ProviderTesseract:
+				return p.runLocalOCRBatch(ctx, pdfPath, ocrPages, provider, opts, extractCfg)
+			default:
+				return nil, fmt.Errorf("unknown OCR provider %q", provider)
+			}
+		})
 		if err != nil {
 			msg := fmt.Sprintf("OCR failed: %v", err)
 			result.Error = &msg
+			result.Code = extract.ErrorCode(err)
 		} else {
-			mergeOCRResults(&result, ocrResults, shouldDoFullOCR)
+			mergeOCRResults(&result, ocrResults, provider, shouldDoFullOCR)
 		}
 	}
 
@@ -144,9 +185,18 @@ func (p *Processor) ProcessHybrid(
 func (p *Processor) ProcessPreview(ctx context.Context, pdfPath string, opts types.HybridProcessorOptions) types.PreviewResult {
 	result := types.PreviewResult{Success: false}
 
-	// Your compiler says PageCount wants ExtractorConfig
-	totalPages, err := extractor.PageCount(ctx, pdfPath, p.extractCfg)
+	extractCfg := p.extractCfg
+	extractCfg.Password = opts.Password
+
+	totalPages, err := extractor.PageCount(ctx, pdfPath, extractCfg)
 	if err != nil {
+		if isPasswordProtectedErr(err) {
+			err = passwordError(opts.Password)
+			msg := err.Error()
+			result.Error = &msg
+			result.Code = extract.ErrorCode(err)
+			return result
+		}
 		msg := fmt.Sprintf("page count: %v", err)
 		result.Error = &msg
 		return result
@@ -166,7 +216,8 @@ func (p *Processor) ProcessPreview(ctx context.Context, pdfPath string, opts typ
 		pages[i] = i + 1
 	}
 
-	pageResults := p.extractPagesParallel(ctx, pdfPath, pages, opts.MinWordsThreshold)
+	pageResults := p.extractPagesParallel(ctx, pdfPath, pages, opts.MinWordsThreshold, extractCfg)
+	result.SampledPages = len(pages)
 
 	needsOCR := 0
 	totalWords := 0
@@ -201,7 +252,7 @@ func (p *Processor) ProcessPreview(ctx context.Context, pdfPath string, opts typ
 
 // ---------- Internal ----------
 
-func (p *Processor) extractPagesParallel(ctx context.Context, pdfPath string, pages []int, minWords int) []types.PageExtractionResult {
+func (p *Processor) extractPagesParallel(ctx context.Context, pdfPath string, pages []int, minWords int, extractCfg extractor.ExtractorConfig) []types.PageExtractionResult {
 	results := make([]types.PageExtractionResult, len(pages))
 
 	workers := runtime.NumCPU()
@@ -232,7 +283,7 @@ func (p *Processor) extractPagesParallel(ctx context.Context, pdfPath string, pa
 			}
 			defer sem.Release(1)
 
-			results[idx] = p.extractSinglePage(ctx, pdfPath, page, minWords)
+			results[idx] = p.extractSinglePage(ctx, pdfPath, page, minWords, extractCfg)
 		}(i, pageNum)
 	}
 
@@ -240,7 +291,7 @@ func (p *Processor) extractPagesParallel(ctx context.Context, pdfPath string, pa
 	return results
 }
 
-func (p *Processor) extractSinglePage(ctx context.Context, pdfPath string, pageNum, minWords int) types.PageExtractionResult {
+func (p *Processor) extractSinglePage(ctx context.Context, pdfPath string, pageNum, minWords int, extractCfg extractor.ExtractorConfig) types.PageExtractionResult {
 	result := types.PageExtractionResult{
 		PageNumber: pageNum,
 		Method:     "text-layer",
@@ -252,7 +303,7 @@ func (p *Processor) extractSinglePage(ctx context.Context, pdfPath string, pageN
 	//
 	// text, err := extractor.TextForPage(ctx, pdfPath, pageNum, p.extractCfg)
 	//
-	text, err := extractor.TextForPage(ctx, pdfPath, pageNum, p.extractCfg)
+	text, err := extractor.TextForPage(ctx, pdfPath, pageNum, extractCfg)
 	if err != nil {
 		result.Method = "needs-ocr"
 		return result
@@ -309,13 +360,102 @@ func runOCRBatch(ctx context.Context, presignedURL string, pages []int, opts typ
 	return results, nil
 }
 
-func mergeOCRResults(result *types.HybridExtractionResult, ocrResults map[int]string, fullOCR bool) {
+// localOCRWorkers bounds concurrent page renders + OCR calls for one document.
+const localOCRWorkers = 2
+
+// runLocalOCRBatch rasterizes pages with pdftoppm (decrypting with the request
+// password) and OCRs each page image with the given provider: Mistral gets it
+// as a base64 data URL, tesseract reads the PNG directly.
+// Pages that fail are left for the caller to report as needs-ocr.
+func (p *Processor) runLocalOCRBatch(ctx context.Context, pdfPath string, pages []int, provider string, opts types.HybridProcessorOptions, extractCfg extractor.ExtractorConfig) (map[int]string, error) {
+	if len(pages) == 0 {
+		return map[int]string{}, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "ocr start (local render): pages=%d provider=%s\n", len(pages), provider)
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		results  = make(map[int]string, len(pages))
+		sem      = semaphore.NewWeighted(localOCRWorkers)
+	)
+	for _, page := range pages {
+		wg.Add(1)
+		go func(page int) {
+			defer wg.Done()
+
+			var text string
+			err := sem.Acquire(ctx, 1)
+			if err == nil {
+				text, err = p.ocrRenderedPage(ctx, pdfPath, page, provider, opts, extractCfg)
+				sem.Release(1)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			results[page] = text
+		}(page)
+	}
+	wg.Wait()
+
+	if len(results) == 0 && firstErr != nil {
+		fmt.Fprintf(os.Stderr, "ocr failed (local render): %v\n", firstErr)
+		return nil, firstErr
+	}
+
+	fmt.Fprintf(os.Stderr, "ocr done (local render): pages=%d provider=%s\n", len(results), provider)
+	return results, nil
+}
+
+func (p *Processor) ocrRenderedPage(ctx context.Context, pdfPath string, page int, provider string, opts types.HybridProcessorOptions, extractCfg extractor.ExtractorConfig) (string, error) {
+	prefix := filepath.Join(filepath.Dir(pdfPath), fmt.Sprintf("ocr-page-%d", page))
+	pngPath, err := extractor.RenderPage(ctx, pdfPath, page, p.cfg.OCRRenderDPI, prefix, extractCfg)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(pngPath)
+
+	if provider == ocr.ProviderTesseract {
+		text, err := ocr.RunTesseract(ctx, pngPath)
+		if err != nil {
+			return "", err
+		}
+		return cleanText(text), nil
+	}
+
+	b, err := os.ReadFile(pngPath)
+	if err != nil {
+		return "", err
+	}
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(b)
+
+	resp, err := ocr.RunMistralImageOCR(ctx, dataURL, *opts.OCRModel)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(resp.Pages))
+	for _, pg := range resp.Pages {
+		parts = append(parts, pg.Markdown)
+	}
+	return cleanText(strings.Join(parts, "\n\n")), nil
+}
+
+// mergeOCRResults fills OCR'd pages in, tagging each with "ocr:<provider>".
+func mergeOCRResults(result *types.HybridExtractionResult, ocrResults map[int]string, provider string, fullOCR bool) {
 	for i := range result.Pages {
 		pageNum := result.Pages[i].PageNumber
 		if ocrText, exists := ocrResults[pageNum]; exists {
 			if fullOCR || result.Pages[i].Method == "needs-ocr" {
 				result.Pages[i].Text = ocrText
-				result.Pages[i].Method = "ocr"
+				result.Pages[i].Method = "ocr:" + provider
 				result.Pages[i].WordCount = quality.CountWords(ocrText)
 			}
 		}
@@ -377,7 +517,7 @@ func cleanText(text string) string {
 func countOCRPages(pages []types.PageExtractionResult) int {
 	count := 0
 	for _, p := range pages {
-		if p.Method == "ocr" {
+		if strings.HasPrefix(p.Method, "ocr") {
 			count++
 		}
 	}
@@ -395,6 +535,18 @@ func isPasswordProtectedErr(err error) bool {
 	if err == nil {
 		return false
 	}
+	if errors.Is(err, extractor.ErrPasswordProtected) {
+		return true
+	}
 	msg := strings.ToLower(err.Error())
 	return strings.Contains(msg, "password protected") || strings.Contains(msg, "incorrect password")
 }
+
+// passwordError distinguishes a missing password from a wrong one. The
+// password itself is never included in the message.
+func passwordError(password string) error {
+	if password == "" {
+		return extract.WithCode(extract.CodePasswordRequired, errors.New("PDF is password protected; supply the password option"))
+	}
+	return extract.WithCode(extract.CodePasswordIncorrect, errors.New("incorrect PDF password"))
+}
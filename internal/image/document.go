@@ -0,0 +1,60 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Limits shared by the analysis of images found inside documents: PDF
+// figures and images embedded in Office, ODF and EPUB files.
+const (
+	// DocumentImageWorkers bounds concurrent vision/OCR calls for one
+	// document.
+	DocumentImageWorkers = 2
+	// MaxDocumentImageBytes skips images too large to send inline.
+	MaxDocumentImageBytes = 20 << 20
+	// DecorativeUses: an image placed this many times (on pages, or at
+	// anchors) is a logo, bullet or background, not content.
+	DecorativeUses = 3
+	// maxAspect filters rules, borders and banner strips.
+	maxAspect = 8
+)
+
+// ContentSized reports whether an image of the given size is worth
+// analysing: at least minPixels on each side and not strip-shaped.
+func ContentSized(width, height, minPixels int) bool {
+	if width < minPixels || height < minPixels {
+		return false
+	}
+	long, short := max(width, height), min(width, height)
+	return short > 0 && long/short <= maxAspect
+}
+
+// DescribeDocumentImage sends an image found inside a document through
+// ProcessImageData and formats the outcome: the vision description,
+// followed by any OCR text.
+func DescribeDocumentImage(ctx context.Context, data []byte, mimeType, ocrModel, visionModel string, visionTimeout time.Duration) (string, error) {
+	if len(data) > MaxDocumentImageBytes {
+		return "", fmt.Errorf("image too large (%d bytes)", len(data))
+	}
+	res, err := ProcessImageData(ctx, data, mimeType, ocrModel, visionModel, visionTimeout)
+	if err != nil {
+		return "", err
+	}
+
+	desc := strings.TrimSpace(res.Description)
+	text := strings.TrimSpace(res.Text)
+	var parts []string
+	if desc != "" {
+		parts = append(parts, desc)
+		if res.ImageType != "" {
+			parts[0] = "(" + res.ImageType + ") " + desc
+		}
+	}
+	if text != "" && text != desc {
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
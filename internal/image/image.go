@@ -2,12 +2,15 @@ package image
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/toricodesthings/file-processing-service/internal/failover"
 	"github.com/toricodesthings/file-processing-service/internal/ocr"
 	"github.com/toricodesthings/file-processing-service/internal/types"
 	"github.com/toricodesthings/file-processing-service/internal/vision"
@@ -68,12 +71,14 @@ func combineOCRPages(ocrResp ocr.OCRResponse) string {
 // ProcessImage classifies an image via a cheap vision model (OpenRouter) and
 // routes to the appropriate extraction method:
 //
-//   - contentType "text"  → Mistral OCR (handwriting, documents, screenshots, …)
+//   - contentType "text"  → OCR (handwriting, documents, screenshots, …)
 //   - contentType "visual"→ vision description only (photos, artwork, …)
 //   - contentType "mixed" → OCR + vision description (diagrams, charts, …)
 //
 // If the vision classifier is unavailable, we fall back to OCR-only (current behaviour).
-func ProcessImage(ctx context.Context, imageURL, ocrModel, visionModel string, visionTimeout time.Duration) (types.ImageExtractionResult, error) {
+// OCR and vision each run through their failover chains; localPath is the
+// downloaded copy of imageURL used by local OCR providers (may be empty).
+func ProcessImage(ctx context.Context, imageURL, localPath, ocrModel, visionModel string, visionTimeout time.Duration) (types.ImageExtractionResult, error) {
 	// ── Validate ─────────────────────────────────────────────────────────────
 	if strings.TrimSpace(imageURL) == "" {
 		msg := "imageUrl required"
@@ -92,16 +97,90 @@ func ProcessImage(ctx context.Context, imageURL, ocrModel, visionModel string, v
 		return types.ImageExtractionResult{Error: &msg}, errors.New(msg)
 	}
 
+	return routeImage(ctx, &imageSource{url: imageURL, path: localPath}, ocrModel, visionModel, visionTimeout)
+}
+
+// ProcessImageData runs the same classification/OCR routing as ProcessImage
+// on image bytes held locally (e.g. figures pulled out of a PDF), sending
+// them to the providers inline as a base64 data URL.
+func ProcessImageData(ctx context.Context, data []byte, mimeType, ocrModel, visionModel string, visionTimeout time.Duration) (types.ImageExtractionResult, error) {
+	if len(data) == 0 {
+		msg := "image data required"
+		return types.ImageExtractionResult{Error: &msg}, errors.New(msg)
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		msg := fmt.Sprintf("unsupported image type %q", mimeType)
+		return types.ImageExtractionResult{Error: &msg}, errors.New(msg)
+	}
+	dataURL := "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	src := &imageSource{url: dataURL, data: data, mimeType: mimeType}
+	defer src.cleanup()
+	return routeImage(ctx, src, ocrModel, visionModel, visionTimeout)
+}
+
+// imageSource is an image addressable by URL for hosted providers and by
+// file path for local ones. In-memory images are written to a temp file the
+// first time a local provider needs them.
+type imageSource struct {
+	url      string
+	path     string
+	data     []byte
+	mimeType string
+	temp     bool
+}
+
+func (s *imageSource) localPath() (string, error) {
+	if s.path != "" {
+		return s.path, nil
+	}
+	if len(s.data) == 0 {
+		return "", errors.New("no local copy of image for local OCR")
+	}
+	ext := "." + strings.TrimPrefix(strings.SplitN(s.mimeType, "+", 2)[0], "image/")
+	f, err := os.CreateTemp("", "image-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(s.data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	s.path, s.temp = f.Name(), true
+	return s.path, nil
+}
+
+func (s *imageSource) cleanup() {
+	if s.temp {
+		os.Remove(s.path)
+	}
+}
+
+// routeImage performs the classification and routing for an already
+// validated image (http(s) or data URL).
+func routeImage(ctx context.Context, src *imageSource, ocrModel, visionModel string, visionTimeout time.Duration) (types.ImageExtractionResult, error) {
 	if ocrModel == "" {
 		ocrModel = "mistral-ocr-latest"
 	}
 
 	// ── Step 1: Vision classification (cheap, ~$0.0001) ──────────────────────
-	visionResult, visionErr := vision.RunVisionClassification(ctx, imageURL, visionModel, visionTimeout)
+	models := failover.Chain(failover.Vision, visionModel)
+	visionResult, usedModel, visionErr := failover.Run(ctx, failover.Vision, models, func(ctx context.Context, model string) (vision.VisionResult, error) {
+		return vision.RunVisionClassification(ctx, src.url, model, visionTimeout)
+	})
 	if visionErr != nil {
 		// Vision unavailable — fall back to OCR-only (preserves current behaviour)
 		fmt.Printf("[image] vision classification failed, falling back to OCR-only: %v\n", visionErr)
-		return processOCROnly(ctx, imageURL, ocrModel)
+		return processOCROnly(ctx, src, ocrModel)
+	}
+
+	visionOnly := types.ImageExtractionResult{
+		Success:     true,
+		Text:        visionResult.Description,
+		Method:      "vision",
+		ImageType:   visionResult.ImageType,
+		Description: visionResult.Description,
+		VisionModel: usedModel,
 	}
 
 	// ── Step 2: Route based on content type ──────────────────────────────────
@@ -109,17 +188,11 @@ func ProcessImage(ctx context.Context, imageURL, ocrModel, visionModel string, v
 	case "text":
 		// Text-heavy content (handwriting, docs, screenshots, whiteboards)
 		// → OCR provides the primary text; vision description is supplementary
-		ocrResult, err := runOCR(ctx, imageURL, ocrModel)
+		ocrResult, provider, err := runOCR(ctx, src, ocrModel)
 		if err != nil {
 			// OCR failed but we still have the vision description
 			fmt.Printf("[image] OCR failed for text content, using vision description: %v\n", err)
-			return types.ImageExtractionResult{
-				Success:     true,
-				Text:        visionResult.Description,
-				Method:      "vision",
-				ImageType:   visionResult.ImageType,
-				Description: visionResult.Description,
-			}, nil
+			return visionOnly, nil
 		}
 
 		return types.ImageExtractionResult{
@@ -128,21 +201,17 @@ func ProcessImage(ctx context.Context, imageURL, ocrModel, visionModel string, v
 			Method:      "ocr",
 			ImageType:   visionResult.ImageType,
 			Description: visionResult.Description,
+			OCRProvider: provider,
+			VisionModel: usedModel,
 		}, nil
 
 	case "mixed":
 		// Significant text AND visual content (diagrams, charts, infographics)
 		// → OCR for text extraction + vision description for visual context
-		ocrResult, err := runOCR(ctx, imageURL, ocrModel)
+		ocrResult, provider, err := runOCR(ctx, src, ocrModel)
 		if err != nil {
 			fmt.Printf("[image] OCR failed for mixed content, using vision description: %v\n", err)
-			return types.ImageExtractionResult{
-				Success:     true,
-				Text:        visionResult.Description,
-				Method:      "vision",
-				ImageType:   visionResult.ImageType,
-				Description: visionResult.Description,
-			}, nil
+			return visionOnly, nil
 		}
 
 		return types.ImageExtractionResult{
@@ -151,54 +220,67 @@ func ProcessImage(ctx context.Context, imageURL, ocrModel, visionModel string, v
 			Method:      "ocr+vision",
 			ImageType:   visionResult.ImageType,
 			Description: visionResult.Description,
+			OCRProvider: provider,
+			VisionModel: usedModel,
 		}, nil
 
 	default:
 		// "visual" or unknown — photo, artwork, no meaningful text
 		// → vision description IS the primary content
-		return types.ImageExtractionResult{
-			Success:     true,
-			Text:        visionResult.Description,
-			Method:      "vision",
-			ImageType:   visionResult.ImageType,
-			Description: visionResult.Description,
-		}, nil
+		return visionOnly, nil
 	}
 }
 
-// runOCR calls Mistral OCR and returns cleaned text.
-func runOCR(ctx context.Context, imageURL, model string) (string, error) {
-	ocrResp, err := ocr.RunMistralImageOCR(ctx, imageURL, model)
-	if err != nil {
-		return "", err
-	}
-
-	if len(ocrResp.Pages) == 0 {
-		return "", errors.New("OCR returned no pages")
-	}
-
-	raw := combineOCRPages(ocrResp)
-	cleaned := cleanOCRText(raw)
-	if cleaned == "" {
-		return "", errors.New("OCR produced empty text")
-	}
+// runOCR runs the OCR failover chain and returns cleaned text and the
+// provider that produced it.
+func runOCR(ctx context.Context, src *imageSource, model string) (string, string, error) {
+	chain := failover.Chain(failover.OCR, ocr.ProviderMistral)
+	return failover.Run(ctx, failover.OCR, chain, func(ctx context.Context, provider string) (string, error) {
+		var raw string
+		switch provider {
+		case ocr.ProviderMistral:
+			ocrResp, err := ocr.RunMistralImageOCR(ctx, src.url, model)
+			if err != nil {
+				return "", err
+			}
+			if len(ocrResp.Pages) == 0 {
+				return "", errors.New("OCR returned no pages")
+			}
+			raw = combineOCRPages(ocrResp)
+		case ocr.ProviderTesseract:
+			path, err := src.localPath()
+			if err != nil {
+				return "", err
+			}
+			if raw, err = ocr.RunTesseract(ctx, path); err != nil {
+				return "", err
+			}
+		default:
+			return "", fmt.Errorf("unknown OCR provider %q", provider)
+		}
 
-	return cleaned, nil
+		cleaned := cleanOCRText(raw)
+		if cleaned == "" {
+			return "", errors.New("OCR produced empty text")
+		}
+		return cleaned, nil
+	})
 }
 
 // processOCROnly is the fallback path when vision is unavailable.
 // This preserves the original behaviour of the endpoint.
-func processOCROnly(ctx context.Context, imageURL, model string) (types.ImageExtractionResult, error) {
-	ocrText, err := runOCR(ctx, imageURL, model)
+func processOCROnly(ctx context.Context, src *imageSource, model string) (types.ImageExtractionResult, error) {
+	ocrText, provider, err := runOCR(ctx, src, model)
 	if err != nil {
 		msg := sanitiseOCRError(err)
 		return types.ImageExtractionResult{Error: &msg}, err
 	}
 
 	return types.ImageExtractionResult{
-		Success: true,
-		Text:    ocrText,
-		Method:  "ocr",
+		Success:     true,
+		Text:        ocrText,
+		Method:      "ocr",
+		OCRProvider: provider,
 	}, nil
 }
 
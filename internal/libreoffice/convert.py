@@ -0,0 +1,55 @@
+# UNO client for the LibreOffice pool. Usage:
+#   convert.py <pipe> ping
+#   convert.py <pipe> convert <input> <output> <filter>
+# Exit status: 0 ok, 2 cannot connect, 3 cannot load, 4 cannot store.
+import sys
+
+import uno
+from com.sun.star.beans import PropertyValue
+
+
+def prop(name, value):
+    p = PropertyValue()
+    p.Name = name
+    p.Value = value
+    return p
+
+
+def desktop(pipe):
+    local = uno.getComponentContext()
+    resolver = local.ServiceManager.createInstanceWithContext("com.sun.star.bridge.UnoUrlResolver", local)
+    ctx = resolver.resolve("uno:pipe,name=%s;urp;StarOffice.ComponentContext" % pipe)
+    return ctx.ServiceManager.createInstanceWithContext("com.sun.star.frame.Desktop", ctx)
+
+
+def main(argv):
+    try:
+        d = desktop(argv[1])
+    except Exception as e:
+        print("connect: %s" % e, file=sys.stderr)
+        return 2
+    if argv[2] == "ping":
+        return 0
+
+    src, dst, filt = argv[3], argv[4], argv[5]
+    try:
+        doc = d.loadComponentFromURL(uno.systemPathToFileUrl(src), "_blank", 0,
+                                     (prop("Hidden", True), prop("ReadOnly", True)))
+    except Exception as e:
+        print("load: %s" % e, file=sys.stderr)
+        return 3
+    if doc is None:
+        print("load: unsupported or unreadable document", file=sys.stderr)
+        return 3
+    try:
+        doc.storeToURL(uno.systemPathToFileUrl(dst), (prop("FilterName", filt), prop("Overwrite", True)))
+    except Exception as e:
+        print("store: %s" % e, file=sys.stderr)
+        return 4
+    finally:
+        doc.close(True)
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main(sys.argv))
@@ -0,0 +1,71 @@
+// Package libreoffice converts documents with LibreOffice. A Pool keeps
+// long-lived headless soffice listeners warm and hands them conversions
+// over UNO. Cold starts a fresh soffice per conversion and is used when
+// the pool is disabled. Either way, every soffice process gets its own
+// user profile, so concurrent conversions never share one.
+package libreoffice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
+)
+
+// Converter converts in to out. format is "<ext>:<FilterName>" as accepted
+// by soffice --convert-to, e.g. "docx:MS Word 2007 XML".
+type Converter interface {
+	Convert(ctx context.Context, in, out, format string) error
+}
+
+// Cold runs `soffice --convert-to` once per conversion.
+type Cold struct {
+	Binary string
+}
+
+func (c Cold) Convert(ctx context.Context, in, out, format string) error {
+	dir, err := os.MkdirTemp("", "lo-cold-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	outDir := filepath.Join(dir, "out")
+
+	cmd := sandbox.Command(ctx, c.Binary,
+		"-env:UserInstallation="+fileURL(filepath.Join(dir, "profile")),
+		"--headless", "--norestore", "--nolockcheck",
+		"--convert-to", format, "--outdir", outDir, in)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("libreoffice conversion: %w", ctx.Err())
+		}
+		return fmt.Errorf("libreoffice conversion failed: %v: %s", err, strings.TrimSpace(string(b)))
+	}
+
+	// soffice names the output after the input with the new extension.
+	ext, _, _ := strings.Cut(format, ":")
+	produced := filepath.Join(outDir, strings.TrimSuffix(filepath.Base(in), filepath.Ext(in))+"."+ext)
+	if _, err := os.Stat(produced); err != nil {
+		return errors.New("libreoffice conversion failed: no output produced")
+	}
+	return moveFile(produced, out)
+}
+
+func fileURL(path string) string {
+	return "file://" + filepath.ToSlash(path)
+}
+
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	b, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(to, b, 0o600)
+}
@@ -0,0 +1,313 @@
+package libreoffice
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
+)
+
+//go:embed convert.py
+var clientScript []byte
+
+// UNO client exit statuses (see convert.py).
+const (
+	exitConnect = 2
+)
+
+// PoolConfig sizes and tunes a Pool.
+type PoolConfig struct {
+	Binary string // soffice
+	// Python is an interpreter with LibreOffice's uno module (python3-uno
+	// on Debian).
+	Python         string
+	Size           int
+	MaxConversions int // recycle a listener after this many conversions; 0 never
+	StartTimeout   time.Duration
+	HealthInterval time.Duration
+}
+
+// Pool hands conversions to long-lived soffice listeners, one conversion per
+// listener at a time. A listener is replaced when it crashes, fails a health
+// check, times out mid-conversion or reaches MaxConversions.
+type Pool struct {
+	cfg    PoolConfig
+	dir    string
+	script string
+	idle   chan *listener
+	closed chan struct{}
+
+	conversions   atomic.Int64
+	failures      atomic.Int64
+	recycled      atomic.Int64
+	startFailures atomic.Int64
+}
+
+type listener struct {
+	id          int
+	pipe        string
+	profile     string
+	cancel      context.CancelFunc
+	done        chan struct{} // closed when soffice exits
+	conversions int
+}
+
+func (l *listener) dead() bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *listener) stop() {
+	l.cancel()
+	<-l.done
+}
+
+// NewPool starts cfg.Size listeners in the background; Convert waits for
+// one to become ready.
+func NewPool(cfg PoolConfig) (*Pool, error) {
+	if cfg.Size <= 0 {
+		return nil, errors.New("libreoffice: pool size must be positive")
+	}
+	for _, bin := range []string{cfg.Binary, cfg.Python} {
+		if _, err := exec.LookPath(bin); err != nil {
+			return nil, fmt.Errorf("libreoffice: %w", err)
+		}
+	}
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = 60 * time.Second
+	}
+	dir, err := os.MkdirTemp("", "lo-pool-*")
+	if err != nil {
+		return nil, err
+	}
+	script := filepath.Join(dir, "convert.py")
+	if err := os.WriteFile(script, clientScript, 0o644); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	sandbox.Grant(dir)
+
+	p := &Pool{
+		cfg:    cfg,
+		dir:    dir,
+		script: script,
+		idle:   make(chan *listener, cfg.Size),
+		closed: make(chan struct{}),
+	}
+	for i := 0; i < cfg.Size; i++ {
+		go p.replace(i)
+	}
+	if cfg.HealthInterval > 0 {
+		go p.healthLoop()
+	}
+	return p, nil
+}
+
+// Convert converts in to out on the next free listener.
+func (p *Pool) Convert(ctx context.Context, in, out, format string) error {
+	_, filter, ok := strings.Cut(format, ":")
+	if !ok {
+		return fmt.Errorf("libreoffice: format %q has no filter name", format)
+	}
+
+	var l *listener
+	select {
+	case l = <-p.idle:
+	case <-ctx.Done():
+		return fmt.Errorf("libreoffice: no listener available: %w", ctx.Err())
+	case <-p.closed:
+		return errors.New("libreoffice: pool closed")
+	}
+
+	code, err := p.client(ctx, l, "convert", in, out, filter)
+	l.conversions++
+	p.conversions.Add(1)
+	if err != nil {
+		p.failures.Add(1)
+	}
+
+	// A timed-out conversion leaves the listener busy with the document, so
+	// it is replaced rather than reused.
+	hung := err != nil && (ctx.Err() != nil || code == exitConnect)
+	if hung || l.dead() || (p.cfg.MaxConversions > 0 && l.conversions >= p.cfg.MaxConversions) {
+		p.recycled.Add(1)
+		go func() {
+			l.stop()
+			p.replace(l.id)
+		}()
+	} else {
+		p.idle <- l
+	}
+	if err != nil {
+		return fmt.Errorf("libreoffice conversion failed: %w", err)
+	}
+	return nil
+}
+
+// client runs the UNO client script against l and returns its exit status.
+func (p *Pool) client(ctx context.Context, l *listener, args ...string) (int, error) {
+	cmd := sandbox.Command(ctx, p.cfg.Python, append([]string{p.script, l.pipe}, args...)...)
+	b, err := cmd.CombinedOutput()
+	if err == nil {
+		return 0, nil
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitCode(), fmt.Errorf("%v: %s", err, strings.TrimSpace(string(b)))
+	}
+	return -1, err
+}
+
+// replace starts listener id, retrying until it comes up or the pool closes,
+// and makes it available.
+func (p *Pool) replace(id int) {
+	backoff := time.Second
+	for {
+		select {
+		case <-p.closed:
+			return
+		default:
+		}
+		l, err := p.start(id)
+		if err == nil {
+			p.idle <- l
+			return
+		}
+		p.startFailures.Add(1)
+		fmt.Fprintf(os.Stderr, "[libreoffice] listener %d failed to start: %v\n", id, err)
+		select {
+		case <-p.closed:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (p *Pool) start(id int) (*listener, error) {
+	l := &listener{
+		id:      id,
+		pipe:    fmt.Sprintf("fileproc-lo-%d-%d", os.Getpid(), id),
+		profile: filepath.Join(p.dir, fmt.Sprintf("profile-%d", id)),
+		done:    make(chan struct{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	cmd := sandbox.Daemon(ctx, p.cfg.Binary,
+		"-env:UserInstallation="+fileURL(l.profile),
+		"--headless", "--invisible", "--nologo", "--norestore", "--nolockcheck", "--nodefault",
+		"--accept=pipe,name="+l.pipe+";urp;StarOffice.ComponentContext")
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	go func() {
+		cmd.Wait()
+		close(l.done)
+	}()
+
+	deadline := time.Now().Add(p.cfg.StartTimeout)
+	for {
+		if l.dead() {
+			cancel()
+			// A profile left broken by a crash would fail every restart.
+			os.RemoveAll(l.profile)
+			return nil, errors.New("soffice exited during startup")
+		}
+		if p.ping(l) == nil {
+			return l, nil
+		}
+		if time.Now().After(deadline) {
+			l.stop()
+			return nil, fmt.Errorf("not ready after %s", p.cfg.StartTimeout)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+func (p *Pool) ping(l *listener) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := p.client(ctx, l, "ping")
+	return err
+}
+
+// healthLoop pings idle listeners and replaces those that fail.
+func (p *Pool) healthLoop() {
+	t := time.NewTicker(p.cfg.HealthInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.closed:
+			return
+		case <-t.C:
+		}
+		for n := len(p.idle); n > 0; n-- {
+			var l *listener
+			select {
+			case l = <-p.idle:
+			default:
+			}
+			if l == nil {
+				break
+			}
+			if l.dead() || p.ping(l) != nil {
+				p.recycled.Add(1)
+				go func() {
+					l.stop()
+					p.replace(l.id)
+				}()
+				continue
+			}
+			p.idle <- l
+		}
+	}
+}
+
+// Close stops the idle listeners and removes the pool directory. Listeners
+// busy with a conversion are killed when the process exits.
+func (p *Pool) Close() {
+	close(p.closed)
+	for {
+		select {
+		case l := <-p.idle:
+			l.stop()
+		default:
+			os.RemoveAll(p.dir)
+			return
+		}
+	}
+}
+
+// PoolStats is reported under /metrics.
+type PoolStats struct {
+	Size          int   `json:"size"`
+	Idle          int   `json:"idle"`
+	Conversions   int64 `json:"conversions"`
+	Failures      int64 `json:"failures"`
+	Recycled      int64 `json:"recycled"`
+	StartFailures int64 `json:"startFailures"`
+}
+
+func (p *Pool) Snapshot() PoolStats {
+	return PoolStats{
+		Size:          p.cfg.Size,
+		Idle:          len(p.idle),
+		Conversions:   p.conversions.Load(),
+		Failures:      p.failures.Load(),
+		Recycled:      p.recycled.Load(),
+		StartFailures: p.startFailures.Load(),
+	}
+}
@@ -0,0 +1,59 @@
+package libreoffice
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeTools writes a stand-in soffice that just stays up and a stand-in
+// UNO client that answers pings and copies files.
+func fakeTools(t *testing.T) (soffice, python string) {
+	dir := t.TempDir()
+	soffice = filepath.Join(dir, "soffice")
+	python = filepath.Join(dir, "python")
+	os.WriteFile(soffice, []byte("#!/bin/sh\nexec sleep 60\n"), 0o755)
+	// argv: script pipe ping | script pipe convert in out filter
+	os.WriteFile(python, []byte("#!/bin/sh\n[ \"$3\" = ping ] && exit 0\ncp \"$4\" \"$5\"\n"), 0o755)
+	return soffice, python
+}
+
+func TestPoolConvertsAndRecycles(t *testing.T) {
+	soffice, python := fakeTools(t)
+	p, err := NewPool(PoolConfig{Binary: soffice, Python: python, Size: 1, MaxConversions: 2, StartTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.doc")
+	os.WriteFile(in, []byte("hello"), 0o600)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		out := filepath.Join(dir, "out.docx")
+		if err := p.Convert(ctx, in, out, "docx:MS Word 2007 XML"); err != nil {
+			t.Fatalf("conversion %d: %v", i, err)
+		}
+		if b, _ := os.ReadFile(out); string(b) != "hello" {
+			t.Fatalf("conversion %d wrote %q", i, b)
+		}
+	}
+	if s := p.Snapshot(); s.Conversions != 3 || s.Recycled != 1 || s.Failures != 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	if err := p.Convert(ctx, in, filepath.Join(dir, "x"), "docx"); err == nil {
+		t.Fatal("format without a filter name should fail")
+	}
+}
+
+func TestPoolRequiresTools(t *testing.T) {
+	if _, err := NewPool(PoolConfig{Binary: "/nonexistent/soffice", Python: "sh", Size: 1}); err == nil {
+		t.Fatal("expected error for missing soffice")
+	}
+}
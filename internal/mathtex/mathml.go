@@ -0,0 +1,178 @@
+package mathtex
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// MathML reads the <math> element opened by start and returns it as LaTeX
+// without math delimiters.
+func MathML(dec *xml.Decoder, start xml.StartElement) string {
+	return tidy(mathml(readTree(dec, start)))
+}
+
+// MathMLDocument converts a standalone MathML document, such as the
+// content.xml of an ODF formula object. It returns "" when b holds no
+// <math> element.
+func MathMLDocument(b []byte) string {
+	dec := xml.NewDecoder(strings.NewReader(string(b)))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "math" {
+			return MathML(dec, se)
+		}
+	}
+}
+
+// mathmlRow renders the element children of n in order.
+func mathmlRow(n *node) string {
+	var sb strings.Builder
+	for _, c := range n.elements() {
+		sb.WriteString(mathml(c))
+	}
+	return sb.String()
+}
+
+// arg returns the i-th element child of n, rendered.
+func arg(n *node, i int) string {
+	els := n.elements()
+	if i >= len(els) {
+		return ""
+	}
+	return mathml(els[i])
+}
+
+func mathml(n *node) string {
+	switch n.name {
+	case "mi":
+		text := strings.TrimSpace(n.textContent())
+		if n.attrs["mathvariant"] == "normal" || len([]rune(text)) > 1 {
+			return word(text)
+		}
+		return escape(text)
+	case "mn":
+		return escape(strings.TrimSpace(n.textContent()))
+	case "mo":
+		op := strings.TrimSpace(n.textContent())
+		if functions[op] {
+			return word(op)
+		}
+		return " " + escape(op) + " "
+	case "mtext", "ms":
+		text := n.textContent()
+		if strings.TrimSpace(text) == "" {
+			return `\ `
+		}
+		return `\text{` + text + `}`
+	case "mspace":
+		return `\ `
+
+	case "mfrac":
+		if t := n.attrs["linethickness"]; t == "0" || t == "0pt" || t == "0px" {
+			return `{` + arg(n, 0) + ` \atop ` + arg(n, 1) + `}`
+		}
+		return `\frac` + group(arg(n, 0)) + group(arg(n, 1))
+	case "msqrt":
+		return `\sqrt` + group(mathmlRow(n))
+	case "mroot":
+		return `\sqrt[` + strings.TrimSpace(arg(n, 1)) + `]` + group(arg(n, 0))
+
+	case "msup":
+		return base(arg(n, 0)) + "^" + group(arg(n, 1))
+	case "msub":
+		return base(arg(n, 0)) + "_" + group(arg(n, 1))
+	case "msubsup":
+		return base(arg(n, 0)) + "_" + group(arg(n, 1)) + "^" + group(arg(n, 2))
+
+	case "munder":
+		b, under := strings.TrimSpace(arg(n, 0)), arg(n, 1)
+		if largeOp(b) {
+			return b + "_" + group(under)
+		}
+		if strings.TrimSpace(under) == "_" || strings.TrimSpace(under) == "̲" {
+			return `\underline` + group(b)
+		}
+		return `\underset` + group(under) + group(b)
+	case "mover":
+		b, over := strings.TrimSpace(arg(n, 0)), strings.TrimSpace(nodeText(n, 1))
+		if largeOp(b) {
+			return b + "^" + group(arg(n, 1))
+		}
+		if cmd, ok := accents[over]; ok {
+			return cmd + group(b)
+		}
+		return `\overset` + group(arg(n, 1)) + group(b)
+	case "munderover":
+		return strings.TrimSpace(arg(n, 0)) + "_" + group(arg(n, 1)) + "^" + group(arg(n, 2))
+
+	case "mfenced":
+		open, ok := n.attrs["open"]
+		if !ok {
+			open = "("
+		}
+		closing, ok := n.attrs["close"]
+		if !ok {
+			closing = ")"
+		}
+		sep, ok := n.attrs["separators"]
+		if !ok {
+			sep = ","
+		}
+		var parts []string
+		for _, c := range n.elements() {
+			parts = append(parts, strings.TrimSpace(mathml(c)))
+		}
+		return `\left` + delim(open) + " " + strings.Join(parts, strings.TrimSpace(escape(sep))+" ") + ` \right` + delim(closing)
+
+	case "mtable":
+		var rows []string
+		for _, tr := range n.elements() {
+			var cells []string
+			for _, td := range tr.elements() {
+				cells = append(cells, strings.TrimSpace(mathmlRow(td)))
+			}
+			rows = append(rows, strings.Join(cells, " & "))
+		}
+		return `\begin{matrix} ` + strings.Join(rows, ` \\ `) + ` \end{matrix}`
+
+	case "menclose":
+		if strings.Contains(n.attrs["notation"], "box") {
+			return `\boxed` + group(mathmlRow(n))
+		}
+		return mathmlRow(n)
+
+	case "semantics":
+		// The first child is the presentation markup; annotations
+		// (StarMath source, content MathML) follow it.
+		if els := n.elements(); len(els) > 0 {
+			return mathml(els[0])
+		}
+		return ""
+	case "annotation", "annotation-xml", "mphantom":
+		return ""
+	}
+	// math, mrow, mstyle, mpadded, merror and unknown containers.
+	return mathmlRow(n)
+}
+
+// nodeText returns the raw text of the i-th element child of n.
+func nodeText(n *node, i int) string {
+	els := n.elements()
+	if i >= len(els) {
+		return ""
+	}
+	return els[i].textContent()
+}
+
+// largeOp reports whether s is a big operator, whose limits are written as
+// scripts rather than stacked with \underset.
+func largeOp(s string) bool {
+	switch s {
+	case `\sum`, `\prod`, `\coprod`, `\int`, `\iint`, `\iiint`, `\oint`, `\bigcup`, `\bigcap`:
+		return true
+	}
+	return strings.HasPrefix(s, `\`) && functions[strings.TrimPrefix(s, `\`)]
+}
@@ -0,0 +1,203 @@
+// Package mathtex converts document equations to LaTeX: Office Math (OMML)
+// from DOCX and PPTX, and MathML from ODF formula objects. Both
+// converters read one equation element from an xml.Decoder into a small
+// tree and render it recursively. Constructs without a LaTeX counterpart
+// fall back to their content, so an equation never disappears.
+package mathtex
+
+import (
+	"encoding/xml"
+	"strings"
+	"unicode/utf8"
+)
+
+// node is an element of an equation; text nodes have an empty name.
+type node struct {
+	name     string
+	attrs    map[string]string // by local name
+	children []*node
+	text     string
+}
+
+// readTree reads the element opened by start, up to and including its end.
+func readTree(dec *xml.Decoder, start xml.StartElement) *node {
+	n := &node{name: start.Name.Local, attrs: map[string]string{}}
+	for _, a := range start.Attr {
+		n.attrs[a.Name.Local] = a.Value
+	}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return n
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n.children = append(n.children, readTree(dec, t))
+		case xml.CharData:
+			n.children = append(n.children, &node{text: string(t)})
+		case xml.EndElement:
+			return n
+		}
+	}
+}
+
+// child returns the first child element named name, or nil.
+func (n *node) child(name string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// elements returns the element children, skipping text.
+func (n *node) elements() []*node {
+	var out []*node
+	for _, c := range n.children {
+		if c.name != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// textContent concatenates all text below n.
+func (n *node) textContent() string {
+	if n == nil {
+		return ""
+	}
+	if n.name == "" {
+		return n.text
+	}
+	var sb strings.Builder
+	for _, c := range n.children {
+		sb.WriteString(c.textContent())
+	}
+	return sb.String()
+}
+
+// symbols maps characters to LaTeX commands.
+var symbols = map[rune]string{
+	'α': `\alpha`, 'β': `\beta`, 'γ': `\gamma`, 'δ': `\delta`, 'ε': `\epsilon`, 'ϵ': `\epsilon`, 'ζ': `\zeta`,
+	'η': `\eta`, 'θ': `\theta`, 'ϑ': `\vartheta`, 'ι': `\iota`, 'κ': `\kappa`, 'λ': `\lambda`, 'μ': `\mu`,
+	'ν': `\nu`, 'ξ': `\xi`, 'π': `\pi`, 'ϖ': `\varpi`, 'ρ': `\rho`, 'ϱ': `\varrho`, 'σ': `\sigma`,
+	'ς': `\varsigma`, 'τ': `\tau`, 'υ': `\upsilon`, 'φ': `\phi`, 'ϕ': `\phi`, 'χ': `\chi`, 'ψ': `\psi`,
+	'ω': `\omega`, 'Γ': `\Gamma`, 'Δ': `\Delta`, 'Θ': `\Theta`, 'Λ': `\Lambda`, 'Ξ': `\Xi`, 'Π': `\Pi`,
+	'Σ': `\Sigma`, 'Υ': `\Upsilon`, 'Φ': `\Phi`, 'Ψ': `\Psi`, 'Ω': `\Omega`,
+	'±': `\pm`, '∓': `\mp`, '×': `\times`, '÷': `\div`, '·': `\cdot`, '⋅': `\cdot`, '∗': `\ast`,
+	'−': `-`, '≤': `\leq`, '≥': `\geq`, '≠': `\neq`, '≈': `\approx`, '≡': `\equiv`, '∼': `\sim`,
+	'≅': `\cong`, '∝': `\propto`, '≪': `\ll`, '≫': `\gg`, '∞': `\infty`, '∂': `\partial`, '∇': `\nabla`,
+	'→': `\to`, '←': `\leftarrow`, '↔': `\leftrightarrow`, '⇒': `\Rightarrow`, '⇐': `\Leftarrow`,
+	'⇔': `\Leftrightarrow`, '↦': `\mapsto`, '∈': `\in`, '∉': `\notin`, '∋': `\ni`, '⊂': `\subset`,
+	'⊃': `\supset`, '⊆': `\subseteq`, '⊇': `\supseteq`, '∪': `\cup`, '∩': `\cap`, '∅': `\emptyset`,
+	'∀': `\forall`, '∃': `\exists`, '¬': `\neg`, '∧': `\wedge`, '∨': `\vee`, '⊕': `\oplus`,
+	'⊗': `\otimes`, '∘': `\circ`, '⊥': `\perp`, '∥': `\parallel`, '∠': `\angle`, '°': `^\circ`,
+	'…': `\ldots`, '⋯': `\cdots`, '⋮': `\vdots`, '⋱': `\ddots`, '′': `'`, '″': `''`, 'ℏ': `\hbar`,
+	'ℓ': `\ell`, 'ℝ': `\mathbb{R}`, 'ℕ': `\mathbb{N}`, 'ℤ': `\mathbb{Z}`, 'ℚ': `\mathbb{Q}`,
+	'ℂ': `\mathbb{C}`, '∑': `\sum`, '∏': `\prod`, '∐': `\coprod`, '∫': `\int`, '∬': `\iint`,
+	'∭': `\iiint`, '∮': `\oint`, '⋃': `\bigcup`, '⋂': `\bigcap`, '√': `\surd`, '⟨': `\langle`,
+	'⟩': `\rangle`, '〈': `\langle`, '〉': `\rangle`, '⌊': `\lfloor`, '⌋': `\rfloor`, '⌈': `\lceil`,
+	'⌉': `\rceil`, '‖': `\|`, '⁡': ``, '⁢': ``, '⁣': `,`, ' ': `~`,
+	'{': `\{`, '}': `\}`, '%': `\%`, '#': `\#`, '&': `\&`, '_': `\_`, '$': `\$`,
+}
+
+// functions are rendered as LaTeX operators (\sin) rather than as letters.
+var functions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true, "tanh": true,
+	"coth": true, "log": true, "ln": true, "lg": true, "exp": true, "lim": true, "liminf": true,
+	"limsup": true, "max": true, "min": true, "sup": true, "inf": true, "det": true, "dim": true,
+	"ker": true, "deg": true, "gcd": true, "arg": true, "Pr": true, "hom": true,
+}
+
+// escape converts equation text to LaTeX, replacing symbols with commands.
+func escape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		cmd, ok := symbols[r]
+		if !ok {
+			sb.WriteRune(r)
+			continue
+		}
+		sb.WriteString(cmd)
+		if strings.HasPrefix(cmd, `\`) && isLetters(cmd[1:]) {
+			// Keep "\alpha x" from reading as "\alphax".
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String()
+}
+
+func isLetters(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// word renders a run of plain (upright) text: a function name becomes its
+// operator, other words are set in \mathrm.
+func word(s string) string {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return ""
+	case functions[s]:
+		return `\` + s + " "
+	case utf8.RuneCountInString(s) == 1 || !isLetters(s):
+		return escape(s)
+	}
+	return `\mathrm{` + s + `}`
+}
+
+// group braces s for use as a script or argument.
+func group(s string) string {
+	return "{" + strings.TrimSpace(s) + "}"
+}
+
+// base braces s when it is more than one symbol, so scripts attach to all
+// of it: x^2 but {x+1}^2.
+func base(s string) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) == 1 || (strings.HasPrefix(s, `\`) && isLetters(strings.TrimSpace(s[1:]))) {
+		return s
+	}
+	return group(s)
+}
+
+// tidy collapses the spaces left between commands.
+func tidy(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// delim renders a delimiter character for \left and \right; "" is the
+// invisible delimiter.
+func delim(ch string) string {
+	switch ch {
+	case "":
+		return "."
+	case "{", "}", "|":
+		if ch == "|" {
+			return "|"
+		}
+		return `\` + ch
+	}
+	return strings.TrimSpace(escape(ch))
+}
+
+// accents maps combining and spacing accent characters to LaTeX commands.
+var accents = map[string]string{
+	"̂": `\hat`, "^": `\hat`, "ˆ": `\hat`, "̃": `\tilde`, "~": `\tilde`, "˜": `\tilde`,
+	"̄": `\bar`, "̅": `\overline`, "¯": `\bar`, "‾": `\overline`, "̇": `\dot`,
+	"˙": `\dot`, "̈": `\ddot`, "¨": `\ddot`, "⃗": `\vec`, "→": `\vec`, "́": `\acute`,
+	"̀": `\grave`, "̆": `\breve`, "̌": `\check`, "ˇ": `\check`,
+}
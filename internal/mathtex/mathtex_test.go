@@ -0,0 +1,91 @@
+package mathtex
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+const mNS = `xmlns:m="http://schemas.openxmlformats.org/officeDocument/2006/math"`
+
+// decode returns a decoder positioned after the root element's start tag.
+func decode(t *testing.T, doc string) (*xml.Decoder, xml.StartElement) {
+	t.Helper()
+	dec := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return dec, se
+		}
+	}
+}
+
+func TestOMML(t *testing.T) {
+	r := func(s string) string { return `<m:r><m:t>` + s + `</m:t></m:r>` }
+	for name, tc := range map[string]struct{ xml, want string }{
+		"fraction": {
+			`<m:f><m:num>` + r("a+b") + `</m:num><m:den>` + r("2") + `</m:den></m:f>`,
+			`\frac{a+b}{2}`,
+		},
+		"superscript": {
+			r("E=m") + `<m:sSup><m:e>` + r("c") + `</m:e><m:sup>` + r("2") + `</m:sup></m:sSup>`,
+			`E=mc^{2}`,
+		},
+		"sum with limits": {
+			`<m:nary><m:naryPr><m:chr m:val="∑"/></m:naryPr><m:sub>` + r("i=1") + `</m:sub><m:sup>` + r("n") + `</m:sup><m:e>` + r("i") + `</m:e></m:nary>`,
+			`\sum_{i=1}^{n} i`,
+		},
+		"integral by default": {
+			`<m:nary><m:sub>` + r("0") + `</m:sub><m:sup>` + r("∞") + `</m:sup><m:e>` + r("f(x)dx") + `</m:e></m:nary>`,
+			`\int_{0}^{\infty} f(x)dx`,
+		},
+		"radical": {
+			`<m:rad><m:radPr><m:degHide m:val="1"/></m:radPr><m:deg/><m:e>` + r("x") + `</m:e></m:rad>` +
+				`<m:rad><m:deg>` + r("3") + `</m:deg><m:e>` + r("y") + `</m:e></m:rad>`,
+			`\sqrt{x}\sqrt[3]{y}`,
+		},
+		"delimiters and greek": {
+			`<m:d><m:dPr><m:begChr m:val="["/><m:endChr m:val="]"/></m:dPr><m:e>` + r("α,β") + `</m:e></m:d>`,
+			`\left[ \alpha ,\beta \right]`,
+		},
+		"function": {
+			`<m:func><m:fName><m:r><m:rPr><m:sty m:val="p"/></m:rPr><m:t>sin</m:t></m:r></m:fName><m:e>` + r("θ") + `</m:e></m:func>`,
+			`\sin \theta`,
+		},
+		"accent and matrix": {
+			`<m:acc><m:e>` + r("x") + `</m:e></m:acc>` +
+				`<m:m><m:mr><m:e>` + r("1") + `</m:e><m:e>` + r("0") + `</m:e></m:mr><m:mr><m:e>` + r("0") + `</m:e><m:e>` + r("1") + `</m:e></m:mr></m:m>`,
+			`\hat{x}\begin{matrix} 1 & 0 \\ 0 & 1 \end{matrix}`,
+		},
+	} {
+		dec, start := decode(t, `<m:oMath `+mNS+`>`+tc.xml+`</m:oMath>`)
+		got := OMML(dec, start)
+		if len(got) != 1 || got[0] != tc.want {
+			t.Errorf("%s: got %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestOMMLParagraph(t *testing.T) {
+	dec, start := decode(t, `<m:oMathPara `+mNS+`><m:oMath><m:r><m:t>a=1</m:t></m:r></m:oMath><m:oMath/><m:oMath><m:r><m:t>b≤2</m:t></m:r></m:oMath></m:oMathPara>`)
+	got := OMML(dec, start)
+	if strings.Join(got, "|") != `a=1|b\leq 2` {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestMathMLDocument(t *testing.T) {
+	doc := `<?xml version="1.0"?><math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><mrow>
+		<mi>x</mi><mo>=</mo><mfrac><mrow><mo>−</mo><mi>b</mi><mo>±</mo><msqrt><msup><mi>b</mi><mn>2</mn></msup><mo>−</mo><mn>4</mn><mi>a</mi><mi>c</mi></msqrt></mrow><mrow><mn>2</mn><mi>a</mi></mrow></mfrac>
+	</mrow><annotation encoding="StarMath 5.0">x = {-b +- sqrt{b^2 - 4ac}} over {2a}</annotation></semantics></math>`
+	want := `x = \frac{- b \pm \sqrt{b^{2} - 4ac}}{2a}`
+	if got := MathMLDocument([]byte(doc)); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := MathMLDocument([]byte(`<chart/>`)); got != "" {
+		t.Fatalf("non-MathML document: got %q", got)
+	}
+}
@@ -0,0 +1,228 @@
+package mathtex
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// OMML reads the Office Math element opened by start and returns its
+// equations as LaTeX without math delimiters: one for an m:oMath, one per
+// contained m:oMath for a display m:oMathPara. Empty equations are dropped.
+func OMML(dec *xml.Decoder, start xml.StartElement) []string {
+	n := readTree(dec, start)
+	if n.name != "oMathPara" {
+		if s := tidy(omml(n)); s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+	var eqs []string
+	for _, c := range n.elements() {
+		if c.name == "oMath" {
+			if s := tidy(omml(c)); s != "" {
+				eqs = append(eqs, s)
+			}
+		}
+	}
+	return eqs
+}
+
+// omml renders the children of n in order.
+func omml(n *node) string {
+	if n == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, c := range n.elements() {
+		sb.WriteString(ommlElement(c))
+	}
+	return sb.String()
+}
+
+// prop returns the val attribute of property element name under n's
+// properties (e.g. m:naryPr/m:chr), and whether it is present.
+func prop(n *node, pr, name string) (string, bool) {
+	p := n.child(pr).child(name)
+	if p == nil {
+		return "", false
+	}
+	v, ok := p.attrs["val"]
+	if !ok {
+		return "", true
+	}
+	return v, true
+}
+
+// on reads an OMML on/off property, which is on when present without val.
+func on(n *node, pr, name string) bool {
+	v, ok := prop(n, pr, name)
+	return ok && v != "0" && v != "off" && v != "false"
+}
+
+func ommlElement(n *node) string {
+	switch n.name {
+	case "r":
+		text := n.child("t").textContent()
+		if _, ok := prop(n, "rPr", "nor"); ok {
+			return `\text{` + text + `}`
+		}
+		if sty, _ := prop(n, "rPr", "sty"); sty == "p" || sty == "b" {
+			return word(text)
+		}
+		if functions[strings.TrimSpace(text)] {
+			return word(text)
+		}
+		return escape(text)
+
+	case "f":
+		num, den := omml(n.child("num")), omml(n.child("den"))
+		switch typ, _ := prop(n, "fPr", "type"); typ {
+		case "lin":
+			return base(num) + "/" + base(den)
+		case "noBar":
+			return `{` + num + ` \atop ` + den + `}`
+		}
+		return `\frac` + group(num) + group(den)
+
+	case "sSup":
+		return base(omml(n.child("e"))) + "^" + group(omml(n.child("sup")))
+	case "sSub":
+		return base(omml(n.child("e"))) + "_" + group(omml(n.child("sub")))
+	case "sSubSup":
+		return base(omml(n.child("e"))) + "_" + group(omml(n.child("sub"))) + "^" + group(omml(n.child("sup")))
+	case "sPre":
+		return "{}_" + group(omml(n.child("sub"))) + "^" + group(omml(n.child("sup"))) + base(omml(n.child("e")))
+
+	case "rad":
+		if on(n, "radPr", "degHide") || strings.TrimSpace(omml(n.child("deg"))) == "" {
+			return `\sqrt` + group(omml(n.child("e")))
+		}
+		return `\sqrt[` + strings.TrimSpace(omml(n.child("deg"))) + `]` + group(omml(n.child("e")))
+
+	case "nary":
+		chr, ok := prop(n, "naryPr", "chr")
+		if !ok {
+			chr = "∫"
+		}
+		op := strings.TrimSpace(escape(chr))
+		if !on(n, "naryPr", "subHide") {
+			if sub := strings.TrimSpace(omml(n.child("sub"))); sub != "" {
+				op += "_" + group(sub)
+			}
+		}
+		if !on(n, "naryPr", "supHide") {
+			if sup := strings.TrimSpace(omml(n.child("sup"))); sup != "" {
+				op += "^" + group(sup)
+			}
+		}
+		return op + " " + omml(n.child("e"))
+
+	case "d":
+		beg, ok := prop(n, "dPr", "begChr")
+		if !ok {
+			beg = "("
+		}
+		end, ok := prop(n, "dPr", "endChr")
+		if !ok {
+			end = ")"
+		}
+		sep, ok := prop(n, "dPr", "sepChr")
+		if !ok {
+			sep = "|"
+		}
+		var parts []string
+		for _, c := range n.elements() {
+			if c.name == "e" {
+				parts = append(parts, strings.TrimSpace(omml(c)))
+			}
+		}
+		joiner := " " + strings.TrimSpace(escape(sep)) + " "
+		if sep == "|" {
+			joiner = ` \mid `
+		}
+		return `\left` + delim(beg) + " " + strings.Join(parts, joiner) + ` \right` + delim(end)
+
+	case "func":
+		name := strings.TrimSpace(omml(n.child("fName")))
+		if plain := strings.TrimSpace(n.child("fName").textContent()); !strings.HasPrefix(name, `\`) && isLetters(plain) && plain == name {
+			name = `\operatorname{` + plain + `}`
+		}
+		return name + " " + omml(n.child("e"))
+
+	case "limLow":
+		e, lim := strings.TrimSpace(omml(n.child("e"))), omml(n.child("lim"))
+		if strings.HasPrefix(e, `\`) && functions[strings.TrimPrefix(e, `\`)] {
+			return e + "_" + group(lim)
+		}
+		return `\underset` + group(lim) + group(e)
+	case "limUpp":
+		return `\overset` + group(omml(n.child("lim"))) + group(omml(n.child("e")))
+
+	case "acc":
+		chr, ok := prop(n, "accPr", "chr")
+		if !ok {
+			chr = "̂"
+		}
+		cmd, ok := accents[chr]
+		if !ok {
+			return `\overset` + group(escape(chr)) + group(omml(n.child("e")))
+		}
+		return cmd + group(omml(n.child("e")))
+
+	case "bar":
+		if pos, _ := prop(n, "barPr", "pos"); pos == "top" {
+			return `\overline` + group(omml(n.child("e")))
+		}
+		return `\underline` + group(omml(n.child("e")))
+
+	case "groupChr":
+		chr, ok := prop(n, "groupChrPr", "chr")
+		if !ok {
+			chr = "⏟"
+		}
+		pos, _ := prop(n, "groupChrPr", "pos")
+		switch {
+		case chr == "⏞" || (chr != "⏟" && pos == "top"):
+			return `\overbrace` + group(omml(n.child("e")))
+		default:
+			return `\underbrace` + group(omml(n.child("e")))
+		}
+
+	case "borderBox":
+		return `\boxed` + group(omml(n.child("e")))
+
+	case "eqArr":
+		var rows []string
+		for _, c := range n.elements() {
+			if c.name == "e" {
+				rows = append(rows, strings.TrimSpace(omml(c)))
+			}
+		}
+		return `\begin{aligned} ` + strings.Join(rows, ` \\ `) + ` \end{aligned}`
+
+	case "m":
+		var rows []string
+		for _, mr := range n.elements() {
+			if mr.name != "mr" {
+				continue
+			}
+			var cells []string
+			for _, c := range mr.elements() {
+				if c.name == "e" {
+					cells = append(cells, strings.TrimSpace(omml(c)))
+				}
+			}
+			rows = append(rows, strings.Join(cells, " & "))
+		}
+		return `\begin{matrix} ` + strings.Join(rows, ` \\ `) + ` \end{matrix}`
+
+	case "box", "phant", "e", "oMath", "num", "den", "sub", "sup", "deg", "lim", "fName":
+		return omml(n)
+	}
+	// Properties (…Pr, ctrlPr) and unknown elements contribute nothing of
+	// their own; unknown containers still render their children.
+	if strings.HasSuffix(n.name, "Pr") {
+		return ""
+	}
+	return omml(n)
+}
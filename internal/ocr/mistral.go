@@ -10,6 +10,10 @@ import (
 	"os"
 	"sort"
 	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/gateway"
+	"github.com/toricodesthings/file-processing-service/internal/resilience"
+	"github.com/toricodesthings/file-processing-service/internal/usage"
 )
 
 type OCRPage struct {
@@ -42,6 +46,12 @@ const (
 	requestTimeout = 120 * time.Second
 )
 
+var retryPolicy = resilience.Policy{
+	MaxAttempts: maxRetries + 1,
+	BaseDelay:   retryDelay,
+	MaxDelay:    20 * time.Second,
+}
+
 func RunMistralOCR(ctx context.Context, presignedURL string, model string, pages0 []int, extractHeader, extractFooter bool) (OCRResponse, error) {
 	key := os.Getenv("MISTRAL_API_KEY")
 	if key == "" {
@@ -92,34 +102,28 @@ func RunMistralOCR(ctx context.Context, presignedURL string, model string, pages
 		return OCRResponse{}, fmt.Errorf("marshal: %w", err)
 	}
 
-	// Retry logic
-	var lastErr error
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			select {
-			case <-ctx.Done():
-				return OCRResponse{}, ctx.Err()
-			case <-time.After(retryDelay * time.Duration(attempt)):
-			}
-		}
-
-		result, err := executeOCRRequest(ctx, key, bodyBytes)
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-
-		// Don't retry client errors (4xx)
-		if isClientError(err) {
-			break
-		}
+	var result OCRResponse
+	err = resilience.Do(ctx, gateway.Mistral, retryPolicy, func(ctx context.Context) error {
+		var err error
+		result, err = executeOCRRequest(ctx, key, bodyBytes)
+		return err
+	})
+	if err == nil {
+		recordOCRUsage(ctx, result, model, len(pages0))
+		return result, nil
 	}
 
-	return OCRResponse{}, fmt.Errorf("OCR failed after %d attempts: %w", maxRetries+1, lastErr)
+	return OCRResponse{}, fmt.Errorf("OCR failed after %d attempts: %w", maxRetries+1, err)
 }
 
 func executeOCRRequest(ctx context.Context, apiKey string, bodyBytes []byte) (OCRResponse, error) {
+	// Queue for a provider slot before starting the per-request timeout.
+	release, err := gateway.Acquire(ctx, gateway.Mistral)
+	if err != nil {
+		return OCRResponse{}, err
+	}
+	defer release()
+
 	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
 	defer cancel()
 
@@ -177,6 +181,7 @@ func executeOCRRequest(ctx context.Context, apiKey string, bodyBytes []byte) (OC
 
 func parseErrorResponse(resp *http.Response) error {
 	bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	retryAfter := resilience.ParseRetryAfter(resp.Header.Get("Retry-After"))
 
 	var errResp mistralErrorResponse
 	if json.Unmarshal(bodyBytes, &errResp) == nil && errResp.Error.Message != "" {
@@ -184,6 +189,7 @@ func parseErrorResponse(resp *http.Response) error {
 			StatusCode: resp.StatusCode,
 			Message:    errResp.Error.Message,
 			Type:       errResp.Error.Type,
+			RetryAfter: retryAfter,
 		}
 	}
 
@@ -191,6 +197,7 @@ func parseErrorResponse(resp *http.Response) error {
 		StatusCode: resp.StatusCode,
 		Message:    string(bodyBytes),
 		Type:       "unknown",
+		RetryAfter: retryAfter,
 	}
 }
 
@@ -198,17 +205,28 @@ type OCRError struct {
 	StatusCode int
 	Message    string
 	Type       string
+	RetryAfter time.Duration // from the Retry-After header, if any
 }
 
 func (e *OCRError) Error() string {
 	return fmt.Sprintf("mistral OCR %d (%s): %s", e.StatusCode, e.Type, e.Message)
 }
 
-func isClientError(err error) bool {
-	if ocrErr, ok := err.(*OCRError); ok {
-		return ocrErr.StatusCode >= 400 && ocrErr.StatusCode < 500
+// HTTPStatus and RetryAfterHint let resilience.Do classify the error.
+func (e *OCRError) HTTPStatus() int               { return e.StatusCode }
+func (e *OCRError) RetryAfterHint() time.Duration { return e.RetryAfter }
+
+// recordOCRUsage reports billed pages, falling back to the pages returned
+// (or requested) when Mistral omits usage_info.
+func recordOCRUsage(ctx context.Context, resp OCRResponse, model string, requested int) {
+	pages := resp.UsageInfo.PagesProcessed
+	if pages <= 0 {
+		pages = max(len(resp.Pages), requested)
 	}
-	return false
+	if resp.Model != "" {
+		model = resp.Model
+	}
+	usage.Record(ctx, usage.Entry{Capability: usage.OCR, Provider: ProviderMistral, Model: model, Pages: pages})
 }
 
 func uniqueInts(xs []int) []int {
@@ -258,28 +276,16 @@ func RunMistralImageOCR(ctx context.Context, imageURL string, model string) (OCR
 		return OCRResponse{}, fmt.Errorf("marshal: %w", err)
 	}
 
-	// Retry logic (same as PDF variant)
-	var lastErr error
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			select {
-			case <-ctx.Done():
-				return OCRResponse{}, ctx.Err()
-			case <-time.After(retryDelay * time.Duration(attempt)):
-			}
-		}
-
-		result, err := executeOCRRequest(ctx, key, bodyBytes)
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-
-		if isClientError(err) {
-			break
-		}
+	var result OCRResponse
+	err = resilience.Do(ctx, gateway.Mistral, retryPolicy, func(ctx context.Context) error {
+		var err error
+		result, err = executeOCRRequest(ctx, key, bodyBytes)
+		return err
+	})
+	if err == nil {
+		recordOCRUsage(ctx, result, model, 1)
+		return result, nil
 	}
 
-	return OCRResponse{}, fmt.Errorf("image OCR failed after %d attempts: %w", maxRetries+1, lastErr)
+	return OCRResponse{}, fmt.Errorf("image OCR failed after %d attempts: %w", maxRetries+1, err)
 }
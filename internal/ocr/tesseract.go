@@ -0,0 +1,84 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
+	"github.com/toricodesthings/file-processing-service/internal/usage"
+)
+
+// Provider names usable in the OCR failover chain.
+const (
+	ProviderMistral   = "mistral"
+	ProviderTesseract = "tesseract"
+)
+
+// Tesseract configures the local tesseract CLI used as an OCR fallback.
+type Tesseract struct {
+	Binary  string
+	Lang    string // tesseract -l value, e.g. "eng" or "eng+deu"
+	Timeout time.Duration
+}
+
+var (
+	tessMu  sync.RWMutex
+	tessCfg = Tesseract{Binary: "tesseract", Lang: "eng", Timeout: 60 * time.Second}
+)
+
+// ConfigureTesseract sets the process-wide tesseract settings. Zero fields
+// keep their defaults.
+func ConfigureTesseract(t Tesseract) {
+	tessMu.Lock()
+	defer tessMu.Unlock()
+	if strings.TrimSpace(t.Binary) != "" {
+		tessCfg.Binary = t.Binary
+	}
+	if strings.TrimSpace(t.Lang) != "" {
+		tessCfg.Lang = t.Lang
+	}
+	if t.Timeout > 0 {
+		tessCfg.Timeout = t.Timeout
+	}
+}
+
+// RunTesseract OCRs a local image file and returns its plain text.
+func RunTesseract(ctx context.Context, imagePath string) (string, error) {
+	tessMu.RLock()
+	t := tessCfg
+	tessMu.RUnlock()
+
+	if imagePath == "" {
+		return "", errors.New("image path required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := sandbox.Command(ctx, t.Binary, imagePath, "stdout", "-l", t.Lang)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("tesseract timed out after %s", t.Timeout)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 300 {
+			msg = msg[:300] + "..."
+		}
+		return "", fmt.Errorf("tesseract: %w: %s", err, msg)
+	}
+
+	usage.Record(ctx, usage.Entry{Capability: usage.OCR, Provider: ProviderTesseract, Pages: 1})
+	text := strings.TrimSpace(stdout.String())
+	if text == "" {
+		return "", errors.New("tesseract produced empty text")
+	}
+	return text, nil
+}
@@ -0,0 +1,94 @@
+package output
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+// ChunkRecord is one line of the .chunks.jsonl output.
+type ChunkRecord struct {
+	Index      int    `json:"index"`
+	PageNumber int    `json:"pageNumber,omitempty"`
+	Text       string `json:"text"`
+	CharCount  int    `json:"charCount"`
+}
+
+// Chunk splits the result's text into pieces of at most maxChars
+// characters. Pages are chunked separately so every chunk can cite its page;
+// within a page (or the whole text, when there are no pages) paragraphs are
+// packed together and only split mid-paragraph when one is too long.
+func Chunk(res extract.Result, maxChars int) []ChunkRecord {
+	var out []ChunkRecord
+	add := func(page int, text string) {
+		for _, c := range pack(text, maxChars) {
+			out = append(out, ChunkRecord{Index: len(out), PageNumber: page, Text: c, CharCount: utf8.RuneCountInString(c)})
+		}
+	}
+	if len(res.Pages) > 0 {
+		for _, p := range res.Pages {
+			add(p.PageNumber, p.Text)
+		}
+	} else {
+		add(0, res.Text)
+	}
+	return out
+}
+
+func pack(text string, maxChars int) []string {
+	var chunks []string
+	var cur strings.Builder
+	curLen := 0
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		cur.Reset()
+		curLen = 0
+	}
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		n := utf8.RuneCountInString(para)
+		if curLen > 0 && curLen+2+n > maxChars {
+			flush()
+		}
+		if n > maxChars {
+			chunks = append(chunks, split(para, maxChars)...)
+			continue
+		}
+		if curLen > 0 {
+			cur.WriteString("\n\n")
+			curLen += 2
+		}
+		cur.WriteString(para)
+		curLen += n
+	}
+	flush()
+	return chunks
+}
+
+// split cuts an over-long paragraph at the last whitespace before each
+// maxChars boundary, or hard at the boundary when there is none.
+func split(s string, maxChars int) []string {
+	var out []string
+	runes := []rune(s)
+	for len(runes) > maxChars {
+		cut := maxChars
+		for i := maxChars; i > maxChars/2; i-- {
+			if runes[i] == ' ' || runes[i] == '\n' {
+				cut = i
+				break
+			}
+		}
+		out = append(out, strings.TrimSpace(string(runes[:cut])))
+		runes = runes[cut:]
+	}
+	if s := strings.TrimSpace(string(runes)); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,302 @@
+// Package output writes extraction results to object storage instead of
+// returning them inline: the text as Markdown, the full result as JSON and,
+// optionally, the text split into chunks as JSON Lines. Destinations are
+// either an s3:// prefix written with the service's credentials or
+// caller-supplied presigned PUT URLs.
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/s3"
+)
+
+// Formats a result can be written as.
+const (
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+	FormatChunks   = "jsonl"
+)
+
+var defaultFormats = []string{FormatMarkdown, FormatJSON}
+
+// Spec is the parsed "output" request option.
+type Spec struct {
+	// URL is an s3://bucket/prefix destination. A trailing "/" names a
+	// directory and the objects are named after the input file.
+	URL string
+	// PutURLs maps a format to a presigned PUT URL for it.
+	PutURLs    map[string]string
+	Formats    []string
+	ChunkChars int
+}
+
+// ParseSpec reads options["output"]. It returns nil, nil when the option is
+// absent.
+func ParseSpec(options map[string]any) (*Spec, error) {
+	raw, ok := options["output"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, errors.New("output must be an object")
+	}
+
+	s := &Spec{ChunkChars: 4000}
+	s.URL, _ = m["url"].(string)
+	s.URL = strings.TrimSpace(s.URL)
+	if v, ok := m["chunkChars"].(float64); ok {
+		s.ChunkChars = int(v)
+	}
+	if s.ChunkChars < 200 || s.ChunkChars > 100000 {
+		return nil, errors.New("output.chunkChars must be between 200 and 100000")
+	}
+
+	if puts, ok := m["putUrls"].(map[string]any); ok {
+		s.PutURLs = map[string]string{}
+		for f, v := range puts {
+			u, _ := v.(string)
+			if !validFormat(f) || strings.TrimSpace(u) == "" {
+				return nil, fmt.Errorf("output.putUrls: invalid entry %q", f)
+			}
+			s.PutURLs[f] = strings.TrimSpace(u)
+			s.Formats = append(s.Formats, f)
+		}
+		slices.Sort(s.Formats)
+	}
+
+	switch {
+	case s.URL != "" && s.PutURLs != nil:
+		return nil, errors.New("output: set url or putUrls, not both")
+	case s.URL == "" && len(s.PutURLs) == 0:
+		return nil, errors.New("output: url or putUrls required")
+	case s.URL != "":
+		if !strings.HasPrefix(s.URL, "s3://") {
+			return nil, errors.New("output.url must be s3://bucket/prefix")
+		}
+		s.Formats = defaultFormats
+		if list, ok := m["formats"].([]any); ok && len(list) > 0 {
+			s.Formats = nil
+			for _, v := range list {
+				f, _ := v.(string)
+				if !validFormat(f) {
+					return nil, fmt.Errorf("output.formats: unknown format %q", f)
+				}
+				if !slices.Contains(s.Formats, f) {
+					s.Formats = append(s.Formats, f)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+func validFormat(f string) bool {
+	return f == FormatMarkdown || f == FormatJSON || f == FormatChunks
+}
+
+// Writer uploads results. The zero value handles presigned PUT URLs only.
+type Writer struct {
+	S3 *s3.Client // nil disables s3:// destinations
+	// Buckets lists the buckets s3:// destinations may name. Writes are
+	// refused when it is empty, so read access never implies write access.
+	Buckets []string
+	Timeout time.Duration // per object upload
+}
+
+// Check validates spec's destinations against the writer's configuration
+// and the URL policy, so a refused destination fails before any paid
+// extraction work. Write checks again at upload time.
+func (w *Writer) Check(ctx context.Context, spec *Spec) error {
+	if spec.URL != "" {
+		_, _, err := w.s3Dest(spec.URL)
+		return err
+	}
+	for _, f := range spec.Formats {
+		if err := extract.CheckURL(ctx, spec.PutURLs[f]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write uploads res in each requested format and returns where each object
+// went, in the order of spec.Formats. fileName names the objects when the
+// destination is a directory.
+func (w *Writer) Write(ctx context.Context, spec *Spec, res extract.Result, fileName string) ([]extract.OutputObject, error) {
+	var dest func(format string) (string, func(context.Context, []byte, string) (string, error), error)
+	if spec.URL != "" {
+		bucket, prefix, err := w.s3Dest(spec.URL)
+		if err != nil {
+			return nil, err
+		}
+		base := baseName(prefix, fileName)
+		dest = func(format string) (string, func(context.Context, []byte, string) (string, error), error) {
+			key := base + "." + format
+			if format == FormatChunks {
+				key = base + ".chunks.jsonl"
+			}
+			return "s3://" + bucket + "/" + key, func(ctx context.Context, b []byte, ct string) (string, error) {
+				info, err := w.S3.Put(ctx, bucket, key, bytes.NewReader(b), int64(len(b)), ct)
+				return info.ETag, err
+			}, nil
+		}
+	} else {
+		dest = func(format string) (string, func(context.Context, []byte, string) (string, error), error) {
+			raw := spec.PutURLs[format]
+			if err := extract.CheckURL(ctx, raw); err != nil {
+				return "", nil, err
+			}
+			return redact(raw), func(ctx context.Context, b []byte, ct string) (string, error) {
+				return w.put(ctx, raw, b, ct)
+			}, nil
+		}
+	}
+
+	var objects []extract.OutputObject
+	for _, format := range spec.Formats {
+		body, ct, err := Encode(format, res, spec.ChunkChars)
+		if err != nil {
+			return nil, err
+		}
+		location, upload, err := dest(format)
+		if err != nil {
+			return nil, err
+		}
+		uctx, cancel := context.WithTimeout(ctx, w.timeout())
+		etag, err := upload(uctx, body, ct)
+		cancel()
+		if err != nil {
+			return nil, extract.WithCode(extract.CodeOutputFailed, fmt.Errorf("write %s output: %w", format, err))
+		}
+		sum := sha256.Sum256(body)
+		objects = append(objects, extract.OutputObject{
+			Format:      format,
+			Location:    location,
+			Size:        int64(len(body)),
+			SHA256:      hex.EncodeToString(sum[:]),
+			ETag:        etag,
+			ContentType: ct,
+		})
+	}
+	return objects, nil
+}
+
+func (w *Writer) timeout() time.Duration {
+	if w.Timeout > 0 {
+		return w.Timeout
+	}
+	return 2 * time.Minute
+}
+
+func (w *Writer) s3Dest(raw string) (bucket, prefix string, err error) {
+	if w.S3 == nil {
+		return "", "", extract.WithCode(extract.CodeURLNotAllowed, errors.New("s3 output is not configured"))
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", "", errors.New("output.url must be s3://bucket/prefix")
+	}
+	if !slices.Contains(w.Buckets, u.Host) {
+		return "", "", extract.WithCode(extract.CodeURLNotAllowed, fmt.Errorf("%w: output bucket %q", extract.ErrURLNotAllowed, u.Host))
+	}
+	prefix = strings.TrimPrefix(u.Path, "/")
+	if slices.Contains(strings.Split(prefix, "/"), "..") {
+		return "", "", errors.New("output.url must not contain ..")
+	}
+	return u.Host, prefix, nil
+}
+
+// baseName is the object key without its format suffix.
+func baseName(prefix, fileName string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	name := path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if name == "" || name == "." || name == "/" {
+		name = "result"
+	}
+	return prefix + name
+}
+
+// put uploads to a presigned URL through SafeClient, so the download URL
+// policy applies to output destinations too.
+func (w *Writer) put(ctx context.Context, raw string, body []byte, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, raw, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "fileproc/2.0")
+
+	resp, err := extract.SafeClient(w.timeout()).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// redact drops the query string, which for presigned URLs is the credential.
+func redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// Encode renders res in format and returns the bytes and content type.
+func Encode(format string, res extract.Result, chunkChars int) ([]byte, string, error) {
+	switch format {
+	case FormatMarkdown:
+		return []byte(res.Text), "text/markdown; charset=utf-8", nil
+	case FormatJSON:
+		b, err := json.Marshal(res)
+		return b, "application/json", err
+	case FormatChunks:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, c := range Chunk(res, chunkChars) {
+			if err := enc.Encode(c); err != nil {
+				return nil, "", err
+			}
+		}
+		return buf.Bytes(), "application/x-ndjson", nil
+	}
+	return nil, "", fmt.Errorf("unknown output format %q", format)
+}
+
+// Strip removes the extracted content from res once it has been written
+// out, keeping counts, metadata and usage.
+func Strip(res *extract.Result) {
+	res.Text = ""
+	res.Pages = nil
+	res.Outline = nil
+	res.FormFields = nil
+	res.Annotations = nil
+	res.Attachments = nil
+}
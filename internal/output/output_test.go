@@ -0,0 +1,114 @@
+package output
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+func TestParseSpec(t *testing.T) {
+	if s, err := ParseSpec(map[string]any{}); s != nil || err != nil {
+		t.Fatalf("absent option: got %+v, %v", s, err)
+	}
+	s, err := ParseSpec(map[string]any{"output": map[string]any{"url": "s3://out/docs/"}})
+	if err != nil || strings.Join(s.Formats, ",") != "md,json" {
+		t.Fatalf("default formats: got %+v, %v", s, err)
+	}
+	for _, bad := range []map[string]any{
+		{"url": "https://example.com/x"},
+		{"url": "s3://out/x", "formats": []any{"pdf"}},
+		{"url": "s3://out/x", "putUrls": map[string]any{"md": "https://x"}},
+		{"putUrls": map[string]any{"txt": "https://x"}},
+		{"url": "s3://out/x", "chunkChars": float64(10)},
+	} {
+		if _, err := ParseSpec(map[string]any{"output": bad}); err == nil {
+			t.Errorf("%v: expected error", bad)
+		}
+	}
+}
+
+func TestChunkPacksParagraphsPerPage(t *testing.T) {
+	para := strings.Repeat("word ", 60) // 300 chars
+	res := extract.Result{Pages: []extract.PageResult{
+		{PageNumber: 1, Text: para + "\n\n" + para + "\n\n" + para},
+		{PageNumber: 2, Text: strings.Repeat("x", 450)},
+	}}
+	chunks := Chunk(res, 700)
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks: %+v", len(chunks), chunks)
+	}
+	if chunks[0].PageNumber != 1 || chunks[1].PageNumber != 1 || chunks[2].PageNumber != 2 {
+		t.Fatalf("page numbers: %+v", chunks)
+	}
+	for i, c := range chunks {
+		if c.Index != i || c.CharCount > 700 {
+			t.Fatalf("chunk %d: %+v", i, c)
+		}
+	}
+	if got := Chunk(extract.Result{Text: strings.Repeat("y", 1000)}, 400); len(got) != 3 {
+		t.Fatalf("hard split: got %d chunks", len(got))
+	}
+}
+
+func TestWritePresignedPUT(t *testing.T) {
+	extract.ConfigureURLPolicy(extract.URLPolicy{AllowPrivate: true, MaxRedirects: 3})
+	defer extract.ConfigureURLPolicy(extract.URLPolicy{MaxRedirects: 3})
+
+	var mu sync.Mutex
+	got := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got[r.URL.Path] = string(b)
+		mu.Unlock()
+		w.Header().Set("ETag", `"e1"`)
+	}))
+	defer srv.Close()
+
+	spec, err := ParseSpec(map[string]any{"output": map[string]any{"putUrls": map[string]any{
+		"md":   srv.URL + "/a.md?X-Amz-Signature=secret",
+		"json": srv.URL + "/a.json?X-Amz-Signature=secret",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := extract.Result{Success: true, Text: "# Title\n\nBody"}
+	objects, err := (&Writer{}).Write(context.Background(), spec, res, "a.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || got["/a.md"] != res.Text || !strings.Contains(got["/a.json"], `"success":true`) {
+		t.Fatalf("objects %+v, stored %v", objects, got)
+	}
+	for _, o := range objects {
+		if strings.Contains(o.Location, "secret") || o.ETag != "e1" || len(o.SHA256) != 64 {
+			t.Fatalf("object %+v", o)
+		}
+	}
+}
+
+func TestWriteRefusesUnlistedBucket(t *testing.T) {
+	spec, _ := ParseSpec(map[string]any{"output": map[string]any{"url": "s3://other/x"}})
+	if err := (&Writer{}).Check(context.Background(), spec); extract.ErrorCode(err) != extract.CodeURLNotAllowed {
+		t.Fatalf("Check: want url_not_allowed, got %v", err)
+	}
+	_, err := (&Writer{}).Write(context.Background(), spec, extract.Result{}, "a.pdf")
+	if extract.ErrorCode(err) != extract.CodeURLNotAllowed {
+		t.Fatalf("want url_not_allowed, got %v", err)
+	}
+
+	spec, _ = ParseSpec(map[string]any{"output": map[string]any{"putUrls": map[string]any{"md": "http://127.0.0.1/a.md"}}})
+	if err := (&Writer{}).Check(context.Background(), spec); extract.ErrorCode(err) != extract.CodeURLNotAllowed {
+		t.Fatalf("Check private PUT URL: want url_not_allowed, got %v", err)
+	}
+}
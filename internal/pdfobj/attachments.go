@@ -0,0 +1,84 @@
+package pdfobj
+
+import "strings"
+
+// FileSpec describes one embedded file. Size is the uncompressed size from
+// /Params /Size (or /DL), or -1 when the file does not declare it. Page is
+// set for files attached through a FileAttachment annotation.
+type FileSpec struct {
+	Name        string
+	Description string
+	MIMEType    string
+	Size        int64
+	Page        int
+}
+
+// EmbeddedFiles lists files from the /Names /EmbeddedFiles tree followed by
+// FileAttachment annotations, the same order pdfdetach reports them in.
+func (d *Document) EmbeddedFiles() []FileSpec {
+	var out []FileSpec
+	if names := d.Dict(d.Catalog()["Names"]); names != nil {
+		d.walkNameTree(names["EmbeddedFiles"], func(key string, v Object) {
+			if spec, ok := d.fileSpec(v, key); ok {
+				out = append(out, spec)
+			}
+		})
+	}
+	for _, p := range d.Pages() {
+		for _, a := range d.Array(p.Dict["Annots"]) {
+			node := d.Dict(a)
+			if node == nil || d.Name(node["Subtype"]) != "FileAttachment" {
+				continue
+			}
+			if spec, ok := d.fileSpec(node["FS"], ""); ok {
+				spec.Page = p.Number
+				out = append(out, spec)
+			}
+		}
+	}
+	return out
+}
+
+// Portfolio reports whether the document is a PDF portfolio (has a
+// /Collection dictionary), in which case the cover sheet text is usually
+// boilerplate and the embedded files carry the content.
+func (d *Document) Portfolio() bool {
+	return d.Dict(d.Catalog()["Collection"]) != nil
+}
+
+func (d *Document) fileSpec(o Object, fallbackName string) (FileSpec, bool) {
+	node := d.Dict(o)
+	if node == nil {
+		return FileSpec{}, false
+	}
+	ef := d.Dict(node["EF"])
+	if ef == nil {
+		return FileSpec{}, false
+	}
+
+	spec := FileSpec{Size: -1}
+	for _, k := range []Name{"UF", "F", "Unix", "DOS", "Mac"} {
+		if s := strings.TrimSpace(d.Text(node[k])); s != "" {
+			spec.Name = s
+			break
+		}
+	}
+	if spec.Name == "" {
+		spec.Name = fallbackName
+	}
+	spec.Description = strings.TrimSpace(d.Text(node["Desc"]))
+
+	stream := ef["UF"]
+	if stream == nil {
+		stream = ef["F"]
+	}
+	if s, ok := d.Resolve(stream).(*Stream); ok {
+		spec.MIMEType = string(d.Name(s.Dict["Subtype"]))
+		if n, ok := d.Int(d.Dict(s.Dict["Params"])["Size"]); ok {
+			spec.Size = int64(n)
+		} else if n, ok := d.Int(s.Dict["DL"]); ok {
+			spec.Size = int64(n)
+		}
+	}
+	return spec, true
+}
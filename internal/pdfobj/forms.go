@@ -0,0 +1,332 @@
+package pdfobj
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	maxFormFields  = 5000
+	maxAnnotations = 10000
+)
+
+// ---------- Page geometry ----------
+
+// Rect is a PDF rectangle in default user space: lower-left (X0, Y0) to
+// upper-right (X1, Y1).
+type Rect struct {
+	X0, Y0, X1, Y1 float64
+}
+
+// PageBox returns the visible area of a page: /CropBox if present, otherwise
+// /MediaBox, both inheritable from ancestor page-tree nodes.
+func (d *Document) PageBox(p Page) (Rect, bool) {
+	for _, key := range []Name{"CropBox", "MediaBox"} {
+		node := p.Dict
+		for depth := 0; node != nil && depth <= maxTreeDepth; depth++ {
+			if r, ok := d.rect(node[key]); ok {
+				return r, true
+			}
+			node = d.Dict(node["Parent"])
+		}
+	}
+	return Rect{}, false
+}
+
+// PageRotation returns a page's inheritable /Rotate as 0, 90, 180 or 270
+// degrees clockwise.
+func (d *Document) PageRotation(p Page) int {
+	node := p.Dict
+	for depth := 0; node != nil && depth <= maxTreeDepth; depth++ {
+		if r, ok := d.Int(node["Rotate"]); ok {
+			return ((r/90)%4 + 4) % 4 * 90
+		}
+		node = d.Dict(node["Parent"])
+	}
+	return 0
+}
+
+func (d *Document) rect(o Object) (Rect, bool) {
+	arr := d.Array(o)
+	if len(arr) != 4 {
+		return Rect{}, false
+	}
+	var v [4]float64
+	for i := range v {
+		f, ok := d.Float(arr[i])
+		if !ok {
+			return Rect{}, false
+		}
+		v[i] = f
+	}
+	return Rect{
+		X0: math.Min(v[0], v[2]), Y0: math.Min(v[1], v[3]),
+		X1: math.Max(v[0], v[2]), Y1: math.Max(v[1], v[3]),
+	}, true
+}
+
+// ---------- AcroForm fields ----------
+
+// FormField is one terminal AcroForm field. Page is the page of its first
+// widget, or 0 if the field has no placed widget.
+type FormField struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Value   string `json:"value,omitempty"`
+	Checked *bool  `json:"checked,omitempty"`
+	Page    int    `json:"page,omitempty"`
+}
+
+// Field flag bits (PDF 32000-1 tables 226, 228, 230).
+const (
+	flagRadio      = 1 << 15
+	flagPushbutton = 1 << 16
+	flagCombo      = 1 << 17
+)
+
+// FormFields returns the terminal fields of the interactive form in field
+// tree order, with inherited /FT, /Ff and /V resolved.
+func (d *Document) FormFields() []FormField {
+	form := d.Dict(d.Catalog()["AcroForm"])
+	if form == nil {
+		return nil
+	}
+	pages := d.Pages()
+	idx := pageIndex(pages)
+	widgetPages := d.widgetPages(pages)
+
+	var fields []FormField
+	seen := map[int]bool{}
+	var walk func(o Object, parentName string, inherited Dict, depth int)
+	walk = func(o Object, parentName string, inherited Dict, depth int) {
+		if depth > maxTreeDepth || len(fields) >= maxFormFields {
+			return
+		}
+		if ref, ok := o.(Ref); ok {
+			if seen[ref.Num] {
+				return
+			}
+			seen[ref.Num] = true
+		}
+		node := d.Dict(o)
+		if node == nil {
+			return
+		}
+
+		name := parentName
+		if partial := d.Text(node["T"]); partial != "" {
+			if name != "" {
+				name += "."
+			}
+			name += partial
+		}
+		attrs := Dict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, k := range []Name{"FT", "Ff", "V"} {
+			if v, ok := node[k]; ok {
+				attrs[k] = v
+			}
+		}
+
+		// Kids that carry /T are child fields; kids without it are widgets
+		// of this field.
+		var widgets []Object
+		hasChildFields := false
+		for _, kid := range d.Array(node["Kids"]) {
+			if kd := d.Dict(kid); kd != nil && kd["T"] != nil {
+				hasChildFields = true
+				walk(kid, name, attrs, depth+1)
+			} else {
+				widgets = append(widgets, kid)
+			}
+		}
+		if hasChildFields && len(widgets) == 0 {
+			return
+		}
+		if len(widgets) == 0 {
+			widgets = []Object{o}
+		}
+
+		field := d.describeField(name, attrs)
+		for _, w := range widgets {
+			if ref, ok := w.(Ref); ok && widgetPages[ref.Num] > 0 {
+				field.Page = widgetPages[ref.Num]
+				break
+			}
+			if wd := d.Dict(w); wd != nil {
+				if pr, ok := wd["P"].(Ref); ok && idx[pr.Num] > 0 {
+					field.Page = idx[pr.Num]
+					break
+				}
+			}
+		}
+		fields = append(fields, field)
+	}
+	for _, f := range d.Array(form["Fields"]) {
+		walk(f, "", Dict{}, 0)
+	}
+	return fields
+}
+
+func (d *Document) describeField(name string, attrs Dict) FormField {
+	flags, _ := d.Int(attrs["Ff"])
+	field := FormField{Name: name}
+
+	switch d.Name(attrs["FT"]) {
+	case "Tx":
+		field.Type = "text"
+		field.Value = d.Text(attrs["V"])
+	case "Btn":
+		switch {
+		case flags&flagPushbutton != 0:
+			field.Type = "button"
+		case flags&flagRadio != 0:
+			field.Type = "radio"
+		default:
+			field.Type = "checkbox"
+		}
+		if field.Type != "button" {
+			state := d.Name(attrs["V"])
+			checked := state != "" && state != "Off"
+			field.Checked = &checked
+			if checked && field.Type == "radio" {
+				field.Value = string(state)
+			}
+		}
+	case "Ch":
+		field.Type = "list"
+		if flags&flagCombo != 0 {
+			field.Type = "combo"
+		}
+		if arr := d.Array(attrs["V"]); arr != nil {
+			var vals []string
+			for _, v := range arr {
+				if s := d.Text(v); s != "" {
+					vals = append(vals, s)
+				}
+			}
+			field.Value = strings.Join(vals, ", ")
+		} else {
+			field.Value = d.Text(attrs["V"])
+		}
+	case "Sig":
+		field.Type = "signature"
+		if attrs["V"] != nil {
+			signed := true
+			field.Checked = &signed
+		}
+	default:
+		field.Type = "unknown"
+		field.Value = d.Text(attrs["V"])
+	}
+	return field
+}
+
+// widgetPages maps annotation object numbers to the page whose /Annots
+// array lists them.
+func (d *Document) widgetPages(pages []Page) map[int]int {
+	out := map[int]int{}
+	for _, p := range pages {
+		for _, a := range d.Array(p.Dict["Annots"]) {
+			if ref, ok := a.(Ref); ok {
+				if _, dup := out[ref.Num]; !dup {
+					out[ref.Num] = p.Number
+				}
+			}
+		}
+	}
+	return out
+}
+
+// ---------- Annotations ----------
+
+// Annotation is one markup annotation. QuadPoints (text markup only) are in
+// default user space, eight numbers per quadrilateral.
+type Annotation struct {
+	Page       int       `json:"page"`
+	Subtype    string    `json:"subtype"`
+	Author     string    `json:"author,omitempty"`
+	Contents   string    `json:"contents,omitempty"`
+	Modified   string    `json:"modified,omitempty"`
+	Rect       Rect      `json:"-"`
+	QuadPoints []float64 `json:"-"`
+}
+
+// TextMarkup reports whether the annotation marks up page text
+// (highlight, underline, strike-out, squiggly).
+func (a Annotation) TextMarkup() bool {
+	switch a.Subtype {
+	case "Highlight", "Underline", "StrikeOut", "Squiggly":
+		return true
+	}
+	return false
+}
+
+// Annotations returns the review annotations of every page in page order.
+// Widgets, links and popups are structural and skipped; other subtypes are
+// kept when they are text markup or carry /Contents.
+func (d *Document) Annotations() []Annotation {
+	var out []Annotation
+	for _, p := range d.Pages() {
+		for _, a := range d.Array(p.Dict["Annots"]) {
+			if len(out) >= maxAnnotations {
+				return out
+			}
+			node := d.Dict(a)
+			if node == nil {
+				continue
+			}
+			subtype := string(d.Name(node["Subtype"]))
+			switch subtype {
+			case "", "Widget", "Link", "Popup":
+				continue
+			}
+
+			ann := Annotation{
+				Page:     p.Number,
+				Subtype:  subtype,
+				Author:   strings.TrimSpace(d.Text(node["T"])),
+				Contents: strings.TrimSpace(d.Text(node["Contents"])),
+				Modified: d.Text(node["M"]),
+			}
+			ann.Rect, _ = d.rect(node["Rect"])
+			for _, q := range d.Array(node["QuadPoints"]) {
+				if f, ok := d.Float(q); ok {
+					ann.QuadPoints = append(ann.QuadPoints, f)
+				}
+			}
+			if len(ann.QuadPoints)%8 != 0 {
+				ann.QuadPoints = nil
+			}
+			if !ann.TextMarkup() && ann.Contents == "" {
+				continue
+			}
+			out = append(out, ann)
+		}
+	}
+	return out
+}
+
+// MarkedRegions returns the bounding box of each quadrilateral of a text
+// markup annotation, falling back to /Rect when /QuadPoints is absent.
+func (a Annotation) MarkedRegions() []Rect {
+	if len(a.QuadPoints) == 0 {
+		if a.Rect == (Rect{}) {
+			return nil
+		}
+		return []Rect{a.Rect}
+	}
+	regions := make([]Rect, 0, len(a.QuadPoints)/8)
+	for i := 0; i+8 <= len(a.QuadPoints); i += 8 {
+		q := a.QuadPoints[i : i+8]
+		r := Rect{X0: q[0], Y0: q[1], X1: q[0], Y1: q[1]}
+		for j := 2; j < 8; j += 2 {
+			r.X0, r.X1 = math.Min(r.X0, q[j]), math.Max(r.X1, q[j])
+			r.Y0, r.Y1 = math.Min(r.Y0, q[j+1]), math.Max(r.Y1, q[j+1])
+		}
+		regions = append(regions, r)
+	}
+	return regions
+}
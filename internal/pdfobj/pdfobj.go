@@ -0,0 +1,818 @@
+// Package pdfobj is a small, read-only PDF object reader used for document
+// structure that poppler's CLI tools do not expose (outline destinations,
+// page labels, AcroForm fields, annotations).
+//
+// It deliberately avoids the cross-reference table: objects are located by
+// scanning for "N G obj" markers (later definitions win, which matches
+// incremental-update semantics) and by unpacking FlateDecode object streams.
+// This is the same recovery strategy most viewers fall back to for damaged
+// files, and it keeps the parser small. Encrypted documents are detected but
+// not decrypted.
+package pdfobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Object is one of: nil, bool, int64, float64, Name, String, Array, Dict, Ref
+// or *Stream.
+type Object any
+
+type Name string
+
+type String []byte
+
+type Array []Object
+
+type Dict map[Name]Object
+
+type Ref struct {
+	Num int
+	Gen int
+}
+
+type Stream struct {
+	Dict Dict
+	Raw  []byte // still encoded
+}
+
+const (
+	maxResolveDepth    = 32
+	maxDecodedStream   = 64 << 20
+	maxObjectStreamObj = 100000
+)
+
+// Document holds the raw file bytes and an index of object locations.
+type Document struct {
+	data    []byte
+	trailer Dict
+
+	// obj num -> byte offset of the "N G obj" header in data
+	offsets map[int]int
+	// obj num -> location inside an object stream
+	packed map[int]packedLoc
+
+	cache map[int]Object
+}
+
+type packedLoc struct {
+	stream int // object number of the ObjStm
+	index  int
+	order  int // file offset of the containing stream, used for precedence
+}
+
+// Open reads a PDF from disk. Files larger than maxBytes are rejected so the
+// caller's memory budget is respected (0 = no limit).
+func Open(path string, maxBytes int64) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && st.Size() > maxBytes {
+		return nil, fmt.Errorf("pdfobj: file exceeds %dMB structure limit", maxBytes/(1<<20))
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse indexes a PDF held in memory.
+func Parse(data []byte) (*Document, error) {
+	if !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")) {
+		return nil, errors.New("pdfobj: missing %PDF header")
+	}
+
+	d := &Document{
+		data:    data,
+		offsets: map[int]int{},
+		packed:  map[int]packedLoc{},
+		cache:   map[int]Object{},
+	}
+	d.scanObjects()
+	d.scanObjectStreams()
+	d.findTrailer()
+
+	if d.trailer == nil || d.trailer["Root"] == nil {
+		return nil, errors.New("pdfobj: trailer with /Root not found")
+	}
+	return d, nil
+}
+
+// Encrypted reports whether the trailer references a security handler.
+func (d *Document) Encrypted() bool {
+	return d.trailer["Encrypt"] != nil
+}
+
+// Trailer returns the effective trailer dictionary.
+func (d *Document) Trailer() Dict { return d.trailer }
+
+// Catalog returns the document catalog (/Root).
+func (d *Document) Catalog() Dict {
+	c, _ := d.Resolve(d.trailer["Root"]).(Dict)
+	return c
+}
+
+// Object loads an indirect object by number.
+func (d *Document) Object(num int) Object {
+	if v, ok := d.cache[num]; ok {
+		return v
+	}
+	// Guard against self-referencing loads while parsing.
+	d.cache[num] = nil
+
+	var obj Object
+	off, direct := d.offsets[num]
+	loc, inStream := d.packed[num]
+	switch {
+	case direct && (!inStream || off > loc.order):
+		obj = d.parseIndirectAt(off)
+	case inStream:
+		obj = d.loadPacked(loc)
+	}
+	d.cache[num] = obj
+	return obj
+}
+
+// Resolve follows indirect references until a direct object is reached.
+func (d *Document) Resolve(o Object) Object {
+	for i := 0; i < maxResolveDepth; i++ {
+		r, ok := o.(Ref)
+		if !ok {
+			return o
+		}
+		o = d.Object(r.Num)
+	}
+	return nil
+}
+
+// Dict resolves o and returns it as a dictionary (stream dictionaries included).
+func (d *Document) Dict(o Object) Dict {
+	switch v := d.Resolve(o).(type) {
+	case Dict:
+		return v
+	case *Stream:
+		return v.Dict
+	}
+	return nil
+}
+
+// Array resolves o and returns it as an array.
+func (d *Document) Array(o Object) Array {
+	a, _ := d.Resolve(o).(Array)
+	return a
+}
+
+// Int resolves o and returns it as an integer.
+func (d *Document) Int(o Object) (int, bool) {
+	switch v := d.Resolve(o).(type) {
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// Float resolves o and returns it as a float.
+func (d *Document) Float(o Object) (float64, bool) {
+	switch v := d.Resolve(o).(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Name resolves o and returns it as a name ("" when absent).
+func (d *Document) Name(o Object) Name {
+	n, _ := d.Resolve(o).(Name)
+	return n
+}
+
+// Text resolves o and decodes it as a PDF text string.
+func (d *Document) Text(o Object) string {
+	switch v := d.Resolve(o).(type) {
+	case String:
+		return DecodeText(v)
+	case Name:
+		return string(v)
+	case *Stream:
+		b, err := v.Decode()
+		if err != nil {
+			return ""
+		}
+		return DecodeText(b)
+	}
+	return ""
+}
+
+// Decode returns the decoded stream contents. Only FlateDecode (without
+// predictors) and unfiltered streams are supported.
+func (s *Stream) Decode() ([]byte, error) {
+	var filters []Name
+	switch f := s.Dict["Filter"].(type) {
+	case nil:
+	case Name:
+		filters = []Name{f}
+	case Array:
+		for _, x := range f {
+			if n, ok := x.(Name); ok {
+				filters = append(filters, n)
+			}
+		}
+	default:
+		return nil, errors.New("pdfobj: unsupported filter")
+	}
+
+	data := s.Raw
+	for _, f := range filters {
+		switch f {
+		case "FlateDecode", "Fl":
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("pdfobj: flate: %w", err)
+			}
+			out, err := io.ReadAll(io.LimitReader(zr, maxDecodedStream+1))
+			zr.Close()
+			if err != nil && len(out) == 0 {
+				return nil, fmt.Errorf("pdfobj: flate: %w", err)
+			}
+			if len(out) > maxDecodedStream {
+				return nil, errors.New("pdfobj: decoded stream too large")
+			}
+			data = out
+		default:
+			return nil, fmt.Errorf("pdfobj: unsupported filter %s", f)
+		}
+	}
+	return data, nil
+}
+
+// DecodeText converts a PDF text string (UTF-16BE with BOM, UTF-8 with BOM,
+// or PDFDocEncoding) to a Go string.
+func DecodeText(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		b = b[2:]
+		u := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
+		return string(b[3:])
+	}
+	rs := make([]rune, 0, len(b))
+	for _, c := range b {
+		if r, ok := pdfDocEncoding[c]; ok {
+			rs = append(rs, r)
+			continue
+		}
+		rs = append(rs, rune(c))
+	}
+	return string(rs)
+}
+
+// pdfDocEncoding lists the code points where PDFDocEncoding differs from
+// Latin-1.
+var pdfDocEncoding = map[byte]rune{
+	0x18: '˘', 0x19: 'ˇ', 0x1A: 'ˆ', 0x1B: '˙', 0x1C: '˝', 0x1D: '˛', 0x1E: '˚', 0x1F: '˜',
+	0x80: '•', 0x81: '†', 0x82: '‡', 0x83: '…', 0x84: '—', 0x85: '–', 0x86: 'ƒ', 0x87: '⁄',
+	0x88: '‹', 0x89: '›', 0x8A: '−', 0x8B: '‰', 0x8C: '„', 0x8D: '“', 0x8E: '”', 0x8F: '‘',
+	0x90: '’', 0x91: '‚', 0x92: '™', 0x93: 'ﬁ', 0x94: 'ﬂ', 0x95: 'Ł', 0x96: 'Œ', 0x97: 'Š',
+	0x98: 'Ÿ', 0x99: 'Ž', 0x9A: 'ı', 0x9B: 'ł', 0x9C: 'œ', 0x9D: 'š', 0x9E: 'ž', 0xA0: '€',
+}
+
+// ---------- Indexing ----------
+
+// scanObjects records the offset of every "N G obj" header in the file.
+func (d *Document) scanObjects() {
+	data := d.data
+	pos := 0
+	for {
+		i := bytes.Index(data[pos:], []byte("obj"))
+		if i < 0 {
+			return
+		}
+		i += pos
+		pos = i + 3
+
+		// Must not be part of "endobj" or a longer token.
+		if i > 0 && !isWhitespace(data[i-1]) {
+			continue
+		}
+		if pos < len(data) && !isWhitespace(data[pos]) && !isDelimiter(data[pos]) {
+			continue
+		}
+
+		j := i - 1
+		for j >= 0 && isWhitespace(data[j]) {
+			j--
+		}
+		genEnd := j + 1
+		for j >= 0 && isDigit(data[j]) {
+			j--
+		}
+		genStart := j + 1
+		if genStart == genEnd || j < 0 || !isWhitespace(data[j]) {
+			continue
+		}
+		for j >= 0 && isWhitespace(data[j]) {
+			j--
+		}
+		numEnd := j + 1
+		for j >= 0 && isDigit(data[j]) {
+			j--
+		}
+		numStart := j + 1
+		if numStart == numEnd || (j >= 0 && !isWhitespace(data[j]) && !isDelimiter(data[j])) {
+			continue
+		}
+
+		num, err := strconv.Atoi(string(data[numStart:numEnd]))
+		if err != nil || num <= 0 {
+			continue
+		}
+		d.offsets[num] = numStart
+	}
+}
+
+// scanObjectStreams unpacks the headers of /Type /ObjStm streams so packed
+// objects can be located.
+func (d *Document) scanObjectStreams() {
+	for num, off := range d.offsets {
+		s, ok := d.parseIndirectAt(off).(*Stream)
+		if !ok || s.Dict["Type"] != Name("ObjStm") {
+			continue
+		}
+		n, _ := s.Dict["N"].(int64)
+		if n <= 0 || n > maxObjectStreamObj {
+			continue
+		}
+		data, err := s.Decode()
+		if err != nil {
+			continue
+		}
+		p := &parser{data: data}
+		for i := 0; i < int(n); i++ {
+			objNum, ok1 := p.parseObject().(int64)
+			_, ok2 := p.parseObject().(int64)
+			if !ok1 || !ok2 {
+				break
+			}
+			prev, exists := d.packed[int(objNum)]
+			if exists && prev.order > off {
+				continue
+			}
+			d.packed[int(objNum)] = packedLoc{stream: num, index: i, order: off}
+		}
+	}
+}
+
+// findTrailer picks the last trailer dictionary (classic or xref stream)
+// that names a catalog.
+func (d *Document) findTrailer() {
+	bestOff := -1
+
+	pos := 0
+	for {
+		i := bytes.Index(d.data[pos:], []byte("trailer"))
+		if i < 0 {
+			break
+		}
+		i += pos
+		pos = i + 7
+		p := &parser{data: d.data, pos: pos}
+		if t, ok := p.parseObject().(Dict); ok && t["Root"] != nil && i > bestOff {
+			d.trailer, bestOff = t, i
+		}
+	}
+
+	for _, off := range d.offsets {
+		s, ok := d.parseIndirectAt(off).(*Stream)
+		if !ok || s.Dict["Type"] != Name("XRef") || s.Dict["Root"] == nil {
+			continue
+		}
+		if off > bestOff {
+			d.trailer, bestOff = s.Dict, off
+		}
+	}
+
+	// Last resort for files with a broken trailer: find a catalog directly.
+	if d.trailer == nil {
+		for num, off := range d.offsets {
+			if dict, ok := d.parseIndirectAt(off).(Dict); ok && dict["Type"] == Name("Catalog") {
+				d.trailer = Dict{"Root": Ref{Num: num}}
+				break
+			}
+		}
+	}
+}
+
+func (d *Document) parseIndirectAt(off int) Object {
+	p := &parser{data: d.data, pos: off}
+	if _, ok := p.parseObject().(int64); !ok {
+		return nil
+	}
+	if _, ok := p.parseObject().(int64); !ok {
+		return nil
+	}
+	if p.keyword() != "obj" {
+		return nil
+	}
+	obj := p.parseObject()
+	dict, ok := obj.(Dict)
+	if !ok {
+		return obj
+	}
+
+	save := p.pos
+	if p.keyword() != "stream" {
+		p.pos = save
+		return dict
+	}
+	// The stream keyword is followed by CRLF or LF.
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	length := -1
+	switch l := dict["Length"].(type) {
+	case int64:
+		length = int(l)
+	case Ref:
+		// Avoid recursion into objects that are still being indexed.
+		if lo, ok := d.offsets[l.Num]; ok {
+			lp := &parser{data: d.data, pos: lo}
+			lp.parseObject()
+			lp.parseObject()
+			if lp.keyword() == "obj" {
+				if n, ok := lp.parseObject().(int64); ok {
+					length = int(n)
+				}
+			}
+		}
+	}
+	end := start + length
+	if length < 0 || end > len(d.data) || !bytes.Contains(d.data[end:min(end+32, len(d.data))], []byte("endstream")) {
+		k := bytes.Index(d.data[start:], []byte("endstream"))
+		if k < 0 {
+			return dict
+		}
+		end = start + k
+		for end > start && (d.data[end-1] == '\n' || d.data[end-1] == '\r') {
+			end--
+		}
+	}
+	return &Stream{Dict: dict, Raw: d.data[start:end]}
+}
+
+func (d *Document) loadPacked(loc packedLoc) Object {
+	s, ok := d.Object(loc.stream).(*Stream)
+	if !ok {
+		return nil
+	}
+	data, err := s.Decode()
+	if err != nil {
+		return nil
+	}
+	n, _ := s.Dict["N"].(int64)
+	first, _ := s.Dict["First"].(int64)
+	if loc.index >= int(n) || int(first) > len(data) {
+		return nil
+	}
+
+	p := &parser{data: data}
+	var rel int64 = -1
+	for i := 0; i <= loc.index; i++ {
+		p.parseObject()
+		v, ok := p.parseObject().(int64)
+		if !ok {
+			return nil
+		}
+		rel = v
+	}
+	pos := int(first) + int(rel)
+	if rel < 0 || pos >= len(data) {
+		return nil
+	}
+	return (&parser{data: data, pos: pos}).parseObject()
+}
+
+// ---------- Lexer / parser ----------
+
+type parser struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isWhitespace(c) {
+			p.pos++
+			continue
+		}
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// keyword reads a bare token (obj, stream, R, true, ...).
+func (p *parser) keyword() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) && !isWhitespace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *parser) parseObject() Object {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > 64 {
+		return nil
+	}
+
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		return p.parseName()
+	case c == '(':
+		return p.parseLiteralString()
+	case c == '<':
+		if p.pos+1 < len(p.data) && p.data[p.pos+1] == '<' {
+			return p.parseDict()
+		}
+		return p.parseHexString()
+	case c == '[':
+		p.pos++
+		var arr Array
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) {
+				return arr
+			}
+			if p.data[p.pos] == ']' {
+				p.pos++
+				return arr
+			}
+			before := p.pos
+			arr = append(arr, p.parseObject())
+			if p.pos == before {
+				p.pos++
+			}
+		}
+	case c == '+' || c == '-' || c == '.' || isDigit(c):
+		return p.parseNumberOrRef()
+	default:
+		switch kw := p.keyword(); kw {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null", "":
+			if kw == "" {
+				p.pos++
+			}
+			return nil
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseNumberOrRef() Object {
+	start := p.pos
+	if p.data[p.pos] == '+' || p.data[p.pos] == '-' {
+		p.pos++
+	}
+	isFloat := false
+	for p.pos < len(p.data) && (isDigit(p.data[p.pos]) || p.data[p.pos] == '.') {
+		if p.data[p.pos] == '.' {
+			isFloat = true
+		}
+		p.pos++
+	}
+	tok := string(p.data[start:p.pos])
+	if isFloat {
+		f, _ := strconv.ParseFloat(tok, 64)
+		return f
+	}
+	n, err := strconv.ParseInt(tok, 10, 64)
+	if err != nil {
+		f, _ := strconv.ParseFloat(tok, 64)
+		return f
+	}
+
+	// Look ahead for "G R".
+	save := p.pos
+	p.skipSpace()
+	genStart := p.pos
+	for p.pos < len(p.data) && isDigit(p.data[p.pos]) {
+		p.pos++
+	}
+	if p.pos > genStart {
+		gen, _ := strconv.Atoi(string(p.data[genStart:p.pos]))
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == 'R' &&
+			(p.pos+1 == len(p.data) || isWhitespace(p.data[p.pos+1]) || isDelimiter(p.data[p.pos+1])) {
+			p.pos++
+			return Ref{Num: int(n), Gen: gen}
+		}
+	}
+	p.pos = save
+	return n
+}
+
+func (p *parser) parseName() Object {
+	p.pos++ // '/'
+	var buf []byte
+	for p.pos < len(p.data) && !isWhitespace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		c := p.data[p.pos]
+		if c == '#' && p.pos+2 < len(p.data) {
+			if v, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				buf = append(buf, byte(v))
+				p.pos += 3
+				continue
+			}
+		}
+		buf = append(buf, c)
+		p.pos++
+	}
+	return Name(buf)
+}
+
+func (p *parser) parseLiteralString() Object {
+	p.pos++ // '('
+	var buf []byte
+	nest := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			nest++
+			buf = append(buf, c)
+		case ')':
+			nest--
+			if nest == 0 {
+				return String(buf)
+			}
+			buf = append(buf, c)
+		case '\\':
+			if p.pos >= len(p.data) {
+				return String(buf)
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; k++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					buf = append(buf, byte(v))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return String(buf)
+}
+
+func (p *parser) parseHexString() Object {
+	p.pos++ // '<'
+	var buf []byte
+	var hi byte
+	half := false
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		if c == '>' {
+			break
+		}
+		v, ok := hexVal(c)
+		if !ok {
+			continue
+		}
+		if half {
+			buf = append(buf, hi<<4|v)
+		} else {
+			hi = v
+		}
+		half = !half
+	}
+	if half {
+		buf = append(buf, hi<<4)
+	}
+	return String(buf)
+}
+
+func (p *parser) parseDict() Object {
+	p.pos += 2 // '<<'
+	dict := Dict{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return dict
+		}
+		if p.data[p.pos] == '>' {
+			p.pos += 2
+			return dict
+		}
+		if p.data[p.pos] != '/' {
+			// Malformed key; skip a token to make progress.
+			before := p.pos
+			p.parseObject()
+			if p.pos == before {
+				p.pos++
+			}
+			continue
+		}
+		key, _ := p.parseName().(Name)
+		dict[key] = p.parseObject()
+	}
+}
+
+func hexVal(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func isWhitespace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
@@ -0,0 +1,205 @@
+package pdfobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF assembles a minimal PDF from numbered object bodies. No xref
+// table is written; the reader does not need one.
+func buildPDF(objs map[int]string, trailer string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	for i := 1; i <= len(objs)+10; i++ {
+		body, ok := objs[i]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i, body)
+	}
+	fmt.Fprintf(&b, "trailer\n%s\n%%%%EOF\n", trailer)
+	return b.Bytes()
+}
+
+func flate(s string) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+	return b.Bytes()
+}
+
+func TestOutlineAndPageLabels(t *testing.T) {
+	objs := map[int]string{
+		1: `<< /Type /Catalog /Pages 2 0 R /Outlines 10 0 R /PageLabels << /Nums [0 << /S /r >> 2 << /S /D /P (A-) >>] >> /Names << /Dests 20 0 R >> >>`,
+		2: `<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R 6 0 R] /Count 4 >>`,
+		3: `<< /Type /Page /Parent 2 0 R >>`,
+		4: `<< /Type /Page /Parent 2 0 R >>`,
+		5: `<< /Type /Page /Parent 2 0 R >>`,
+		6: `<< /Type /Page /Parent 2 0 R >>`,
+
+		10: `<< /Type /Outlines /First 11 0 R /Last 12 0 R >>`,
+		11: `<< /Title (Preface) /Dest [3 0 R /Fit] /Next 12 0 R >>`,
+		12: `<< /Title <FEFF004300680061007000740065007200200031> /A << /S /GoTo /D (ch1) >> /First 13 0 R >>`,
+		13: `<< /Title (Section 1.1) /Dest [6 0 R /XYZ 0 700 0] >>`,
+
+		20: `<< /Names [(ch1) [5 0 R /Fit]] >>`,
+	}
+	doc, err := Parse(buildPDF(objs, "<< /Root 1 0 R >>"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if n := len(doc.Pages()); n != 4 {
+		t.Fatalf("expected 4 pages, got %d", n)
+	}
+
+	out := doc.Outline()
+	want := []OutlineItem{
+		{Title: "Preface", Level: 1, Page: 1},
+		{Title: "Chapter 1", Level: 1, Page: 3},
+		{Title: "Section 1.1", Level: 2, Page: 4},
+	}
+	if len(out) != len(want) {
+		t.Fatalf("outline = %+v", out)
+	}
+	for i := range want {
+		if out[i] != want[i] {
+			t.Fatalf("outline[%d] = %+v, want %+v", i, out[i], want[i])
+		}
+	}
+
+	labels := doc.PageLabels(4)
+	if strings.Join(labels, ",") != "i,ii,A-1,A-2" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+}
+
+func TestObjectStreams(t *testing.T) {
+	page := "<< /Type /Page /Parent 2 0 R >> "
+	item := "<< /Title (Packed) /Dest [7 0 R /Fit] >>"
+	// Offsets in the header are relative to /First.
+	header := fmt.Sprintf("7 0 8 %d ", len(page))
+	body := flate(header + page + item)
+
+	objs := map[int]string{
+		1: `<< /Type /Catalog /Pages 2 0 R /Outlines 3 0 R >>`,
+		2: `<< /Type /Pages /Kids [7 0 R] /Count 1 >>`,
+		3: `<< /First 8 0 R >>`,
+		4: fmt.Sprintf("<< /Type /ObjStm /N 2 /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", len(header), len(body), body),
+	}
+	doc, err := Parse(buildPDF(objs, "<< /Root 1 0 R >>"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out := doc.Outline()
+	if len(out) != 1 || out[0].Title != "Packed" || out[0].Page != 1 {
+		t.Fatalf("unexpected outline: %+v", out)
+	}
+}
+
+func TestLiteralStringEscapes(t *testing.T) {
+	p := &parser{data: []byte(`(a\(b\) \101\nc (nested))`)}
+	s, ok := p.parseObject().(String)
+	if !ok {
+		t.Fatalf("expected string")
+	}
+	if got := string(s); got != "a(b) A\nc (nested)" {
+		t.Fatalf("unexpected string %q", got)
+	}
+}
+
+func TestFormatLabel(t *testing.T) {
+	cases := map[struct {
+		style Name
+		n     int
+	}]string{
+		{"R", 14}: "XIV",
+		{"a", 28}: "bb",
+		{"D", 7}:  "7",
+	}
+	for in, want := range cases {
+		if got := formatLabel(in.style, in.n); got != want {
+			t.Fatalf("formatLabel(%q, %d) = %q, want %q", in.style, in.n, got, want)
+		}
+	}
+}
+
+func TestFormFieldsAndAnnotations(t *testing.T) {
+	objs := map[int]string{
+		1: `<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [10 0 R 11 0 R 12 0 R] >> >>`,
+		2: `<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] >>`,
+		3: `<< /Type /Page /Parent 2 0 R /Annots [20 0 R 13 0 R 14 0 R 21 0 R 22 0 R] >>`,
+
+		10: `<< /T (name) /FT /Tx /V (Jane Doe) /Subtype /Widget /P 3 0 R >>`,
+		11: `<< /T (agree) /FT /Btn /V /Yes /Kids [20 0 R] >>`,
+		12: `<< /T (size) /FT /Btn /Ff 32768 /V /M /Kids [13 0 R 14 0 R] >>`,
+		13: `<< /Subtype /Widget /Parent 12 0 R >>`,
+		14: `<< /Subtype /Widget /Parent 12 0 R >>`,
+		20: `<< /Subtype /Widget /Parent 11 0 R >>`,
+		21: `<< /Subtype /Highlight /T (Alice) /Contents (check this) /QuadPoints [10 700 110 700 10 680 110 680] /Rect [10 680 110 700] >>`,
+		22: `<< /Subtype /Link /Rect [0 0 1 1] >>`,
+	}
+	doc, err := Parse(buildPDF(objs, "<< /Root 1 0 R >>"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	fields := doc.FormFields()
+	if len(fields) != 3 {
+		t.Fatalf("fields = %+v", fields)
+	}
+	if f := fields[0]; f.Type != "text" || f.Value != "Jane Doe" || f.Page != 1 {
+		t.Fatalf("text field = %+v", f)
+	}
+	if f := fields[1]; f.Type != "checkbox" || f.Checked == nil || !*f.Checked || f.Page != 1 {
+		t.Fatalf("checkbox = %+v", f)
+	}
+	if f := fields[2]; f.Type != "radio" || f.Value != "M" {
+		t.Fatalf("radio = %+v", f)
+	}
+
+	annots := doc.Annotations()
+	if len(annots) != 1 || annots[0].Subtype != "Highlight" || annots[0].Author != "Alice" {
+		t.Fatalf("annotations = %+v", annots)
+	}
+	regions := annots[0].MarkedRegions()
+	if len(regions) != 1 || regions[0] != (Rect{X0: 10, Y0: 680, X1: 110, Y1: 700}) {
+		t.Fatalf("regions = %+v", regions)
+	}
+	box, ok := doc.PageBox(doc.Pages()[0])
+	if !ok || box.Y1 != 792 {
+		t.Fatalf("page box = %+v", box)
+	}
+}
+
+func TestEmbeddedFiles(t *testing.T) {
+	objs := map[int]string{
+		1: `<< /Type /Catalog /Pages 2 0 R /Names << /EmbeddedFiles << /Names [(q.xlsx) 5 0 R] >> >> /Collection << /View /D >> >>`,
+		2: `<< /Type /Pages /Kids [3 0 R] /Count 1 >>`,
+		3: `<< /Type /Page /Parent 2 0 R /Annots [7 0 R] >>`,
+		5: `<< /Type /Filespec /F (q.xlsx) /UF <FEFF0071002E0078006C00730078> /Desc (Quote) /EF << /F 6 0 R >> >>`,
+		6: "<< /Type /EmbeddedFile /Subtype /application#2Fvnd.ms-excel /Params << /Size 1234 >> /Length 3 >>\nstream\nabc\nendstream",
+		7: `<< /Subtype /FileAttachment /FS << /F (note.txt) /EF << /F 6 0 R >> >> >>`,
+	}
+	doc, err := Parse(buildPDF(objs, "<< /Root 1 0 R >>"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !doc.Portfolio() {
+		t.Fatalf("expected portfolio")
+	}
+	files := doc.EmbeddedFiles()
+	if len(files) != 2 {
+		t.Fatalf("files = %+v", files)
+	}
+	if f := files[0]; f.Name != "q.xlsx" || f.Description != "Quote" || f.Size != 1234 || f.MIMEType != "application/vnd.ms-excel" {
+		t.Fatalf("file[0] = %+v", f)
+	}
+	if f := files[1]; f.Name != "note.txt" || f.Page != 1 {
+		t.Fatalf("file[1] = %+v", f)
+	}
+}
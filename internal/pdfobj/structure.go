@@ -0,0 +1,333 @@
+package pdfobj
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	maxPages        = 50000
+	maxOutlineItems = 5000
+	maxTreeDepth    = 32
+)
+
+// Page is one leaf of the page tree.
+type Page struct {
+	Number int // 1-based
+	Ref    Ref
+	Dict   Dict
+}
+
+// Pages walks the page tree in document order.
+func (d *Document) Pages() []Page {
+	root := d.Catalog()
+	if root == nil {
+		return nil
+	}
+	var pages []Page
+	seen := map[int]bool{}
+	var walk func(o Object, depth int)
+	walk = func(o Object, depth int) {
+		if depth > maxTreeDepth || len(pages) >= maxPages {
+			return
+		}
+		ref, isRef := o.(Ref)
+		if isRef {
+			if seen[ref.Num] {
+				return
+			}
+			seen[ref.Num] = true
+		}
+		node := d.Dict(o)
+		if node == nil {
+			return
+		}
+		if kids := d.Array(node["Kids"]); kids != nil && node["Type"] != Name("Page") {
+			for _, k := range kids {
+				walk(k, depth+1)
+			}
+			return
+		}
+		pages = append(pages, Page{Number: len(pages) + 1, Ref: ref, Dict: node})
+	}
+	walk(root["Pages"], 0)
+	return pages
+}
+
+// pageIndex maps page object numbers to 1-based page numbers.
+func pageIndex(pages []Page) map[int]int {
+	idx := make(map[int]int, len(pages))
+	for _, p := range pages {
+		if p.Ref.Num > 0 {
+			idx[p.Ref.Num] = p.Number
+		}
+	}
+	return idx
+}
+
+// ---------- Outline ----------
+
+// OutlineItem is one bookmark entry. Page is 0 when the destination cannot be
+// resolved to a page of this document.
+type OutlineItem struct {
+	Title string `json:"title"`
+	Level int    `json:"level"`
+	Page  int    `json:"page,omitempty"`
+}
+
+// Outline returns the bookmark tree flattened in reading order. Level is
+// 1-based nesting depth.
+func (d *Document) Outline() []OutlineItem {
+	root := d.Dict(d.Catalog()["Outlines"])
+	if root == nil {
+		return nil
+	}
+
+	pages := d.Pages()
+	idx := pageIndex(pages)
+	named := d.namedDests()
+
+	var items []OutlineItem
+	seen := map[int]bool{}
+	var walk func(first Object, level int)
+	walk = func(first Object, level int) {
+		if level > maxTreeDepth {
+			return
+		}
+		cur := first
+		for cur != nil && len(items) < maxOutlineItems {
+			ref, ok := cur.(Ref)
+			if ok {
+				if seen[ref.Num] {
+					return
+				}
+				seen[ref.Num] = true
+			}
+			node := d.Dict(cur)
+			if node == nil {
+				return
+			}
+
+			title := strings.TrimSpace(strings.Join(strings.Fields(d.Text(node["Title"])), " "))
+			if title != "" {
+				items = append(items, OutlineItem{
+					Title: title,
+					Level: level,
+					Page:  d.outlinePage(node, idx, named),
+				})
+			}
+			if node["First"] != nil {
+				walk(node["First"], level+1)
+			}
+			cur = node["Next"]
+		}
+	}
+	walk(root["First"], 1)
+	return items
+}
+
+func (d *Document) outlinePage(node Dict, idx map[int]int, named map[string]Object) int {
+	dest := node["Dest"]
+	if dest == nil {
+		if action := d.Dict(node["A"]); action != nil && d.Name(action["S"]) == "GoTo" {
+			dest = action["D"]
+		}
+	}
+	return d.destPage(dest, idx, named, 0)
+}
+
+func (d *Document) destPage(dest Object, idx map[int]int, named map[string]Object, depth int) int {
+	if depth > 4 {
+		return 0
+	}
+	switch v := d.Resolve(dest).(type) {
+	case Array:
+		if len(v) == 0 {
+			return 0
+		}
+		if r, ok := v[0].(Ref); ok {
+			return idx[r.Num]
+		}
+		// Remote-style destinations use a zero-based page number.
+		if n, ok := v[0].(int64); ok {
+			return int(n) + 1
+		}
+	case Dict:
+		return d.destPage(v["D"], idx, named, depth+1)
+	case String:
+		return d.destPage(named[string(v)], idx, named, depth+1)
+	case Name:
+		return d.destPage(named[string(v)], idx, named, depth+1)
+	}
+	return 0
+}
+
+// namedDests collects named destinations from both the PDF 1.1 /Dests
+// dictionary and the PDF 1.2+ /Names /Dests name tree.
+func (d *Document) namedDests() map[string]Object {
+	out := map[string]Object{}
+	cat := d.Catalog()
+	if dests := d.Dict(cat["Dests"]); dests != nil {
+		for k, v := range dests {
+			out[string(k)] = v
+		}
+	}
+	if names := d.Dict(cat["Names"]); names != nil {
+		d.walkNameTree(names["Dests"], func(key string, v Object) {
+			out[key] = v
+		})
+	}
+	return out
+}
+
+// walkNameTree visits every key/value pair of a name tree.
+func (d *Document) walkNameTree(root Object, visit func(key string, v Object)) {
+	seen := map[int]bool{}
+	var walk func(o Object, depth int)
+	walk = func(o Object, depth int) {
+		if depth > maxTreeDepth {
+			return
+		}
+		if r, ok := o.(Ref); ok {
+			if seen[r.Num] {
+				return
+			}
+			seen[r.Num] = true
+		}
+		node := d.Dict(o)
+		if node == nil {
+			return
+		}
+		if arr := d.Array(node["Names"]); arr != nil {
+			for i := 0; i+1 < len(arr); i += 2 {
+				key, _ := d.Resolve(arr[i]).(String)
+				visit(DecodeText(key), arr[i+1])
+			}
+		}
+		for _, k := range d.Array(node["Kids"]) {
+			walk(k, depth+1)
+		}
+	}
+	walk(root, 0)
+}
+
+// ---------- Page labels ----------
+
+// PageLabels returns the display label of every page (index 0 = page 1), or
+// nil when the document defines no /PageLabels.
+func (d *Document) PageLabels(pageCount int) []string {
+	root := d.Catalog()["PageLabels"]
+	if root == nil || pageCount <= 0 {
+		return nil
+	}
+
+	type rangeStart struct {
+		start int
+		spec  Dict
+	}
+	var ranges []rangeStart
+	seen := map[int]bool{}
+	var walk func(o Object, depth int)
+	walk = func(o Object, depth int) {
+		if depth > maxTreeDepth {
+			return
+		}
+		if r, ok := o.(Ref); ok {
+			if seen[r.Num] {
+				return
+			}
+			seen[r.Num] = true
+		}
+		node := d.Dict(o)
+		if node == nil {
+			return
+		}
+		if nums := d.Array(node["Nums"]); nums != nil {
+			for i := 0; i+1 < len(nums); i += 2 {
+				start, ok := d.Int(nums[i])
+				if !ok || start < 0 {
+					continue
+				}
+				ranges = append(ranges, rangeStart{start: start, spec: d.Dict(nums[i+1])})
+			}
+		}
+		for _, k := range d.Array(node["Kids"]) {
+			walk(k, depth+1)
+		}
+	}
+	walk(root, 0)
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	labels := make([]string, pageCount)
+	for _, r := range ranges {
+		end := pageCount
+		// Ranges are sorted by start in a valid number tree, but don't rely on it.
+		for _, other := range ranges {
+			if other.start > r.start && other.start < end {
+				end = other.start
+			}
+		}
+		style := d.Name(r.spec["S"])
+		prefix := d.Text(r.spec["P"])
+		first := 1
+		if st, ok := d.Int(r.spec["St"]); ok && st > 0 {
+			first = st
+		}
+		for p := r.start; p < end && p < pageCount; p++ {
+			labels[p] = prefix + formatLabel(style, first+p-r.start)
+		}
+	}
+	for i, l := range labels {
+		if l == "" {
+			labels[i] = strconv.Itoa(i + 1)
+		}
+	}
+	return labels
+}
+
+func formatLabel(style Name, n int) string {
+	switch style {
+	case "D":
+		return strconv.Itoa(n)
+	case "R":
+		return strings.ToUpper(roman(n))
+	case "r":
+		return roman(n)
+	case "A":
+		return strings.ToUpper(letters(n))
+	case "a":
+		return letters(n)
+	}
+	// No numbering style: the label is the prefix alone.
+	return ""
+}
+
+func roman(n int) string {
+	if n <= 0 || n >= 4000 {
+		return strconv.Itoa(n)
+	}
+	vals := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	syms := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	var sb strings.Builder
+	for i, v := range vals {
+		for n >= v {
+			sb.WriteString(syms[i])
+			n -= v
+		}
+	}
+	return sb.String()
+}
+
+// letters implements the PDF alphabetic style: a..z, aa..zz, aaa..zzz.
+func letters(n int) string {
+	if n <= 0 {
+		return strconv.Itoa(n)
+	}
+	repeat := (n-1)/26 + 1
+	if repeat > 100 {
+		return strconv.Itoa(n)
+	}
+	return strings.Repeat(string(rune('a'+(n-1)%26)), repeat)
+}
@@ -0,0 +1,185 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker states.
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// BreakerConfig configures one provider's breaker. Zero values fall back to
+// the defaults below.
+type BreakerConfig struct {
+	FailureThreshold int           // consecutive failures that open the breaker
+	Cooldown         time.Duration // how long to stay open before probing
+}
+
+const (
+	defaultFailureThreshold = 5
+	defaultCooldown         = 30 * time.Second
+)
+
+// BreakerStats is a point-in-time view of one breaker for /health and /metrics.
+type BreakerStats struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	Opens               int64      `json:"opens"`
+	Rejected            int64      `json:"rejected"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+	RetryAt             *time.Time `json:"retryAt,omitempty"`
+}
+
+// Breaker is a consecutive-failure circuit breaker. While open it rejects
+// calls; after Cooldown it lets a single probe through (half-open) and
+// closes again on success or re-opens on failure.
+type Breaker struct {
+	name string
+	cfg  BreakerConfig
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	opens    int64
+	rejected int64
+}
+
+// NewBreaker returns a closed breaker for the named provider.
+func NewBreaker(name string, cfg BreakerConfig) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultCooldown
+	}
+	return &Breaker{name: name, cfg: cfg, now: time.Now, state: StateClosed}
+}
+
+// allow reports whether a call may proceed, moving open → half-open once the
+// cooldown has elapsed.
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.Cooldown {
+			b.rejected++
+			return unavailable(b.name)
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			b.rejected++
+			return unavailable(b.name)
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+func (b *Breaker) record(o outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch o {
+	case outcomeSuccess:
+		b.failures = 0
+		b.state = StateClosed
+		b.probing = false
+	case outcomeFailure:
+		b.failures++
+		if b.state == StateHalfOpen || b.failures >= b.cfg.FailureThreshold {
+			if b.state != StateOpen {
+				b.opens++
+			}
+			b.state = StateOpen
+			b.openedAt = b.now()
+		}
+		b.probing = false
+	case outcomeNeutral:
+		// Free the probe slot so the next caller can test the provider.
+		b.probing = false
+	}
+}
+
+// Open reports whether calls are currently being rejected.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == StateOpen && b.now().Sub(b.openedAt) < b.cfg.Cooldown
+}
+
+// Stats returns current state and counters.
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := BreakerStats{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Opens:               b.opens,
+		Rejected:            b.rejected,
+	}
+	if b.state == StateOpen {
+		opened := b.openedAt
+		retry := opened.Add(b.cfg.Cooldown)
+		s.OpenedAt, s.RetryAt = &opened, &retry
+	}
+	return s
+}
+
+// ── Process-wide registry ────────────────────────────────────────────────────
+
+var (
+	mu       sync.RWMutex
+	breakers = map[string]*Breaker{}
+)
+
+// Configure installs (or replaces) the breaker for a provider. Call it at
+// startup, before any requests are made.
+func Configure(name string, cfg BreakerConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	breakers[name] = NewBreaker(name, cfg)
+}
+
+// BreakerFor returns the provider's breaker, creating one with default
+// settings if the provider was never configured.
+func BreakerFor(name string) *Breaker {
+	mu.RLock()
+	b, ok := breakers[name]
+	mu.RUnlock()
+	if ok {
+		return b
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if b, ok := breakers[name]; ok {
+		return b
+	}
+	b = NewBreaker(name, BreakerConfig{})
+	breakers[name] = b
+	return b
+}
+
+// Snapshot returns the stats of every known breaker, keyed by provider.
+func Snapshot() map[string]BreakerStats {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make(map[string]BreakerStats, len(breakers))
+	for n, b := range breakers {
+		out[n] = b.Stats()
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+// Package resilience wraps outbound provider calls with retries (exponential
+// backoff with jitter, Retry-After aware) and a per-provider circuit breaker
+// that fails fast while a provider is down.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+	"github.com/toricodesthings/file-processing-service/internal/gateway"
+)
+
+// ErrCircuitOpen is returned (wrapped with the provider_unavailable code)
+// when a provider's breaker is open.
+var ErrCircuitOpen = errors.New("provider temporarily unavailable (circuit open)")
+
+// maxRetryAfter caps how long a Retry-After header can make us wait; longer
+// hints end the retry loop instead.
+const maxRetryAfter = 60 * time.Second
+
+// StatusError is implemented by provider errors that carry an HTTP status.
+type StatusError interface {
+	HTTPStatus() int
+}
+
+// RetryAfterError is implemented by provider errors that carry a parsed
+// Retry-After hint.
+type RetryAfterError interface {
+	RetryAfterHint() time.Duration
+}
+
+// Policy configures the retry loop of one call.
+type Policy struct {
+	MaxAttempts int           // total attempts, including the first
+	BaseDelay   time.Duration // delay before the first retry, doubled each time
+	MaxDelay    time.Duration // backoff cap (before jitter)
+}
+
+// Do runs fn until it succeeds, returns a non-retryable error, or the policy
+// is exhausted. Each attempt first asks the provider's breaker for
+// permission and reports its outcome back. The last error is returned
+// unwrapped so callers keep their typed errors.
+func Do(ctx context.Context, provider string, p Policy, fn func(ctx context.Context) error) error {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	b := BreakerFor(provider)
+
+	var lastErr error
+	for attempt := 0; attempt < p.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay, ok := retryDelay(p, attempt, lastErr)
+			if !ok || !fitsDeadline(ctx, delay) {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		if err := b.allow(); err != nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			return err
+		}
+
+		err := fn(ctx)
+		b.record(classify(ctx, err))
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !Retryable(ctx, err) {
+			break
+		}
+	}
+	return lastErr
+}
+
+// Retryable reports whether err is worth another attempt: network failures,
+// 408/425/429 and 5xx. Cancellation, queue overflow and other 4xx are final.
+func Retryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, gateway.ErrQueueFull) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var se StatusError
+	if errors.As(err, &se) {
+		switch s := se.HTTPStatus(); {
+		case s == http.StatusRequestTimeout, s == http.StatusTooEarly, s == http.StatusTooManyRequests:
+			return true
+		case s >= 500:
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota // provider answered (including 4xx for our input)
+	outcomeFailure                // provider down or erroring
+	outcomeNeutral                // says nothing about provider health
+)
+
+// classify maps a call result to a breaker outcome. 5xx and transport errors
+// count against the provider; 429 is back-pressure handled by Retry-After,
+// and local conditions (cancellation, our own queue) are neutral.
+func classify(ctx context.Context, err error) outcome {
+	if err == nil {
+		return outcomeSuccess
+	}
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, gateway.ErrQueueFull) {
+		return outcomeNeutral
+	}
+	var se StatusError
+	if errors.As(err, &se) {
+		s := se.HTTPStatus()
+		switch {
+		case s >= 500:
+			return outcomeFailure
+		case s == http.StatusTooManyRequests:
+			return outcomeNeutral
+		default:
+			return outcomeSuccess
+		}
+	}
+	return outcomeFailure
+}
+
+// retryDelay picks the wait before attempt (1-based retry number): the
+// provider's Retry-After when given, otherwise exponential backoff with
+// equal jitter. ok is false when Retry-After asks for more than we wait.
+func retryDelay(p Policy, attempt int, lastErr error) (time.Duration, bool) {
+	var ra RetryAfterError
+	if errors.As(lastErr, &ra) {
+		if d := ra.RetryAfterHint(); d > 0 {
+			if d > maxRetryAfter {
+				return 0, false
+			}
+			return d, true
+		}
+	}
+	return Backoff(p, attempt), true
+}
+
+// Backoff returns BaseDelay * 2^(attempt-1), capped at MaxDelay, with half of
+// it randomised so concurrent callers spread out.
+func Backoff(p Policy, attempt int) time.Duration {
+	d := p.BaseDelay
+	if d <= 0 {
+		d = time.Second
+	}
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			d = p.MaxDelay
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+func fitsDeadline(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > delay
+}
+
+// ParseRetryAfter parses a Retry-After header value: delay-seconds or an
+// HTTP-date. It returns 0 when absent or invalid.
+func ParseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// unavailable builds the error returned while a breaker is open.
+func unavailable(provider string) error {
+	return extract.WithCode(extract.CodeProviderUnavailable, &providerError{provider: provider})
+}
+
+type providerError struct{ provider string }
+
+func (e *providerError) Error() string { return e.provider + ": " + ErrCircuitOpen.Error() }
+func (e *providerError) Unwrap() error { return ErrCircuitOpen }
@@ -0,0 +1,148 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+type statusErr struct {
+	status     int
+	retryAfter time.Duration
+}
+
+func (e *statusErr) Error() string                 { return http.StatusText(e.status) }
+func (e *statusErr) HTTPStatus() int               { return e.status }
+func (e *statusErr) RetryAfterHint() time.Duration { return e.retryAfter }
+
+var fast = Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestDoRetriesServerErrors(t *testing.T) {
+	Configure("t-retry", BreakerConfig{FailureThreshold: 10})
+	calls := 0
+	err := Do(context.Background(), "t-retry", fast, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return &statusErr{status: 503}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("err=%v calls=%d, want success on third call", err, calls)
+	}
+}
+
+func TestDoStopsOnClientError(t *testing.T) {
+	Configure("t-client", BreakerConfig{})
+	calls := 0
+	err := Do(context.Background(), "t-client", fast, func(context.Context) error {
+		calls++
+		return &statusErr{status: 400}
+	})
+	var se *statusErr
+	if !errors.As(err, &se) || calls != 1 {
+		t.Fatalf("err=%v calls=%d, want the 400 after one call", err, calls)
+	}
+	if st := BreakerFor("t-client").Stats(); st.ConsecutiveFailures != 0 {
+		t.Fatalf("4xx counted as provider failure: %+v", st)
+	}
+}
+
+func TestDoHonoursRetryAfter(t *testing.T) {
+	Configure("t-ra", BreakerConfig{})
+	var gap time.Duration
+	var last time.Time
+	calls := 0
+	err := Do(context.Background(), "t-ra", fast, func(context.Context) error {
+		calls++
+		if calls == 1 {
+			last = time.Now()
+			return &statusErr{status: 429, retryAfter: 50 * time.Millisecond}
+		}
+		gap = time.Since(last)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gap < 50*time.Millisecond {
+		t.Fatalf("retried after %v, want >= Retry-After", gap)
+	}
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	Configure("t-breaker", BreakerConfig{FailureThreshold: 2, Cooldown: time.Minute})
+	b := BreakerFor("t-breaker")
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	fail := func(context.Context) error { return &statusErr{status: 500} }
+	_ = Do(context.Background(), "t-breaker", Policy{MaxAttempts: 2, BaseDelay: time.Millisecond}, fail)
+	if st := b.Stats(); st.State != StateOpen || st.Opens != 1 {
+		t.Fatalf("stats = %+v, want open", st)
+	}
+
+	called := false
+	err := Do(context.Background(), "t-breaker", fast, func(context.Context) error { called = true; return nil })
+	if called || !errors.Is(err, ErrCircuitOpen) || extract.ErrorCode(err) != extract.CodeProviderUnavailable {
+		t.Fatalf("open breaker: called=%v err=%v code=%q", called, err, extract.ErrorCode(err))
+	}
+
+	// After the cooldown a single probe is let through and closes it.
+	now = now.Add(2 * time.Minute)
+	if err := Do(context.Background(), "t-breaker", fast, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if st := b.Stats(); st.State != StateClosed || st.ConsecutiveFailures != 0 {
+		t.Fatalf("stats = %+v, want closed", st)
+	}
+}
+
+func TestHalfOpenFailureReopens(t *testing.T) {
+	b := NewBreaker("t-half", BreakerConfig{FailureThreshold: 1, Cooldown: time.Second})
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.record(outcomeFailure)
+	now = now.Add(2 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if err := b.allow(); err == nil {
+		t.Fatal("second caller allowed while probe in flight")
+	}
+	b.record(outcomeFailure)
+	if st := b.Stats(); st.State != StateOpen || st.Opens != 2 {
+		t.Fatalf("stats = %+v, want re-opened", st)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := ParseRetryAfter("3"); d != 3*time.Second {
+		t.Fatalf("seconds: %v", d)
+	}
+	if d := ParseRetryAfter(time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)); d < 8*time.Second || d > 10*time.Second {
+		t.Fatalf("http-date: %v", d)
+	}
+	for _, v := range []string{"", "soon", "-1"} {
+		if d := ParseRetryAfter(v); d != 0 {
+			t.Fatalf("%q: %v", v, d)
+		}
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+	p := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, max := range map[int]time.Duration{1: 100, 2: 200, 3: 300, 6: 300} {
+		max *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if d := Backoff(p, attempt); d < max/2 || d > max {
+				t.Fatalf("attempt %d: %v outside [%v, %v]", attempt, d, max/2, max)
+			}
+		}
+	}
+}
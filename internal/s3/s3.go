@@ -0,0 +1,317 @@
+// Package s3 is a minimal client for S3-compatible object storage (AWS S3,
+// Cloudflare R2, MinIO) signed with AWS Signature Version 4. It covers only
+// what the service needs: GET, HEAD and PUT of single objects and presigned
+// GET URLs.
+package s3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	emptySHA256     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Config describes one S3-compatible endpoint and its credentials.
+type Config struct {
+	Endpoint        string // e.g. https://s3.us-east-1.amazonaws.com, http://localhost:9000
+	Region          string // "auto" for R2
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PathStyle addresses objects as endpoint/bucket/key rather than
+	// bucket.endpoint/key. MinIO and most self-hosted stores need it.
+	PathStyle bool
+	Timeout   time.Duration
+}
+
+// Client signs and sends object requests.
+type Client struct {
+	cfg      Config
+	endpoint *url.URL
+	http     *http.Client
+	now      func() time.Time
+}
+
+// Error is a non-2xx response from the store.
+type Error struct {
+	Status int
+	Code   string // S3 error code when the body carried one, e.g. NoSuchKey
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("s3: HTTP %d %s", e.Status, e.Code)
+	}
+	return fmt.Sprintf("s3: HTTP %d", e.Status)
+}
+
+func (e *Error) HTTPStatus() int { return e.Status }
+
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(strings.TrimSpace(cfg.Endpoint), "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", cfg.Endpoint)
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3: access key and secret required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	return &Client{cfg: cfg, endpoint: u, http: &http.Client{Timeout: cfg.Timeout}, now: time.Now}, nil
+}
+
+// ObjectInfo is what HEAD and GET report about an object.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ETag        string
+}
+
+// Get opens an object for reading. The caller closes the body.
+func (c *Client) Get(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	resp, err := c.do(ctx, http.MethodGet, bucket, key, nil, 0, "", emptySHA256)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return resp.Body, info(resp), nil
+}
+
+// Head returns an object's size and type without reading it.
+func (c *Client) Head(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	resp, err := c.do(ctx, http.MethodHead, bucket, key, nil, 0, "", emptySHA256)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return info(resp), nil
+}
+
+// Put uploads size bytes from body. The payload is sent unsigned (TLS
+// protects it in transit), so body can be streamed from disk.
+func (c *Client) Put(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	resp, err := c.do(ctx, http.MethodPut, bucket, key, body, size, contentType, unsignedPayload)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return ObjectInfo{Size: size, ContentType: contentType, ETag: strings.Trim(resp.Header.Get("ETag"), `"`)}, nil
+}
+
+// PresignGet returns a URL that allows anyone holding it to GET the object
+// until it expires (at most seven days).
+func (c *Client) PresignGet(bucket, key string, expires time.Duration) string {
+	if expires <= 0 || expires > 7*24*time.Hour {
+		expires = time.Hour
+	}
+	u := c.objectURL(bucket, key)
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := c.scope(now)
+
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", c.cfg.AccessKeyID+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if c.cfg.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", c.cfg.SessionToken)
+	}
+	u.RawQuery = canonicalQuery(q)
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + c.signature(now, amzDate, scope, canonical)
+	return u.String()
+}
+
+func (c *Client) do(ctx context.Context, method, bucket, key string, body io.Reader, size int64, contentType, payloadHash string) (*http.Response, error) {
+	u := c.objectURL(bucket, key)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.sign(req, payloadHash)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, &Error{Status: resp.StatusCode, Code: errorCode(b)}
+	}
+	return resp, nil
+}
+
+// sign adds SigV4 Authorization headers to req.
+func (c *Client) sign(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := c.scope(now)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	sig := c.signature(now, amzDate, scope, canonical)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.cfg.AccessKeyID, scope, signed, sig))
+}
+
+func (c *Client) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + c.cfg.Region + "/s3/aws4_request"
+}
+
+func (c *Client) signature(now time.Time, amzDate, scope, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	k := hmacSHA256([]byte("AWS4"+c.cfg.SecretAccessKey), now.Format("20060102"))
+	k = hmacSHA256(k, c.cfg.Region)
+	k = hmacSHA256(k, "s3")
+	k = hmacSHA256(k, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(k, toSign))
+}
+
+func (c *Client) objectURL(bucket, key string) *url.URL {
+	u := *c.endpoint
+	escapedKey := escapePath(key)
+	if c.cfg.PathStyle {
+		u.Path = c.endpoint.Path + "/" + bucket + "/" + key
+		u.RawPath = c.endpoint.Path + "/" + bucket + "/" + escapedKey
+	} else {
+		u.Host = bucket + "." + c.endpoint.Host
+		u.Path = c.endpoint.Path + "/" + key
+		u.RawPath = c.endpoint.Path + "/" + escapedKey
+	}
+	return &u
+}
+
+// escapePath URI-encodes each key segment the way SigV4 expects: everything
+// but unreserved characters, keeping "/".
+func escapePath(key string) string {
+	segs := strings.Split(key, "/")
+	for i, s := range segs {
+		segs[i] = uriEncode(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func info(resp *http.Response) ObjectInfo {
+	ct := resp.Header.Get("Content-Type")
+	if i := strings.Index(ct, ";"); i > 0 {
+		ct = ct[:i]
+	}
+	return ObjectInfo{
+		Size:        resp.ContentLength,
+		ContentType: strings.ToLower(strings.TrimSpace(ct)),
+		ETag:        strings.Trim(resp.Header.Get("ETag"), `"`),
+	}
+}
+
+// errorCode pulls <Code> out of an S3 XML error body.
+func errorCode(body []byte) string {
+	s := string(body)
+	i := strings.Index(s, "<Code>")
+	j := strings.Index(s, "</Code>")
+	if i < 0 || j <= i {
+		return ""
+	}
+	return s[i+len("<Code>") : j]
+}
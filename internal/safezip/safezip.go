@@ -0,0 +1,219 @@
+// Package safezip opens zip containers (OOXML, ODF, EPUB) with limits on
+// entry count, uncompressed size and compression ratio, so a small crafted
+// archive cannot inflate into gigabytes of memory.
+package safezip
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+// ErrLimitsExceeded is wrapped by every limit violation. Errors carry the
+// archive_limits_exceeded code.
+var ErrLimitsExceeded = errors.New("archive limits exceeded")
+
+// Limits bound what an archive may inflate to.
+type Limits struct {
+	MaxEntries    int
+	MaxEntryBytes int64 // uncompressed size of one entry
+	MaxTotalBytes int64 // uncompressed bytes read from the whole archive
+	// MaxRatio caps uncompressed/compressed size for entries of at least
+	// ratioFloor bytes; smaller entries are too small to matter.
+	MaxRatio float64
+}
+
+// DefaultLimits are generous for real documents: a 500-slide deck or a
+// 2,000-chapter EPUB stays well inside them.
+var DefaultLimits = Limits{
+	MaxEntries:    10000,
+	MaxEntryBytes: 128 << 20,
+	MaxTotalBytes: 512 << 20,
+	MaxRatio:      200,
+}
+
+const ratioFloor = 1 << 20
+
+var (
+	limitsMu sync.RWMutex
+	limits   = DefaultLimits
+)
+
+// Configure replaces the process-wide limits. Zero fields keep their
+// defaults.
+func Configure(l Limits) {
+	if l.MaxEntries <= 0 {
+		l.MaxEntries = DefaultLimits.MaxEntries
+	}
+	if l.MaxEntryBytes <= 0 {
+		l.MaxEntryBytes = DefaultLimits.MaxEntryBytes
+	}
+	if l.MaxTotalBytes <= 0 {
+		l.MaxTotalBytes = DefaultLimits.MaxTotalBytes
+	}
+	if l.MaxRatio <= 0 {
+		l.MaxRatio = DefaultLimits.MaxRatio
+	}
+	limitsMu.Lock()
+	limits = l
+	limitsMu.Unlock()
+}
+
+// Current returns the process-wide limits.
+func Current() Limits {
+	limitsMu.RLock()
+	defer limitsMu.RUnlock()
+	return limits
+}
+
+func exceeded(format string, args ...any) error {
+	return extract.WithCode(extract.CodeArchiveLimitsExceeded, fmt.Errorf("%w: "+format, append([]any{ErrLimitsExceeded}, args...)...))
+}
+
+// Reader is a zip.Reader whose entries are read through the limits. The
+// declared sizes are checked when it is opened; the bytes actually inflated
+// are counted as entries are read, since declared sizes can lie.
+type Reader struct {
+	*zip.Reader
+	limits Limits
+	read   atomic.Int64
+}
+
+// ReadCloser is a Reader backed by an open file.
+type ReadCloser struct {
+	*Reader
+	f *os.File
+}
+
+func (rc *ReadCloser) Close() error { return rc.f.Close() }
+
+// OpenReader opens the archive at path.
+func OpenReader(path string) (*ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := NewReader(f, st.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &ReadCloser{Reader: r, f: f}, nil
+}
+
+// NewReader reads the archive directory from ra and checks it against the
+// current limits.
+func NewReader(ra io.ReaderAt, size int64) (*Reader, error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, err
+	}
+	l := Current()
+	if err := Check(zr, l); err != nil {
+		return nil, err
+	}
+	return &Reader{Reader: zr, limits: l}, nil
+}
+
+// Check validates an archive's directory (entry count, declared sizes and
+// ratios) against l. It is exported for packages that hand the file to a
+// library with its own zip handling.
+func Check(zr *zip.Reader, l Limits) error {
+	if len(zr.File) > l.MaxEntries {
+		return exceeded("%d entries (limit %d)", len(zr.File), l.MaxEntries)
+	}
+	var total uint64
+	for _, f := range zr.File {
+		size := f.UncompressedSize64
+		if size > uint64(l.MaxEntryBytes) {
+			return exceeded("entry %q is %d bytes uncompressed (limit %d)", f.Name, size, l.MaxEntryBytes)
+		}
+		if size >= ratioFloor && f.Method != zip.Store {
+			if f.CompressedSize64 == 0 || float64(size)/float64(f.CompressedSize64) > l.MaxRatio {
+				return exceeded("entry %q compression ratio above %.0f", f.Name, l.MaxRatio)
+			}
+		}
+		total += size
+		if total > uint64(l.MaxTotalBytes) {
+			return exceeded("more than %d bytes uncompressed", l.MaxTotalBytes)
+		}
+	}
+	return nil
+}
+
+// CheckFile opens the archive at path only to run Check with the current
+// limits. A file that is not a zip passes, so callers can leave format
+// errors to whatever opens it next.
+func CheckFile(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	return Check(&zr.Reader, Current())
+}
+
+// Open opens f, which must belong to r, for limited reading.
+func (r *Reader) Open(f *zip.File) (io.ReadCloser, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &entryReader{rc: rc, r: r, name: f.Name, left: r.limits.MaxEntryBytes}, nil
+}
+
+// ReadFile returns the contents of the entry called name.
+func (r *Reader) ReadFile(name string) ([]byte, error) {
+	f := r.Lookup(name)
+	if f == nil {
+		return nil, fmt.Errorf("missing %s", name)
+	}
+	rc, err := r.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Lookup returns the entry called name, or nil.
+func (r *Reader) Lookup(name string) *zip.File {
+	for _, f := range r.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+type entryReader struct {
+	rc   io.ReadCloser
+	r    *Reader
+	name string
+	left int64
+}
+
+func (e *entryReader) Read(p []byte) (int, error) {
+	n, err := e.rc.Read(p)
+	e.left -= int64(n)
+	if e.left < 0 {
+		return n, exceeded("entry %q inflates past %d bytes", e.name, e.r.limits.MaxEntryBytes)
+	}
+	if e.r.read.Add(int64(n)) > e.r.limits.MaxTotalBytes {
+		return n, exceeded("more than %d bytes inflated", e.r.limits.MaxTotalBytes)
+	}
+	return n, err
+}
+
+func (e *entryReader) Close() error { return e.rc.Close() }
@@ -0,0 +1,84 @@
+package safezip
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+func archive(t *testing.T, entries map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func open(t *testing.T, l Limits, entries map[string]string) (*Reader, error) {
+	t.Helper()
+	Configure(l)
+	t.Cleanup(func() { Configure(DefaultLimits) })
+	r := archive(t, entries)
+	return NewReader(r, r.Size())
+}
+
+func wantExceeded(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, ErrLimitsExceeded) || extract.ErrorCode(err) != extract.CodeArchiveLimitsExceeded {
+		t.Fatalf("want archive_limits_exceeded, got %v", err)
+	}
+}
+
+func TestRejectsHighRatio(t *testing.T) {
+	// 8MiB of zeros deflates to a few KiB.
+	_, err := open(t, DefaultLimits, map[string]string{"word/document.xml": strings.Repeat("\x00", 8<<20)})
+	wantExceeded(t, err)
+}
+
+func TestRejectsTooManyEntries(t *testing.T) {
+	entries := map[string]string{}
+	for _, n := range []string{"a", "b", "c", "d"} {
+		entries[n] = n
+	}
+	_, err := open(t, Limits{MaxEntries: 3}, entries)
+	wantExceeded(t, err)
+}
+
+func TestRejectsDeclaredSizes(t *testing.T) {
+	_, err := open(t, Limits{MaxEntryBytes: 100}, map[string]string{"a": strings.Repeat("x", 101)})
+	wantExceeded(t, err)
+
+	_, err = open(t, Limits{MaxTotalBytes: 150}, map[string]string{"a": strings.Repeat("x", 100), "b": strings.Repeat("y", 100)})
+	wantExceeded(t, err)
+}
+
+func TestCountsInflatedBytesAcrossReads(t *testing.T) {
+	r, err := open(t, Limits{MaxTotalBytes: 250}, map[string]string{"a": strings.Repeat("x", 200)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.ReadFile("a")
+	if err != nil || len(b) != 200 {
+		t.Fatalf("first read: %d bytes, %v", len(b), err)
+	}
+	// Re-reading the same entry still counts against the archive budget.
+	_, err = r.ReadFile("a")
+	wantExceeded(t, err)
+
+	if _, err := r.ReadFile("missing"); err == nil || errors.Is(err, ErrLimitsExceeded) {
+		t.Fatalf("missing entry: got %v", err)
+	}
+}
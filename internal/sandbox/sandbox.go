@@ -0,0 +1,333 @@
+// Package sandbox runs external tools (poppler, LibreOffice, ffmpeg,
+// tesseract, whisper) on untrusted input. Each tool runs in its own process
+// group, and the whole group is killed when the context ends. On Linux a
+// tool is also killed when the service exits. Tools can also run under
+// rlimits, as an unprivileged user, and in an empty network namespace.
+// Per-tool resource usage is kept for /metrics.
+//
+// rlimits cannot be set on a child through os/exec. When limits are
+// configured, the service re-executes itself as a small helper. The helper
+// lowers its own limits and then execs the tool, so the binary's main must
+// call Init first.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Limits are applied to each tool process. Zero leaves a limit unset.
+type Limits struct {
+	MemoryBytes uint64 // RLIMIT_AS
+	CPUSeconds  uint64 // RLIMIT_CPU
+	FileBytes   uint64 // RLIMIT_FSIZE: largest file the tool may write
+	OpenFiles   uint64 // RLIMIT_NOFILE
+}
+
+func (l Limits) zero() bool { return l == Limits{} }
+
+// Config is the process-wide sandbox policy.
+type Config struct {
+	Limits
+	// UID and GID, when UID is non-zero, are the unprivileged user tools run
+	// as. The service must run as root to switch users.
+	UID, GID uint32
+	// IsolateNetwork runs tools in a new, empty network namespace (Linux,
+	// root only). None of the tools needs the network.
+	IsolateNetwork bool
+	// LogUsage writes one line per invocation with its resource usage.
+	LogUsage bool
+}
+
+// envHelper marks the re-executed helper. Its value holds the limits.
+const envHelper = "FILEPROC_SANDBOX_RLIMITS"
+
+var (
+	cfgMu sync.RWMutex
+	cfg   Config
+	self  string
+)
+
+// Configure installs c for all later Commands.
+func Configure(c Config) error {
+	if (c.UID != 0 || c.IsolateNetwork) && os.Geteuid() != 0 {
+		return errors.New("sandbox: dropping privileges and network isolation require running as root")
+	}
+	if c.IsolateNetwork && !netnsSupported {
+		return errors.New("sandbox: network isolation is only supported on Linux")
+	}
+	exe := ""
+	if !c.Limits.zero() {
+		var err error
+		if exe, err = os.Executable(); err != nil {
+			return fmt.Errorf("sandbox: locate own executable for rlimit helper: %w", err)
+		}
+	}
+	cfgMu.Lock()
+	cfg, self = c, exe
+	cfgMu.Unlock()
+	return nil
+}
+
+func current() (Config, string) {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg, self
+}
+
+// Init turns the process into the rlimit helper when it was started as one,
+// and never returns in that case. Call it first thing in main.
+func Init() {
+	spec, ok := os.LookupEnv(envHelper)
+	if !ok {
+		return
+	}
+	os.Unsetenv(envHelper)
+	if err := applyLimits(spec); err != nil {
+		fmt.Fprintf(os.Stderr, "[sandbox] %v\n", err)
+		os.Exit(126)
+	}
+	if len(os.Args) < 2 {
+		os.Exit(127)
+	}
+	err := syscall.Exec(os.Args[1], os.Args[1:], os.Environ())
+	fmt.Fprintf(os.Stderr, "[sandbox] exec %s: %v\n", os.Args[1], err)
+	os.Exit(127)
+}
+
+var limitResources = []int{syscall.RLIMIT_AS, syscall.RLIMIT_CPU, syscall.RLIMIT_FSIZE, syscall.RLIMIT_NOFILE}
+
+func (l Limits) encode() string {
+	return fmt.Sprintf("%d,%d,%d,%d", l.MemoryBytes, l.CPUSeconds, l.FileBytes, l.OpenFiles)
+}
+
+func applyLimits(spec string) error {
+	parts := strings.Split(spec, ",")
+	if len(parts) != len(limitResources) {
+		return fmt.Errorf("bad limits %q", spec)
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad limits %q", spec)
+		}
+		if v == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(limitResources[i], &syscall.Rlimit{Cur: v, Max: v}); err != nil {
+			return fmt.Errorf("setrlimit: %w", err)
+		}
+	}
+	return nil
+}
+
+// Cmd is an exec.Cmd that runs sandboxed and records its resource usage
+// when it finishes. Use its Run, Output, CombinedOutput or Start/Wait
+// rather than the embedded Cmd's, which would skip the recording.
+type Cmd struct {
+	*exec.Cmd
+	tool  string
+	start time.Time
+	ctx   context.Context
+	usage Usage
+}
+
+// Command is exec.CommandContext for tools that process untrusted input.
+func Command(ctx context.Context, name string, args ...string) *Cmd {
+	c, _ := current()
+	return command(ctx, c.Limits, name, args)
+}
+
+// Daemon is Command for long-lived servers such as LibreOffice listeners.
+// RLIMIT_CPU is not applied: it counts the CPU time of every job the server
+// handles, and would kill it mid-job.
+func Daemon(ctx context.Context, name string, args ...string) *Cmd {
+	c, _ := current()
+	limits := c.Limits
+	limits.CPUSeconds = 0
+	return command(ctx, limits, name, args)
+}
+
+func command(ctx context.Context, limits Limits, name string, args []string) *Cmd {
+	c, exe := current()
+	cmd := exec.CommandContext(ctx, name, args...)
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	dieWithParent(attr)
+	env := os.Environ()
+	if c.UID != 0 {
+		attr.Credential = &syscall.Credential{Uid: c.UID, Gid: c.GID}
+		grantPaths(args, c.GID)
+		// The service's HOME is not writable by the sandbox user, and
+		// LibreOffice keeps its profile there.
+		env = append(env, "HOME="+os.TempDir())
+		cmd.Env = env
+	}
+	if c.IsolateNetwork {
+		isolateNetwork(attr)
+	}
+	cmd.SysProcAttr = attr
+
+	if exe != "" && !limits.zero() {
+		// Run through the helper: argv is [self, tool path, args...].
+		if path, err := exec.LookPath(name); err == nil {
+			cmd.Path = exe
+			cmd.Args = append([]string{exe, path}, args...)
+			cmd.Env = append(env, envHelper+"="+limits.encode())
+		}
+	}
+
+	// Kill the whole group so children such as soffice.bin go too, and
+	// stop waiting on pipes they may still hold open.
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	return &Cmd{Cmd: cmd, tool: filepath.Base(name), ctx: ctx}
+}
+
+// Grant gives the sandbox user access to paths that tools use but that do
+// not appear in their arguments, such as a profile directory. It does
+// nothing unless tools run as a separate user.
+func Grant(paths ...string) {
+	if c, _ := current(); c.UID != 0 {
+		for _, p := range paths {
+			grant(p, c.GID)
+		}
+	}
+}
+
+// grantPaths gives the sandbox group access to the absolute paths among
+// args and the directories holding them (for output files the tool will
+// create). Work files live in 0700 temp directories owned by the service;
+// only paths inside the temp directory are changed, so configured files
+// such as models keep their permissions.
+func grantPaths(args []string, gid uint32) {
+	tmp := filepath.Clean(os.TempDir())
+	for _, a := range args {
+		if !filepath.IsAbs(a) {
+			continue
+		}
+		for _, p := range []string{filepath.Dir(a), a} {
+			if inside(tmp, p) {
+				grant(p, gid)
+			}
+		}
+	}
+}
+
+// inside reports whether p is strictly below dir.
+func inside(dir, p string) bool {
+	rel, err := filepath.Rel(dir, filepath.Clean(p))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func grant(p string, gid uint32) {
+	st, err := os.Stat(p)
+	if err != nil {
+		return
+	}
+	mode := st.Mode().Perm() | 0o060
+	if st.IsDir() {
+		mode |= 0o010
+	}
+	os.Chown(p, -1, int(gid))
+	os.Chmod(p, mode)
+}
+
+func (c *Cmd) Start() error {
+	c.start = time.Now()
+	return c.Cmd.Start()
+}
+
+func (c *Cmd) Wait() error {
+	err := c.Cmd.Wait()
+	c.record(err)
+	return err
+}
+
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Output runs the command and returns its stdout. As with exec.Cmd, a
+// non-zero exit reports stderr in the *exec.ExitError.
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	captureErr := c.Stderr == nil
+	if captureErr {
+		c.Stderr = &stderr
+	}
+	err := c.Run()
+	var ee *exec.ExitError
+	if captureErr && errors.As(err, &ee) {
+		ee.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil || c.Stderr != nil {
+		return nil, errors.New("exec: Stdout or Stderr already set")
+	}
+	var b bytes.Buffer
+	c.Stdout = &b
+	c.Stderr = &b
+	err := c.Run()
+	return b.Bytes(), err
+}
+
+// Usage is the resource usage of one finished invocation.
+type Usage struct {
+	Tool     string
+	Wall     time.Duration
+	User     time.Duration
+	System   time.Duration
+	MaxRSSKB int64
+	ExitCode int
+	Killed   bool // by the context (timeout or cancellation) or a signal
+}
+
+// Usage reports the finished command's resource usage.
+func (c *Cmd) Usage() Usage { return c.usage }
+
+func (c *Cmd) record(err error) {
+	u := Usage{Tool: c.tool, Wall: time.Since(c.start), ExitCode: -1}
+	if ps := c.ProcessState; ps != nil {
+		u.ExitCode = ps.ExitCode()
+		u.User, u.System = ps.UserTime(), ps.SystemTime()
+		if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+			u.MaxRSSKB = maxRSSKB(ru)
+		}
+		if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			u.Killed = true
+		}
+	}
+	if c.ctx.Err() != nil {
+		u.Killed = true
+	}
+	c.usage = u
+	stats.add(u, err != nil)
+
+	if conf, _ := current(); conf.LogUsage {
+		fmt.Fprintf(os.Stderr, "[sandbox] tool=%s exit=%d killed=%t wall=%s user=%s sys=%s maxrss=%dKB\n",
+			u.Tool, u.ExitCode, u.Killed, u.Wall.Round(time.Millisecond), u.User.Round(time.Millisecond), u.System.Round(time.Millisecond), u.MaxRSSKB)
+	}
+}
@@ -0,0 +1,18 @@
+package sandbox
+
+import "syscall"
+
+const netnsSupported = true
+
+func isolateNetwork(attr *syscall.SysProcAttr) {
+	attr.Cloneflags |= syscall.CLONE_NEWNET
+}
+
+// dieWithParent kills the tool when the service exits, so long-lived
+// children such as LibreOffice listeners are never orphaned.
+func dieWithParent(attr *syscall.SysProcAttr) {
+	attr.Pdeathsig = syscall.SIGKILL
+}
+
+// Linux reports ru_maxrss in kilobytes.
+func maxRSSKB(ru *syscall.Rusage) int64 { return ru.Maxrss }
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"runtime"
+	"syscall"
+)
+
+const netnsSupported = false
+
+func isolateNetwork(*syscall.SysProcAttr) {}
+
+func dieWithParent(*syscall.SysProcAttr) {}
+
+// macOS reports ru_maxrss in bytes, the BSDs in kilobytes.
+func maxRSSKB(ru *syscall.Rusage) int64 {
+	if runtime.GOOS == "darwin" {
+		return int64(ru.Maxrss) / 1024
+	}
+	return int64(ru.Maxrss)
+}
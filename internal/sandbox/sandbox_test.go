@@ -0,0 +1,115 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// The test binary doubles as the rlimit helper, as the server does.
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
+func TestTimeoutKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	// The shell's background child is what used to survive a timeout.
+	cmd := Command(ctx, "sh", "-c", "sleep 30 & echo $! > "+pidFile+"; wait")
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected the command to be killed")
+	}
+	if u := cmd.Usage(); !u.Killed || u.Tool != "sh" {
+		t.Fatalf("usage = %+v", u)
+	}
+
+	b, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	deadline := time.Now().Add(2 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("grandchild %d survived the timeout", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if s := Snapshot()["sh"]; s.Runs == 0 || s.Killed == 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
+
+// alive treats zombies as dead: an orphan's reaper may be slow to collect it.
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+func TestLimitsAppliedThroughHelper(t *testing.T) {
+	if err := Configure(Config{Limits: Limits{OpenFiles: 64, FileBytes: 1 << 20}}); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(Config{})
+
+	out, err := Command(context.Background(), "sh", "-c", "ulimit -n; ulimit -f").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ulimit -f reports 512-byte blocks in POSIX shells, KiB in bash.
+	got := strings.Fields(string(out))
+	if len(got) != 2 || got[0] != "64" || (got[1] != "2048" && got[1] != "1024") {
+		t.Fatalf("limits in child: %q", out)
+	}
+}
+
+func TestDaemonHasNoCPULimit(t *testing.T) {
+	if err := Configure(Config{Limits: Limits{CPUSeconds: 30, OpenFiles: 64}}); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(Config{})
+
+	for _, tc := range []struct {
+		cmd  func(context.Context, string, ...string) *Cmd
+		want string
+	}{{Command, "30 64"}, {Daemon, "unlimited 64"}} {
+		out, err := tc.cmd(context.Background(), "sh", "-c", "ulimit -t; ulimit -n").Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(strings.Fields(string(out)), " "); got != tc.want {
+			t.Errorf("limits in child: got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestGrantStaysInsideTempDir(t *testing.T) {
+	for p, want := range map[string]bool{
+		"/tmp/fileproc-1/in.pdf": true,
+		"/tmp/fileproc-1":        true,
+		"/tmp":                   false,
+		"/tmp/../etc/passwd":     false,
+		"/opt/models/ggml.bin":   false,
+		"/tmpfoo/x":              false,
+	} {
+		if got := inside("/tmp", p); got != want {
+			t.Errorf("inside(/tmp, %s) = %t, want %t", p, got, want)
+		}
+	}
+}
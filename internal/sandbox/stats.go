@@ -0,0 +1,52 @@
+package sandbox
+
+import "sync"
+
+// ToolStats are running totals for one tool.
+type ToolStats struct {
+	Runs     int64 `json:"runs"`
+	Failures int64 `json:"failures"`
+	Killed   int64 `json:"killed"`
+	WallMs   int64 `json:"wallMs"`
+	CPUMs    int64 `json:"cpuMs"`
+	// MaxRSSKB is the largest peak resident set seen for the tool.
+	MaxRSSKB int64 `json:"maxRssKb"`
+}
+
+type toolStats struct {
+	mu sync.Mutex
+	m  map[string]*ToolStats
+}
+
+var stats = &toolStats{m: map[string]*ToolStats{}}
+
+func (s *toolStats) add(u Usage, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.m[u.Tool]
+	if t == nil {
+		t = &ToolStats{}
+		s.m[u.Tool] = t
+	}
+	t.Runs++
+	if failed {
+		t.Failures++
+	}
+	if u.Killed {
+		t.Killed++
+	}
+	t.WallMs += u.Wall.Milliseconds()
+	t.CPUMs += (u.User + u.System).Milliseconds()
+	t.MaxRSSKB = max(t.MaxRSSKB, u.MaxRSSKB)
+}
+
+// Snapshot returns per-tool totals since startup.
+func Snapshot() map[string]ToolStats {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	out := make(map[string]ToolStats, len(stats.m))
+	for k, v := range stats.m {
+		out[k] = *v
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package signing
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func signed(ts time.Time, nonce string, body []byte) http.Header {
+	h := http.Header{}
+	h.Set(HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+	h.Set(HeaderNonce, nonce)
+	h.Set(HeaderSignature, Sign(secret, "POST", "/extract", ts.Unix(), nonce, "", body))
+	return h
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	v := NewVerifier(secret, 5*time.Minute)
+	v.now = func() time.Time { return now }
+	body := []byte(`{"presignedUrl":"https://example.com/a.pdf"}`)
+
+	h := signed(now, "nonce-0000000001", body)
+	if err := v.Verify("POST", "/extract", h, body); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+	if err := v.Verify("POST", "/extract", h, body); !errors.Is(err, ErrReplay) {
+		t.Fatalf("replay: want ErrReplay, got %v", err)
+	}
+
+	h = signed(now, "nonce-0000000002", body)
+	if err := v.Verify("POST", "/extract", h, []byte(`{}`)); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("tampered body: want ErrBadSignature, got %v", err)
+	}
+	if err := v.Verify("POST", "/preview", h, body); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("other path: want ErrBadSignature, got %v", err)
+	}
+	// A failed verification must not burn the nonce.
+	if err := v.Verify("POST", "/extract", h, body); err != nil {
+		t.Fatalf("nonce burned by forged request: %v", err)
+	}
+
+	h = signed(now, "nonce-0000000004", body)
+	h.Set(HeaderClientID, "203.0.113.7")
+	if err := v.Verify("POST", "/extract", h, body); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("added client ID: want ErrBadSignature, got %v", err)
+	}
+	h.Set(HeaderSignature, Sign(secret, "POST", "/extract", now.Unix(), "nonce-0000000004", "203.0.113.7", body))
+	if err := v.Verify("POST", "/extract", h, body); err != nil {
+		t.Fatalf("signed client ID: %v", err)
+	}
+
+	h = signed(now.Add(-6*time.Minute), "nonce-0000000003", body)
+	if err := v.Verify("POST", "/extract", h, body); !errors.Is(err, ErrSkew) {
+		t.Fatalf("stale: want ErrSkew, got %v", err)
+	}
+
+	h = signed(now, "short", body)
+	if err := v.Verify("POST", "/extract", h, body); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("short nonce: want ErrMalformed, got %v", err)
+	}
+}
+
+func TestSweepDropsExpiredNonces(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	v := NewVerifier(secret, time.Minute)
+	v.now = func() time.Time { return now }
+	if err := v.Verify("POST", "/extract", signed(now, "nonce-0000000001", nil), nil); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	v.Sweep()
+	if len(v.nonces) != 0 {
+		t.Fatalf("nonces = %d, want 0", len(v.nonces))
+	}
+}
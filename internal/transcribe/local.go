@@ -0,0 +1,111 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/sandbox"
+	"github.com/toricodesthings/file-processing-service/internal/usage"
+)
+
+// Provider names usable in the transcription failover chain.
+const (
+	ProviderGroq    = "groq"
+	ProviderWhisper = "whisper"
+)
+
+// LocalWhisper runs the openai-whisper CLI on the local machine as a
+// transcription fallback. It reads the audio from disk (whisper decodes it
+// with ffmpeg) and produces the same Response shape as the Groq client.
+type LocalWhisper struct {
+	binary  string
+	model   string
+	timeout time.Duration
+}
+
+func NewLocalWhisper(binary, model string, timeout time.Duration) *LocalWhisper {
+	if strings.TrimSpace(binary) == "" {
+		binary = "whisper"
+	}
+	if strings.TrimSpace(model) == "" {
+		model = "base"
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	return &LocalWhisper{binary: binary, model: model, timeout: timeout}
+}
+
+// Model returns the whisper model name used for every call.
+func (w *LocalWhisper) Model() string { return w.model }
+
+// Transcribe transcribes the audio file at path. opts.Model and
+// opts.ResponseFormat are ignored: the local model is fixed by configuration
+// and output is always read back as JSON.
+func (w *LocalWhisper) Transcribe(ctx context.Context, path string, opts Options) (Response, error) {
+	if strings.TrimSpace(path) == "" {
+		return Response{}, errors.New("audio path required")
+	}
+
+	outDir, err := os.MkdirTemp(filepath.Dir(path), "whisper-")
+	if err != nil {
+		return Response{}, err
+	}
+	defer os.RemoveAll(outDir)
+
+	args := []string{path,
+		"--model", w.model,
+		"--output_format", "json",
+		"--output_dir", outDir,
+		"--fp16", "False",
+		"--verbose", "False",
+	}
+	if lang := strings.TrimSpace(opts.Language); lang != "" {
+		args = append(args, "--language", lang)
+	}
+	if prompt := strings.TrimSpace(opts.Prompt); prompt != "" {
+		args = append(args, "--initial_prompt", prompt)
+	}
+	if opts.Temperature != nil {
+		args = append(args, "--temperature", fmt.Sprintf("%g", *opts.Temperature))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := sandbox.Command(ctx, w.binary, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Response{}, fmt.Errorf("whisper timed out after %s", w.timeout)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 300 {
+			msg = msg[len(msg)-300:]
+		}
+		return Response{}, fmt.Errorf("whisper: %w: %s", err, msg)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	b, err := os.ReadFile(filepath.Join(outDir, base+".json"))
+	if err != nil {
+		return Response{}, fmt.Errorf("whisper output missing: %w", err)
+	}
+	var out Response
+	if err := json.Unmarshal(b, &out); err != nil {
+		return Response{}, fmt.Errorf("decode whisper output: %w", err)
+	}
+	if n := len(out.Segments); n > 0 && out.Duration == 0 {
+		out.Duration = out.Segments[n-1].End
+	}
+	usage.Record(ctx, usage.Entry{Capability: usage.Transcription, Provider: ProviderWhisper, Model: w.model, Seconds: out.Duration})
+	return out, nil
+}
@@ -11,6 +11,10 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/gateway"
+	"github.com/toricodesthings/file-processing-service/internal/resilience"
+	"github.com/toricodesthings/file-processing-service/internal/usage"
 )
 
 const (
@@ -18,6 +22,14 @@ const (
 	defaultModel   = "whisper-large-v3-turbo"
 )
 
+// retryPolicy keeps retries short: uploads can be large and Groq's 429s
+// usually carry a Retry-After that sets the real delay.
+var retryPolicy = resilience.Policy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    10 * time.Second,
+}
+
 var ErrAPIKeyMissing = errors.New("GROQ_API_KEY not set")
 
 type Client struct {
@@ -51,6 +63,7 @@ type APIError struct {
 	StatusCode int
 	Type       string
 	Message    string
+	RetryAfter time.Duration // from the Retry-After header, if any
 }
 
 func (e *APIError) Error() string {
@@ -60,6 +73,10 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("groq %d: %s", e.StatusCode, e.Message)
 }
 
+// HTTPStatus and RetryAfterHint let resilience.Do classify the error.
+func (e *APIError) HTTPStatus() int               { return e.StatusCode }
+func (e *APIError) RetryAfterHint() time.Duration { return e.RetryAfter }
+
 type groqErrorResponse struct {
 	Error struct {
 		Message string `json:"message"`
@@ -119,14 +136,39 @@ func (c *Client) Transcribe(ctx context.Context, fileName string, fileContent []
 	}
 	_ = writer.Close()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL, body)
+	payload := body.Bytes()
+	contentType := writer.FormDataContentType()
+
+	var out Response
+	err = resilience.Do(ctx, gateway.Groq, retryPolicy, func(ctx context.Context) error {
+		var err error
+		out, err = c.send(ctx, payload, contentType)
+		return err
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	usage.Record(ctx, usage.Entry{Capability: usage.Transcription, Provider: ProviderGroq, Model: model, Seconds: out.Duration})
+	return out, nil
+}
+
+// send performs one transcription request. The multipart payload is rebuilt
+// into a fresh reader so retries resend the full body.
+func (c *Client) send(ctx context.Context, payload []byte, contentType string) (Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL, bytes.NewReader(payload))
 	if err != nil {
 		return Response{}, err
 	}
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("User-Agent", "fileproc/2.0")
 
+	release, err := gateway.Acquire(ctx, gateway.Groq)
+	if err != nil {
+		return Response{}, err
+	}
+	defer release()
+
 	httpClient := &http.Client{Timeout: c.timeout}
 	resp, err := httpClient.Do(req)
 	if err != nil {
@@ -140,7 +182,7 @@ func (c *Client) Transcribe(ctx context.Context, fileName string, fileContent []
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return Response{}, parseAPIError(resp.StatusCode, bodyBytes)
+		return Response{}, parseAPIError(resp.StatusCode, resp.Header, bodyBytes)
 	}
 
 	var out Response
@@ -150,10 +192,12 @@ func (c *Client) Transcribe(ctx context.Context, fileName string, fileContent []
 	return out, nil
 }
 
-func parseAPIError(statusCode int, body []byte) error {
+func parseAPIError(statusCode int, header http.Header, body []byte) error {
+	retryAfter := resilience.ParseRetryAfter(header.Get("Retry-After"))
+
 	var parsed groqErrorResponse
 	if err := json.Unmarshal(body, &parsed); err == nil && strings.TrimSpace(parsed.Error.Message) != "" {
-		return &APIError{StatusCode: statusCode, Type: strings.TrimSpace(parsed.Error.Type), Message: parsed.Error.Message}
+		return &APIError{StatusCode: statusCode, Type: strings.TrimSpace(parsed.Error.Type), Message: parsed.Error.Message, RetryAfter: retryAfter}
 	}
 
 	msg := strings.TrimSpace(string(body))
@@ -163,5 +207,5 @@ func parseAPIError(statusCode int, body []byte) error {
 	if len(msg) > 300 {
 		msg = msg[:300] + "..."
 	}
-	return &APIError{StatusCode: statusCode, Message: msg}
+	return &APIError{StatusCode: statusCode, Message: msg, RetryAfter: retryAfter}
 }
@@ -11,6 +11,9 @@ type HybridProcessorOptions struct {
 	ExtractFooter bool    `json:"extractFooter"`
 	OCRModel      *string `json:"ocrModel"`
 
+	// Password for encrypted PDFs. Never serialized or logged.
+	Password string `json:"-"`
+
 	// Preview-only knobs (text-layer only)
 	PreviewMaxPages int `json:"previewMaxPages"` // default e.g. 8
 	PreviewMaxChars int `json:"previewMaxChars"` // default e.g. 20000
@@ -23,8 +26,9 @@ type ExtractRequest struct {
 
 type PageExtractionResult struct {
 	PageNumber int    `json:"pageNumber"`
+	PageLabel  string `json:"pageLabel,omitempty"` // printed label from /PageLabels (e.g. "iv")
 	Text       string `json:"text"`
-	Method     string `json:"method"` // "text-layer" | "ocr"
+	Method     string `json:"method"` // "text-layer" | "ocr:<provider>" | "needs-ocr"
 	WordCount  int    `json:"wordCount"`
 }
 
@@ -36,7 +40,9 @@ type HybridExtractionResult struct {
 	TextLayerPages     int                    `json:"textLayerPages"`
 	OCRPages           int                    `json:"ocrPages"`
 	CostSavingsPercent int                    `json:"costSavingsPercent"`
+	Metadata           map[string]string      `json:"metadata,omitempty"` // pdfinfo document properties
 	Error              *string                `json:"error,omitempty"`
+	Code               string                 `json:"code,omitempty"`
 }
 
 type PreviewResult struct {
@@ -46,7 +52,9 @@ type PreviewResult struct {
 	WordCount      int     `json:"wordCount"`
 	TotalPages     int     `json:"totalPages"`
 	TextLayerPages int     `json:"textLayerPages"`
+	SampledPages   int     `json:"sampledPages"` // leading pages inspected (≤ PreviewMaxPages)
 	Error          *string `json:"error,omitempty"`
+	Code           string  `json:"code,omitempty"`
 }
 
 // ── Image extraction types ───────────────────────────────────────────────────
@@ -61,5 +69,7 @@ type ImageExtractionResult struct {
 	Method      string  `json:"method,omitempty"`      // "ocr" | "vision" | "ocr+vision"
 	ImageType   string  `json:"imageType,omitempty"`   // "handwriting" | "photo" | "diagram" | etc.
 	Description string  `json:"description,omitempty"` // Vision-generated description (present when vision ran)
+	OCRProvider string  `json:"ocrProvider,omitempty"` // OCR provider that produced Text ("mistral" | "tesseract")
+	VisionModel string  `json:"visionModel,omitempty"` // Vision model that classified the image
 	Error       *string `json:"error,omitempty"`
 }
@@ -0,0 +1,234 @@
+// Package usage records the paid provider work done while serving a request
+// (OCR pages, vision tokens, transcription seconds), prices it from a
+// configurable table and keeps per-client totals for /metrics.
+//
+// Provider clients call Record with the request context; the HTTP handler
+// attaches a Recorder with WithRecorder and reads it back with Summary once
+// extraction is done.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/toricodesthings/file-processing-service/internal/extract"
+)
+
+// Capabilities.
+const (
+	OCR           = "ocr"
+	Vision        = "vision"
+	Transcription = "transcription"
+)
+
+// Entry is one provider call (or a batch of pages in one call).
+type Entry struct {
+	Capability   string
+	Provider     string
+	Model        string
+	Pages        int
+	InputTokens  int
+	OutputTokens int
+	Seconds      float64
+}
+
+// Price is the cost of one provider or provider/model. All fields are USD.
+type Price struct {
+	PerCall                float64 `json:"perCall,omitempty"`
+	PerPage                float64 `json:"perPage,omitempty"`
+	InputPerMillionTokens  float64 `json:"inputPerMillionTokens,omitempty"`
+	OutputPerMillionTokens float64 `json:"outputPerMillionTokens,omitempty"`
+	PerAudioHour           float64 `json:"perAudioHour,omitempty"`
+}
+
+// PriceTable maps "provider/model" or "provider" to a price. The more
+// specific key wins; providers with no entry (e.g. local tesseract) are free.
+type PriceTable map[string]Price
+
+// DefaultPrices are list prices at the time of writing; override them with
+// PRICE_TABLE_FILE.
+var DefaultPrices = PriceTable{
+	"mistral":    {PerPage: 0.001},
+	"openrouter": {InputPerMillionTokens: 0.10, OutputPerMillionTokens: 0.30},
+	"groq":       {PerAudioHour: 0.04},
+}
+
+// LoadPrices reads a JSON price table from path and layers it over
+// DefaultPrices. An empty path returns the defaults.
+func LoadPrices(path string) (PriceTable, error) {
+	out := PriceTable{}
+	for k, v := range DefaultPrices {
+		out[k] = v
+	}
+	if path == "" {
+		return out, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read price table: %w", err)
+	}
+	var custom PriceTable
+	if err := json.Unmarshal(b, &custom); err != nil {
+		return nil, fmt.Errorf("parse price table: %w", err)
+	}
+	for k, v := range custom {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (t PriceTable) lookup(provider, model string) Price {
+	if p, ok := t[provider+"/"+model]; ok && model != "" {
+		return p
+	}
+	return t[provider]
+}
+
+// Cost prices one usage item.
+func (t PriceTable) Cost(it extract.UsageItem) float64 {
+	p := t.lookup(it.Provider, it.Model)
+	c := p.PerCall*float64(it.Calls) +
+		p.PerPage*float64(it.Pages) +
+		p.InputPerMillionTokens*float64(it.InputTokens)/1e6 +
+		p.OutputPerMillionTokens*float64(it.OutputTokens)/1e6 +
+		p.PerAudioHour*it.Seconds/3600
+	return roundUSD(c)
+}
+
+func roundUSD(v float64) float64 { return math.Round(v*1e6) / 1e6 }
+
+// ── Per-request recorder ────────────────────────────────────────────────────
+
+type itemKey struct{ capability, provider, model string }
+
+// Recorder accumulates entries for one request. It is safe for concurrent
+// use by page and figure workers.
+type Recorder struct {
+	mu    sync.Mutex
+	items map[itemKey]*extract.UsageItem
+}
+
+type ctxKey struct{}
+
+// WithRecorder returns a context whose provider calls are recorded into the
+// returned Recorder.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	r := &Recorder{items: map[itemKey]*extract.UsageItem{}}
+	return context.WithValue(ctx, ctxKey{}, r), r
+}
+
+// Record adds e to the context's Recorder, if any.
+func Record(ctx context.Context, e Entry) {
+	r, _ := ctx.Value(ctxKey{}).(*Recorder)
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := itemKey{e.Capability, e.Provider, e.Model}
+	it := r.items[k]
+	if it == nil {
+		it = &extract.UsageItem{Capability: e.Capability, Provider: e.Provider, Model: e.Model}
+		r.items[k] = it
+	}
+	it.Calls++
+	it.Pages += e.Pages
+	it.InputTokens += e.InputTokens
+	it.OutputTokens += e.OutputTokens
+	it.Seconds += e.Seconds
+}
+
+// Summary prices the recorded items. It returns nil when nothing was
+// recorded, so free extractions carry no usage block.
+func (r *Recorder) Summary(prices PriceTable) *extract.Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.items) == 0 {
+		return nil
+	}
+	u := &extract.Usage{}
+	for _, it := range r.items {
+		item := *it
+		item.CostUSD = prices.Cost(item)
+		u.Items = append(u.Items, item)
+		u.OCRPages += item.Pages
+		u.VisionInputTokens += item.InputTokens
+		u.VisionOutputTokens += item.OutputTokens
+		u.TranscriptionSeconds += item.Seconds
+		u.EstimatedCostUSD += item.CostUSD
+	}
+	u.EstimatedCostUSD = roundUSD(u.EstimatedCostUSD)
+	sort.Slice(u.Items, func(i, j int) bool {
+		a, b := u.Items[i], u.Items[j]
+		if a.Capability != b.Capability {
+			return a.Capability < b.Capability
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.Model < b.Model
+	})
+	return u
+}
+
+// ── Per-client totals ───────────────────────────────────────────────────────
+
+// ClientTotals aggregates usage across all requests of one API client.
+type ClientTotals struct {
+	Requests             int64   `json:"requests"`
+	OCRPages             int64   `json:"ocrPages"`
+	VisionInputTokens    int64   `json:"visionInputTokens"`
+	VisionOutputTokens   int64   `json:"visionOutputTokens"`
+	TranscriptionSeconds float64 `json:"transcriptionSeconds"`
+	EstimatedCostUSD     float64 `json:"estimatedCostUsd"`
+}
+
+// maxClients bounds the per-client map; further clients are pooled under
+// "other".
+const maxClients = 1000
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[string]*ClientTotals{}
+)
+
+// AddClient counts one request for client and adds its usage (which may be
+// nil).
+func AddClient(client string, u *extract.Usage) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	t := clients[client]
+	if t == nil && len(clients) >= maxClients {
+		client = "other"
+		t = clients[client]
+	}
+	if t == nil {
+		t = &ClientTotals{}
+		clients[client] = t
+	}
+	t.Requests++
+	if u == nil {
+		return
+	}
+	t.OCRPages += int64(u.OCRPages)
+	t.VisionInputTokens += int64(u.VisionInputTokens)
+	t.VisionOutputTokens += int64(u.VisionOutputTokens)
+	t.TranscriptionSeconds += u.TranscriptionSeconds
+	t.EstimatedCostUSD = roundUSD(t.EstimatedCostUSD + u.EstimatedCostUSD)
+}
+
+// ClientSnapshot returns totals per client for /metrics.
+func ClientSnapshot() map[string]ClientTotals {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	out := make(map[string]ClientTotals, len(clients))
+	for k, v := range clients {
+		out[k] = *v
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRecorderSummary(t *testing.T) {
+	ctx, rec := WithRecorder(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Record(ctx, Entry{Capability: OCR, Provider: "mistral", Model: "mistral-ocr-latest", Pages: 1})
+		}()
+	}
+	wg.Wait()
+	Record(ctx, Entry{Capability: Vision, Provider: "openrouter", Model: "m", InputTokens: 1_000_000, OutputTokens: 500_000})
+	Record(ctx, Entry{Capability: Transcription, Provider: "groq", Model: "whisper", Seconds: 1800})
+	Record(ctx, Entry{Capability: OCR, Provider: "tesseract", Pages: 3})
+
+	u := rec.Summary(DefaultPrices)
+	if u.OCRPages != 13 || u.VisionInputTokens != 1_000_000 || u.TranscriptionSeconds != 1800 {
+		t.Fatalf("totals = %+v", u)
+	}
+	if len(u.Items) != 4 || u.Items[0].Provider != "mistral" || u.Items[0].Calls != 10 {
+		t.Fatalf("items = %+v", u.Items)
+	}
+	// 10 pages * 0.001 + 1M in * 0.10/M + 0.5M out * 0.30/M + 0.5h * 0.04 + free tesseract
+	if want := 0.01 + 0.10 + 0.15 + 0.02; u.EstimatedCostUSD != want {
+		t.Fatalf("cost = %v, want %v", u.EstimatedCostUSD, want)
+	}
+}
+
+func TestRecordWithoutRecorder(t *testing.T) {
+	Record(context.Background(), Entry{Capability: OCR, Provider: "mistral", Pages: 1})
+	_, rec := WithRecorder(context.Background())
+	if u := rec.Summary(DefaultPrices); u != nil {
+		t.Fatalf("empty recorder summary = %+v, want nil", u)
+	}
+}
+
+func TestLoadPricesOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	body := `{"openrouter/cheap": {"inputPerMillionTokens": 0.01}, "mistral": {"perPage": 0.002}}`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	prices, err := LoadPrices(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prices["mistral"].PerPage != 0.002 || prices["groq"].PerAudioHour != DefaultPrices["groq"].PerAudioHour {
+		t.Fatalf("prices = %+v", prices)
+	}
+	if p := prices.lookup("openrouter", "cheap"); p.InputPerMillionTokens != 0.01 {
+		t.Fatalf("model price = %+v", p)
+	}
+	if p := prices.lookup("openrouter", "other"); p.InputPerMillionTokens != DefaultPrices["openrouter"].InputPerMillionTokens {
+		t.Fatalf("provider fallback = %+v", p)
+	}
+}
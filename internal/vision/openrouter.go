@@ -10,6 +10,10 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/toricodesthings/file-processing-service/internal/gateway"
+	"github.com/toricodesthings/file-processing-service/internal/resilience"
+	"github.com/toricodesthings/file-processing-service/internal/usage"
 )
 
 // ── Public types ─────────────────────────────────────────────────────────────
@@ -30,6 +34,12 @@ const (
 	defaultVisionModel = "mistralai/mistral-small-3.1-24b-instruct"
 )
 
+var visionRetryPolicy = resilience.Policy{
+	MaxAttempts: visionMaxRetries + 1,
+	BaseDelay:   visionRetryDelay,
+	MaxDelay:    10 * time.Second,
+}
+
 // classificationPrompt asks the model to classify the image and produce a
 // description in a single pass.  The structured-output JSON schema enforces
 // the shape, so the prompt can be short.
@@ -81,10 +91,17 @@ var classificationSchema = map[string]any{
 
 type chatCompletionResponse struct {
 	ID      string                  `json:"id"`
+	Model   string                  `json:"model"`
 	Choices []chatCompletionChoice  `json:"choices"`
+	Usage   *chatCompletionUsage    `json:"usage,omitempty"`
 	Error   *openRouterErrorPayload `json:"error,omitempty"`
 }
 
+type chatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
 type chatCompletionChoice struct {
 	Index        int                   `json:"index"`
 	Message      chatCompletionMessage `json:"message"`
@@ -107,18 +124,16 @@ type VisionError struct {
 	StatusCode int
 	Code       string
 	Message    string
+	RetryAfter time.Duration // from the Retry-After header, if any
 }
 
 func (e *VisionError) Error() string {
 	return fmt.Sprintf("openrouter vision %d (%s): %s", e.StatusCode, e.Code, e.Message)
 }
 
-func isVisionClientError(err error) bool {
-	if ve, ok := err.(*VisionError); ok {
-		return ve.StatusCode >= 400 && ve.StatusCode < 500
-	}
-	return false
-}
+// HTTPStatus and RetryAfterHint let resilience.Do classify the error.
+func (e *VisionError) HTTPStatus() int               { return e.StatusCode }
+func (e *VisionError) RetryAfterHint() time.Duration { return e.RetryAfter }
 
 // ── Public API ───────────────────────────────────────────────────────────────
 
@@ -168,35 +183,29 @@ func RunVisionClassification(ctx context.Context, imageURL string, model string,
 		return VisionResult{}, fmt.Errorf("marshal request: %w", err)
 	}
 
-	// Retry loop
-	var lastErr error
-	for attempt := 0; attempt <= visionMaxRetries; attempt++ {
-		if attempt > 0 {
-			select {
-			case <-ctx.Done():
-				return VisionResult{}, ctx.Err()
-			case <-time.After(visionRetryDelay * time.Duration(attempt)):
-			}
-		}
-
-		result, err := executeVisionRequest(ctx, key, bodyBytes, timeout)
-		if err == nil {
-			return result, nil
-		}
-		lastErr = err
-
-		// Don't retry client errors (4xx)
-		if isVisionClientError(err) {
-			break
-		}
+	var result VisionResult
+	err = resilience.Do(ctx, gateway.OpenRouter, visionRetryPolicy, func(ctx context.Context) error {
+		var err error
+		result, err = executeVisionRequest(ctx, key, bodyBytes, timeout)
+		return err
+	})
+	if err == nil {
+		return result, nil
 	}
 
-	return VisionResult{}, fmt.Errorf("vision classification failed after %d attempts: %w", visionMaxRetries+1, lastErr)
+	return VisionResult{}, fmt.Errorf("vision classification failed after %d attempts: %w", visionMaxRetries+1, err)
 }
 
 // ── Internal ─────────────────────────────────────────────────────────────────
 
 func executeVisionRequest(ctx context.Context, apiKey string, bodyBytes []byte, timeout time.Duration) (VisionResult, error) {
+	// Queue for a provider slot before starting the per-request timeout.
+	release, err := gateway.Acquire(ctx, gateway.OpenRouter)
+	if err != nil {
+		return VisionResult{}, err
+	}
+	defer release()
+
 	reqCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -232,7 +241,7 @@ func executeVisionRequest(ctx context.Context, apiKey string, bodyBytes []byte,
 
 	// Non-2xx → error
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return VisionResult{}, parseVisionError(resp.StatusCode, rawBody)
+		return VisionResult{}, parseVisionError(resp.StatusCode, resp.Header, rawBody)
 	}
 
 	// Parse OpenRouter chat completion response
@@ -250,6 +259,17 @@ func executeVisionRequest(ctx context.Context, apiKey string, bodyBytes []byte,
 		}
 	}
 
+	// Tokens are billed even if the content below turns out unusable.
+	if u := completionResp.Usage; u != nil {
+		usage.Record(ctx, usage.Entry{
+			Capability:   usage.Vision,
+			Provider:     gateway.OpenRouter,
+			Model:        completionResp.Model,
+			InputTokens:  u.PromptTokens,
+			OutputTokens: u.CompletionTokens,
+		})
+	}
+
 	// Extract assistant message content
 	if len(completionResp.Choices) == 0 {
 		return VisionResult{}, fmt.Errorf("empty choices in response")
@@ -277,7 +297,9 @@ func executeVisionRequest(ctx context.Context, apiKey string, bodyBytes []byte,
 	return result, nil
 }
 
-func parseVisionError(statusCode int, body []byte) error {
+func parseVisionError(statusCode int, header http.Header, body []byte) error {
+	retryAfter := resilience.ParseRetryAfter(header.Get("Retry-After"))
+
 	// Try to extract structured error
 	var errResp struct {
 		Error struct {
@@ -290,6 +312,7 @@ func parseVisionError(statusCode int, body []byte) error {
 			StatusCode: statusCode,
 			Code:       errResp.Error.Code,
 			Message:    errResp.Error.Message,
+			RetryAfter: retryAfter,
 		}
 	}
 
@@ -301,5 +324,6 @@ func parseVisionError(statusCode int, body []byte) error {
 		StatusCode: statusCode,
 		Code:       "unknown",
 		Message:    msg,
+		RetryAfter: retryAfter,
 	}
 }
@@ -1,6 +1,7 @@
 export const ROUTES = {
   HEALTH: "/health",
   PREVIEW: "/api/preview",
+  ESTIMATE: "/api/estimate",
   EXTRACT: "/api/extract",
   FILE_PRESIGN: "/api/file/presign",
 } as const;
@@ -11,6 +12,7 @@ export const CONTAINER = {
 
   HEALTH_URL: "http://container/health",
   PREVIEW_URL: "http://container/preview",
+  ESTIMATE_URL: "http://container/estimate",
   EXTRACT_URL: "http://container/extract",
 
   START_TIMEOUT_MS: 30_000,
@@ -32,7 +34,7 @@ export const LIMITS = {
 export const CORS_HEADERS: Record<string, string> = {
   "Access-Control-Allow-Origin": "*",
   "Access-Control-Allow-Methods": "GET, POST, OPTIONS",
-  "Access-Control-Allow-Headers": "Content-Type",
+  "Access-Control-Allow-Headers": "Content-Type, X-API-Key",
   "Access-Control-Max-Age": "86400",
 };
 
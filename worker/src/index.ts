@@ -6,6 +6,9 @@ export interface Env {
   OPENROUTER_API_KEY: string;
   GROQ_API_KEY: string;
   INTERNAL_SHARED_SECRET: string;
+  // REQUEST_SIGNING is passed to the container; unless it is "required",
+  // the legacy X-Internal-Auth header is sent next to the signature.
+  REQUEST_SIGNING?: string;
   FILE_BUCKET: R2Bucket;
   FILEPROC: { getByName(name: string): FileProcContainer };
   RATE_LIMITER: { limit(options: { key: string }): Promise<{ success: boolean }> };
@@ -42,6 +45,7 @@ async function getReadyInstance(env: Env): Promise<FileProcContainer> {
         OPENROUTER_API_KEY: env.OPENROUTER_API_KEY || "",
         GROQ_API_KEY: env.GROQ_API_KEY || "",
         INTERNAL_SHARED_SECRET: env.INTERNAL_SHARED_SECRET || "",
+        REQUEST_SIGNING: env.REQUEST_SIGNING || "optional",
       },
     },
     ports: CONTAINER.PORT,
@@ -83,6 +87,61 @@ async function checkRateLimit(
   }
 }
 
+// containerHeaders authenticates a proxied call. Every call is HMAC-signed
+// with the shared secret (see internal/signing), so an intercepted request
+// cannot be replayed. A caller's own API key is forwarded as well: a
+// container with API keys configured applies that key's limits and quotas,
+// and one without falls back to the signature. Signed calls carry the
+// caller's identity as X-Client-Id, which is part of the signature, so the
+// container accounts usage per client rather than to the Worker.
+async function containerHeaders(
+  req: Request,
+  env: Env,
+  containerUrl: string,
+  body: string
+): Promise<Record<string, string>> {
+  const headers: Record<string, string> = { "Content-Type": "application/json" };
+  const apiKey = req.headers.get("X-API-Key");
+  if (apiKey) {
+    headers["X-API-Key"] = apiKey;
+  }
+
+  const url = new URL(containerUrl);
+  const timestamp = Math.floor(Date.now() / 1000).toString();
+  const nonce = crypto.randomUUID().replaceAll("-", "");
+  const bodyHash = await sha256Hex(new TextEncoder().encode(body));
+  const clientId = getClientIdentifier(req);
+  const canonical = ["POST", url.pathname + url.search, timestamp, nonce, bodyHash, clientId].join("\n");
+
+  const key = await crypto.subtle.importKey(
+    "raw",
+    new TextEncoder().encode(env.INTERNAL_SHARED_SECRET),
+    { name: "HMAC", hash: "SHA-256" },
+    false,
+    ["sign"]
+  );
+  const sig = await crypto.subtle.sign("HMAC", key, new TextEncoder().encode(canonical));
+
+  headers["X-Signature-Timestamp"] = timestamp;
+  headers["X-Signature-Nonce"] = nonce;
+  headers["X-Signature"] = toHex(sig);
+  headers["X-Client-Id"] = clientId;
+  // During migration the container may still run with signing off, which
+  // only accepts the legacy header.
+  if ((env.REQUEST_SIGNING || "optional") !== "required") {
+    headers["X-Internal-Auth"] = env.INTERNAL_SHARED_SECRET;
+  }
+  return headers;
+}
+
+async function sha256Hex(data: Uint8Array): Promise<string> {
+  return toHex(await crypto.subtle.digest("SHA-256", data));
+}
+
+function toHex(buf: ArrayBuffer): string {
+  return [...new Uint8Array(buf)].map((b) => b.toString(16).padStart(2, "0")).join("");
+}
+
 function getClientIdentifier(req: Request): string {
   return (
     req.headers.get("CF-Connecting-IP") ||
@@ -124,9 +183,20 @@ async function createPresignedUrl(bucket: R2Bucket, key: string, expiresInSecond
   return signer.call(bucket, key, { expiresIn: expiresInSeconds });
 }
 
+// resolveUrl returns the http(s) URL the container should fetch. Public
+// callers may not use the container's other sources (s3://, file://, data:),
+// which read with the service's own credentials and filesystem. For the same
+// reason they may only write results to presigned PUT URLs, not s3:// output.
 async function resolveUrl(body: any, bucket: R2Bucket): Promise<string> {
+  delete body.source;
+  if (body.options && typeof body.options.output === "object" && body.options.output !== null) {
+    delete body.options.output.url;
+  }
   const presignedUrl = getStringField(body, "presignedUrl");
-  if (presignedUrl) return presignedUrl;
+  if (presignedUrl) {
+    if (!/^https?:\/\//i.test(presignedUrl)) throw new Error("invalid_url");
+    return presignedUrl;
+  }
 
   const key = getStringField(body, "key");
   if (key) {
@@ -176,7 +246,10 @@ export default {
         }
       }
 
-      if (url.pathname === ROUTES.PREVIEW && req.method === "POST") {
+      // Preview and estimate share one proxy: both are cheap and never call
+      // a paid provider.
+      if ((url.pathname === ROUTES.PREVIEW || url.pathname === ROUTES.ESTIMATE) && req.method === "POST") {
+        const isEstimate = url.pathname === ROUTES.ESTIMATE;
         const clientId = getClientIdentifier(req);
         const rateLimit = await checkRateLimit(env.RATE_LIMITER, clientId);
         if (!rateLimit.allowed) {
@@ -193,6 +266,9 @@ export default {
           if (err?.message === "invalid_key") {
             return json({ success: false, error: "Invalid key", code: "bad_request" }, { status: 400 });
           }
+          if (err?.message === "invalid_url") {
+            return json({ success: false, error: "presignedUrl must be http(s)", code: "bad_request" }, { status: 400 });
+          }
           if (err?.message === "not_found") {
             return json({ success: false, error: "Not found", code: "not_found" }, { status: 404 });
           }
@@ -203,19 +279,18 @@ export default {
         }
 
         const inst = await getReadyInstance(env);
+        const containerUrl = isEstimate ? CONTAINER.ESTIMATE_URL : CONTAINER.PREVIEW_URL;
+        const payload = JSON.stringify(body);
         const resp = await inst.fetch(
-          new Request(CONTAINER.PREVIEW_URL, {
+          new Request(containerUrl, {
             method: "POST",
-            headers: {
-              "Content-Type": "application/json",
-              "X-Internal-Auth": env.INTERNAL_SHARED_SECRET,
-            },
-            body: JSON.stringify(body),
+            headers: await containerHeaders(req, env, containerUrl, payload),
+            body: payload,
           })
         );
 
         if (!resp.ok) {
-          console.error("preview container response not ok", {
+          console.error(`${isEstimate ? "estimate" : "preview"} container response not ok`, {
             status: resp.status,
           });
         }
@@ -243,6 +318,9 @@ export default {
           if (err?.message === "invalid_key") {
             return json({ success: false, error: "Invalid key", code: "bad_request" }, { status: 400 });
           }
+          if (err?.message === "invalid_url") {
+            return json({ success: false, error: "presignedUrl must be http(s)", code: "bad_request" }, { status: 400 });
+          }
           if (err?.message === "not_found") {
             return json({ success: false, error: "Not found", code: "not_found" }, { status: 404 });
           }
@@ -253,14 +331,12 @@ export default {
         }
 
         const inst = await getReadyInstance(env);
+        const payload = JSON.stringify(body);
         const resp = await inst.fetch(
           new Request(CONTAINER.EXTRACT_URL, {
             method: "POST",
-            headers: {
-              "Content-Type": "application/json",
-              "X-Internal-Auth": env.INTERNAL_SHARED_SECRET,
-            },
-            body: JSON.stringify(body),
+            headers: await containerHeaders(req, env, CONTAINER.EXTRACT_URL, payload),
+            body: payload,
           })
         );
 